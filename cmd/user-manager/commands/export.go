@@ -0,0 +1,50 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Export the roles and users of an organization
+
+package commands
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/document"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var exportConfig = document.ExportConfig{}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the roles and users of an organization",
+	Long: `Export the roles, with their primitives, and the users, with their profile and role, of an organization
+as a versioned YAML or JSON document that can be imported into another organization.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		err := document.RunExport(exportConfig)
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot export organization")
+		}
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportConfig.UserManagerAddress, "userManagerAddress", "localhost:8920",
+		"User Manager address (host:port)")
+	exportCmd.Flags().StringVar(&exportConfig.OrganizationId, "org", "", "Organization identifier")
+	exportCmd.Flags().StringVar(&exportConfig.Path, "output", "", "Path of the output file, standard output if not set")
+	exportCmd.Flags().StringVar(&exportConfig.Format, "format", document.YAMLFormat, "Format of the document (yaml or json)")
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Import users from a file
+
+package commands
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/bulk"
+	"github.com/nalej/user-manager/internal/pkg/document"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var importConfig = bulk.Config{}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import users or an organization document",
+	Long: `Import users from a CSV or JSON file. CSV files require a header row with the columns
+email, password, name, last_name, title, phone, location, role_id and photo_base64. JSON files contain a list
+of objects with the same fields. Users without password are invited and must reset their password.
+
+Organization documents created with the export command, YAML files or JSON files containing an object, are
+imported creating the missing roles and remapping the role identifiers of the users, which are invited.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		isDocument, format, err := document.IsDocument(importConfig.Path)
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot read file")
+		}
+		if isDocument {
+			err = document.RunImport(importConfig, format)
+		} else {
+			importer := bulk.NewImporter(importConfig)
+			err = importer.Run()
+		}
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot import users")
+		}
+	},
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importConfig.UserManagerAddress, "userManagerAddress", "localhost:8920",
+		"User Manager address (host:port)")
+	importCmd.Flags().StringVar(&importConfig.OrganizationId, "org", "", "Organization identifier")
+	importCmd.Flags().StringVar(&importConfig.OrganizationId, "organizationId", "", "Organization identifier")
+	_ = importCmd.Flags().MarkDeprecated("organizationId", "use --org instead")
+	importCmd.Flags().StringVar(&importConfig.Path, "file", "", "Path of the file with the users")
+	importCmd.Flags().StringVar(&importConfig.Format, "format", "", "Format of the file (csv or json), determined by the extension if not set")
+	importCmd.Flags().BoolVar(&importConfig.DryRun, "dryRun", false, "Validate the users without adding them")
+	importCmd.Flags().BoolVar(&importConfig.Invite, "invite", false, "Invite the users without password")
+	importCmd.Flags().IntVar(&importConfig.Concurrency, "concurrency", 0, "Number of users added in parallel")
+	rootCmd.AddCommand(importCmd)
+}
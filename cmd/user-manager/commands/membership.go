@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Declarative management of the roles and users of an organization
+
+package commands
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/membership"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var membershipConfig = membership.Config{}
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the changes required to match a membership spec",
+	Long: `Compare the roles and users declared in a YAML spec with the ones of an organization and show the
+roles and users to add, the role changes and, when pruning, the users to remove.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		err := membership.NewRunner(membershipConfig).Plan()
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot compute plan")
+		}
+	},
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Apply a membership spec to an organization",
+	Long: `Compute the changes required to match a YAML spec and apply them. New users are invited and must
+reset their password. Users not declared in the spec are only removed with --prune.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		err := membership.NewRunner(membershipConfig).Apply()
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot apply plan")
+		}
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{planCmd, applyCmd} {
+		cmd.Flags().StringVar(&membershipConfig.UserManagerAddress, "userManagerAddress", "localhost:8920",
+			"User Manager address (host:port)")
+		cmd.Flags().StringVar(&membershipConfig.OrganizationId, "org", "", "Organization identifier")
+		cmd.Flags().StringVar(&membershipConfig.Path, "file", "", "Path of the YAML spec")
+		cmd.Flags().BoolVar(&membershipConfig.Prune, "prune", false, "Remove the users not declared in the spec")
+		rootCmd.AddCommand(cmd)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Offboard an organization removing all its users and roles
+
+package commands
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/offboard"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var offboardConfig = offboard.Config{}
+
+var offboardCmd = &cobra.Command{
+	Use:   "offboard",
+	Short: "Remove all the users and roles of an organization",
+	Long: `Remove the credentials and records of every user, the service accounts and every role of an
+organization, owners included. Without --token a confirmation token is requested and the number of users and
+roles that will be removed is shown. Running with the token performs the removal and prints a report. An
+interrupted offboarding is resumed running again with the same token, or with a new one once it expires.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		SetupLogging()
+		err := offboard.NewRunner(offboardConfig).Run()
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot offboard organization")
+		}
+	},
+}
+
+func init() {
+	offboardCmd.Flags().StringVar(&offboardConfig.UserManagerAddress, "userManagerAddress", "localhost:8920",
+		"User Manager address (host:port)")
+	offboardCmd.Flags().StringVar(&offboardConfig.OrganizationId, "org", "", "Organization identifier")
+	offboardCmd.Flags().StringVar(&offboardConfig.Token, "token", "", "Confirmation token, a new one is requested if not set")
+	rootCmd.AddCommand(offboardCmd)
+}
@@ -44,5 +44,28 @@ func init() {
 		"System Model address (host:port)")
 	runCmd.PersistentFlags().StringVar(&config.AuthxAddress, "authxAddress", "localhost:8810",
 		"Authx address (host:port)")
+	runCmd.Flags().StringVar(&config.ScyllaDBAddress, "scyllaDBAddress", "localhost",
+		"Address of a node of the ScyllaDB cluster")
+	runCmd.Flags().IntVar(&config.ScyllaDBPort, "scyllaDBPort", 9042, "Port of the CQL native protocol of ScyllaDB")
+	runCmd.Flags().StringVar(&config.KeySpace, "keyspace", "user_manager", "ScyllaDB keyspace of the user manager")
+	runCmd.Flags().IntVar(&config.RemovedUserRetentionDays, "removedUserRetentionDays", 30,
+		"Number of days a removed user can be restored")
+	runCmd.Flags().StringVar(&config.AccessRequestApprover, "accessRequestApprover", "ORG",
+		"Access primitive required to approve or deny access requests")
+	runCmd.Flags().IntVar(&config.AccessRequestExpirationHours, "accessRequestExpirationHours", 72,
+		"Number of hours an access request can be resolved before it expires")
+	runCmd.Flags().IntVar(&config.ScimPort, "scimPort", 8921, "Port to launch the SCIM endpoint")
+	runCmd.Flags().StringVar(&config.ScimTokensPath, "scimTokensPath", "",
+		"File with the bearer tokens of the SCIM endpoint, the endpoint is disabled if empty")
+	runCmd.Flags().StringVar(&config.LdapSyncPath, "ldapSyncPath", "",
+		"File with the LDAP synchronization settings of each organization, the synchronization is disabled if empty")
+	runCmd.Flags().StringVar(&config.ClaimRulesPath, "claimRulesPath", "",
+		"File with the claim rules used to provision users from external identity providers")
+	runCmd.Flags().StringVar(&config.EmailRulesPath, "emailRulesPath", "",
+		"File with the provider-specific rules used to normalize the emails, such as ignoring dots or subaddresses")
+	runCmd.Flags().StringVar(&config.MfaKeyPath, "mfaKeyPath", "",
+		"File with the key used to encrypt the TOTP secrets, MFA enrollment is disabled if empty")
+	runCmd.Flags().StringVar(&config.RoleTemplatesPath, "roleTemplatesPath", "",
+		"Directory with the role templates that can be applied to the organizations")
 	rootCmd.AddCommand(runCmd)
 }
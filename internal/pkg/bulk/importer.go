@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulk
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// ImportTimeout with the maximum time to wait for the import of a file.
+const ImportTimeout = 10 * time.Minute
+
+// Config with the options of the import command.
+type Config struct {
+	// UserManagerAddress with the host:port of the user manager.
+	UserManagerAddress string
+	// OrganizationId where the users are imported.
+	OrganizationId string
+	// Path of the file with the users.
+	Path string
+	// Format of the file. If empty, it is determined by the file extension.
+	Format string
+	// DryRun validates the users without adding them.
+	DryRun bool
+	// Invite the users without password.
+	Invite bool
+	// Concurrency with the number of users added in parallel.
+	Concurrency int
+}
+
+// Validate the import configuration.
+func (c *Config) Validate() derrors.Error {
+	if c.UserManagerAddress == "" {
+		return derrors.NewInvalidArgumentError("userManagerAddress must be set")
+	}
+	if c.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError("organizationId must be set")
+	}
+	if c.Path == "" {
+		return derrors.NewInvalidArgumentError("file must be set")
+	}
+	return nil
+}
+
+// Importer reading users from a file and adding them through the user manager.
+type Importer struct {
+	config Config
+	output io.Writer
+}
+
+// NewImporter creates an importer writing the report to the standard output.
+func NewImporter(config Config) *Importer {
+	return &Importer{config: config, output: os.Stdout}
+}
+
+// Run the import of the users.
+func (i *Importer) Run() derrors.Error {
+	vErr := i.config.Validate()
+	if vErr != nil {
+		return vErr
+	}
+	format := i.config.Format
+	if format == "" {
+		detected, err := FormatFromPath(i.config.Path)
+		if err != nil {
+			return err
+		}
+		format = detected
+	}
+	file, err := os.Open(i.config.Path)
+	if err != nil {
+		return derrors.AsError(err, "cannot open file")
+	}
+	defer file.Close()
+	rows, pErr := ParseRows(file, format)
+	if pErr != nil {
+		return pErr
+	}
+
+	invite := i.config.Invite
+	if !invite && !HasPasswords(rows) {
+		log.Info().Msg("no passwords found, users will be invited")
+		invite = true
+	}
+
+	request := &grpc_user_manager_go.BulkAddUsersRequest{
+		OrganizationId: i.config.OrganizationId,
+		Users:          make([]*grpc_user_manager_go.AddUserRequest, 0, len(rows)),
+		DryRun:         i.config.DryRun,
+		Invite:         invite,
+		MaxConcurrency: int32(i.config.Concurrency),
+	}
+	for _, row := range rows {
+		request.Users = append(request.Users, row.ToAddUserRequest(i.config.OrganizationId))
+	}
+
+	conn, err := grpc.Dial(i.config.UserManagerAddress, grpc.WithInsecure())
+	if err != nil {
+		return derrors.AsError(err, "cannot create connection with the user manager")
+	}
+	defer conn.Close()
+	client := grpc_user_manager_go.NewUserManagerClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ImportTimeout)
+	defer cancel()
+	response, err := client.BulkAddUsers(ctx, request)
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	PrintReport(i.output, response, i.config.DryRun)
+	return nil
+}
+
+// PrintReport writes the result of each row of a bulk operation.
+func PrintReport(output io.Writer, response *grpc_user_manager_go.BulkAddUsersResponse, dryRun bool) {
+	w := tabwriter.NewWriter(output, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROW\tEMAIL\tSTATUS\tDETAIL")
+	for _, result := range response.Results {
+		status := "ADDED"
+		if dryRun {
+			status = "VALID"
+		}
+		if result.Success && result.Invited {
+			status = fmt.Sprintf("%s (INVITED)", status)
+		}
+		if !result.Success {
+			status = "ERROR"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", result.Row, result.Email, status, result.Error)
+	}
+	w.Flush()
+	fmt.Fprintf(output, "\n%d succeeded, %d failed\n", response.Added, response.Failed)
+}
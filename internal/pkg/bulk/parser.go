@@ -0,0 +1,174 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// CSVFormat for files with a header row and one user per row.
+	CSVFormat = "csv"
+	// JSONFormat for files with a list of user objects.
+	JSONFormat = "json"
+)
+
+// Columns accepted in the CSV files.
+const (
+	emailColumn       = "email"
+	passwordColumn    = "password"
+	nameColumn        = "name"
+	lastNameColumn    = "last_name"
+	titleColumn       = "title"
+	phoneColumn       = "phone"
+	locationColumn    = "location"
+	roleIDColumn      = "role_id"
+	photoBase64Column = "photo_base64"
+)
+
+var validColumns = map[string]bool{
+	emailColumn: true, passwordColumn: true, nameColumn: true, lastNameColumn: true, titleColumn: true,
+	phoneColumn: true, locationColumn: true, roleIDColumn: true, photoBase64Column: true,
+}
+
+// Row with the information of a user to be imported.
+type Row struct {
+	Email       string `json:"email"`
+	Password    string `json:"password,omitempty"`
+	Name        string `json:"name"`
+	LastName    string `json:"last_name"`
+	Title       string `json:"title"`
+	Phone       string `json:"phone,omitempty"`
+	Location    string `json:"location,omitempty"`
+	RoleId      string `json:"role_id"`
+	PhotoBase64 string `json:"photo_base64,omitempty"`
+}
+
+// ToAddUserRequest transforms the row into an AddUserRequest of a given organization.
+func (r *Row) ToAddUserRequest(organizationID string) *grpc_user_manager_go.AddUserRequest {
+	return &grpc_user_manager_go.AddUserRequest{
+		OrganizationId: organizationID,
+		Email:          r.Email,
+		Password:       r.Password,
+		Name:           r.Name,
+		PhotoBase64:    r.PhotoBase64,
+		LastName:       r.LastName,
+		Location:       r.Location,
+		Phone:          r.Phone,
+		Title:          r.Title,
+		RoleId:         r.RoleId,
+	}
+}
+
+// FormatFromPath determines the format of a file using its extension.
+func FormatFromPath(path string) (string, derrors.Error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return CSVFormat, nil
+	case ".json":
+		return JSONFormat, nil
+	}
+	return "", derrors.NewInvalidArgumentError("cannot determine the format of the file, use csv or json").WithParams(path)
+}
+
+// ParseRows reads the rows of a CSV or JSON source.
+func ParseRows(reader io.Reader, format string) ([]Row, derrors.Error) {
+	switch format {
+	case CSVFormat:
+		return ParseCSV(reader)
+	case JSONFormat:
+		return ParseJSON(reader)
+	}
+	return nil, derrors.NewInvalidArgumentError("unsupported format").WithParams(format)
+}
+
+// ParseCSV reads the rows of a CSV source. The first row must contain the column names.
+func ParseCSV(reader io.Reader) ([]Row, derrors.Error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read csv")
+	}
+	if len(records) == 0 {
+		return nil, derrors.NewInvalidArgumentError("csv header row is missing")
+	}
+	header := records[0]
+	for index, column := range header {
+		header[index] = strings.ToLower(strings.TrimSpace(column))
+		if !validColumns[header[index]] {
+			return nil, derrors.NewInvalidArgumentError("unknown csv column").WithParams(column)
+		}
+	}
+	rows := make([]Row, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := Row{}
+		for index, value := range record {
+			value = strings.TrimSpace(value)
+			switch header[index] {
+			case emailColumn:
+				row.Email = value
+			case passwordColumn:
+				row.Password = value
+			case nameColumn:
+				row.Name = value
+			case lastNameColumn:
+				row.LastName = value
+			case titleColumn:
+				row.Title = value
+			case phoneColumn:
+				row.Phone = value
+			case locationColumn:
+				row.Location = value
+			case roleIDColumn:
+				row.RoleId = value
+			case photoBase64Column:
+				row.PhotoBase64 = value
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseJSON reads the rows of a JSON source containing a list of users.
+func ParseJSON(reader io.Reader) ([]Row, derrors.Error) {
+	rows := make([]Row, 0)
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&rows)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read json")
+	}
+	return rows, nil
+}
+
+// HasPasswords checks if any of the rows contains a password.
+func HasPasswords(rows []Row) bool {
+	for _, row := range rows {
+		if row.Password != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bulk
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"strings"
+)
+
+var _ = ginkgo.Describe("Bulk parser", func() {
+
+	ginkgo.It("should be able to parse a csv file", func() {
+		content := `email,password,name,last_name,title,role_id
+user1@mail.com,password,User,One,Engineer,role1
+user2@mail.com, ,User,Two,Manager,role2
+`
+		rows, err := ParseCSV(strings.NewReader(content))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(rows)).Should(gomega.Equal(2))
+		gomega.Expect(rows[0].Email).Should(gomega.Equal("user1@mail.com"))
+		gomega.Expect(rows[0].RoleId).Should(gomega.Equal("role1"))
+		gomega.Expect(rows[1].Password).Should(gomega.BeEmpty())
+		gomega.Expect(HasPasswords(rows)).To(gomega.BeTrue())
+
+		request := rows[1].ToAddUserRequest("org")
+		gomega.Expect(request.OrganizationId).Should(gomega.Equal("org"))
+		gomega.Expect(request.Title).Should(gomega.Equal("Manager"))
+	})
+
+	ginkgo.It("should fail to parse a csv file with unknown columns", func() {
+		content := `email,nickname
+user1@mail.com,user1
+`
+		_, err := ParseCSV(strings.NewReader(content))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to parse a json file", func() {
+		content := `[{"email":"user1@mail.com","name":"User","last_name":"One","title":"Engineer","role_id":"role1"}]`
+		rows, err := ParseRows(strings.NewReader(content), JSONFormat)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(rows)).Should(gomega.Equal(1))
+		gomega.Expect(rows[0].LastName).Should(gomega.Equal("One"))
+		gomega.Expect(HasPasswords(rows)).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should determine the format from the file extension", func() {
+		format, err := FormatFromPath("/tmp/users.CSV")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(format).Should(gomega.Equal(CSVFormat))
+		_, err = FormatFromPath("/tmp/users.txt")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+})
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package document
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"gopkg.in/yaml.v2"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Version of the document format.
+const Version = "v1"
+
+const (
+	// YAMLFormat for YAML documents.
+	YAMLFormat = "yaml"
+	// JSONFormat for JSON documents.
+	JSONFormat = "json"
+)
+
+// Document with the portable representation of the roles and users of an organization.
+type Document struct {
+	// Version of the document format.
+	Version string `json:"version" yaml:"version"`
+	// OrganizationId from which the document was exported.
+	OrganizationId string `json:"organization_id,omitempty" yaml:"organization_id,omitempty"`
+	// Roles of the organization.
+	Roles []Role `json:"roles" yaml:"roles"`
+	// Users of the organization.
+	Users []User `json:"users" yaml:"users"`
+}
+
+// Role with the portable representation of a role.
+type Role struct {
+	// RoleId in the source organization. Users reference their role with it.
+	RoleId string `json:"role_id" yaml:"role_id"`
+	// Name of the role.
+	Name string `json:"name" yaml:"name"`
+	// Internal flag of the role.
+	Internal bool `json:"internal,omitempty" yaml:"internal,omitempty"`
+	// Primitives with the names of the access primitives of the role.
+	Primitives []string `json:"primitives" yaml:"primitives"`
+}
+
+// User with the portable representation of a user. Passwords are not part of the document.
+type User struct {
+	Email       string `json:"email" yaml:"email"`
+	Name        string `json:"name" yaml:"name"`
+	LastName    string `json:"last_name" yaml:"last_name"`
+	Title       string `json:"title" yaml:"title"`
+	Phone       string `json:"phone,omitempty" yaml:"phone,omitempty"`
+	Location    string `json:"location,omitempty" yaml:"location,omitempty"`
+	PhotoBase64 string `json:"photo_base64,omitempty" yaml:"photo_base64,omitempty"`
+	// RoleId in the source organization.
+	RoleId string `json:"role_id" yaml:"role_id"`
+}
+
+// PrimitivesToNames converts a list of access primitives into their names.
+func PrimitivesToNames(primitives []grpc_authx_go.AccessPrimitive) []string {
+	result := make([]string, 0, len(primitives))
+	for _, primitive := range primitives {
+		result = append(result, primitive.String())
+	}
+	return result
+}
+
+// NamesToPrimitives converts a list of names into access primitives.
+func NamesToPrimitives(names []string) ([]grpc_authx_go.AccessPrimitive, derrors.Error) {
+	result := make([]grpc_authx_go.AccessPrimitive, 0, len(names))
+	for _, name := range names {
+		value, exists := grpc_authx_go.AccessPrimitive_value[strings.ToUpper(name)]
+		if !exists {
+			return nil, derrors.NewInvalidArgumentError("unknown access primitive").WithParams(name)
+		}
+		result = append(result, grpc_authx_go.AccessPrimitive(value))
+	}
+	return result, nil
+}
+
+// Validate the content of the document.
+func (d *Document) Validate() derrors.Error {
+	if d.Version != Version {
+		return derrors.NewInvalidArgumentError("unsupported document version").WithParams(d.Version, Version)
+	}
+	roleIDs := make(map[string]bool, 0)
+	for _, role := range d.Roles {
+		if role.RoleId == "" || role.Name == "" {
+			return derrors.NewInvalidArgumentError("roles require role_id and name")
+		}
+		if roleIDs[role.RoleId] {
+			return derrors.NewInvalidArgumentError("duplicated role_id").WithParams(role.RoleId)
+		}
+		roleIDs[role.RoleId] = true
+		if len(role.Primitives) == 0 {
+			return derrors.NewInvalidArgumentError("at least one primitive is expected").WithParams(role.Name)
+		}
+		_, err := NamesToPrimitives(role.Primitives)
+		if err != nil {
+			return err
+		}
+	}
+	emails := make(map[string]bool, 0)
+	for _, user := range d.Users {
+		if user.Email == "" {
+			return derrors.NewInvalidArgumentError("users require email")
+		}
+		if emails[user.Email] {
+			return derrors.NewInvalidArgumentError("duplicated email").WithParams(user.Email)
+		}
+		emails[user.Email] = true
+		if !roleIDs[user.RoleId] {
+			return derrors.NewInvalidArgumentError("user references an unknown role").WithParams(user.Email, user.RoleId)
+		}
+	}
+	return nil
+}
+
+// Write the document in a given format.
+func (d *Document) Write(writer io.Writer, format string) derrors.Error {
+	var content []byte
+	var err error
+	switch format {
+	case YAMLFormat:
+		content, err = yaml.Marshal(d)
+	case JSONFormat:
+		content, err = json.MarshalIndent(d, "", "  ")
+		content = append(content, '\n')
+	default:
+		return derrors.NewInvalidArgumentError("unsupported format").WithParams(format)
+	}
+	if err != nil {
+		return derrors.AsError(err, "cannot serialize document")
+	}
+	_, err = writer.Write(content)
+	if err != nil {
+		return derrors.AsError(err, "cannot write document")
+	}
+	return nil
+}
+
+// Read a document in a given format.
+func Read(reader io.Reader, format string) (*Document, derrors.Error) {
+	doc := &Document{}
+	var err error
+	switch format {
+	case YAMLFormat:
+		decoder := yaml.NewDecoder(reader)
+		decoder.SetStrict(true)
+		err = decoder.Decode(doc)
+	case JSONFormat:
+		decoder := json.NewDecoder(reader)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(doc)
+	default:
+		return nil, derrors.NewInvalidArgumentError("unsupported format").WithParams(format)
+	}
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read document")
+	}
+	vErr := doc.Validate()
+	if vErr != nil {
+		return nil, vErr
+	}
+	return doc, nil
+}
+
+// IsDocument checks if a file contains an organization document. YAML files are always documents while JSON
+// files are documents when they contain an object instead of a list of users.
+func IsDocument(path string) (bool, string, derrors.Error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true, YAMLFormat, nil
+	case ".json":
+		file, err := os.Open(path)
+		if err != nil {
+			return false, "", derrors.AsError(err, "cannot open file")
+		}
+		defer file.Close()
+		reader := bufio.NewReader(file)
+		for {
+			r, _, err := reader.ReadRune()
+			if err != nil {
+				return false, "", derrors.AsError(err, "cannot read file")
+			}
+			if !strings.ContainsRune(" \t\r\n", r) {
+				return r == '{', JSONFormat, nil
+			}
+		}
+	}
+	return false, "", nil
+}
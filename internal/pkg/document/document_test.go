@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package document
+
+import (
+	"bytes"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func createDocument() *Document {
+	return &Document{
+		Version:        Version,
+		OrganizationId: "org",
+		Roles: []Role{
+			{RoleId: "owner", Name: "Owner", Primitives: []string{"ORG"}},
+			{RoleId: "dev", Name: "Developer", Primitives: []string{"APPS", "RESOURCES"}},
+		},
+		Users: []User{
+			{Email: "owner@mail.com", Name: "Owner", LastName: "User", Title: "CEO", RoleId: "owner"},
+			{Email: "dev@mail.com", Name: "Dev", LastName: "User", Title: "Engineer", RoleId: "dev"},
+		},
+	}
+}
+
+var _ = ginkgo.Describe("Organization document", func() {
+
+	table.DescribeTable("should be able to write and read a document",
+		func(format string) {
+			doc := createDocument()
+			buffer := &bytes.Buffer{}
+			err := doc.Write(buffer, format)
+			gomega.Expect(err).To(gomega.Succeed())
+
+			retrieved, err := Read(buffer, format)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved).Should(gomega.Equal(doc))
+		},
+		table.Entry("yaml", YAMLFormat),
+		table.Entry("json", JSONFormat),
+	)
+
+	ginkgo.It("should reject documents with unknown versions", func() {
+		doc := createDocument()
+		doc.Version = "v0"
+		gomega.Expect(doc.Validate()).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject users referencing unknown roles", func() {
+		doc := createDocument()
+		doc.Users[0].RoleId = "unknown"
+		gomega.Expect(doc.Validate()).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject unknown primitives", func() {
+		doc := createDocument()
+		doc.Roles[0].Primitives = []string{"UNKNOWN"}
+		gomega.Expect(doc.Validate()).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject roles named as existing ones with different primitives", func() {
+		doc := createDocument()
+		existing := []*grpc_authx_go.Role{
+			{RoleId: "r1", Name: "Owner", Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}},
+			{RoleId: "r2", Name: "Developer", Primitives: []grpc_authx_go.AccessPrimitive{
+				grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_APPS}},
+		}
+		gomega.Expect(CheckRoleConflicts(doc, existing)).To(gomega.Succeed())
+		existing[1].Primitives = []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}
+		err := CheckRoleConflicts(doc, existing)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should convert primitives from and to names", func() {
+		primitives, err := NamesToPrimitives([]string{"org", "APPS"})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(primitives).Should(gomega.Equal([]grpc_authx_go.AccessPrimitive{
+			grpc_authx_go.AccessPrimitive_ORG, grpc_authx_go.AccessPrimitive_APPS}))
+		gomega.Expect(PrimitivesToNames(primitives)).Should(gomega.Equal([]string{"ORG", "APPS"}))
+	})
+
+	ginkgo.It("should detect organization documents", func() {
+		dir, err := ioutil.TempDir("", "document")
+		gomega.Expect(err).To(gomega.Succeed())
+		defer os.RemoveAll(dir)
+
+		docPath := filepath.Join(dir, "org.json")
+		gomega.Expect(ioutil.WriteFile(docPath, []byte(` {"version":"v1"}`), 0600)).To(gomega.Succeed())
+		usersPath := filepath.Join(dir, "users.json")
+		gomega.Expect(ioutil.WriteFile(usersPath, []byte(`[{"email":"user@mail.com"}]`), 0600)).To(gomega.Succeed())
+
+		isDocument, format, dErr := IsDocument(docPath)
+		gomega.Expect(dErr).To(gomega.Succeed())
+		gomega.Expect(isDocument).To(gomega.BeTrue())
+		gomega.Expect(format).Should(gomega.Equal(JSONFormat))
+
+		isDocument, _, dErr = IsDocument(usersPath)
+		gomega.Expect(dErr).To(gomega.Succeed())
+		gomega.Expect(isDocument).To(gomega.BeFalse())
+
+		isDocument, format, dErr = IsDocument(filepath.Join(dir, "org.yaml"))
+		gomega.Expect(dErr).To(gomega.Succeed())
+		gomega.Expect(isDocument).To(gomega.BeTrue())
+		gomega.Expect(format).Should(gomega.Equal(YAMLFormat))
+	})
+})
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package document
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"sort"
+)
+
+// Export builds the document of an organization using the user manager.
+func Export(client grpc_user_manager_go.UserManagerClient, organizationID string) (*Document, derrors.Error) {
+	orgID := &grpc_organization_go.OrganizationId{
+		OrganizationId: organizationID,
+	}
+	roles, err := client.ListRoles(context.Background(), orgID)
+	if err != nil {
+		return nil, conversions.ToDerror(err)
+	}
+	users, err := client.ListUsers(context.Background(), orgID)
+	if err != nil {
+		return nil, conversions.ToDerror(err)
+	}
+
+	doc := &Document{
+		Version:        Version,
+		OrganizationId: organizationID,
+		Roles:          make([]Role, 0, len(roles.Roles)),
+		Users:          make([]User, 0, len(users.Users)),
+	}
+	for _, role := range roles.Roles {
+		doc.Roles = append(doc.Roles, Role{
+			RoleId:     role.RoleId,
+			Name:       role.Name,
+			Internal:   role.Internal,
+			Primitives: PrimitivesToNames(role.Primitives),
+		})
+	}
+	for _, user := range users.Users {
+		doc.Users = append(doc.Users, User{
+			Email:       user.Email,
+			Name:        user.Name,
+			LastName:    user.LastName,
+			Title:       user.Title,
+			Phone:       user.Phone,
+			Location:    user.Location,
+			PhotoBase64: user.PhotoBase64,
+			RoleId:      user.RoleId,
+		})
+	}
+	// sort the entries so exports of the same organization can be compared
+	sort.Slice(doc.Roles, func(i, j int) bool { return doc.Roles[i].Name < doc.Roles[j].Name })
+	sort.Slice(doc.Users, func(i, j int) bool { return doc.Users[i].Email < doc.Users[j].Email })
+	return doc, nil
+}
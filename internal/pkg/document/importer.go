@@ -0,0 +1,175 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package document
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// ImportReport with the result of importing a document into an organization.
+type ImportReport struct {
+	// RoleMapping with the role identifiers of the document mapped to the ones in the target organization.
+	RoleMapping map[string]string
+	// CreatedRoles with the names of the roles that have been created.
+	CreatedRoles []string
+	// Users with the result of adding the users.
+	Users *grpc_user_manager_go.BulkAddUsersResponse
+}
+
+// Import a document into an organization. Roles are matched by name with the ones already in the organization
+// and created otherwise, remapping the role identifiers of the users. A document with a role named as an existing
+// one but with different primitives is rejected before any change. As passwords are not part of the document,
+// the users are invited and must reset their password.
+func Import(client grpc_user_manager_go.UserManagerClient, doc *Document, organizationID string) (*ImportReport, derrors.Error) {
+	roles, err := client.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{
+		OrganizationId: organizationID,
+	})
+	if err != nil {
+		return nil, conversions.ToDerror(err)
+	}
+	cErr := CheckRoleConflicts(doc, roles.Roles)
+	if cErr != nil {
+		return nil, cErr
+	}
+	existing := make(map[string]string, 0)
+	for _, role := range roles.Roles {
+		existing[role.Name] = role.RoleId
+	}
+
+	report := &ImportReport{
+		RoleMapping:  make(map[string]string, 0),
+		CreatedRoles: make([]string, 0),
+	}
+	// 1. Roles
+	for _, role := range doc.Roles {
+		if roleID, exists := existing[role.Name]; exists {
+			report.RoleMapping[role.RoleId] = roleID
+			continue
+		}
+		primitives, pErr := NamesToPrimitives(role.Primitives)
+		if pErr != nil {
+			return report, pErr
+		}
+		added, err := client.AddRole(context.Background(), &grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           role.Name,
+			Internal:       role.Internal,
+			Primitives:     primitives,
+		})
+		if err != nil {
+			return report, conversions.ToDerror(err)
+		}
+		log.Debug().Str("name", role.Name).Str("roleID", added.RoleId).Msg("role created")
+		report.RoleMapping[role.RoleId] = added.RoleId
+		report.CreatedRoles = append(report.CreatedRoles, role.Name)
+	}
+
+	// 2. Users
+	if len(doc.Users) == 0 {
+		report.Users = &grpc_user_manager_go.BulkAddUsersResponse{}
+		return report, nil
+	}
+	toAdd := make([]*grpc_user_manager_go.AddUserRequest, 0, len(doc.Users))
+	for _, user := range doc.Users {
+		toAdd = append(toAdd, &grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          user.Email,
+			Name:           user.Name,
+			PhotoBase64:    user.PhotoBase64,
+			LastName:       user.LastName,
+			Location:       user.Location,
+			Phone:          user.Phone,
+			Title:          user.Title,
+			RoleId:         report.RoleMapping[user.RoleId],
+		})
+	}
+	report.Users = &grpc_user_manager_go.BulkAddUsersResponse{
+		Results: make([]*grpc_user_manager_go.BulkAddUserResult, 0, len(toAdd)),
+	}
+	// send the users in chunks accepted by the user manager
+	for start := 0; start < len(toAdd); start += entities.MaxBulkUsers {
+		end := start + entities.MaxBulkUsers
+		if end > len(toAdd) {
+			end = len(toAdd)
+		}
+		request := &grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: organizationID,
+			Users:          toAdd[start:end],
+			Invite:         true,
+		}
+		response, err := client.BulkAddUsers(context.Background(), request)
+		if err != nil {
+			return report, conversions.ToDerror(err)
+		}
+		for _, result := range response.Results {
+			result.Row += int32(start)
+			report.Users.Results = append(report.Users.Results, result)
+		}
+		report.Users.Added += response.Added
+		report.Users.Failed += response.Failed
+	}
+	return report, nil
+}
+
+// CheckRoleConflicts checks that the roles of a document matching an existing role by name have the same primitives.
+func CheckRoleConflicts(doc *Document, roles []*grpc_authx_go.Role) derrors.Error {
+	byName := make(map[string]*grpc_authx_go.Role, len(roles))
+	for _, role := range roles {
+		byName[role.Name] = role
+	}
+	conflicts := make([]string, 0)
+	for _, role := range doc.Roles {
+		existing, exists := byName[role.Name]
+		if !exists {
+			continue
+		}
+		primitives, err := NamesToPrimitives(role.Primitives)
+		if err != nil {
+			return err
+		}
+		if !samePrimitives(primitives, existing.Primitives) {
+			conflicts = append(conflicts, role.Name)
+		}
+	}
+	if len(conflicts) > 0 {
+		return derrors.NewFailedPreconditionError("roles already exist with different primitives").WithParams(conflicts)
+	}
+	return nil
+}
+
+// samePrimitives checks if two lists contain the same primitives regardless of the order.
+func samePrimitives(a []grpc_authx_go.AccessPrimitive, b []grpc_authx_go.AccessPrimitive) bool {
+	set := make(map[grpc_authx_go.AccessPrimitive]bool, len(a))
+	for _, primitive := range a {
+		set[primitive] = true
+	}
+	other := make(map[grpc_authx_go.AccessPrimitive]bool, len(b))
+	for _, primitive := range b {
+		if !set[primitive] {
+			return false
+		}
+		other[primitive] = true
+	}
+	return len(set) == len(other)
+}
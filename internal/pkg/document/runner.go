@@ -0,0 +1,149 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package document
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/bulk"
+	"google.golang.org/grpc"
+	"io"
+	"os"
+	"sort"
+)
+
+// ExportConfig with the options of the export command.
+type ExportConfig struct {
+	// UserManagerAddress with the host:port of the user manager.
+	UserManagerAddress string
+	// OrganizationId to export.
+	OrganizationId string
+	// Path of the output file. If empty, the document is written to the standard output.
+	Path string
+	// Format of the document.
+	Format string
+}
+
+// Validate the export configuration.
+func (c *ExportConfig) Validate() derrors.Error {
+	if c.UserManagerAddress == "" {
+		return derrors.NewInvalidArgumentError("userManagerAddress must be set")
+	}
+	if c.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError("org must be set")
+	}
+	if c.Format != YAMLFormat && c.Format != JSONFormat {
+		return derrors.NewInvalidArgumentError("format must be yaml or json")
+	}
+	return nil
+}
+
+// getClient creates a user manager client.
+func getClient(address string) (grpc_user_manager_go.UserManagerClient, *grpc.ClientConn, derrors.Error) {
+	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	if err != nil {
+		return nil, nil, derrors.AsError(err, "cannot create connection with the user manager")
+	}
+	return grpc_user_manager_go.NewUserManagerClient(conn), conn, nil
+}
+
+// RunExport exports the document of an organization.
+func RunExport(config ExportConfig) derrors.Error {
+	vErr := config.Validate()
+	if vErr != nil {
+		return vErr
+	}
+	client, conn, cErr := getClient(config.UserManagerAddress)
+	if cErr != nil {
+		return cErr
+	}
+	defer conn.Close()
+
+	doc, eErr := Export(client, config.OrganizationId)
+	if eErr != nil {
+		return eErr
+	}
+	var output io.Writer = os.Stdout
+	if config.Path != "" {
+		file, err := os.Create(config.Path)
+		if err != nil {
+			return derrors.AsError(err, "cannot create output file")
+		}
+		defer file.Close()
+		output = file
+	}
+	return doc.Write(output, config.Format)
+}
+
+// RunImport imports a document into an organization, writing the report to the standard output.
+func RunImport(config bulk.Config, format string) derrors.Error {
+	vErr := config.Validate()
+	if vErr != nil {
+		return vErr
+	}
+	if config.DryRun {
+		return derrors.NewInvalidArgumentError("dryRun is not supported when importing organization documents")
+	}
+	file, err := os.Open(config.Path)
+	if err != nil {
+		return derrors.AsError(err, "cannot open file")
+	}
+	defer file.Close()
+	doc, rErr := Read(file, format)
+	if rErr != nil {
+		return rErr
+	}
+
+	client, conn, cErr := getClient(config.UserManagerAddress)
+	if cErr != nil {
+		return cErr
+	}
+	defer conn.Close()
+
+	report, iErr := Import(client, doc, config.OrganizationId)
+	if report != nil {
+		printImportReport(os.Stdout, doc, report)
+	}
+	return iErr
+}
+
+// printImportReport writes the roles and users imported.
+func printImportReport(output io.Writer, doc *Document, report *ImportReport) {
+	created := make(map[string]bool, 0)
+	for _, name := range report.CreatedRoles {
+		created[name] = true
+	}
+	roles := make([]Role, len(doc.Roles))
+	copy(roles, doc.Roles)
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	for _, role := range roles {
+		targetID, mapped := report.RoleMapping[role.RoleId]
+		if !mapped {
+			continue
+		}
+		action := "existing"
+		if created[role.Name] {
+			action = "created"
+		}
+		fmt.Fprintf(output, "role %s: %s -> %s (%s)\n", role.Name, role.RoleId, targetID, action)
+	}
+	if report.Users != nil {
+		fmt.Fprintln(output)
+		bulk.PrintReport(output, report.Users, false)
+	}
+}
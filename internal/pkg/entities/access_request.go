@@ -0,0 +1,128 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"time"
+)
+
+// accessRequestIDLength with the number of random bytes of an access request identifier.
+const accessRequestIDLength = 16
+
+// accessRequestTransitions with the states that can be reached from each state. Only pending requests can change,
+// the rest of the states are final.
+var accessRequestTransitions = map[grpc_user_manager_go.AccessRequestState][]grpc_user_manager_go.AccessRequestState{
+	grpc_user_manager_go.AccessRequestState_PENDING: {
+		grpc_user_manager_go.AccessRequestState_APPROVED,
+		grpc_user_manager_go.AccessRequestState_DENIED,
+		grpc_user_manager_go.AccessRequestState_CANCELLED,
+		grpc_user_manager_go.AccessRequestState_EXPIRED,
+	},
+}
+
+// AccessRequest is the request of a user to be assigned a role, which must be approved by another user.
+type AccessRequest struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// AccessRequestId with the access request identifier.
+	AccessRequestId string
+	// Email of the user requesting the role.
+	Email string
+	// RoleId with the requested role.
+	RoleId string
+	// Justification provided by the user.
+	Justification string
+	// GrantDuration with the number of seconds the role is granted once approved. The role is assigned permanently if
+	// zero.
+	GrantDuration int64
+	// State of the request.
+	State grpc_user_manager_go.AccessRequestState
+	// Created with the creation timestamp.
+	Created int64
+	// ExpiresAt with the timestamp after which a pending request can no longer be approved.
+	ExpiresAt int64
+	// ResolvedBy with the email of the user that approved, denied or cancelled the request.
+	ResolvedBy string
+	// ResolvedAt with the timestamp of the resolution.
+	ResolvedAt int64
+	// Comment of the resolution.
+	Comment string
+}
+
+// NewAccessRequest creates a pending AccessRequest that expires after a given time.
+func NewAccessRequest(request *grpc_user_manager_go.CreateAccessRequestRequest, ttl time.Duration) (*AccessRequest, derrors.Error) {
+	id, err := randomHex(accessRequestIDLength)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &AccessRequest{
+		OrganizationId:  request.OrganizationId,
+		AccessRequestId: id,
+		Email:           request.Email,
+		RoleId:          request.RoleId,
+		Justification:   request.Justification,
+		GrantDuration:   request.GrantDuration,
+		State:           grpc_user_manager_go.AccessRequestState_PENDING,
+		Created:         now.Unix(),
+		ExpiresAt:       now.Add(ttl).Unix(),
+	}, nil
+}
+
+// IsPending checks if the request is waiting for a resolution.
+func (ar *AccessRequest) IsPending() bool {
+	return ar.State == grpc_user_manager_go.AccessRequestState_PENDING
+}
+
+// IsExpired checks if a pending request has expired at a given timestamp.
+func (ar *AccessRequest) IsExpired(timestamp int64) bool {
+	return ar.IsPending() && ar.ExpiresAt <= timestamp
+}
+
+// Transition moves the request to a new state recording who resolved it.
+func (ar *AccessRequest) Transition(state grpc_user_manager_go.AccessRequestState, resolvedBy string, comment string, timestamp int64) derrors.Error {
+	for _, allowed := range accessRequestTransitions[ar.State] {
+		if allowed == state {
+			ar.State = state
+			ar.ResolvedBy = resolvedBy
+			ar.ResolvedAt = timestamp
+			ar.Comment = comment
+			return nil
+		}
+	}
+	return derrors.NewFailedPreconditionError("invalid access request transition").WithParams(ar.AccessRequestId, ar.State.String(), state.String())
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (ar *AccessRequest) ToGRPC() *grpc_user_manager_go.AccessRequest {
+	return &grpc_user_manager_go.AccessRequest{
+		OrganizationId:  ar.OrganizationId,
+		AccessRequestId: ar.AccessRequestId,
+		Email:           ar.Email,
+		RoleId:          ar.RoleId,
+		Justification:   ar.Justification,
+		GrantDuration:   ar.GrantDuration,
+		State:           ar.State,
+		Created:         ar.Created,
+		ExpiresAt:       ar.ExpiresAt,
+		ResolvedBy:      ar.ResolvedBy,
+		ResolvedAt:      ar.ResolvedAt,
+		Comment:         ar.Comment,
+	}
+}
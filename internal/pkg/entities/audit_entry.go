@@ -0,0 +1,86 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"fmt"
+	"github.com/nalej/grpc-user-manager-go"
+	"time"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditRoleGranted              = "role_granted"
+	AuditRoleReverted             = "role_reverted"
+	AuditRoleRevertFailed         = "role_revert_failed"
+	AuditRoleGrantReplaced        = "role_grant_replaced"
+	AuditAccessRequested          = "access_requested"
+	AuditAccessApproved           = "access_approved"
+	AuditAccessDenied             = "access_denied"
+	AuditAccessCancelled          = "access_cancelled"
+	AuditAccessExpired            = "access_expired"
+	AuditOrganizationBootstrapped = "organization_bootstrapped"
+	AuditOrganizationOffboarded   = "organization_offboarded"
+	AuditOwnershipTransferred     = "ownership_transferred"
+	AuditOwnershipTransferFailed  = "ownership_transfer_failed"
+	AuditGroupCreated             = "group_created"
+	AuditGroupUpdated             = "group_updated"
+	AuditGroupRemoved             = "group_removed"
+	AuditGroupMembersAdded        = "group_members_added"
+	AuditGroupMembersRemoved      = "group_members_removed"
+	AuditRoleAdded                = "role_added"
+	AuditRoleRemoved              = "role_removed"
+	AuditMemberInvited            = "member_invited"
+	AuditEmailChanged             = "email_changed"
+	AuditEmailMigrated            = "email_migrated"
+)
+
+// AuditEntry records an operation performed on the users of an organization.
+type AuditEntry struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Timestamp of the operation.
+	Timestamp int64
+	// Action with the type of operation.
+	Action string
+	// Email of the affected user.
+	Email string
+	// Details with a human readable description of the operation.
+	Details string
+}
+
+// NewAuditEntry creates an AuditEntry with the current timestamp.
+func NewAuditEntry(organizationID string, action string, email string, format string, args ...interface{}) *AuditEntry {
+	return &AuditEntry{
+		OrganizationId: organizationID,
+		Timestamp:      time.Now().Unix(),
+		Action:         action,
+		Email:          email,
+		Details:        fmt.Sprintf(format, args...),
+	}
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (ae *AuditEntry) ToGRPC() *grpc_user_manager_go.AuditEntry {
+	return &grpc_user_manager_go.AuditEntry{
+		OrganizationId: ae.OrganizationId,
+		Timestamp:      ae.Timestamp,
+		Action:         ae.Action,
+		Email:          ae.Email,
+		Details:        ae.Details,
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/grpc-user-manager-go"
+	"strings"
+)
+
+const (
+	// DefaultEmailClaim with the claim containing the email of the user.
+	DefaultEmailClaim = "email"
+	// EmailVerifiedClaim with the claim stating if the email has been verified by the identity provider.
+	EmailVerifiedClaim = "email_verified"
+)
+
+// RoleRule assigns a role to the users whose claim contains a value.
+type RoleRule struct {
+	// Claim with the name of the claim, such as groups.
+	Claim string `yaml:"claim"`
+	// Value expected in the claim. The comparison is case insensitive.
+	Value string `yaml:"value"`
+	// RoleId assigned if the claim contains the value.
+	RoleId string `yaml:"role_id"`
+}
+
+// ClaimRules of an organization mapping the claims of a verified assertion into a user.
+type ClaimRules struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string `yaml:"organization_id"`
+	// Issuer expected in the assertions. Any issuer is accepted if empty.
+	Issuer string `yaml:"issuer"`
+	// EmailClaim with the claim containing the email.
+	EmailClaim string `yaml:"email_claim"`
+	// RequireEmailVerified rejects the assertions whose email_verified claim is not true.
+	RequireEmailVerified bool `yaml:"require_email_verified"`
+	// NameClaim with the claim containing the name.
+	NameClaim string `yaml:"name_claim"`
+	// LastNameClaim with the claim containing the last name.
+	LastNameClaim string `yaml:"last_name_claim"`
+	// TitleClaim with the claim containing the title.
+	TitleClaim string `yaml:"title_claim"`
+	// PhoneClaim with the claim containing the phone.
+	PhoneClaim string `yaml:"phone_claim"`
+	// LocationClaim with the claim containing the location.
+	LocationClaim string `yaml:"location_claim"`
+	// RoleRules evaluated in order. The first matching rule determines the role.
+	RoleRules []RoleRule `yaml:"role_rules"`
+	// DefaultRoleId assigned if no rule matches. Users without matching rule are rejected if empty.
+	DefaultRoleId string `yaml:"default_role_id"`
+	// SyncRole updates the role of existing users on every login. Otherwise the role is only set on creation.
+	SyncRole bool `yaml:"sync_role"`
+}
+
+// Claims of an assertion indexed by their name.
+type Claims map[string][]string
+
+// NewClaims indexes the claims of a provisioning request.
+func NewClaims(claims []*grpc_user_manager_go.Claim) Claims {
+	result := make(Claims, len(claims))
+	for _, claim := range claims {
+		result[claim.Name] = append(result[claim.Name], claim.Values...)
+	}
+	return result
+}
+
+// Get the first value of a claim.
+func (c Claims) Get(name string) string {
+	if name == "" || len(c[name]) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(c[name][0])
+}
+
+// Email obtains the email of the user.
+func (r *ClaimRules) Email(claims Claims) string {
+	if r.EmailClaim == "" {
+		return claims.Get(DefaultEmailClaim)
+	}
+	return claims.Get(r.EmailClaim)
+}
+
+// Role obtains the role of the first matching rule, or the default role.
+func (r *ClaimRules) Role(claims Claims) string {
+	for _, rule := range r.RoleRules {
+		for _, value := range claims[rule.Claim] {
+			if strings.EqualFold(strings.TrimSpace(value), rule.Value) {
+				return rule.RoleId
+			}
+		}
+	}
+	return r.DefaultRoleId
+}
+
+// ToAddUserRequest maps the claims into the request to add the user. The password is not set.
+func (r *ClaimRules) ToAddUserRequest(claims Claims) *grpc_user_manager_go.AddUserRequest {
+	return &grpc_user_manager_go.AddUserRequest{
+		OrganizationId: r.OrganizationId,
+		Email:          r.Email(claims),
+		Name:           claims.Get(r.NameClaim),
+		LastName:       claims.Get(r.LastNameClaim),
+		Title:          claims.Get(r.TitleClaim),
+		Phone:          claims.Get(r.PhoneClaim),
+		Location:       claims.Get(r.LocationClaim),
+		RoleId:         r.Role(claims),
+	}
+}
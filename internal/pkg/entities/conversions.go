@@ -23,8 +23,9 @@ import (
 
 func ToChangePasswordRequest(source *grpc_user_manager_go.ChangePasswordRequest) *grpc_authx_go.ChangePasswordRequest {
 	request := &grpc_authx_go.ChangePasswordRequest{
-		Username:    source.Email,
-		NewPassword: source.NewPassword,
+		OrganizationId: source.OrganizationId,
+		Username:       source.Email,
+		NewPassword:    source.NewPassword,
 	}
 	return request
 }
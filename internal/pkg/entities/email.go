@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"golang.org/x/net/idna"
+	"strings"
+)
+
+// EmailRule with the provider-specific rules applied to the local part of the addresses of a set of domains.
+type EmailRule struct {
+	// Domains with the domains the rule applies to.
+	Domains []string `yaml:"domains"`
+	// CanonicalDomain replacing the domain of the address if set, e.g. gmail.com for googlemail.com.
+	CanonicalDomain string `yaml:"canonicalDomain"`
+	// IgnoreDots removes the dots of the local part.
+	IgnoreDots bool `yaml:"ignoreDots"`
+	// SubaddressSeparator removes the part of the local part that follows it, e.g. + for bob+news.
+	SubaddressSeparator string `yaml:"subaddressSeparator"`
+}
+
+func ValidEmailRule(rule *EmailRule) derrors.Error {
+	if len(rule.Domains) == 0 {
+		return derrors.NewInvalidArgumentError("email rule must have at least one domain")
+	}
+	if len(rule.SubaddressSeparator) > 1 {
+		return derrors.NewInvalidArgumentError("subaddressSeparator must be a single character").WithParams(rule.SubaddressSeparator)
+	}
+	return nil
+}
+
+// EmailNormalizer transforms the email addresses into the canonical form used to store and look up the users, so
+// two addresses that reach the same mailbox identify the same user.
+type EmailNormalizer struct {
+	// rules indexed by the ASCII form of the domain.
+	rules map[string]EmailRule
+}
+
+// NewEmailNormalizer creates a normalizer applying a set of provider rules. A domain cannot be listed in several rules.
+func NewEmailNormalizer(rules []EmailRule) (*EmailNormalizer, derrors.Error) {
+	indexed := make(map[string]EmailRule, 0)
+	for index := range rules {
+		rule := rules[index]
+		vErr := ValidEmailRule(&rule)
+		if vErr != nil {
+			return nil, vErr
+		}
+		if rule.CanonicalDomain != "" {
+			canonical, cErr := NormalizeDomain(rule.CanonicalDomain)
+			if cErr != nil {
+				return nil, cErr
+			}
+			rule.CanonicalDomain = canonical
+		}
+		for _, domain := range rule.Domains {
+			normalized, dErr := NormalizeDomain(domain)
+			if dErr != nil {
+				return nil, dErr
+			}
+			if _, exists := indexed[normalized]; exists {
+				return nil, derrors.NewAlreadyExistsError("domain is listed in several email rules").WithParams(domain)
+			}
+			indexed[normalized] = rule
+		}
+	}
+	return &EmailNormalizer{rules: indexed}, nil
+}
+
+// Normalize returns the canonical form of an email. The address is trimmed, the domain is converted to its lowercase
+// ASCII form and the local part is lowercased, as the users are identified case-insensitively. The rule of the domain,
+// if any, is applied afterwards. Empty emails are returned unchanged so the validators report them.
+func (en *EmailNormalizer) Normalize(email string) (string, derrors.Error) {
+	trimmed := strings.TrimSpace(email)
+	if trimmed == "" {
+		return "", nil
+	}
+	at := strings.LastIndex(trimmed, "@")
+	if at <= 0 || at == len(trimmed)-1 {
+		return "", derrors.NewInvalidArgumentError(invalidEmail).WithParams(email)
+	}
+	domain, err := NormalizeDomain(trimmed[at+1:])
+	if err != nil {
+		return "", err
+	}
+	local := strings.ToLower(trimmed[:at])
+	if rule, exists := en.rules[domain]; exists {
+		if rule.SubaddressSeparator != "" {
+			local = strings.SplitN(local, rule.SubaddressSeparator, 2)[0]
+		}
+		if rule.IgnoreDots {
+			local = strings.Replace(local, ".", "", -1)
+		}
+		if local == "" {
+			return "", derrors.NewInvalidArgumentError(invalidEmail).WithParams(email)
+		}
+		if rule.CanonicalDomain != "" {
+			domain = rule.CanonicalDomain
+		}
+	}
+	return local + "@" + domain, nil
+}
+
+// NormalizeDomain converts a domain, possibly internationalized, to its lowercase ASCII form.
+func NormalizeDomain(domain string) (string, derrors.Error) {
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	if err != nil {
+		return "", derrors.NewInvalidArgumentError("invalid email domain", err).WithParams(domain)
+	}
+	return strings.ToLower(ascii), nil
+}
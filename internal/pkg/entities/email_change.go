@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/subtle"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"time"
+)
+
+// emailChangeTokenLength with the number of random bytes of an email change confirmation token.
+const emailChangeTokenLength = 16
+
+// EmailChange with a pending change of the email of a user. The change is confirmed with a token sent to the new
+// address, so only the owner of the new address can complete it.
+type EmailChange struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email of the user.
+	Email string
+	// NewEmail with the address the user is moved to.
+	NewEmail string
+	// TokenHash with the hash of the confirmation token.
+	TokenHash string
+	// ExpiresAt with the timestamp after which the change can no longer be confirmed.
+	ExpiresAt int64
+}
+
+// NewEmailChange creates a pending EmailChange. The confirmation token is returned but only its hash is kept.
+func NewEmailChange(request *grpc_user_manager_go.EmailChangeRequest, ttl time.Duration) (*EmailChange, string, derrors.Error) {
+	token, err := randomHex(emailChangeTokenLength)
+	if err != nil {
+		return nil, "", err
+	}
+	return &EmailChange{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+		NewEmail:       request.NewEmail,
+		TokenHash:      HashToken(token),
+		ExpiresAt:      time.Now().Add(ttl).Unix(),
+	}, token, nil
+}
+
+// Confirms checks if a token confirms the change at a given timestamp.
+func (ec *EmailChange) Confirms(token string, timestamp int64) bool {
+	if subtle.ConstantTimeCompare([]byte(ec.TokenHash), []byte(HashToken(token))) != 1 {
+		return false
+	}
+	return ec.ExpiresAt > timestamp
+}
+
+// ToGRPC converts the entity into its gRPC counterpart including the confirmation token.
+func (ec *EmailChange) ToGRPC(token string) *grpc_user_manager_go.EmailChangeToken {
+	return &grpc_user_manager_go.EmailChangeToken{
+		OrganizationId: ec.OrganizationId,
+		Email:          ec.Email,
+		NewEmail:       ec.NewEmail,
+		Token:          token,
+		ExpiresAt:      ec.ExpiresAt,
+	}
+}
+
+// EmailAlias links the former email of a user to the current one during a grace period after the change. The former
+// address cannot be taken by other users while the alias is active.
+type EmailAlias struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email with the former address of the user.
+	Email string
+	// NewEmail with the current address of the user.
+	NewEmail string
+	// ExpiresAt with the timestamp the alias stops resolving.
+	ExpiresAt int64
+}
+
+// NewEmailAlias creates an EmailAlias for a completed change.
+func NewEmailAlias(change *EmailChange, gracePeriod time.Duration) *EmailAlias {
+	return &EmailAlias{
+		OrganizationId: change.OrganizationId,
+		Email:          change.Email,
+		NewEmail:       change.NewEmail,
+		ExpiresAt:      time.Now().Add(gracePeriod).Unix(),
+	}
+}
+
+// IsActive checks if the alias still resolves at a given timestamp.
+func (ea *EmailAlias) IsActive(timestamp int64) bool {
+	return ea.ExpiresAt > timestamp
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (ea *EmailAlias) ToGRPC() *grpc_user_manager_go.EmailAlias {
+	return &grpc_user_manager_go.EmailAlias{
+		OrganizationId: ea.OrganizationId,
+		Email:          ea.Email,
+		NewEmail:       ea.NewEmail,
+		ExpiresAt:      ea.ExpiresAt,
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package entities
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"strings"
+)
+
+// wildcardPrefix with the prefix of the domain patterns that match the subdomains of a domain.
+const wildcardPrefix = "*."
+
+// EmailDomainPolicy with the email domains whose users can be members of an organization. A pattern is either a domain,
+// matching only that domain, or a domain preceded by *., matching any of its subdomains. Denied domains take precedence
+// over the allowed ones, and every domain not denied is allowed if the policy has no allowed domains.
+type EmailDomainPolicy struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// AllowedDomains with the patterns of the domains the emails must belong to.
+	AllowedDomains []string
+	// DeniedDomains with the patterns of the domains the emails cannot belong to.
+	DeniedDomains []string
+}
+
+// NewEmailDomainPolicy creates an EmailDomainPolicy from its gRPC counterpart with the patterns in their ASCII form.
+func NewEmailDomainPolicy(policy *grpc_user_manager_go.EmailDomainPolicy) (*EmailDomainPolicy, derrors.Error) {
+	allowed, err := normalizeDomainPatterns(policy.AllowedDomains)
+	if err != nil {
+		return nil, err
+	}
+	denied, err := normalizeDomainPatterns(policy.DeniedDomains)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailDomainPolicy{
+		OrganizationId: policy.OrganizationId,
+		AllowedDomains: allowed,
+		DeniedDomains:  denied,
+	}, nil
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (edp *EmailDomainPolicy) ToGRPC() *grpc_user_manager_go.EmailDomainPolicy {
+	return &grpc_user_manager_go.EmailDomainPolicy{
+		OrganizationId: edp.OrganizationId,
+		AllowedDomains: edp.AllowedDomains,
+		DeniedDomains:  edp.DeniedDomains,
+	}
+}
+
+// Violation describes why a normalized email is not accepted by the policy, or returns an empty string if it is.
+func (edp *EmailDomainPolicy) Violation(email string) string {
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for _, pattern := range edp.DeniedDomains {
+		if matchesDomainPattern(pattern, domain) {
+			return fmt.Sprintf("domain %s is denied by %s", domain, pattern)
+		}
+	}
+	if len(edp.AllowedDomains) == 0 {
+		return ""
+	}
+	for _, pattern := range edp.AllowedDomains {
+		if matchesDomainPattern(pattern, domain) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("domain %s is not allowed", domain)
+}
+
+// Check that a normalized email is accepted by the policy.
+func (edp *EmailDomainPolicy) Check(email string) derrors.Error {
+	violation := edp.Violation(email)
+	if violation != "" {
+		return derrors.NewPermissionDeniedError("email domain is not allowed in the organization").WithParams(edp.OrganizationId, email, violation)
+	}
+	return nil
+}
+
+// matchesDomainPattern checks if a domain matches a pattern, both in their ASCII form.
+func matchesDomainPattern(pattern string, domain string) bool {
+	if strings.HasPrefix(pattern, wildcardPrefix) {
+		return strings.HasSuffix(domain, pattern[1:])
+	}
+	return domain == pattern
+}
+
+// normalizeDomainPattern converts the domain of a pattern to its lowercase ASCII form.
+func normalizeDomainPattern(pattern string) (string, derrors.Error) {
+	trimmed := strings.TrimSpace(pattern)
+	prefix := ""
+	if strings.HasPrefix(trimmed, wildcardPrefix) {
+		prefix = wildcardPrefix
+		trimmed = trimmed[len(wildcardPrefix):]
+	}
+	domain, err := NormalizeDomain(trimmed)
+	if err != nil {
+		return "", err
+	}
+	// wildcards may match the subdomains of a top-level domain, but the emails always have one
+	if domain == "" || strings.Contains(domain, "*") || (prefix == "" && !strings.Contains(domain, ".")) {
+		return "", derrors.NewInvalidArgumentError("invalid domain pattern").WithParams(pattern)
+	}
+	return prefix + domain, nil
+}
+
+// normalizeDomainPatterns converts a list of patterns to their ASCII form, rejecting the duplicated ones.
+func normalizeDomainPatterns(patterns []string) ([]string, derrors.Error) {
+	result := make([]string, 0, len(patterns))
+	found := make(map[string]bool, len(patterns))
+	for _, pattern := range patterns {
+		normalized, err := normalizeDomainPattern(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if found[normalized] {
+			return nil, derrors.NewInvalidArgumentError("duplicated domain pattern").WithParams(pattern)
+		}
+		found[normalized] = true
+		result = append(result, normalized)
+	}
+	return result, nil
+}
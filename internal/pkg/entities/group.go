@@ -0,0 +1,198 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"time"
+)
+
+// groupIDLength with the number of random bytes of a group identifier.
+const groupIDLength = 16
+
+// Group of users of an organization. The members of a group with a role binding can act with that role besides the
+// role assigned to them, see EffectiveRole for the precedence.
+type Group struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// GroupId with the group identifier.
+	GroupId string
+	// Name of the group.
+	Name string
+	// Description of the group.
+	Description string
+	// RoleId with the role bound to the group. The group grants no role if empty.
+	RoleId string
+	// Members with the emails of the users of the group.
+	Members []string
+	// Created with the creation timestamp.
+	Created int64
+}
+
+// NewGroup creates an empty Group with a random identifier.
+func NewGroup(request *grpc_user_manager_go.AddGroupRequest) (*Group, derrors.Error) {
+	id, err := randomHex(groupIDLength)
+	if err != nil {
+		return nil, err
+	}
+	return &Group{
+		OrganizationId: request.OrganizationId,
+		GroupId:        id,
+		Name:           request.Name,
+		Description:    request.Description,
+		RoleId:         request.RoleId,
+		Members:        make([]string, 0),
+		Created:        time.Now().Unix(),
+	}, nil
+}
+
+// HasMember checks if a user belongs to the group.
+func (g *Group) HasMember(email string) bool {
+	for _, member := range g.Members {
+		if member == email {
+			return true
+		}
+	}
+	return false
+}
+
+// AddMembers adds a set of users to the group, returning the ones that were not members already.
+func (g *Group) AddMembers(emails []string) []string {
+	added := make([]string, 0, len(emails))
+	for _, email := range emails {
+		if !g.HasMember(email) {
+			g.Members = append(g.Members, email)
+			added = append(added, email)
+		}
+	}
+	return added
+}
+
+// RemoveMembers removes a set of users from the group, returning the ones that were members.
+func (g *Group) RemoveMembers(emails []string) []string {
+	toRemove := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		toRemove[email] = true
+	}
+	removed := make([]string, 0, len(emails))
+	remaining := make([]string, 0, len(g.Members))
+	for _, member := range g.Members {
+		if toRemove[member] {
+			removed = append(removed, member)
+		} else {
+			remaining = append(remaining, member)
+		}
+	}
+	g.Members = remaining
+	return removed
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (g *Group) ToGRPC(roleName string) *grpc_user_manager_go.Group {
+	return &grpc_user_manager_go.Group{
+		OrganizationId: g.OrganizationId,
+		GroupId:        g.GroupId,
+		Name:           g.Name,
+		Description:    g.Description,
+		RoleId:         g.RoleId,
+		RoleName:       roleName,
+		Members:        append([]string{}, g.Members...),
+		Created:        g.Created,
+	}
+}
+
+// RoleCandidate with a role a user can act with and the group it comes from, if any.
+type RoleCandidate struct {
+	// Role with the union of the effective primitives of the roles of the candidate, named after the first one.
+	Role *grpc_authx_go.Role
+	// Roles with the roles merged into the candidate.
+	Roles []*grpc_authx_go.Role
+	// GroupId with the group binding the role. Empty for the roles assigned to the user.
+	GroupId string
+	// GroupName with the name of the group binding the role.
+	GroupName string
+}
+
+// NewRoleCandidate merges a set of roles into a candidate. The first role gives the identifier and name of the
+// candidate.
+func NewRoleCandidate(roles []*grpc_authx_go.Role, groupID string, groupName string) *RoleCandidate {
+	merged := *roles[0]
+	merged.Primitives = make([]grpc_authx_go.AccessPrimitive, 0)
+	for _, role := range roles {
+		for _, primitive := range role.Primitives {
+			if !grantsPrimitive(&merged, primitive) {
+				merged.Primitives = append(merged.Primitives, primitive)
+			}
+		}
+	}
+	return &RoleCandidate{
+		Role:      &merged,
+		Roles:     roles,
+		GroupId:   groupID,
+		GroupName: groupName,
+	}
+}
+
+// GrantedBy retrieves the first role of the candidate granting a primitive, or nil if none does.
+func (rc *RoleCandidate) GrantedBy(primitive grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+	for _, role := range rc.Roles {
+		if grantsPrimitive(role, primitive) {
+			return role
+		}
+	}
+	return nil
+}
+
+// EffectiveRole selects the role a user acts with among the roles assigned to the user and the roles bound to its
+// groups. The roles assigned to the user, the primary one and the additional ones, form a single candidate with the
+// union of their primitives. Candidates are expected with the assigned roles first followed by the groups sorted by
+// name. The precedence is:
+// 1.- A role with the ORG primitive over any role without it.
+// 2.- The role with more effective primitives.
+// 3.- The first candidate on ties, so the assigned roles win over the groups and groups are taken by name.
+func EffectiveRole(candidates []RoleCandidate) *RoleCandidate {
+	var selected *RoleCandidate
+	for index := range candidates {
+		candidate := &candidates[index]
+		if selected == nil || outranks(candidate.Role, selected.Role) {
+			selected = candidate
+		}
+	}
+	return selected
+}
+
+// outranks checks if a role takes precedence over another one.
+func outranks(role *grpc_authx_go.Role, other *grpc_authx_go.Role) bool {
+	isOwner := grantsPrimitive(role, grpc_authx_go.AccessPrimitive_ORG)
+	otherIsOwner := grantsPrimitive(other, grpc_authx_go.AccessPrimitive_ORG)
+	if isOwner != otherIsOwner {
+		return isOwner
+	}
+	return len(role.Primitives) > len(other.Primitives)
+}
+
+// grantsPrimitive checks if a role includes a given primitive.
+func grantsPrimitive(role *grpc_authx_go.Role, primitive grpc_authx_go.AccessPrimitive) bool {
+	for _, current := range role.Primitives {
+		if current == primitive {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import "time"
+
+// Identity of a person across organizations. Users are keyed by organization and email, so the identity links the
+// accounts that share the same email in several organizations. Credentials stay per organization.
+type Identity struct {
+	// Email shared by the accounts of the identity.
+	Email string
+	// Memberships with the organizations the identity belongs to, in the order they were joined.
+	Memberships []IdentityMembership
+	// PropagateProfile is set if the profile updates in one organization are applied to the others.
+	PropagateProfile bool
+	// Created with the creation timestamp.
+	Created int64
+}
+
+// IdentityMembership with an organization an identity belongs to.
+type IdentityMembership struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Joined with the timestamp the identity joined the organization.
+	Joined int64
+}
+
+// NewIdentity creates an Identity without memberships.
+func NewIdentity(email string) *Identity {
+	return &Identity{
+		Email:       email,
+		Memberships: make([]IdentityMembership, 0),
+		Created:     time.Now().Unix(),
+	}
+}
+
+// HasMembership checks if the identity belongs to an organization.
+func (i *Identity) HasMembership(organizationID string) bool {
+	for _, membership := range i.Memberships {
+		if membership.OrganizationId == organizationID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddMembership links an organization to the identity, returning false if it was already linked.
+func (i *Identity) AddMembership(organizationID string) bool {
+	if i.HasMembership(organizationID) {
+		return false
+	}
+	i.Memberships = append(i.Memberships, IdentityMembership{OrganizationId: organizationID, Joined: time.Now().Unix()})
+	return true
+}
+
+// RemoveMembership unlinks an organization from the identity, returning false if it was not linked.
+func (i *Identity) RemoveMembership(organizationID string) bool {
+	for index, membership := range i.Memberships {
+		if membership.OrganizationId == organizationID {
+			i.Memberships = append(i.Memberships[:index], i.Memberships[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
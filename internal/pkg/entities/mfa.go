@@ -0,0 +1,278 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// MFAIssuer with the issuer shown by the authenticator applications.
+	MFAIssuer = "Nalej"
+	// TOTPPeriod with the number of seconds a TOTP code is valid.
+	TOTPPeriod = 30
+	// TOTPDigits with the number of digits of a TOTP code.
+	TOTPDigits = 6
+	// totpSkew with the number of periods before and after the current one that are accepted to tolerate clock drift.
+	totpSkew = 1
+	// totpSecretLength with the number of random bytes of a TOTP secret, as recommended by RFC 4226.
+	totpSecretLength = 20
+	// RecoveryCodeCount with the number of recovery codes generated at once.
+	RecoveryCodeCount = 10
+	// recoveryCodeLength with the number of random bytes of a recovery code.
+	recoveryCodeLength = 5
+	// MaxMFAAttempts with the number of consecutive failed verifications that lock the MFA of a user.
+	MaxMFAAttempts = 5
+	// MFALockout with the time the MFA of a user stays locked after too many failed verifications.
+	MFALockout = 15 * time.Minute
+)
+
+// MFA contains the TOTP enrollment of a user. The secret is stored encrypted and the recovery codes are stored hashed.
+type MFA struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email of the user.
+	Email string
+	// EncryptedSecret with the TOTP secret encrypted with the MFA key of the service.
+	EncryptedSecret string
+	// Confirmed is set once the user proves the enrollment with a valid code. MFA is not enabled until then.
+	Confirmed bool
+	// LastTimeStep with the TOTP time step of the last accepted code, so codes cannot be replayed.
+	LastTimeStep int64
+	// RecoveryCodes with the SHA-256 hashes of the unused recovery codes.
+	RecoveryCodes []string
+	// FailedAttempts with the number of verifications since the last successful one.
+	FailedAttempts int32
+	// LockedUntil with the timestamp until which verifications are rejected.
+	LockedUntil int64
+	// Created with the timestamp of the enrollment.
+	Created int64
+}
+
+// NewMFA creates a pending enrollment with an encrypted secret.
+func NewMFA(organizationID string, email string, encryptedSecret string) *MFA {
+	return &MFA{
+		OrganizationId:  organizationID,
+		Email:           email,
+		EncryptedSecret: encryptedSecret,
+		RecoveryCodes:   make([]string, 0),
+		Created:         time.Now().Unix(),
+	}
+}
+
+// VerifyCode checks a TOTP code against the secret allowing a drift of one period. Codes of a time step already used
+// are rejected. The time step of the code is returned so it can be recorded.
+func (m *MFA) VerifyCode(secret string, code string, timestamp int64) (int64, bool) {
+	current := timestamp / TOTPPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= m.LastTimeStep {
+			continue
+		}
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsLocked checks if verifications are rejected at a given timestamp.
+func (m *MFA) IsLocked(timestamp int64) bool {
+	return m.LockedUntil > timestamp
+}
+
+// RegisterAttempt counts a verification before checking the code, locking the MFA once the maximum number of
+// attempts is reached. A successful verification resets the count with ResetAttempts.
+func (m *MFA) RegisterAttempt(timestamp int64) {
+	m.FailedAttempts++
+	if m.FailedAttempts >= MaxMFAAttempts {
+		m.FailedAttempts = 0
+		m.LockedUntil = timestamp + int64(MFALockout.Seconds())
+	}
+}
+
+// ResetAttempts clears the failed attempts and the lock after a successful verification.
+func (m *MFA) ResetAttempts() {
+	m.FailedAttempts = 0
+	m.LockedUntil = 0
+}
+
+// UseRecoveryCode consumes a recovery code returning whether it was valid.
+func (m *MFA) UseRecoveryCode(code string) bool {
+	hash := HashToken(normalizeRecoveryCode(code))
+	for index, stored := range m.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			remaining := make([]string, 0, len(m.RecoveryCodes)-1)
+			remaining = append(remaining, m.RecoveryCodes[:index]...)
+			m.RecoveryCodes = append(remaining, m.RecoveryCodes[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NewTOTPSecret generates a random base32 TOTP secret.
+func NewTOTPSecret() (string, derrors.Error) {
+	buffer := make([]byte, totpSecretLength)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", derrors.AsError(err, "cannot generate TOTP secret")
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buffer), nil
+}
+
+// TOTPCode computes the RFC 6238 code of a base32 secret for a given time step.
+func TOTPCode(secret string, timeStep int64) (string, derrors.Error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", derrors.NewInvalidArgumentError("invalid TOTP secret", err)
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(timeStep))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// TOTPURI builds the otpauth URI used by the authenticator applications to import a secret.
+func TOTPURI(issuer string, account string, secret string) string {
+	label := url.PathEscape(fmt.Sprintf("%s:%s", issuer, account))
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", TOTPPeriod))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, params.Encode())
+}
+
+// NewRecoveryCodes generates a set of recovery codes returning the codes and their hashes.
+func NewRecoveryCodes() ([]string, []string, derrors.Error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		random, err := randomHex(recoveryCodeLength)
+		if err != nil {
+			return nil, nil, err
+		}
+		code := fmt.Sprintf("%s-%s", random[:recoveryCodeLength], random[recoveryCodeLength:])
+		codes = append(codes, code)
+		hashes = append(hashes, HashToken(normalizeRecoveryCode(code)))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode removes the separators and the case of a recovery code.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// SecretCipher encrypts the TOTP secrets at rest using AES-256-GCM.
+type SecretCipher struct {
+	aead cipher.AEAD
+}
+
+// NewSecretCipher creates a cipher whose AES key is the SHA-256 hash of the configured key.
+func NewSecretCipher(key string) (*SecretCipher, derrors.Error) {
+	if key == "" {
+		return nil, derrors.NewInvalidArgumentError("mfa key cannot be empty")
+	}
+	hash := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(hash[:])
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot create MFA cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot create MFA cipher")
+	}
+	return &SecretCipher{aead: aead}, nil
+}
+
+// Encrypt a secret returning the base64 encoding of the nonce and the ciphertext.
+func (sc *SecretCipher) Encrypt(secret string) (string, derrors.Error) {
+	nonce := make([]byte, sc.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", derrors.AsError(err, "cannot generate nonce")
+	}
+	sealed := sc.aead.Seal(nonce, nonce, []byte(secret), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt a secret encrypted with Encrypt.
+func (sc *SecretCipher) Decrypt(encrypted string) (string, derrors.Error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil || len(sealed) < sc.aead.NonceSize() {
+		return "", derrors.NewInternalError("invalid encrypted secret")
+	}
+	nonce := sealed[:sc.aead.NonceSize()]
+	secret, err := sc.aead.Open(nil, nonce, sealed[sc.aead.NonceSize():], nil)
+	if err != nil {
+		return "", derrors.NewInternalError("cannot decrypt secret, the MFA key may have changed")
+	}
+	return string(secret), nil
+}
+
+// MFAPolicy with the MFA requirements of an organization.
+type MFAPolicy struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// RequireForOwners requires MFA for the users whose role has the ORG primitive.
+	RequireForOwners bool
+}
+
+// NewMFAPolicy creates a MFAPolicy from its gRPC counterpart.
+func NewMFAPolicy(policy *grpc_user_manager_go.MFAPolicy) *MFAPolicy {
+	return &MFAPolicy{
+		OrganizationId:   policy.OrganizationId,
+		RequireForOwners: policy.RequireForOwners,
+	}
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (mp *MFAPolicy) ToGRPC() *grpc_user_manager_go.MFAPolicy {
+	return &grpc_user_manager_go.MFAPolicy{
+		OrganizationId:   mp.OrganizationId,
+		RequireForOwners: mp.RequireForOwners,
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/subtle"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"time"
+)
+
+// offboardingTokenLength with the number of random bytes of an offboarding confirmation token.
+const offboardingTokenLength = 16
+
+// Offboarding tracks the removal of all the users and roles of an organization. The removal is confirmed with a
+// token and the progress is kept so an interrupted offboarding can be resumed.
+type Offboarding struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// TokenHash with the hash of the confirmation token.
+	TokenHash string
+	// ExpiresAt with the timestamp after which a pending offboarding can no longer be confirmed.
+	ExpiresAt int64
+	// Status of the offboarding.
+	Status grpc_user_manager_go.OffboardingStatus
+	// StartedAt with the timestamp of the first confirmation.
+	StartedAt int64
+	// CompletedAt with the timestamp the last user and role were removed.
+	CompletedAt int64
+	// RemovedUsers with the emails of the users removed so far.
+	RemovedUsers []string
+	// RemovedRoles with the identifiers of the roles removed so far.
+	RemovedRoles []string
+	// RemovedServiceAccounts with the identifiers of the service accounts removed so far.
+	RemovedServiceAccounts []string
+	// Failures of the last run.
+	Failures []string
+}
+
+// NewOffboarding creates a pending Offboarding. The confirmation token is returned but only its hash is kept.
+func NewOffboarding(organizationID string, ttl time.Duration) (*Offboarding, string, derrors.Error) {
+	token, err := randomHex(offboardingTokenLength)
+	if err != nil {
+		return nil, "", err
+	}
+	return &Offboarding{
+		OrganizationId:         organizationID,
+		TokenHash:              HashToken(token),
+		ExpiresAt:              time.Now().Add(ttl).Unix(),
+		Status:                 grpc_user_manager_go.OffboardingStatus_PENDING,
+		RemovedUsers:           make([]string, 0),
+		RemovedRoles:           make([]string, 0),
+		RemovedServiceAccounts: make([]string, 0),
+		Failures:               make([]string, 0),
+	}, token, nil
+}
+
+// RenewToken replaces the confirmation token, keeping the progress of an offboarding in progress.
+func (o *Offboarding) RenewToken(ttl time.Duration) (string, derrors.Error) {
+	token, err := randomHex(offboardingTokenLength)
+	if err != nil {
+		return "", err
+	}
+	o.TokenHash = HashToken(token)
+	o.ExpiresAt = time.Now().Add(ttl).Unix()
+	return token, nil
+}
+
+// IsCompleted checks if all the users and roles have been removed.
+func (o *Offboarding) IsCompleted() bool {
+	return o.Status == grpc_user_manager_go.OffboardingStatus_COMPLETED
+}
+
+// Confirms checks if a token confirms the offboarding at a given timestamp. Tokens expire whatever the status of
+// the offboarding, an offboarding in progress is resumed requesting a new token.
+func (o *Offboarding) Confirms(token string, timestamp int64) bool {
+	if subtle.ConstantTimeCompare([]byte(o.TokenHash), []byte(HashToken(token))) != 1 {
+		return false
+	}
+	return o.ExpiresAt > timestamp
+}
+
+// Start marks the offboarding as in progress. The start timestamp of a resumed offboarding is kept.
+func (o *Offboarding) Start(timestamp int64) {
+	if o.Status == grpc_user_manager_go.OffboardingStatus_PENDING {
+		o.Status = grpc_user_manager_go.OffboardingStatus_IN_PROGRESS
+		o.StartedAt = timestamp
+	}
+	o.Failures = make([]string, 0)
+}
+
+// Complete marks the offboarding as completed.
+func (o *Offboarding) Complete(timestamp int64) {
+	o.Status = grpc_user_manager_go.OffboardingStatus_COMPLETED
+	o.CompletedAt = timestamp
+}
+
+// ToGRPC converts the entity into its gRPC report.
+func (o *Offboarding) ToGRPC() *grpc_user_manager_go.OffboardingReport {
+	return &grpc_user_manager_go.OffboardingReport{
+		OrganizationId:         o.OrganizationId,
+		Status:                 o.Status,
+		StartedAt:              o.StartedAt,
+		CompletedAt:            o.CompletedAt,
+		RemovedUsers:           append([]string{}, o.RemovedUsers...),
+		RemovedRoles:           append([]string{}, o.RemovedRoles...),
+		RemovedServiceAccounts: append([]string{}, o.RemovedServiceAccounts...),
+		Failures:               append([]string{}, o.Failures...),
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import "github.com/nalej/grpc-user-manager-go"
+
+// DefaultMinOwners with the minimum number of owners of the organizations without an owner policy.
+const DefaultMinOwners = 1
+
+// OwnerPolicy with the minimum number of users holding a role with the ORG primitive that an organization must keep.
+type OwnerPolicy struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// MinOwners with the minimum number of owners.
+	MinOwners int
+}
+
+// NewOwnerPolicy creates an OwnerPolicy from its gRPC counterpart.
+func NewOwnerPolicy(policy *grpc_user_manager_go.OwnerPolicy) *OwnerPolicy {
+	return &OwnerPolicy{
+		OrganizationId: policy.OrganizationId,
+		MinOwners:      int(policy.MinOwners),
+	}
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (op *OwnerPolicy) ToGRPC() *grpc_user_manager_go.OwnerPolicy {
+	return &grpc_user_manager_go.OwnerPolicy{
+		OrganizationId: op.OrganizationId,
+		MinOwners:      int32(op.MinOwners),
+	}
+}
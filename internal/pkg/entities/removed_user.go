@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/grpc-user-manager-go"
+)
+
+// RemovedUser contains the snapshot of a user taken when it was removed from the system. The
+// snapshot is retained until ExpiresAt so the user can be restored.
+type RemovedUser struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email of the removed user.
+	Email string
+	// User with the enriched user information, role included, at removal time.
+	User *grpc_user_manager_go.User
+	// RemovedAt with the timestamp of the removal.
+	RemovedAt int64
+	// ExpiresAt with the timestamp after which the user can no longer be restored.
+	ExpiresAt int64
+}
+
+// NewRemovedUser creates a RemovedUser from the snapshot of a user.
+func NewRemovedUser(user *grpc_user_manager_go.User, removedAt int64, expiresAt int64) *RemovedUser {
+	return &RemovedUser{
+		OrganizationId: user.OrganizationId,
+		Email:          user.Email,
+		User:           user,
+		RemovedAt:      removedAt,
+		ExpiresAt:      expiresAt,
+	}
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (ru *RemovedUser) ToGRPC() *grpc_user_manager_go.RemovedUser {
+	return &grpc_user_manager_go.RemovedUser{
+		OrganizationId: ru.OrganizationId,
+		Email:          ru.Email,
+		User:           ru.User,
+		RemovedAt:      ru.RemovedAt,
+		ExpiresAt:      ru.ExpiresAt,
+	}
+}
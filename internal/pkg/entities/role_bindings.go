@@ -0,0 +1,67 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+// RoleBindings with the roles a user holds besides its primary role. The primary role is the one stored in authx, so
+// clients that only know about a single role keep working with it.
+type RoleBindings struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email of the user.
+	Email string
+	// RoleIds with the additional roles of the user.
+	RoleIds []string
+}
+
+// NewRoleBindings creates a RoleBindings without additional roles.
+func NewRoleBindings(organizationID string, email string) *RoleBindings {
+	return &RoleBindings{
+		OrganizationId: organizationID,
+		Email:          email,
+		RoleIds:        make([]string, 0),
+	}
+}
+
+// Has checks if the user holds a role as an additional role.
+func (rb *RoleBindings) Has(roleID string) bool {
+	for _, current := range rb.RoleIds {
+		if current == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds an additional role, returning false if the user already held it.
+func (rb *RoleBindings) Add(roleID string) bool {
+	if rb.Has(roleID) {
+		return false
+	}
+	rb.RoleIds = append(rb.RoleIds, roleID)
+	return true
+}
+
+// Remove removes an additional role, returning false if the user did not hold it.
+func (rb *RoleBindings) Remove(roleID string) bool {
+	for index, current := range rb.RoleIds {
+		if current == roleID {
+			rb.RoleIds = append(rb.RoleIds[:index], rb.RoleIds[index+1:]...)
+			return true
+		}
+	}
+	return false
+}
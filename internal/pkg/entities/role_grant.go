@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/grpc-user-manager-go"
+)
+
+// RoleGrant is a temporary role assignment. The user is reverted to PreviousRoleId once the grant expires.
+type RoleGrant struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// Email of the user.
+	Email string
+	// RoleId with the temporary role.
+	RoleId string
+	// PreviousRoleId with the role the user had before the grant.
+	PreviousRoleId string
+	// GrantedAt with the timestamp of the assignment.
+	GrantedAt int64
+	// ExpiresAt with the timestamp at which the user is reverted to the previous role.
+	ExpiresAt int64
+	// RevertError with the error of the last failed attempt to revert the user to the previous role.
+	RevertError string
+}
+
+// NewRoleGrant creates a RoleGrant for an assignment with expiration.
+func NewRoleGrant(request *grpc_user_manager_go.AssignRoleRequest, previousRoleID string, grantedAt int64) *RoleGrant {
+	return &RoleGrant{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+		RoleId:         request.RoleId,
+		PreviousRoleId: previousRoleID,
+		GrantedAt:      grantedAt,
+		ExpiresAt:      request.ExpiresAt,
+	}
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (rg *RoleGrant) ToGRPC() *grpc_user_manager_go.RoleGrant {
+	return &grpc_user_manager_go.RoleGrant{
+		OrganizationId: rg.OrganizationId,
+		Email:          rg.Email,
+		RoleId:         rg.RoleId,
+		PreviousRoleId: rg.PreviousRoleId,
+		GrantedAt:      rg.GrantedAt,
+		ExpiresAt:      rg.ExpiresAt,
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"sort"
+)
+
+// RoleParents with the roles a role inherits its primitives from and the primitives the role declares itself. Authx
+// keeps the effective primitives of the roles, so the declared ones are needed to resolve them again when the parents
+// or their primitives change.
+type RoleParents struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// RoleId with the role identifier.
+	RoleId string
+	// ParentRoleIds with the identifiers of the parent roles.
+	ParentRoleIds []string
+	// Primitives with the primitives declared by the role, without the inherited ones.
+	Primitives []grpc_authx_go.AccessPrimitive
+}
+
+// NewRoleParents creates a RoleParents with a copy of the given parents and primitives.
+func NewRoleParents(organizationID string, roleID string, parentRoleIDs []string, primitives []grpc_authx_go.AccessPrimitive) *RoleParents {
+	parents := make([]string, len(parentRoleIDs))
+	copy(parents, parentRoleIDs)
+	declared := make([]grpc_authx_go.AccessPrimitive, len(primitives))
+	copy(declared, primitives)
+	return &RoleParents{
+		OrganizationId: organizationID,
+		RoleId:         roleID,
+		ParentRoleIds:  parents,
+		Primitives:     declared,
+	}
+}
+
+// DeclaredRoles returns copies of the roles of an organization with the primitives they declare. The primitives of the
+// roles without parents are the effective ones, while the ones of the roles with parents are taken from their parents.
+func DeclaredRoles(roles []*grpc_authx_go.Role, parents []RoleParents) []*grpc_authx_go.Role {
+	declared := make(map[string][]grpc_authx_go.AccessPrimitive, len(parents))
+	for _, current := range parents {
+		if len(current.ParentRoleIds) > 0 {
+			declared[current.RoleId] = current.Primitives
+		}
+	}
+	result := make([]*grpc_authx_go.Role, 0, len(roles))
+	for _, role := range roles {
+		copied := *role
+		if primitives, exists := declared[role.RoleId]; exists {
+			copied.Primitives = primitives
+		}
+		result = append(result, &copied)
+	}
+	return result
+}
+
+// RoleHierarchy with the parent roles of the roles of an organization indexed by role_id. Roles without parents are
+// not included.
+type RoleHierarchy map[string][]string
+
+// NewRoleHierarchy creates the hierarchy of an organization from the parents of its roles.
+func NewRoleHierarchy(parents []RoleParents) RoleHierarchy {
+	hierarchy := make(RoleHierarchy, len(parents))
+	for _, current := range parents {
+		if len(current.ParentRoleIds) > 0 {
+			hierarchy[current.RoleId] = current.ParentRoleIds
+		}
+	}
+	return hierarchy
+}
+
+// WithParents returns a copy of the hierarchy where a role has the given parents.
+func (rh RoleHierarchy) WithParents(roleID string, parentRoleIDs []string) RoleHierarchy {
+	result := make(RoleHierarchy, len(rh)+1)
+	for id, parents := range rh {
+		result[id] = parents
+	}
+	delete(result, roleID)
+	if len(parentRoleIDs) > 0 {
+		result[roleID] = parentRoleIDs
+	}
+	return result
+}
+
+// Without returns a copy of the hierarchy without a role.
+func (rh RoleHierarchy) Without(roleID string) RoleHierarchy {
+	return rh.WithParents(roleID, nil)
+}
+
+// Children retrieves the roles that declare a role as parent, sorted by identifier.
+func (rh RoleHierarchy) Children(roleID string) []string {
+	children := make([]string, 0)
+	for id, parents := range rh {
+		for _, parent := range parents {
+			if parent == roleID {
+				children = append(children, id)
+				break
+			}
+		}
+	}
+	sort.Strings(children)
+	return children
+}
+
+// CheckCycles checks that no role inherits from itself. The error includes the roles that form the cycle.
+func (rh RoleHierarchy) CheckCycles() derrors.Error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(rh))
+	path := make([]string, 0)
+	var visit func(roleID string) derrors.Error
+	visit = func(roleID string) derrors.Error {
+		switch state[roleID] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append([]string{}, path...)
+			cycle = append(cycle, roleID)
+			return derrors.NewFailedPreconditionError("role hierarchy cannot have cycles").WithParams(cycle)
+		}
+		state[roleID] = visiting
+		path = append(path, roleID)
+		for _, parent := range rh[roleID] {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[roleID] = visited
+		return nil
+	}
+	roleIDs := make([]string, 0, len(rh))
+	for roleID := range rh {
+		roleIDs = append(roleIDs, roleID)
+	}
+	sort.Strings(roleIDs)
+	for _, roleID := range roleIDs {
+		if err := visit(roleID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EffectivePrimitives retrieves the primitives of a role followed by the ones inherited from its ancestors, without
+// duplicates. Parents that are not among the roles are ignored, and a role is only visited once so a corrupted
+// hierarchy cannot loop forever.
+func (rh RoleHierarchy) EffectivePrimitives(roleID string, roles map[string]*grpc_authx_go.Role) []grpc_authx_go.AccessPrimitive {
+	result := make([]grpc_authx_go.AccessPrimitive, 0)
+	included := make(map[grpc_authx_go.AccessPrimitive]bool, 0)
+	visited := make(map[string]bool, 0)
+	var visit func(current string)
+	visit = func(current string) {
+		role, exists := roles[current]
+		if visited[current] || !exists {
+			return
+		}
+		visited[current] = true
+		for _, primitive := range role.Primitives {
+			if !included[primitive] {
+				included[primitive] = true
+				result = append(result, primitive)
+			}
+		}
+		for _, parent := range rh[current] {
+			visit(parent)
+		}
+	}
+	visit(roleID)
+	return result
+}
+
+// EffectiveRoles returns copies of the roles of an organization with their effective primitives.
+func (rh RoleHierarchy) EffectiveRoles(roles []*grpc_authx_go.Role) []*grpc_authx_go.Role {
+	indexed := make(map[string]*grpc_authx_go.Role, len(roles))
+	for _, role := range roles {
+		indexed[role.RoleId] = role
+	}
+	result := make([]*grpc_authx_go.Role, 0, len(roles))
+	for _, role := range roles {
+		effective := *role
+		effective.Primitives = rh.EffectivePrimitives(role.RoleId, indexed)
+		result = append(result, &effective)
+	}
+	return result
+}
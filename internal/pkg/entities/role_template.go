@@ -0,0 +1,99 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+)
+
+// TemplateRole is a role created when a template is applied to an organization.
+type TemplateRole struct {
+	// Name of the role.
+	Name string `yaml:"name"`
+	// Description of the role.
+	Description string `yaml:"description"`
+	// Primitives with the names of the access primitives of the role.
+	Primitives []string `yaml:"primitives"`
+}
+
+// AccessPrimitives converts the names of the primitives of the role.
+func (tr *TemplateRole) AccessPrimitives() ([]grpc_authx_go.AccessPrimitive, derrors.Error) {
+	result := make([]grpc_authx_go.AccessPrimitive, 0, len(tr.Primitives))
+	for _, name := range tr.Primitives {
+		value, exists := grpc_authx_go.AccessPrimitive_value[name]
+		if !exists {
+			return nil, derrors.NewInvalidArgumentError("invalid primitive").WithParams(tr.Name, name)
+		}
+		result = append(result, grpc_authx_go.AccessPrimitive(value))
+	}
+	return result, nil
+}
+
+// RoleTemplate is a named set of roles that can be created in any organization.
+type RoleTemplate struct {
+	// Name of the template.
+	Name string `yaml:"name"`
+	// Description of the template.
+	Description string `yaml:"description"`
+	// Roles of the template.
+	Roles []TemplateRole `yaml:"roles"`
+}
+
+// DefaultRoleTemplate returns the roles created when an organization is bootstrapped without a template.
+func DefaultRoleTemplate() RoleTemplate {
+	return RoleTemplate{
+		Name:        "default",
+		Description: "Default roles of a new organization",
+		Roles: []TemplateRole{
+			{Name: "Owner", Description: "Manages the organization and its users", Primitives: []string{"ORG"}},
+			{Name: "Operator", Description: "Manages the infrastructure", Primitives: []string{"RESOURCES", "PROFILE"}},
+			{Name: "Developer", Description: "Manages the applications", Primitives: []string{"APPS", "PROFILE"}},
+		},
+	}
+}
+
+// OwnerRole returns the first role of the template with the ORG primitive, if any.
+func (rt *RoleTemplate) OwnerRole() *TemplateRole {
+	for index := range rt.Roles {
+		for _, primitive := range rt.Roles[index].Primitives {
+			if primitive == grpc_authx_go.AccessPrimitive_ORG.String() {
+				return &rt.Roles[index]
+			}
+		}
+	}
+	return nil
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (rt *RoleTemplate) ToGRPC() *grpc_user_manager_go.RoleTemplate {
+	roles := make([]*grpc_user_manager_go.RoleTemplateRole, 0, len(rt.Roles))
+	for _, role := range rt.Roles {
+		primitives, _ := role.AccessPrimitives()
+		roles = append(roles, &grpc_user_manager_go.RoleTemplateRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Primitives:  primitives,
+		})
+	}
+	return &grpc_user_manager_go.RoleTemplate{
+		Name:        rt.Name,
+		Description: rt.Description,
+		Roles:       roles,
+	}
+}
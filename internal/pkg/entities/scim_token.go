@@ -0,0 +1,47 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ScimToken with the bearer token used by the identity provider of an organization to access the SCIM endpoint.
+type ScimToken struct {
+	// OrganizationId with the organization managed with the token.
+	OrganizationId string
+	// TokenHash with the SHA-256 hash of the token. Tokens are never stored in clear.
+	TokenHash string
+	// DefaultRoleId with the role assigned to the users provisioned without group.
+	DefaultRoleId string
+}
+
+// NewScimToken creates a ScimToken hashing the token.
+func NewScimToken(organizationID string, token string, defaultRoleID string) *ScimToken {
+	return &ScimToken{
+		OrganizationId: organizationID,
+		TokenHash:      HashToken(token),
+		DefaultRoleId:  defaultRoleID,
+	}
+}
+
+// HashToken obtains the hexadecimal SHA-256 hash of a token.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
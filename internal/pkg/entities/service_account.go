@@ -0,0 +1,170 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package entities
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"strings"
+	"time"
+)
+
+const (
+	// ApiKeyPrefix identifies the API keys generated by user-manager.
+	ApiKeyPrefix = "umk"
+	// apiKeyIDLength with the number of random bytes of the public identifier of a key.
+	apiKeyIDLength = 8
+	// apiKeySecretLength with the number of random bytes of the secret part of a key.
+	apiKeySecretLength = 32
+	// serviceAccountIDLength with the number of random bytes of a service account identifier.
+	serviceAccountIDLength = 16
+)
+
+// ServiceAccount is a non-human member of an organization. Service accounts have a role but no email, photo or
+// password, and they authenticate with API keys.
+type ServiceAccount struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// ServiceAccountId with the service account identifier.
+	ServiceAccountId string
+	// Name of the service account.
+	Name string
+	// Description of the service account.
+	Description string
+	// RoleId with the role of the service account.
+	RoleId string
+	// Created with the creation timestamp.
+	Created int64
+}
+
+// NewServiceAccount creates a ServiceAccount with a random identifier.
+func NewServiceAccount(request *grpc_user_manager_go.AddServiceAccountRequest) (*ServiceAccount, derrors.Error) {
+	id, err := randomHex(serviceAccountIDLength)
+	if err != nil {
+		return nil, err
+	}
+	return &ServiceAccount{
+		OrganizationId:   request.OrganizationId,
+		ServiceAccountId: id,
+		Name:             request.Name,
+		Description:      request.Description,
+		RoleId:           request.RoleId,
+		Created:          time.Now().Unix(),
+	}, nil
+}
+
+// ToGRPC converts the entity into its gRPC counterpart.
+func (sa *ServiceAccount) ToGRPC(roleName string) *grpc_user_manager_go.ServiceAccount {
+	return &grpc_user_manager_go.ServiceAccount{
+		OrganizationId:   sa.OrganizationId,
+		ServiceAccountId: sa.ServiceAccountId,
+		Name:             sa.Name,
+		Description:      sa.Description,
+		RoleId:           sa.RoleId,
+		RoleName:         roleName,
+		Created:          sa.Created,
+	}
+}
+
+// ApiKey of a service account. Only the hash of the key is stored, the key is returned once when it is generated.
+type ApiKey struct {
+	// OrganizationId with the organization identifier.
+	OrganizationId string
+	// ServiceAccountId with the service account owning the key.
+	ServiceAccountId string
+	// KeyId with the public identifier of the key, which is part of the key itself.
+	KeyId string
+	// KeyHash with the SHA-256 hash of the key.
+	KeyHash string
+	// Scopes with the names of the access primitives the key is restricted to. All the primitives of the role are
+	// granted if empty.
+	Scopes []string
+	// Created with the creation timestamp.
+	Created int64
+	// ExpiresAt with the expiration timestamp. The key does not expire if zero.
+	ExpiresAt int64
+	// LastUsed with the timestamp of the last authentication with the key.
+	LastUsed int64
+}
+
+// NewApiKey generates a key for a service account, returning the entity and the key.
+func NewApiKey(organizationID string, serviceAccountID string, scopes []string, expiresAt int64) (*ApiKey, string, derrors.Error) {
+	keyID, err := randomHex(apiKeyIDLength)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomHex(apiKeySecretLength)
+	if err != nil {
+		return nil, "", err
+	}
+	key := fmt.Sprintf("%s_%s_%s", ApiKeyPrefix, keyID, secret)
+	return &ApiKey{
+		OrganizationId:   organizationID,
+		ServiceAccountId: serviceAccountID,
+		KeyId:            keyID,
+		KeyHash:          HashToken(key),
+		Scopes:           append([]string{}, scopes...),
+		Created:          time.Now().Unix(),
+		ExpiresAt:        expiresAt,
+	}, key, nil
+}
+
+// ApiKeyID extracts the public identifier of a key.
+func ApiKeyID(key string) (string, derrors.Error) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != ApiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", derrors.NewUnauthenticatedError("invalid api key format")
+	}
+	return parts[1], nil
+}
+
+// Matches checks in constant time if a key corresponds to the stored hash.
+func (ak *ApiKey) Matches(key string) bool {
+	return subtle.ConstantTimeCompare([]byte(ak.KeyHash), []byte(HashToken(key))) == 1
+}
+
+// IsExpired checks if the key is expired at a given timestamp.
+func (ak *ApiKey) IsExpired(timestamp int64) bool {
+	return ak.ExpiresAt != 0 && ak.ExpiresAt <= timestamp
+}
+
+// ToGRPC converts the entity into its gRPC counterpart. The hash is never returned.
+func (ak *ApiKey) ToGRPC() *grpc_user_manager_go.ApiKey {
+	return &grpc_user_manager_go.ApiKey{
+		OrganizationId:   ak.OrganizationId,
+		ServiceAccountId: ak.ServiceAccountId,
+		KeyId:            ak.KeyId,
+		Scopes:           append([]string{}, ak.Scopes...),
+		Created:          ak.Created,
+		ExpiresAt:        ak.ExpiresAt,
+		LastUsed:         ak.LastUsed,
+	}
+}
+
+// randomHex generates a hexadecimal string from a number of random bytes.
+func randomHex(length int) (string, derrors.Error) {
+	buffer := make([]byte, length)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", derrors.AsError(err, "cannot generate random identifier")
+	}
+	return hex.EncodeToString(buffer), nil
+}
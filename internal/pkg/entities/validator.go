@@ -34,6 +34,20 @@ const (
 	emptyRoleID         = "role_id cannot be empty"
 	emptyPassword       = "password cannot be empty"
 	invalidEmail        = "invalid email"
+
+	emptyServiceAccountID = "service_account_id cannot be empty"
+	emptyCode             = "code cannot be empty"
+	emptyAccessRequestID  = "access_request_id cannot be empty"
+	emptyGroupID          = "group_id cannot be empty"
+)
+
+const (
+	// MaxBulkUsers with the maximum number of users that can be added in a bulk operation.
+	MaxBulkUsers = 1000
+	// MaxBulkConcurrency with the maximum number of users that can be added in parallel in a bulk operation.
+	MaxBulkConcurrency = 16
+	// MaxCheckAccessBatch with the maximum number of access checks that can be evaluated in a batch.
+	MaxCheckAccessBatch = 1000
 )
 
 func ValidOrganizationID(organizationID *grpc_organization_go.OrganizationId) derrors.Error {
@@ -60,8 +74,52 @@ func ValidAddRoleRequest(addRoleRequest *grpc_user_manager_go.AddRoleRequest) de
 	if addRoleRequest.Name == "" {
 		return derrors.NewInvalidArgumentError(emptyName)
 	}
-	if len(addRoleRequest.Primitives) == 0 {
-		return derrors.NewInvalidArgumentError("at least one primitive is expected")
+	if len(addRoleRequest.Primitives) == 0 && len(addRoleRequest.ParentRoleIds) == 0 {
+		return derrors.NewInvalidArgumentError("at least one primitive or parent role is expected")
+	}
+	return validParentRoleIDs(addRoleRequest.ParentRoleIds)
+}
+
+func ValidUpdateRoleRequest(updateRoleRequest *grpc_user_manager_go.UpdateRoleRequest) derrors.Error {
+	if updateRoleRequest.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if updateRoleRequest.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	if !updateRoleRequest.UpdateName && !updateRoleRequest.UpdateDescription && !updateRoleRequest.UpdatePrimitives &&
+		!updateRoleRequest.UpdateParents {
+		return derrors.NewInvalidArgumentError("nothing to update")
+	}
+	if updateRoleRequest.UpdateName && updateRoleRequest.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	if updateRoleRequest.UpdatePrimitives && len(updateRoleRequest.Primitives) == 0 &&
+		!(updateRoleRequest.UpdateParents && len(updateRoleRequest.ParentRoleIds) > 0) {
+		return derrors.NewInvalidArgumentError("at least one primitive or parent role is expected")
+	}
+	if updateRoleRequest.UpdateParents {
+		for _, parentRoleID := range updateRoleRequest.ParentRoleIds {
+			if parentRoleID == updateRoleRequest.RoleId {
+				return derrors.NewInvalidArgumentError("a role cannot be its own parent").WithParams(parentRoleID)
+			}
+		}
+		return validParentRoleIDs(updateRoleRequest.ParentRoleIds)
+	}
+	return nil
+}
+
+// validParentRoleIDs checks that the parents of a role are not empty nor repeated.
+func validParentRoleIDs(parentRoleIDs []string) derrors.Error {
+	found := make(map[string]bool, len(parentRoleIDs))
+	for _, parentRoleID := range parentRoleIDs {
+		if parentRoleID == "" {
+			return derrors.NewInvalidArgumentError("parent_role_ids cannot contain empty identifiers")
+		}
+		if found[parentRoleID] {
+			return derrors.NewInvalidArgumentError("parent_role_ids cannot contain duplicates").WithParams(parentRoleID)
+		}
+		found[parentRoleID] = true
 	}
 	return nil
 }
@@ -86,23 +144,49 @@ func ValidAssignRoleRequest(assignRoleRequest *grpc_user_manager_go.AssignRoleRe
 	if assignRoleRequest.RoleId == "" {
 		return derrors.NewInvalidArgumentError(emptyRoleID)
 	}
+	if assignRoleRequest.ExpiresAt < 0 {
+		return derrors.NewInvalidArgumentError("expires_at cannot be negative")
+	}
+	if _, exists := grpc_user_manager_go.AssignRoleOperation_name[int32(assignRoleRequest.Operation)]; !exists {
+		return derrors.NewInvalidArgumentError("invalid operation").WithParams(assignRoleRequest.Operation)
+	}
+	if assignRoleRequest.Operation != grpc_user_manager_go.AssignRoleOperation_REPLACE && assignRoleRequest.ExpiresAt != 0 {
+		return derrors.NewInvalidArgumentError("only the primary role can be granted temporarily")
+	}
 	return nil
 }
 
+// rxEmail with the accepted format of the email addresses once normalized. The local part accepts the atext
+// characters of RFC 5322 and the top-level domain may be an IDN in punycode.
+var rxEmail = regexp.MustCompile("^([a-zA-Z0-9!#$%&'*+/=?^_`{|}~\\-.]+)@([a-zA-Z0-9_\\-.]+)\\.([a-zA-Z]{2,30}|xn--[a-zA-Z0-9\\-]{1,59})$")
+
+func validEmail(email string) bool {
+	return len(email) <= 254 && rxEmail.MatchString(email)
+}
+
 func ValidAddUserRequest(addUserRequest *grpc_user_manager_go.AddUserRequest) derrors.Error {
+	err := ValidInviteUserRequest(addUserRequest)
+	if err != nil {
+		return err
+	}
+	if addUserRequest.Password == "" {
+		return derrors.NewInvalidArgumentError(emptyPassword)
+	}
+	return nil
+}
+
+// ValidInviteUserRequest validates the request of a user that is invited. The password is not required as a random
+// one is generated.
+func ValidInviteUserRequest(addUserRequest *grpc_user_manager_go.AddUserRequest) derrors.Error {
 	if addUserRequest.OrganizationId == "" {
 		return derrors.NewInvalidArgumentError(emptyOrganizationId)
 	}
 	if addUserRequest.Email == "" {
 		return derrors.NewInvalidArgumentError(emptyEmail)
 	}
-	var rxEmail = regexp.MustCompile(`^([a-zA-Z0-9_\-.]+)@([a-zA-Z0-9_\-.]+)\.([a-zA-Z]{2,30})$`)
-	if len(addUserRequest.Email) > 254 || !rxEmail.MatchString(addUserRequest.Email) {
+	if !validEmail(addUserRequest.Email) {
 		return derrors.NewInvalidArgumentError(invalidEmail)
 	}
-	if addUserRequest.Password == "" {
-		return derrors.NewInvalidArgumentError(emptyPassword)
-	}
 	if addUserRequest.Name == "" {
 		return derrors.NewInvalidArgumentError(emptyName)
 	}
@@ -149,3 +233,455 @@ func ValidUpdateUserRequest(updateUserRequest *grpc_user_go.UpdateUserRequest) d
 	}
 	return nil
 }
+
+func ValidBulkAddUsersRequest(request *grpc_user_manager_go.BulkAddUsersRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if len(request.Users) == 0 {
+		return derrors.NewInvalidArgumentError("at least one user is expected")
+	}
+	if len(request.Users) > MaxBulkUsers {
+		return derrors.NewInvalidArgumentError("too many users in a single request").WithParams(len(request.Users), MaxBulkUsers)
+	}
+	if request.MaxConcurrency < 0 || request.MaxConcurrency > MaxBulkConcurrency {
+		return derrors.NewInvalidArgumentError("invalid max_concurrency").WithParams(request.MaxConcurrency, MaxBulkConcurrency)
+	}
+	return nil
+}
+
+func ValidProvisionFromAssertionRequest(request *grpc_user_manager_go.ProvisionFromAssertionRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if len(request.Claims) == 0 {
+		return derrors.NewInvalidArgumentError("claims cannot be empty")
+	}
+	for _, claim := range request.Claims {
+		if claim.Name == "" {
+			return derrors.NewInvalidArgumentError("claim name cannot be empty")
+		}
+	}
+	return nil
+}
+
+func ValidClaimRules(rules *ClaimRules) derrors.Error {
+	if rules.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	for _, rule := range rules.RoleRules {
+		if rule.Claim == "" || rule.Value == "" || rule.RoleId == "" {
+			return derrors.NewInvalidArgumentError("role rules require claim, value and role_id").WithParams(rules.OrganizationId)
+		}
+	}
+	if len(rules.RoleRules) == 0 && rules.DefaultRoleId == "" {
+		return derrors.NewInvalidArgumentError("role_rules or default_role_id must be set").WithParams(rules.OrganizationId)
+	}
+	return nil
+}
+
+func ValidAddServiceAccountRequest(request *grpc_user_manager_go.AddServiceAccountRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	if request.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	return nil
+}
+
+func ValidServiceAccountID(serviceAccountID *grpc_user_manager_go.ServiceAccountId) derrors.Error {
+	if serviceAccountID.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if serviceAccountID.ServiceAccountId == "" {
+		return derrors.NewInvalidArgumentError(emptyServiceAccountID)
+	}
+	return nil
+}
+
+func ValidCreateApiKeyRequest(request *grpc_user_manager_go.CreateApiKeyRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.ServiceAccountId == "" {
+		return derrors.NewInvalidArgumentError(emptyServiceAccountID)
+	}
+	for _, scope := range request.Scopes {
+		if _, exists := grpc_authx_go.AccessPrimitive_value[scope]; !exists {
+			return derrors.NewInvalidArgumentError("invalid scope").WithParams(scope)
+		}
+	}
+	if request.ExpiresAt < 0 {
+		return derrors.NewInvalidArgumentError("expires_at cannot be negative")
+	}
+	return nil
+}
+
+func ValidApiKeyID(keyID *grpc_user_manager_go.ApiKeyId) derrors.Error {
+	if keyID.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if keyID.ServiceAccountId == "" {
+		return derrors.NewInvalidArgumentError(emptyServiceAccountID)
+	}
+	if keyID.KeyId == "" {
+		return derrors.NewInvalidArgumentError("key_id cannot be empty")
+	}
+	return nil
+}
+
+func ValidAuthenticateApiKeyRequest(request *grpc_user_manager_go.AuthenticateApiKeyRequest) derrors.Error {
+	if request.Key == "" {
+		return derrors.NewInvalidArgumentError("key cannot be empty")
+	}
+	return nil
+}
+
+func ValidMFACodeRequest(request *grpc_user_manager_go.MFACodeRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.Code == "" {
+		return derrors.NewInvalidArgumentError(emptyCode)
+	}
+	return nil
+}
+
+func ValidMFAPolicy(policy *grpc_user_manager_go.MFAPolicy) derrors.Error {
+	if policy.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	return nil
+}
+
+func ValidOwnerPolicy(policy *grpc_user_manager_go.OwnerPolicy) derrors.Error {
+	if policy.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if policy.MinOwners < 1 {
+		return derrors.NewInvalidArgumentError("min_owners must be at least 1")
+	}
+	return nil
+}
+
+func ValidEmailDomainPolicy(policy *grpc_user_manager_go.EmailDomainPolicy) derrors.Error {
+	if policy.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	for _, patterns := range [][]string{policy.AllowedDomains, policy.DeniedDomains} {
+		_, err := normalizeDomainPatterns(patterns)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidAddGroupRequest(request *grpc_user_manager_go.AddGroupRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	return nil
+}
+
+func ValidGroupID(groupID *grpc_user_manager_go.GroupId) derrors.Error {
+	if groupID.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if groupID.GroupId == "" {
+		return derrors.NewInvalidArgumentError(emptyGroupID)
+	}
+	return nil
+}
+
+func ValidUpdateGroupRequest(request *grpc_user_manager_go.UpdateGroupRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.GroupId == "" {
+		return derrors.NewInvalidArgumentError(emptyGroupID)
+	}
+	if !request.UpdateName && !request.UpdateDescription && !request.UpdateRole {
+		return derrors.NewInvalidArgumentError("nothing to update")
+	}
+	if request.UpdateName && request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	return nil
+}
+
+func ValidGroupMembersRequest(request *grpc_user_manager_go.GroupMembersRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.GroupId == "" {
+		return derrors.NewInvalidArgumentError(emptyGroupID)
+	}
+	if len(request.Emails) == 0 {
+		return derrors.NewInvalidArgumentError("emails cannot be empty")
+	}
+	found := make(map[string]bool, len(request.Emails))
+	for _, email := range request.Emails {
+		if email == "" {
+			return derrors.NewInvalidArgumentError(emptyEmail)
+		}
+		if found[email] {
+			return derrors.NewInvalidArgumentError("emails cannot contain duplicates").WithParams(email)
+		}
+		found[email] = true
+	}
+	return nil
+}
+
+func ValidCheckAccessRequest(request *grpc_user_manager_go.CheckAccessRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if len(request.Primitives) == 0 {
+		return derrors.NewInvalidArgumentError("primitives cannot be empty")
+	}
+	for _, primitive := range request.Primitives {
+		if _, exists := grpc_authx_go.AccessPrimitive_name[int32(primitive)]; !exists {
+			return derrors.NewInvalidArgumentError("invalid primitive").WithParams(primitive)
+		}
+	}
+	return nil
+}
+
+func ValidCheckAccessBatchRequest(request *grpc_user_manager_go.CheckAccessBatchRequest) derrors.Error {
+	if len(request.Requests) == 0 {
+		return derrors.NewInvalidArgumentError("requests cannot be empty")
+	}
+	if len(request.Requests) > MaxCheckAccessBatch {
+		return derrors.NewInvalidArgumentError("too many access checks in a single request").WithParams(len(request.Requests), MaxCheckAccessBatch)
+	}
+	for _, check := range request.Requests {
+		if err := ValidCheckAccessRequest(check); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidCreateAccessRequestRequest(request *grpc_user_manager_go.CreateAccessRequestRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	if request.Justification == "" {
+		return derrors.NewInvalidArgumentError("justification cannot be empty")
+	}
+	if request.GrantDuration < 0 {
+		return derrors.NewInvalidArgumentError("grant_duration cannot be negative")
+	}
+	return nil
+}
+
+func ValidResolveAccessRequestRequest(request *grpc_user_manager_go.ResolveAccessRequestRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.AccessRequestId == "" {
+		return derrors.NewInvalidArgumentError(emptyAccessRequestID)
+	}
+	if request.ApproverEmail == "" {
+		return derrors.NewInvalidArgumentError("approver_email cannot be empty")
+	}
+	return nil
+}
+
+func ValidCancelAccessRequestRequest(request *grpc_user_manager_go.CancelAccessRequestRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.AccessRequestId == "" {
+		return derrors.NewInvalidArgumentError(emptyAccessRequestID)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	return nil
+}
+
+func ValidRoleTemplate(template *RoleTemplate) derrors.Error {
+	if template.Name == "" {
+		return derrors.NewInvalidArgumentError("template name cannot be empty")
+	}
+	if len(template.Roles) == 0 {
+		return derrors.NewInvalidArgumentError("at least one role is expected").WithParams(template.Name)
+	}
+	names := make(map[string]bool, len(template.Roles))
+	for index := range template.Roles {
+		role := &template.Roles[index]
+		if role.Name == "" {
+			return derrors.NewInvalidArgumentError(emptyName).WithParams(template.Name)
+		}
+		if names[role.Name] {
+			return derrors.NewInvalidArgumentError("duplicated role name").WithParams(template.Name, role.Name)
+		}
+		names[role.Name] = true
+		if len(role.Primitives) == 0 {
+			return derrors.NewInvalidArgumentError("at least one primitive is expected").WithParams(template.Name, role.Name)
+		}
+		_, err := role.AccessPrimitives()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ValidApplyRoleTemplateRequest(request *grpc_user_manager_go.ApplyRoleTemplateRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.TemplateName == "" {
+		return derrors.NewInvalidArgumentError("template_name cannot be empty")
+	}
+	return nil
+}
+
+func ValidCloneRoleRequest(request *grpc_user_manager_go.CloneRoleRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	if request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	return nil
+}
+
+func ValidBootstrapOrganizationRequest(request *grpc_user_manager_go.BootstrapOrganizationRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if !validEmail(request.Email) {
+		return derrors.NewInvalidArgumentError(invalidEmail)
+	}
+	if request.Password == "" {
+		return derrors.NewInvalidArgumentError(emptyPassword)
+	}
+	if request.Name == "" {
+		return derrors.NewInvalidArgumentError(emptyName)
+	}
+	if request.LastName == "" {
+		return derrors.NewInvalidArgumentError(emptyLastName)
+	}
+	if request.Title == "" {
+		return derrors.NewInvalidArgumentError(emptyTitle)
+	}
+	return nil
+}
+
+func ValidOffboardOrganizationRequest(request *grpc_user_manager_go.OffboardOrganizationRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.ConfirmationToken == "" {
+		return derrors.NewInvalidArgumentError("confirmation_token cannot be empty")
+	}
+	return nil
+}
+
+func ValidTransferOwnershipRequest(request *grpc_user_manager_go.TransferOwnershipRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.FromEmail == "" || request.ToEmail == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.FromEmail == request.ToEmail {
+		return derrors.NewInvalidArgumentError("from_email and to_email must be different").WithParams(request.FromEmail)
+	}
+	if request.DemoteToRoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	return nil
+}
+
+func ValidListMembershipsRequest(request *grpc_user_manager_go.ListMembershipsRequest) derrors.Error {
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	return nil
+}
+
+func ValidInviteMemberRequest(request *grpc_user_manager_go.InviteMemberRequest) derrors.Error {
+	if request.OrganizationId == "" || request.SourceOrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.OrganizationId == request.SourceOrganizationId {
+		return derrors.NewInvalidArgumentError("organization_id and source_organization_id must be different").WithParams(request.OrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.RoleId == "" {
+		return derrors.NewInvalidArgumentError(emptyRoleID)
+	}
+	return nil
+}
+
+func ValidProfilePropagationRequest(request *grpc_user_manager_go.ProfilePropagationRequest) derrors.Error {
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	return nil
+}
+
+func ValidEmailChangeRequest(request *grpc_user_manager_go.EmailChangeRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" || request.NewEmail == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.Email == request.NewEmail {
+		return derrors.NewInvalidArgumentError("email and new_email must be different").WithParams(request.Email)
+	}
+	return nil
+}
+
+func ValidChangeEmailRequest(request *grpc_user_manager_go.ChangeEmailRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	if request.Email == "" {
+		return derrors.NewInvalidArgumentError(emptyEmail)
+	}
+	if request.ConfirmationToken == "" {
+		return derrors.NewInvalidArgumentError("confirmation_token cannot be empty")
+	}
+	return nil
+}
+
+func ValidMigrateEmailsRequest(request *grpc_user_manager_go.MigrateEmailsRequest) derrors.Error {
+	if request.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError(emptyOrganizationId)
+	}
+	return nil
+}
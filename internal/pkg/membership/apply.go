@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package membership
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/document"
+	"github.com/rs/zerolog/log"
+	"io"
+)
+
+// Apply the operations of a plan in order: roles are added and updated first, then users, then role changes with
+// promotions before demotions, then the role updates revoking the ORG primitive and finally removals. The user
+// manager keeps enforcing the last owner checks on each operation. The application stops on the first failure.
+func Apply(client grpc_user_manager_go.UserManagerClient, plan *Plan, output io.Writer) derrors.Error {
+	orgID := &grpc_organization_go.OrganizationId{
+		OrganizationId: plan.OrganizationId,
+	}
+	roles, err := client.ListRoles(context.Background(), orgID)
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	roleIDs := make(map[string]string, 0)
+	for _, role := range roles.Roles {
+		if _, exists := roleIDs[role.Name]; !exists {
+			roleIDs[role.Name] = role.RoleId
+		}
+	}
+
+	// 1. Roles
+	for _, role := range plan.RolesToAdd {
+		primitives, pErr := document.NamesToPrimitives(role.Primitives)
+		if pErr != nil {
+			return pErr
+		}
+		added, err := client.AddRole(context.Background(), &grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: plan.OrganizationId,
+			Name:           role.Name,
+			Primitives:     primitives,
+		})
+		if err != nil {
+			return conversions.ToDerror(err)
+		}
+		roleIDs[role.Name] = added.RoleId
+		fmt.Fprintf(output, "role %s added\n", role.Name)
+	}
+	for _, update := range plan.RolesToUpdate {
+		if update.RevokesOwner {
+			continue
+		}
+		uErr := updateRole(client, plan.OrganizationId, roleIDs[update.Name], update, output)
+		if uErr != nil {
+			return uErr
+		}
+	}
+
+	// 2. Users, invited as the spec does not contain passwords
+	if len(plan.UsersToAdd) > 0 {
+		request := &grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: plan.OrganizationId,
+			Users:          make([]*grpc_user_manager_go.AddUserRequest, 0, len(plan.UsersToAdd)),
+			Invite:         true,
+		}
+		for _, user := range plan.UsersToAdd {
+			request.Users = append(request.Users, &grpc_user_manager_go.AddUserRequest{
+				OrganizationId: plan.OrganizationId,
+				Email:          user.Email,
+				Name:           user.Name,
+				LastName:       user.LastName,
+				Title:          user.Title,
+				Phone:          user.Phone,
+				Location:       user.Location,
+				RoleId:         roleIDs[user.Role],
+			})
+		}
+		response, err := client.BulkAddUsers(context.Background(), request)
+		if err != nil {
+			return conversions.ToDerror(err)
+		}
+		for _, result := range response.Results {
+			if !result.Success {
+				return derrors.NewInternalError("cannot add user").WithParams(result.Email, result.Error)
+			}
+			fmt.Fprintf(output, "user %s added\n", result.Email)
+		}
+	}
+
+	// 3. Role changes
+	for _, change := range plan.RoleChanges {
+		_, err := client.AssignRole(context.Background(), &grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: plan.OrganizationId,
+			Email:          change.Email,
+			RoleId:         roleIDs[change.ToRole],
+		})
+		if err != nil {
+			return conversions.ToDerror(err)
+		}
+		fmt.Fprintf(output, "user %s: %s -> %s\n", change.Email, change.FromRole, change.ToRole)
+	}
+	// 4. Role updates revoking the ORG primitive
+	for _, update := range plan.RolesToUpdate {
+		if !update.RevokesOwner {
+			continue
+		}
+		uErr := updateRole(client, plan.OrganizationId, roleIDs[update.Name], update, output)
+		if uErr != nil {
+			return uErr
+		}
+	}
+
+	// 5. Removals
+	for _, removal := range plan.UsersToRemove {
+		_, err := client.RemoveUser(context.Background(), &grpc_user_go.UserId{
+			OrganizationId: plan.OrganizationId,
+			Email:          removal.Email,
+		})
+		if err != nil {
+			return conversions.ToDerror(err)
+		}
+		fmt.Fprintf(output, "user %s removed\n", removal.Email)
+	}
+	log.Debug().Str("organizationID", plan.OrganizationId).Msg("plan applied")
+	return nil
+}
+
+// updateRole sets the primitives of an existing role.
+func updateRole(client grpc_user_manager_go.UserManagerClient, organizationID string, roleID string, update RoleUpdate, output io.Writer) derrors.Error {
+	primitives, pErr := document.NamesToPrimitives(update.ToPrimitives)
+	if pErr != nil {
+		return pErr
+	}
+	_, err := client.UpdateRole(context.Background(), &grpc_user_manager_go.UpdateRoleRequest{
+		OrganizationId:   organizationID,
+		RoleId:           roleID,
+		UpdatePrimitives: true,
+		Primitives:       primitives,
+	})
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	fmt.Fprintf(output, "role %s updated\n", update.Name)
+	return nil
+}
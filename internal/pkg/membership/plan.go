@@ -0,0 +1,246 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package membership
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/document"
+	"io"
+	"sort"
+	"strings"
+)
+
+// RoleChange with the change of the role of an existing user.
+type RoleChange struct {
+	Email    string
+	FromRole string
+	ToRole   string
+	// Promotion is set when the new role grants the ORG primitive.
+	Promotion bool
+}
+
+// RoleUpdate with the change of the primitives of an existing role.
+type RoleUpdate struct {
+	Name           string
+	FromPrimitives []string
+	ToPrimitives   []string
+	// RevokesOwner is set when the role loses the ORG primitive.
+	RevokesOwner bool
+}
+
+// UserRemoval with a user that is not declared in the spec.
+type UserRemoval struct {
+	Email string
+	Role  string
+}
+
+// Plan with the operations required to move an organization to the state declared in a spec.
+type Plan struct {
+	OrganizationId string
+	// RolesToAdd with the roles declared in the spec that do not exist.
+	RolesToAdd []Role
+	// RolesToUpdate with the roles whose primitives differ from the spec. Updates revoking the ORG primitive are
+	// placed last so they are applied after the role changes.
+	RolesToUpdate []RoleUpdate
+	// UsersToAdd with the users declared in the spec that do not exist.
+	UsersToAdd []User
+	// RoleChanges with the users whose role differs from the spec. Promotions are placed first so the
+	// organization never runs out of owners while the plan is applied.
+	RoleChanges []RoleChange
+	// UsersToRemove with the users not declared in the spec. Only filled when pruning.
+	UsersToRemove []UserRemoval
+	// Warnings with the differences that the plan does not fix.
+	Warnings []string
+}
+
+// IsEmpty checks if the plan has any operation.
+func (p *Plan) IsEmpty() bool {
+	return len(p.RolesToAdd) == 0 && len(p.RolesToUpdate) == 0 && len(p.UsersToAdd) == 0 && len(p.RoleChanges) == 0 && len(p.UsersToRemove) == 0
+}
+
+// hasOrgPrimitive checks if a list of primitives contains the ORG one.
+func hasOrgPrimitive(primitives []grpc_authx_go.AccessPrimitive) bool {
+	for _, primitive := range primitives {
+		if primitive == grpc_authx_go.AccessPrimitive_ORG {
+			return true
+		}
+	}
+	return false
+}
+
+// sameNames checks if two lists of primitive names contain the same elements.
+func sameNames(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for index := range sortedA {
+		if sortedA[index] != sortedB[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// Compute the plan that moves the live roles and users of an organization to the state declared in a spec. Users
+// not declared in the spec are only removed when pruning, and users with internal roles are never removed. The plan
+// is rejected if the resulting organization has no user with the ORG primitive.
+func Compute(organizationID string, spec *Spec, roles *grpc_authx_go.RoleList, users *grpc_user_manager_go.UserList, prune bool) (*Plan, derrors.Error) {
+	plan := &Plan{
+		OrganizationId: organizationID,
+		RolesToAdd:     make([]Role, 0),
+		RolesToUpdate:  make([]RoleUpdate, 0),
+		UsersToAdd:     make([]User, 0),
+		RoleChanges:    make([]RoleChange, 0),
+		UsersToRemove:  make([]UserRemoval, 0),
+		Warnings:       make([]string, 0),
+	}
+
+	// ORG primitive of each role, indexed by name, after applying the plan
+	ownerRoles := make(map[string]bool, 0)
+
+	// 1. Roles
+	liveRoles := make(map[string]*grpc_authx_go.Role, 0)
+	for _, role := range roles.Roles {
+		if _, exists := liveRoles[role.Name]; exists {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("role %s is duplicated in the organization", role.Name))
+			continue
+		}
+		liveRoles[role.Name] = role
+		ownerRoles[role.Name] = hasOrgPrimitive(role.Primitives)
+	}
+	specRoles := make(map[string]bool, 0)
+	for _, role := range spec.Roles {
+		specRoles[role.Name] = true
+		primitives, err := document.NamesToPrimitives(role.Primitives)
+		if err != nil {
+			return nil, err
+		}
+		live, exists := liveRoles[role.Name]
+		if !exists {
+			plan.RolesToAdd = append(plan.RolesToAdd, role)
+			ownerRoles[role.Name] = hasOrgPrimitive(primitives)
+			continue
+		}
+		livePrimitives := document.PrimitivesToNames(live.Primitives)
+		if !sameNames(livePrimitives, role.Primitives) {
+			plan.RolesToUpdate = append(plan.RolesToUpdate, RoleUpdate{
+				Name:           role.Name,
+				FromPrimitives: livePrimitives,
+				ToPrimitives:   role.Primitives,
+				RevokesOwner:   ownerRoles[role.Name] && !hasOrgPrimitive(primitives),
+			})
+			ownerRoles[role.Name] = hasOrgPrimitive(primitives)
+		}
+	}
+	sort.SliceStable(plan.RolesToUpdate, func(i, j int) bool {
+		return !plan.RolesToUpdate[i].RevokesOwner && plan.RolesToUpdate[j].RevokesOwner
+	})
+	for _, role := range roles.Roles {
+		if !specRoles[role.Name] && !role.Internal {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("role %s is not declared in the spec", role.Name))
+		}
+	}
+
+	// 2. Users
+	owners := 0
+	liveUsers := make(map[string]*grpc_user_manager_go.User, 0)
+	for _, user := range users.Users {
+		liveUsers[user.Email] = user
+	}
+	for _, user := range spec.Users {
+		if ownerRoles[user.Role] {
+			owners++
+		}
+		live, exists := liveUsers[user.Email]
+		if !exists {
+			plan.UsersToAdd = append(plan.UsersToAdd, user)
+			continue
+		}
+		if live.RoleName != user.Role {
+			plan.RoleChanges = append(plan.RoleChanges, RoleChange{
+				Email:     user.Email,
+				FromRole:  live.RoleName,
+				ToRole:    user.Role,
+				Promotion: ownerRoles[user.Role],
+			})
+		}
+	}
+	specUsers := make(map[string]bool, 0)
+	for _, user := range spec.Users {
+		specUsers[user.Email] = true
+	}
+	for _, user := range users.Users {
+		if specUsers[user.Email] {
+			continue
+		}
+		if prune && !user.InternalRole {
+			plan.UsersToRemove = append(plan.UsersToRemove, UserRemoval{Email: user.Email, Role: user.RoleName})
+			continue
+		}
+		if ownerRoles[user.RoleName] {
+			owners++
+		}
+		if !user.InternalRole {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("user %s is not declared in the spec", user.Email))
+		}
+	}
+	if owners == 0 {
+		return nil, derrors.NewFailedPreconditionError(fmt.Sprintf("the plan would leave the organization without users with the %s primitive",
+			grpc_authx_go.AccessPrimitive_ORG.String()))
+	}
+
+	sort.SliceStable(plan.RoleChanges, func(i, j int) bool {
+		return plan.RoleChanges[i].Promotion && !plan.RoleChanges[j].Promotion
+	})
+	return plan, nil
+}
+
+// Print a human readable diff of the plan.
+func (p *Plan) Print(output io.Writer) {
+	for _, role := range p.RolesToAdd {
+		fmt.Fprintf(output, "+ role %s [%s]\n", role.Name, strings.Join(role.Primitives, ", "))
+	}
+	for _, update := range p.RolesToUpdate {
+		fmt.Fprintf(output, "~ role %s: [%s] -> [%s]\n", update.Name, strings.Join(update.FromPrimitives, ", "),
+			strings.Join(update.ToPrimitives, ", "))
+	}
+	for _, user := range p.UsersToAdd {
+		fmt.Fprintf(output, "+ user %s (%s)\n", user.Email, user.Role)
+	}
+	for _, change := range p.RoleChanges {
+		fmt.Fprintf(output, "~ user %s: %s -> %s\n", change.Email, change.FromRole, change.ToRole)
+	}
+	for _, removal := range p.UsersToRemove {
+		fmt.Fprintf(output, "- user %s (%s)\n", removal.Email, removal.Role)
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(output, "! %s\n", warning)
+	}
+	if p.IsEmpty() {
+		fmt.Fprintln(output, "No changes. The organization matches the spec.")
+		return
+	}
+	fmt.Fprintf(output, "\nPlan: %d roles to add, %d roles to update, %d users to add, %d role changes, %d users to remove.\n",
+		len(p.RolesToAdd), len(p.RolesToUpdate), len(p.UsersToAdd), len(p.RoleChanges), len(p.UsersToRemove))
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package membership
+
+import (
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"strings"
+)
+
+const testSpec = `
+version: v1
+roles:
+  - name: Owner
+    primitives: [ORG]
+  - name: Developer
+    primitives: [APPS, RESOURCES]
+users:
+  - email: owner@mail.com
+    role: Owner
+  - email: dev@mail.com
+    name: Dev
+    last_name: User
+    title: Engineer
+    role: Developer
+`
+
+func liveRoles() *grpc_authx_go.RoleList {
+	return &grpc_authx_go.RoleList{
+		Roles: []*grpc_authx_go.Role{
+			{RoleId: "owner", Name: "Owner", Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}},
+			{RoleId: "viewer", Name: "Viewer", Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE}},
+		},
+	}
+}
+
+var _ = ginkgo.Describe("Membership plan", func() {
+
+	var spec *Spec
+
+	ginkgo.BeforeEach(func() {
+		read, err := ReadSpec(strings.NewReader(testSpec))
+		gomega.Expect(err).To(gomega.Succeed())
+		spec = read
+	})
+
+	ginkgo.It("should reject specs referencing undeclared roles", func() {
+		spec.Users[0].Role = "Unknown"
+		gomega.Expect(spec.Validate()).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should compute the roles and users to add and the role changes", func() {
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "viewer", RoleName: "Viewer"},
+				{Email: "other@mail.com", RoleId: "owner", RoleName: "Owner"},
+			},
+		}
+		plan, err := Compute("org", spec, liveRoles(), users, false)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(plan.RolesToAdd)).Should(gomega.Equal(1))
+		gomega.Expect(plan.RolesToAdd[0].Name).Should(gomega.Equal("Developer"))
+		gomega.Expect(len(plan.UsersToAdd)).Should(gomega.Equal(1))
+		gomega.Expect(plan.UsersToAdd[0].Email).Should(gomega.Equal("dev@mail.com"))
+		gomega.Expect(len(plan.RoleChanges)).Should(gomega.Equal(1))
+		gomega.Expect(plan.RoleChanges[0].Promotion).To(gomega.BeTrue())
+		gomega.Expect(plan.UsersToRemove).To(gomega.BeEmpty())
+		// undeclared role and user
+		gomega.Expect(len(plan.Warnings)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should only remove undeclared users when pruning", func() {
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "owner", RoleName: "Owner"},
+				{Email: "other@mail.com", RoleId: "viewer", RoleName: "Viewer"},
+				{Email: "internal@mail.com", RoleId: "internal", RoleName: "Internal", InternalRole: true},
+			},
+		}
+		plan, err := Compute("org", spec, liveRoles(), users, true)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(plan.UsersToRemove)).Should(gomega.Equal(1))
+		gomega.Expect(plan.UsersToRemove[0].Email).Should(gomega.Equal("other@mail.com"))
+	})
+
+	ginkgo.It("should reject plans leaving the organization without owners", func() {
+		spec.Users[0].Role = "Developer"
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "owner", RoleName: "Owner"},
+			},
+		}
+		_, err := Compute("org", spec, liveRoles(), users, true)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should update the primitives of the roles that differ from the spec", func() {
+		roles := liveRoles()
+		roles.Roles = append(roles.Roles, &grpc_authx_go.Role{RoleId: "dev", Name: "Developer",
+			Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}})
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "owner", RoleName: "Owner"},
+				{Email: "dev@mail.com", RoleId: "dev", RoleName: "Developer"},
+			},
+		}
+		plan, err := Compute("org", spec, roles, users, true)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(plan.RolesToUpdate).To(gomega.HaveLen(1))
+		gomega.Expect(plan.RolesToUpdate[0].Name).To(gomega.Equal("Developer"))
+		gomega.Expect(plan.RolesToUpdate[0].ToPrimitives).To(gomega.ConsistOf("APPS", "RESOURCES"))
+		gomega.Expect(plan.RolesToUpdate[0].RevokesOwner).To(gomega.BeTrue())
+		gomega.Expect(plan.IsEmpty()).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should reject plans whose role updates leave the organization without owners", func() {
+		spec.Roles[0].Primitives = []string{"PROFILE"}
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "owner", RoleName: "Owner"},
+			},
+		}
+		_, err := Compute("org", spec, liveRoles(), users, true)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should compute an empty plan when the organization matches the spec", func() {
+		roles := liveRoles()
+		roles.Roles = append(roles.Roles, &grpc_authx_go.Role{RoleId: "dev", Name: "Developer",
+			Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_APPS}})
+		users := &grpc_user_manager_go.UserList{
+			Users: []*grpc_user_manager_go.User{
+				{Email: "owner@mail.com", RoleId: "owner", RoleName: "Owner"},
+				{Email: "dev@mail.com", RoleId: "dev", RoleName: "Developer"},
+			},
+		}
+		plan, err := Compute("org", spec, roles, users, true)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(plan.IsEmpty()).To(gomega.BeTrue())
+	})
+})
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package membership
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"google.golang.org/grpc"
+	"os"
+)
+
+// Config with the options of the plan and apply commands.
+type Config struct {
+	// UserManagerAddress with the host:port of the user manager.
+	UserManagerAddress string
+	// OrganizationId to reconcile.
+	OrganizationId string
+	// Path of the spec.
+	Path string
+	// Prune removes the users not declared in the spec.
+	Prune bool
+}
+
+// Validate the configuration.
+func (c *Config) Validate() derrors.Error {
+	if c.UserManagerAddress == "" {
+		return derrors.NewInvalidArgumentError("userManagerAddress must be set")
+	}
+	if c.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError("org must be set")
+	}
+	if c.Path == "" {
+		return derrors.NewInvalidArgumentError("file must be set")
+	}
+	return nil
+}
+
+// Runner computing and applying plans against a user manager.
+type Runner struct {
+	config Config
+}
+
+// NewRunner creates a runner with a given configuration.
+func NewRunner(config Config) *Runner {
+	return &Runner{config: config}
+}
+
+// Plan computes and prints the plan.
+func (r *Runner) Plan() derrors.Error {
+	conn, err := r.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = r.computePlan(grpc_user_manager_go.NewUserManagerClient(conn))
+	return err
+}
+
+// Apply computes, prints and applies the plan.
+func (r *Runner) Apply() derrors.Error {
+	conn, err := r.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	client := grpc_user_manager_go.NewUserManagerClient(conn)
+	plan, err := r.computePlan(client)
+	if err != nil {
+		return err
+	}
+	if plan.IsEmpty() {
+		return nil
+	}
+	fmt.Println()
+	return Apply(client, plan, os.Stdout)
+}
+
+// connect validates the configuration and creates the connection with the user manager.
+func (r *Runner) connect() (*grpc.ClientConn, derrors.Error) {
+	vErr := r.config.Validate()
+	if vErr != nil {
+		return nil, vErr
+	}
+	conn, err := grpc.Dial(r.config.UserManagerAddress, grpc.WithInsecure())
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot create connection with the user manager")
+	}
+	return conn, nil
+}
+
+// computePlan reads the spec and the live state to compute and print the plan.
+func (r *Runner) computePlan(client grpc_user_manager_go.UserManagerClient) (*Plan, derrors.Error) {
+	file, err := os.Open(r.config.Path)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot open spec")
+	}
+	defer file.Close()
+	spec, sErr := ReadSpec(file)
+	if sErr != nil {
+		return nil, sErr
+	}
+
+	orgID := &grpc_organization_go.OrganizationId{
+		OrganizationId: r.config.OrganizationId,
+	}
+	roles, err := client.ListRoles(context.Background(), orgID)
+	if err != nil {
+		return nil, conversions.ToDerror(err)
+	}
+	users, err := client.ListUsers(context.Background(), orgID)
+	if err != nil {
+		return nil, conversions.ToDerror(err)
+	}
+	plan, pErr := Compute(r.config.OrganizationId, spec, roles, users, r.config.Prune)
+	if pErr != nil {
+		return nil, pErr
+	}
+	plan.Print(os.Stdout)
+	return plan, nil
+}
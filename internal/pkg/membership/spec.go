@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package membership
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/document"
+	"gopkg.in/yaml.v2"
+	"io"
+)
+
+// Version of the spec format.
+const Version = "v1"
+
+// Spec with the desired roles and users of an organization.
+type Spec struct {
+	// Version of the spec format.
+	Version string `yaml:"version"`
+	// Roles with the desired roles.
+	Roles []Role `yaml:"roles"`
+	// Users with the desired users.
+	Users []User `yaml:"users"`
+}
+
+// Role with the desired name and primitives of a role.
+type Role struct {
+	Name       string   `yaml:"name"`
+	Primitives []string `yaml:"primitives"`
+}
+
+// User with the desired role of a user. The profile is only used when the user is added.
+type User struct {
+	Email    string `yaml:"email"`
+	Name     string `yaml:"name"`
+	LastName string `yaml:"last_name"`
+	Title    string `yaml:"title"`
+	Phone    string `yaml:"phone,omitempty"`
+	Location string `yaml:"location,omitempty"`
+	// Role with the name of the role of the user.
+	Role string `yaml:"role"`
+}
+
+// ReadSpec reads a YAML spec.
+func ReadSpec(reader io.Reader) (*Spec, derrors.Error) {
+	spec := &Spec{}
+	decoder := yaml.NewDecoder(reader)
+	decoder.SetStrict(true)
+	err := decoder.Decode(spec)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read spec")
+	}
+	vErr := spec.Validate()
+	if vErr != nil {
+		return nil, vErr
+	}
+	return spec, nil
+}
+
+// Validate the content of the spec. Users can only reference roles declared in the spec.
+func (s *Spec) Validate() derrors.Error {
+	if s.Version != Version {
+		return derrors.NewInvalidArgumentError("unsupported spec version").WithParams(s.Version, Version)
+	}
+	roles := make(map[string]bool, 0)
+	for _, role := range s.Roles {
+		if role.Name == "" {
+			return derrors.NewInvalidArgumentError("roles require name")
+		}
+		if roles[role.Name] {
+			return derrors.NewInvalidArgumentError("duplicated role").WithParams(role.Name)
+		}
+		roles[role.Name] = true
+		if len(role.Primitives) == 0 {
+			return derrors.NewInvalidArgumentError("at least one primitive is expected").WithParams(role.Name)
+		}
+		_, err := document.NamesToPrimitives(role.Primitives)
+		if err != nil {
+			return err
+		}
+	}
+	emails := make(map[string]bool, 0)
+	for _, user := range s.Users {
+		if user.Email == "" {
+			return derrors.NewInvalidArgumentError("users require email")
+		}
+		if emails[user.Email] {
+			return derrors.NewInvalidArgumentError("duplicated user").WithParams(user.Email)
+		}
+		emails[user.Email] = true
+		if !roles[user.Role] {
+			return derrors.NewInvalidArgumentError("user references a role not declared in the spec").WithParams(user.Email, user.Role)
+		}
+	}
+	return nil
+}
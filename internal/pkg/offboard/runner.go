@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboard
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"google.golang.org/grpc"
+	"io"
+	"os"
+	"time"
+)
+
+// Config with the options of the offboard command.
+type Config struct {
+	// UserManagerAddress with the host:port of the user manager.
+	UserManagerAddress string
+	// OrganizationId to offboard.
+	OrganizationId string
+	// Token confirming the offboarding. A new token is requested if empty.
+	Token string
+}
+
+// Validate the configuration.
+func (c *Config) Validate() derrors.Error {
+	if c.UserManagerAddress == "" {
+		return derrors.NewInvalidArgumentError("userManagerAddress must be set")
+	}
+	if c.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError("org must be set")
+	}
+	return nil
+}
+
+// Runner requesting and confirming the offboarding of an organization.
+type Runner struct {
+	config Config
+}
+
+// NewRunner creates a runner with a given configuration.
+func NewRunner(config Config) *Runner {
+	return &Runner{config: config}
+}
+
+// Run requests a confirmation token if none is set, or offboards the organization and prints the report otherwise.
+// An error is returned if the offboarding is not completed, so it can be resumed running again with the same token,
+// or with a new one if it has expired.
+func (r *Runner) Run() derrors.Error {
+	vErr := r.config.Validate()
+	if vErr != nil {
+		return vErr
+	}
+	conn, err := grpc.Dial(r.config.UserManagerAddress, grpc.WithInsecure())
+	if err != nil {
+		return derrors.AsError(err, "cannot create connection with the user manager")
+	}
+	defer conn.Close()
+	return r.offboard(grpc_user_manager_go.NewUserManagerClient(conn), os.Stdout)
+}
+
+// offboard requests the token or offboards the organization through a client, printing the result to a writer.
+func (r *Runner) offboard(client grpc_user_manager_go.UserManagerClient, writer io.Writer) derrors.Error {
+	if r.config.Token == "" {
+		token, err := client.RequestOffboardingToken(context.Background(), &grpc_organization_go.OrganizationId{
+			OrganizationId: r.config.OrganizationId,
+		})
+		if err != nil {
+			return conversions.ToDerror(err)
+		}
+		PrintToken(token, writer)
+		return nil
+	}
+
+	report, err := client.OffboardOrganization(context.Background(), &grpc_user_manager_go.OffboardOrganizationRequest{
+		OrganizationId:    r.config.OrganizationId,
+		ConfirmationToken: r.config.Token,
+	})
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	PrintReport(report, writer)
+	if report.Status != grpc_user_manager_go.OffboardingStatus_COMPLETED {
+		return derrors.NewFailedPreconditionError("offboarding not completed, run again with the same token or request a new one to resume").WithParams(r.config.OrganizationId)
+	}
+	return nil
+}
+
+// PrintToken prints what will be removed and how to confirm it.
+func PrintToken(token *grpc_user_manager_go.OffboardingToken, writer io.Writer) {
+	fmt.Fprintf(writer, "Organization %s has %d users and %d roles that will be removed.\n", token.OrganizationId, token.NumUsers, token.NumRoles)
+	fmt.Fprintf(writer, "Confirm before %s with:\n", time.Unix(token.ExpiresAt, 0).Format(time.RFC3339))
+	fmt.Fprintf(writer, "  user-manager offboard --org %s --token %s\n", token.OrganizationId, token.Token)
+}
+
+// PrintReport prints the elements removed and the failures of an offboarding.
+func PrintReport(report *grpc_user_manager_go.OffboardingReport, writer io.Writer) {
+	fmt.Fprintf(writer, "Organization %s: %s\n", report.OrganizationId, report.Status.String())
+	printList(writer, "Removed users", report.RemovedUsers)
+	printList(writer, "Removed service accounts", report.RemovedServiceAccounts)
+	printList(writer, "Removed roles", report.RemovedRoles)
+	printList(writer, "Failures", report.Failures)
+}
+
+func printList(writer io.Writer, title string, elements []string) {
+	fmt.Fprintf(writer, "%s: %d\n", title, len(elements))
+	for _, element := range elements {
+		fmt.Fprintf(writer, "  %s\n", element)
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboard
+
+import (
+	"bytes"
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fakeClient answers the offboarding requests with a fixed token or report.
+type fakeClient struct {
+	grpc_user_manager_go.UserManagerClient
+	token  *grpc_user_manager_go.OffboardingToken
+	report *grpc_user_manager_go.OffboardingReport
+	err    error
+	// confirmed with the token of the last offboarding request.
+	confirmed string
+}
+
+func (f *fakeClient) RequestOffboardingToken(ctx context.Context, in *grpc_organization_go.OrganizationId, opts ...grpc.CallOption) (*grpc_user_manager_go.OffboardingToken, error) {
+	return f.token, f.err
+}
+
+func (f *fakeClient) OffboardOrganization(ctx context.Context, in *grpc_user_manager_go.OffboardOrganizationRequest, opts ...grpc.CallOption) (*grpc_user_manager_go.OffboardingReport, error) {
+	f.confirmed = in.ConfirmationToken
+	return f.report, f.err
+}
+
+var _ = ginkgo.Describe("Offboard runner", func() {
+
+	var client *fakeClient
+	var output *bytes.Buffer
+
+	ginkgo.BeforeEach(func() {
+		client = &fakeClient{
+			token: &grpc_user_manager_go.OffboardingToken{
+				OrganizationId: "org",
+				Token:          "token",
+				NumUsers:       2,
+				NumRoles:       3,
+			},
+			report: &grpc_user_manager_go.OffboardingReport{
+				OrganizationId: "org",
+				Status:         grpc_user_manager_go.OffboardingStatus_COMPLETED,
+				RemovedUsers:   []string{"owner@mail.com", "member@mail.com"},
+				RemovedRoles:   []string{"role"},
+			},
+		}
+		output = &bytes.Buffer{}
+	})
+
+	ginkgo.It("should require the address and the organization", func() {
+		config := Config{UserManagerAddress: "localhost:8920", OrganizationId: "org"}
+		gomega.Expect(config.Validate()).To(gomega.Succeed())
+		config.OrganizationId = ""
+		gomega.Expect(config.Validate()).NotTo(gomega.Succeed())
+		config = Config{OrganizationId: "org"}
+		gomega.Expect(NewRunner(config).Run()).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should request a token if none is set", func() {
+		runner := NewRunner(Config{UserManagerAddress: "localhost:8920", OrganizationId: "org"})
+		gomega.Expect(runner.offboard(client, output)).To(gomega.Succeed())
+		gomega.Expect(client.confirmed).To(gomega.BeEmpty())
+		gomega.Expect(output.String()).To(gomega.ContainSubstring("2 users and 3 roles"))
+		gomega.Expect(output.String()).To(gomega.ContainSubstring("--org org --token token"))
+	})
+
+	ginkgo.It("should offboard the organization with the token and print the report", func() {
+		runner := NewRunner(Config{UserManagerAddress: "localhost:8920", OrganizationId: "org", Token: "token"})
+		gomega.Expect(runner.offboard(client, output)).To(gomega.Succeed())
+		gomega.Expect(client.confirmed).To(gomega.Equal("token"))
+		gomega.Expect(output.String()).To(gomega.ContainSubstring("Removed users: 2\n  owner@mail.com\n  member@mail.com\n"))
+		gomega.Expect(output.String()).To(gomega.ContainSubstring("Failures: 0\n"))
+	})
+
+	ginkgo.It("should fail if the offboarding is not completed", func() {
+		client.report.Status = grpc_user_manager_go.OffboardingStatus_IN_PROGRESS
+		client.report.Failures = []string{"role role: failed"}
+		runner := NewRunner(Config{UserManagerAddress: "localhost:8920", OrganizationId: "org", Token: "token"})
+		err := runner.offboard(client, output)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(output.String()).To(gomega.ContainSubstring("Failures: 1\n  role role: failed\n"))
+	})
+
+	ginkgo.It("should return the errors of the user manager", func() {
+		client.err = status.Error(codes.PermissionDenied, "invalid confirmation token")
+		runner := NewRunner(Config{UserManagerAddress: "localhost:8920", OrganizationId: "org", Token: "token"})
+		err := runner.offboard(client, output)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(output.String()).To(gomega.BeEmpty())
+	})
+})
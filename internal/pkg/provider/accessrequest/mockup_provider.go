@@ -0,0 +1,109 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accessrequest
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupAccessRequestProvider is an in-memory implementation of the access request provider.
+type MockupAccessRequestProvider struct {
+	sync.Mutex
+	// requests indexed by organization_id and access_request_id.
+	requests map[string]map[string]entities.AccessRequest
+}
+
+// NewMockupAccessRequestProvider creates an empty in-memory provider.
+func NewMockupAccessRequestProvider() *MockupAccessRequestProvider {
+	return &MockupAccessRequestProvider{
+		requests: make(map[string]map[string]entities.AccessRequest, 0),
+	}
+}
+
+// Add a new access request.
+func (m *MockupAccessRequestProvider) Add(request entities.AccessRequest) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	requests, exists := m.requests[request.OrganizationId]
+	if !exists {
+		requests = make(map[string]entities.AccessRequest, 0)
+		m.requests[request.OrganizationId] = requests
+	}
+	if _, exists = requests[request.AccessRequestId]; exists {
+		return derrors.NewAlreadyExistsError("access request").WithParams(request.OrganizationId, request.AccessRequestId)
+	}
+	requests[request.AccessRequestId] = request
+	return nil
+}
+
+// Get an access request.
+func (m *MockupAccessRequestProvider) Get(organizationID string, accessRequestID string) (*entities.AccessRequest, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	request, exists := m.requests[organizationID][accessRequestID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("access request").WithParams(organizationID, accessRequestID)
+	}
+	return &request, nil
+}
+
+// Update an existing access request.
+func (m *MockupAccessRequestProvider) Update(request entities.AccessRequest) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.requests[request.OrganizationId][request.AccessRequestId]; !exists {
+		return derrors.NewNotFoundError("access request").WithParams(request.OrganizationId, request.AccessRequestId)
+	}
+	m.requests[request.OrganizationId][request.AccessRequestId] = request
+	return nil
+}
+
+// List the access requests of an organization.
+func (m *MockupAccessRequestProvider) List(organizationID string) ([]entities.AccessRequest, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.AccessRequest, 0)
+	for _, request := range m.requests[organizationID] {
+		result = append(result, request)
+	}
+	return result, nil
+}
+
+// ListExpired lists the pending requests of all the organizations that expired before a given timestamp.
+func (m *MockupAccessRequestProvider) ListExpired(timestamp int64) ([]entities.AccessRequest, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.AccessRequest, 0)
+	for _, requests := range m.requests {
+		for _, request := range requests {
+			if request.IsExpired(timestamp) {
+				result = append(result, request)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Clear all the access requests.
+func (m *MockupAccessRequestProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.requests = make(map[string]map[string]entities.AccessRequest, 0)
+	return nil
+}
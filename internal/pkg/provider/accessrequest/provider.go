@@ -0,0 +1,38 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accessrequest
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the access requests.
+type Provider interface {
+	// Add a new access request.
+	Add(request entities.AccessRequest) derrors.Error
+	// Get an access request.
+	Get(organizationID string, accessRequestID string) (*entities.AccessRequest, derrors.Error)
+	// Update an existing access request.
+	Update(request entities.AccessRequest) derrors.Error
+	// List the access requests of an organization.
+	List(organizationID string) ([]entities.AccessRequest, derrors.Error)
+	// ListExpired lists the pending requests of all the organizations that expired before a given timestamp.
+	ListExpired(timestamp int64) ([]entities.AccessRequest, derrors.Error)
+	// Clear all the access requests.
+	Clear() derrors.Error
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accessrequest
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createAccessRequest(organizationID string, accessRequestID string, expiresAt int64) entities.AccessRequest {
+	return entities.AccessRequest{
+		OrganizationId:  organizationID,
+		AccessRequestId: accessRequestID,
+		Email:           "user@mail.com",
+		RoleId:          "admin",
+		Justification:   "incident",
+		GrantDuration:   3600,
+		State:           grpc_user_manager_go.AccessRequestState_PENDING,
+		Created:         1,
+		ExpiresAt:       expiresAt,
+	}
+}
+
+// RunTest registers the tests that every access request provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to add and retrieve an access request", func() {
+		request := createAccessRequest("org", "request1", 10)
+		gomega.Expect(provider.Add(request)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "request1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(request))
+
+		err = provider.Add(request)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+
+		_, err = provider.Get("org", "missing")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to update an access request", func() {
+		request := createAccessRequest("org", "request1", 10)
+		gomega.Expect(provider.Add(request)).To(gomega.Succeed())
+
+		request.State = grpc_user_manager_go.AccessRequestState_APPROVED
+		request.ResolvedBy = "owner@mail.com"
+		request.ResolvedAt = 5
+		request.Comment = "approved"
+		gomega.Expect(provider.Update(request)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "request1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(request))
+
+		err = provider.Update(createAccessRequest("org", "missing", 10))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to list the access requests of an organization", func() {
+		gomega.Expect(provider.Add(createAccessRequest("org", "request1", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createAccessRequest("org", "request2", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createAccessRequest("other", "request3", 10))).To(gomega.Succeed())
+
+		requests, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(requests)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should list the expired pending requests of all the organizations", func() {
+		gomega.Expect(provider.Add(createAccessRequest("org", "request1", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createAccessRequest("org", "request2", 30))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createAccessRequest("other", "request3", 20))).To(gomega.Succeed())
+		resolved := createAccessRequest("other", "request4", 10)
+		resolved.State = grpc_user_manager_go.AccessRequestState_DENIED
+		gomega.Expect(provider.Add(resolved)).To(gomega.Succeed())
+
+		expired, err := provider.ListExpired(20)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(expired)).Should(gomega.Equal(2))
+		for _, request := range expired {
+			gomega.Expect(request.State).Should(gomega.Equal(grpc_user_manager_go.AccessRequestState_PENDING))
+			gomega.Expect(request.ExpiresAt).Should(gomega.BeNumerically("<=", 20))
+		}
+	})
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accessrequest
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const accessRequestsTable = "access_requests"
+
+const accessRequestColumns = "organization_id, access_request_id, email, role_id, justification, grant_duration, state, created, expires_at, resolved_by, resolved_at, comment"
+
+// ScyllaAccessRequestProvider is a ScyllaDB implementation of the access request provider.
+type ScyllaAccessRequestProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaAccessRequestProvider creates a provider that stores the requests in the keyspace of a session.
+func NewScyllaAccessRequestProvider(session *scylladb.Session) *ScyllaAccessRequestProvider {
+	return &ScyllaAccessRequestProvider{session: session}
+}
+
+// accessRequestValues returns the values of the columns of a request.
+func accessRequestValues(request entities.AccessRequest) []interface{} {
+	return []interface{}{request.OrganizationId, request.AccessRequestId, request.Email, request.RoleId,
+		request.Justification, request.GrantDuration, int32(request.State), request.Created, request.ExpiresAt,
+		request.ResolvedBy, request.ResolvedAt, request.Comment}
+}
+
+// scanAccessRequest reads a request from the columns of a row.
+func scanAccessRequest(scan func(dest ...interface{}) error) (*entities.AccessRequest, error) {
+	var request entities.AccessRequest
+	var state int32
+	err := scan(&request.OrganizationId, &request.AccessRequestId, &request.Email, &request.RoleId,
+		&request.Justification, &request.GrantDuration, &state, &request.Created, &request.ExpiresAt,
+		&request.ResolvedBy, &request.ResolvedAt, &request.Comment)
+	if err != nil {
+		return nil, err
+	}
+	request.State = grpc_user_manager_go.AccessRequestState(state)
+	return &request, nil
+}
+
+// Add a new access request.
+func (sp *ScyllaAccessRequestProvider) Add(request entities.AccessRequest) derrors.Error {
+	applied, err := sp.session.ExecCAS("INSERT INTO "+accessRequestsTable+" ("+accessRequestColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS",
+		accessRequestValues(request)...)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("access request").WithParams(request.OrganizationId, request.AccessRequestId)
+	}
+	return nil
+}
+
+// Get an access request.
+func (sp *ScyllaAccessRequestProvider) Get(organizationID string, accessRequestID string) (*entities.AccessRequest, derrors.Error) {
+	requests, err := sp.list("SELECT "+accessRequestColumns+" FROM "+accessRequestsTable+" WHERE organization_id = ? AND access_request_id = ?",
+		[]interface{}{organizationID, accessRequestID}, func(request entities.AccessRequest) bool {
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, derrors.NewNotFoundError("access request").WithParams(organizationID, accessRequestID)
+	}
+	return &requests[0], nil
+}
+
+// Update an existing access request.
+func (sp *ScyllaAccessRequestProvider) Update(request entities.AccessRequest) derrors.Error {
+	applied, err := sp.session.ExecCAS("UPDATE "+accessRequestsTable+" SET email = ?, role_id = ?, justification = ?, grant_duration = ?, state = ?, created = ?, expires_at = ?, resolved_by = ?, resolved_at = ?, comment = ? WHERE organization_id = ? AND access_request_id = ? IF EXISTS",
+		append(accessRequestValues(request)[2:], request.OrganizationId, request.AccessRequestId)...)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("access request").WithParams(request.OrganizationId, request.AccessRequestId)
+	}
+	return nil
+}
+
+// list the requests returned by a query that satisfy a filter.
+func (sp *ScyllaAccessRequestProvider) list(stmt string, values []interface{}, filter func(request entities.AccessRequest) bool) ([]entities.AccessRequest, derrors.Error) {
+	result := make([]entities.AccessRequest, 0)
+	err := sp.session.Iterate(stmt, values, func(scanner gocql.Scanner) error {
+		request, sErr := scanAccessRequest(scanner.Scan)
+		if sErr == nil && filter(*request) {
+			result = append(result, *request)
+		}
+		return sErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// List the access requests of an organization.
+func (sp *ScyllaAccessRequestProvider) List(organizationID string) ([]entities.AccessRequest, derrors.Error) {
+	return sp.list("SELECT "+accessRequestColumns+" FROM "+accessRequestsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(request entities.AccessRequest) bool {
+			return true
+		})
+}
+
+// ListExpired lists the pending requests of all the organizations that expired before a given timestamp. The
+// expiration is not indexed, so all the requests are read.
+func (sp *ScyllaAccessRequestProvider) ListExpired(timestamp int64) ([]entities.AccessRequest, derrors.Error) {
+	return sp.list("SELECT "+accessRequestColumns+" FROM "+accessRequestsTable, nil, func(request entities.AccessRequest) bool {
+		return request.IsExpired(timestamp)
+	})
+}
+
+// Clear all the access requests.
+func (sp *ScyllaAccessRequestProvider) Clear() derrors.Error {
+	return sp.session.Truncate(accessRequestsTable)
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+RUN_INTEGRATION_TEST=true
+IT_SCYLLA_HOST=127.0.0.1
+IT_SCYLLA_PORT=9042
+IT_KEYSPACE=user_manager
+*/
+
+package accessrequest
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/rs/zerolog/log"
+	"os"
+	"strconv"
+)
+
+var _ = ginkgo.Describe("Scylla access request provider", func() {
+
+	if !utils.RunIntegrationTests() {
+		log.Warn().Msg("Integration tests are skipped")
+		return
+	}
+
+	var (
+		scyllaHost = os.Getenv("IT_SCYLLA_HOST")
+		scyllaPort = os.Getenv("IT_SCYLLA_PORT")
+		keyspace   = os.Getenv("IT_KEYSPACE")
+		port, pErr = strconv.Atoi(scyllaPort)
+	)
+
+	if scyllaHost == "" || pErr != nil || keyspace == "" {
+		ginkgo.Fail("missing environment variables")
+	}
+
+	RunTest(NewScyllaAccessRequestProvider(scylladb.NewSession(scyllaHost, port, keyspace)))
+})
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupAuditProvider is an in-memory implementation of the audit provider.
+type MockupAuditProvider struct {
+	sync.Mutex
+	// entries indexed by organization_id.
+	entries map[string][]entities.AuditEntry
+}
+
+// NewMockupAuditProvider creates an empty in-memory provider.
+func NewMockupAuditProvider() *MockupAuditProvider {
+	return &MockupAuditProvider{
+		entries: make(map[string][]entities.AuditEntry, 0),
+	}
+}
+
+// Add an entry to the log.
+func (m *MockupAuditProvider) Add(entry entities.AuditEntry) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.entries[entry.OrganizationId] = append(m.entries[entry.OrganizationId], entry)
+	return nil
+}
+
+// List the entries of an organization in chronological order.
+func (m *MockupAuditProvider) List(organizationID string) ([]entities.AuditEntry, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	return append([]entities.AuditEntry{}, m.entries[organizationID]...), nil
+}
+
+// Clear all the entries.
+func (m *MockupAuditProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.entries = make(map[string][]entities.AuditEntry, 0)
+	return nil
+}
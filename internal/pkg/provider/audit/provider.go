@@ -0,0 +1,32 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the audit log.
+type Provider interface {
+	// Add an entry to the log.
+	Add(entry entities.AuditEntry) derrors.Error
+	// List the entries of an organization in chronological order.
+	List(organizationID string) ([]entities.AuditEntry, derrors.Error)
+	// Clear all the entries.
+	Clear() derrors.Error
+}
@@ -0,0 +1,58 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every audit provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should list the entries of an organization in chronological order", func() {
+		for _, timestamp := range []int64{10, 20, 20, 30} {
+			gomega.Expect(provider.Add(entities.AuditEntry{
+				OrganizationId: "org",
+				Timestamp:      timestamp,
+				Action:         "action",
+				Email:          "user@mail.com",
+				Details:        "details",
+			})).To(gomega.Succeed())
+		}
+		gomega.Expect(provider.Add(entities.AuditEntry{OrganizationId: "other", Timestamp: 10})).To(gomega.Succeed())
+
+		entries, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(entries)).Should(gomega.Equal(4))
+		for index, timestamp := range []int64{10, 20, 20, 30} {
+			gomega.Expect(entries[index].Timestamp).Should(gomega.Equal(timestamp))
+			gomega.Expect(entries[index].Action).Should(gomega.Equal("action"))
+		}
+	})
+
+	ginkgo.It("should return an empty log for an organization without entries", func() {
+		entries, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(entries).Should(gomega.BeEmpty())
+	})
+}
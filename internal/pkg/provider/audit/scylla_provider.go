@@ -0,0 +1,66 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const auditEntriesTable = "audit_entries"
+
+// ScyllaAuditProvider is a ScyllaDB implementation of the audit provider. The entries of an organization are
+// clustered by timestamp, with a time UUID to keep apart the entries of the same second.
+type ScyllaAuditProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaAuditProvider creates a provider that stores the log in the keyspace of a session.
+func NewScyllaAuditProvider(session *scylladb.Session) *ScyllaAuditProvider {
+	return &ScyllaAuditProvider{session: session}
+}
+
+// Add an entry to the log.
+func (sp *ScyllaAuditProvider) Add(entry entities.AuditEntry) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+auditEntriesTable+" (organization_id, timestamp, entry_id, action, email, details) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.OrganizationId, entry.Timestamp, gocql.TimeUUID(), entry.Action, entry.Email, entry.Details)
+}
+
+// List the entries of an organization in chronological order.
+func (sp *ScyllaAuditProvider) List(organizationID string) ([]entities.AuditEntry, derrors.Error) {
+	result := make([]entities.AuditEntry, 0)
+	err := sp.session.Iterate("SELECT organization_id, timestamp, action, email, details FROM "+auditEntriesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var entry entities.AuditEntry
+			sErr := scanner.Scan(&entry.OrganizationId, &entry.Timestamp, &entry.Action, &entry.Email, &entry.Details)
+			if sErr == nil {
+				result = append(result, entry)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Clear all the entries.
+func (sp *ScyllaAuditProvider) Clear() derrors.Error {
+	return sp.session.Truncate(auditEntriesTable)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package claimrules
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+// Load reads a YAML file with the claim rules of each organization and stores them in the provider.
+func Load(path string, provider Provider) derrors.Error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return derrors.AsError(err, "cannot read claim rules file")
+	}
+	rules := make([]entities.ClaimRules, 0)
+	err = yaml.UnmarshalStrict(content, &rules)
+	if err != nil {
+		return derrors.NewInvalidArgumentError("cannot decode claim rules file", err)
+	}
+	for index := range rules {
+		vErr := entities.ValidClaimRules(&rules[index])
+		if vErr != nil {
+			return vErr
+		}
+		aErr := provider.Add(rules[index])
+		if aErr != nil {
+			return aErr
+		}
+	}
+	return nil
+}
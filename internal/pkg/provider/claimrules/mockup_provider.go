@@ -0,0 +1,76 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package claimrules
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupClaimRulesProvider is an in-memory implementation of the claim rules provider.
+type MockupClaimRulesProvider struct {
+	sync.Mutex
+	// rules indexed by organization_id.
+	rules map[string]entities.ClaimRules
+}
+
+// NewMockupClaimRulesProvider creates an empty in-memory provider.
+func NewMockupClaimRulesProvider() *MockupClaimRulesProvider {
+	return &MockupClaimRulesProvider{
+		rules: make(map[string]entities.ClaimRules, 0),
+	}
+}
+
+// Add or replace the claim rules of an organization.
+func (m *MockupClaimRulesProvider) Add(rules entities.ClaimRules) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	rules.RoleRules = append([]entities.RoleRule{}, rules.RoleRules...)
+	m.rules[rules.OrganizationId] = rules
+	return nil
+}
+
+// Get the claim rules of an organization.
+func (m *MockupClaimRulesProvider) Get(organizationID string) (*entities.ClaimRules, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	rules, exists := m.rules[organizationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("claim rules").WithParams(organizationID)
+	}
+	return &rules, nil
+}
+
+// Remove the claim rules of an organization.
+func (m *MockupClaimRulesProvider) Remove(organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.rules[organizationID]; !exists {
+		return derrors.NewNotFoundError("claim rules").WithParams(organizationID)
+	}
+	delete(m.rules, organizationID)
+	return nil
+}
+
+// Clear all the claim rules.
+func (m *MockupClaimRulesProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.rules = make(map[string]entities.ClaimRules, 0)
+	return nil
+}
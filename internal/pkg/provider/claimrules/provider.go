@@ -0,0 +1,34 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package claimrules
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the claim rules of the organizations.
+type Provider interface {
+	// Add or replace the claim rules of an organization.
+	Add(rules entities.ClaimRules) derrors.Error
+	// Get the claim rules of an organization.
+	Get(organizationID string) (*entities.ClaimRules, derrors.Error)
+	// Remove the claim rules of an organization.
+	Remove(organizationID string) derrors.Error
+	// Clear all the claim rules.
+	Clear() derrors.Error
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package domainpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupEmailDomainPolicyProvider is an in-memory implementation of the email domain policy provider.
+type MockupEmailDomainPolicyProvider struct {
+	sync.Mutex
+	// policies indexed by organization_id.
+	policies map[string]entities.EmailDomainPolicy
+}
+
+// NewMockupEmailDomainPolicyProvider creates an empty in-memory provider.
+func NewMockupEmailDomainPolicyProvider() *MockupEmailDomainPolicyProvider {
+	return &MockupEmailDomainPolicyProvider{
+		policies: make(map[string]entities.EmailDomainPolicy, 0),
+	}
+}
+
+// Set the policy of an organization. A previous policy of the same organization is replaced.
+func (m *MockupEmailDomainPolicyProvider) Set(policy entities.EmailDomainPolicy) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.policies[policy.OrganizationId] = policy
+	return nil
+}
+
+// Get the policy of an organization.
+func (m *MockupEmailDomainPolicyProvider) Get(organizationID string) (*entities.EmailDomainPolicy, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	policy, exists := m.policies[organizationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("email domain policy").WithParams(organizationID)
+	}
+	return &policy, nil
+}
+
+// Remove the policy of an organization.
+func (m *MockupEmailDomainPolicyProvider) Remove(organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.policies[organizationID]; !exists {
+		return derrors.NewNotFoundError("email domain policy").WithParams(organizationID)
+	}
+	delete(m.policies, organizationID)
+	return nil
+}
+
+// Clear all the policies.
+func (m *MockupEmailDomainPolicyProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.policies = make(map[string]entities.EmailDomainPolicy, 0)
+	return nil
+}
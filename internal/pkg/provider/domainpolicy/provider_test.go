@@ -0,0 +1,85 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package domainpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every email domain policy provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove a policy", func() {
+		policy := entities.EmailDomainPolicy{
+			OrganizationId: "org",
+			AllowedDomains: []string{"mail.com", "*.mail.com"},
+			DeniedDomains:  []string{"spam.mail.com"},
+		}
+		gomega.Expect(provider.Set(policy)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(policy))
+
+		gomega.Expect(provider.Remove("org")).To(gomega.Succeed())
+		_, err = provider.Get("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.Remove("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous policy of the organization", func() {
+		gomega.Expect(provider.Set(entities.EmailDomainPolicy{
+			OrganizationId: "org",
+			AllowedDomains: []string{"mail.com"},
+			DeniedDomains:  []string{"spam.com"},
+		})).To(gomega.Succeed())
+		gomega.Expect(provider.Set(entities.EmailDomainPolicy{
+			OrganizationId: "org",
+			AllowedDomains: []string{"other.com"},
+			DeniedDomains:  []string{"spam.com"},
+		})).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.AllowedDomains).Should(gomega.Equal([]string{"other.com"}))
+	})
+
+	ginkgo.It("should keep the policies of the organizations apart", func() {
+		gomega.Expect(provider.Set(entities.EmailDomainPolicy{
+			OrganizationId: "org1",
+			AllowedDomains: []string{"mail.com"},
+			DeniedDomains:  []string{"spam.com"},
+		})).To(gomega.Succeed())
+
+		_, err := provider.Get("org2")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		gomega.Expect(provider.Remove("org2")).NotTo(gomega.Succeed())
+		_, err = provider.Get("org1")
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package domainpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const domainPoliciesTable = "email_domain_policies"
+
+// ScyllaEmailDomainPolicyProvider is a ScyllaDB implementation of the email domain policy provider.
+type ScyllaEmailDomainPolicyProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaEmailDomainPolicyProvider creates a provider that stores the policies in the keyspace of a session.
+func NewScyllaEmailDomainPolicyProvider(session *scylladb.Session) *ScyllaEmailDomainPolicyProvider {
+	return &ScyllaEmailDomainPolicyProvider{session: session}
+}
+
+// Set the policy of an organization. A previous policy of the same organization is replaced.
+func (sp *ScyllaEmailDomainPolicyProvider) Set(policy entities.EmailDomainPolicy) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+domainPoliciesTable+" (organization_id, allowed_domains, denied_domains) VALUES (?, ?, ?)",
+		policy.OrganizationId, policy.AllowedDomains, policy.DeniedDomains)
+}
+
+// Get the policy of an organization.
+func (sp *ScyllaEmailDomainPolicyProvider) Get(organizationID string) (*entities.EmailDomainPolicy, derrors.Error) {
+	var policy entities.EmailDomainPolicy
+	found, err := sp.session.Scan("SELECT organization_id, allowed_domains, denied_domains FROM "+domainPoliciesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, &policy.OrganizationId, &policy.AllowedDomains, &policy.DeniedDomains)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("email domain policy").WithParams(organizationID)
+	}
+	return &policy, nil
+}
+
+// Remove the policy of an organization.
+func (sp *ScyllaEmailDomainPolicyProvider) Remove(organizationID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+domainPoliciesTable+" WHERE organization_id = ? IF EXISTS", organizationID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("email domain policy").WithParams(organizationID)
+	}
+	return nil
+}
+
+// Clear all the policies.
+func (sp *ScyllaEmailDomainPolicyProvider) Clear() derrors.Error {
+	return sp.session.Truncate(domainPoliciesTable)
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package emailchange
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sort"
+	"sync"
+)
+
+// MockupEmailChangeProvider is an in-memory implementation of the email change provider.
+type MockupEmailChangeProvider struct {
+	sync.Mutex
+	// changes indexed by organization_id and email.
+	changes map[string]map[string]entities.EmailChange
+	// aliases indexed by organization_id and former email.
+	aliases map[string]map[string]entities.EmailAlias
+}
+
+// NewMockupEmailChangeProvider creates an empty in-memory provider.
+func NewMockupEmailChangeProvider() *MockupEmailChangeProvider {
+	return &MockupEmailChangeProvider{
+		changes: make(map[string]map[string]entities.EmailChange, 0),
+		aliases: make(map[string]map[string]entities.EmailAlias, 0),
+	}
+}
+
+// SetChange sets the pending change of a user. A previous change of the same user is replaced.
+func (m *MockupEmailChangeProvider) SetChange(change entities.EmailChange) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	organization, exists := m.changes[change.OrganizationId]
+	if !exists {
+		organization = make(map[string]entities.EmailChange, 0)
+		m.changes[change.OrganizationId] = organization
+	}
+	organization[change.Email] = change
+	return nil
+}
+
+// GetChange retrieves the pending change of a user.
+func (m *MockupEmailChangeProvider) GetChange(organizationID string, email string) (*entities.EmailChange, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	change, exists := m.changes[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("email change").WithParams(organizationID, email)
+	}
+	return &change, nil
+}
+
+// RemoveChange removes the pending change of a user.
+func (m *MockupEmailChangeProvider) RemoveChange(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.changes[organizationID][email]; !exists {
+		return derrors.NewNotFoundError("email change").WithParams(organizationID, email)
+	}
+	delete(m.changes[organizationID], email)
+	return nil
+}
+
+// ListChanges lists the pending changes of the users of an organization sorted by email.
+func (m *MockupEmailChangeProvider) ListChanges(organizationID string) ([]entities.EmailChange, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.EmailChange, 0, len(m.changes[organizationID]))
+	for _, change := range m.changes[organizationID] {
+		result = append(result, change)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Email < result[j].Email
+	})
+	return result, nil
+}
+
+// SetAlias sets the alias of a former email. A previous alias of the same email is replaced.
+func (m *MockupEmailChangeProvider) SetAlias(alias entities.EmailAlias) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	organization, exists := m.aliases[alias.OrganizationId]
+	if !exists {
+		organization = make(map[string]entities.EmailAlias, 0)
+		m.aliases[alias.OrganizationId] = organization
+	}
+	organization[alias.Email] = alias
+	return nil
+}
+
+// GetAlias retrieves the alias of a former email.
+func (m *MockupEmailChangeProvider) GetAlias(organizationID string, email string) (*entities.EmailAlias, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	alias, exists := m.aliases[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("email alias").WithParams(organizationID, email)
+	}
+	return &alias, nil
+}
+
+// RemoveAlias removes the alias of a former email.
+func (m *MockupEmailChangeProvider) RemoveAlias(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.aliases[organizationID][email]; !exists {
+		return derrors.NewNotFoundError("email alias").WithParams(organizationID, email)
+	}
+	delete(m.aliases[organizationID], email)
+	return nil
+}
+
+// ListAliases lists the aliases of the former emails of an organization sorted by email.
+func (m *MockupEmailChangeProvider) ListAliases(organizationID string) ([]entities.EmailAlias, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.EmailAlias, 0, len(m.aliases[organizationID]))
+	for _, alias := range m.aliases[organizationID] {
+		result = append(result, alias)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Email < result[j].Email
+	})
+	return result, nil
+}
+
+// Clear all the changes and aliases.
+func (m *MockupEmailChangeProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.changes = make(map[string]map[string]entities.EmailChange, 0)
+	m.aliases = make(map[string]map[string]entities.EmailAlias, 0)
+	return nil
+}
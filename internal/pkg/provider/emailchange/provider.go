@@ -0,0 +1,44 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package emailchange
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the pending email changes and the aliases of the completed ones.
+type Provider interface {
+	// SetChange sets the pending change of a user. A previous change of the same user is replaced.
+	SetChange(change entities.EmailChange) derrors.Error
+	// GetChange retrieves the pending change of a user.
+	GetChange(organizationID string, email string) (*entities.EmailChange, derrors.Error)
+	// RemoveChange removes the pending change of a user.
+	RemoveChange(organizationID string, email string) derrors.Error
+	// ListChanges lists the pending changes of the users of an organization sorted by email.
+	ListChanges(organizationID string) ([]entities.EmailChange, derrors.Error)
+	// SetAlias sets the alias of a former email. A previous alias of the same email is replaced.
+	SetAlias(alias entities.EmailAlias) derrors.Error
+	// GetAlias retrieves the alias of a former email.
+	GetAlias(organizationID string, email string) (*entities.EmailAlias, derrors.Error)
+	// RemoveAlias removes the alias of a former email.
+	RemoveAlias(organizationID string, email string) derrors.Error
+	// ListAliases lists the aliases of the former emails of an organization sorted by email.
+	ListAliases(organizationID string) ([]entities.EmailAlias, derrors.Error)
+	// Clear all the changes and aliases.
+	Clear() derrors.Error
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package emailchange
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every email change provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove a change", func() {
+		change := entities.EmailChange{
+			OrganizationId: "org",
+			Email:          "user@mail.com",
+			NewEmail:       "new@mail.com",
+			TokenHash:      "hash",
+			ExpiresAt:      10,
+		}
+		gomega.Expect(provider.SetChange(change)).To(gomega.Succeed())
+
+		retrieved, err := provider.GetChange("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(change))
+
+		change.NewEmail = "other@mail.com"
+		gomega.Expect(provider.SetChange(change)).To(gomega.Succeed())
+		retrieved, err = provider.GetChange("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.NewEmail).Should(gomega.Equal("other@mail.com"))
+
+		gomega.Expect(provider.RemoveChange("org", "user@mail.com")).To(gomega.Succeed())
+		_, err = provider.GetChange("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.RemoveChange("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove an alias", func() {
+		alias := entities.EmailAlias{
+			OrganizationId: "org",
+			Email:          "old@mail.com",
+			NewEmail:       "user@mail.com",
+			ExpiresAt:      10,
+		}
+		gomega.Expect(provider.SetAlias(alias)).To(gomega.Succeed())
+
+		retrieved, err := provider.GetAlias("org", "old@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(alias))
+		_, err = provider.GetChange("org", "old@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		gomega.Expect(provider.RemoveAlias("org", "old@mail.com")).To(gomega.Succeed())
+		_, err = provider.GetAlias("org", "old@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.RemoveAlias("org", "old@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should list the changes and aliases of an organization", func() {
+		for _, email := range []string{"b@mail.com", "a@mail.com"} {
+			gomega.Expect(provider.SetChange(entities.EmailChange{OrganizationId: "org", Email: email, NewEmail: "new-" + email})).To(gomega.Succeed())
+			gomega.Expect(provider.SetAlias(entities.EmailAlias{OrganizationId: "org", Email: "old-" + email, NewEmail: email})).To(gomega.Succeed())
+		}
+		gomega.Expect(provider.SetChange(entities.EmailChange{OrganizationId: "other", Email: "c@mail.com"})).To(gomega.Succeed())
+		gomega.Expect(provider.SetAlias(entities.EmailAlias{OrganizationId: "other", Email: "old-c@mail.com"})).To(gomega.Succeed())
+
+		changes, err := provider.ListChanges("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(changes).To(gomega.HaveLen(2))
+		gomega.Expect(changes[0].Email).Should(gomega.Equal("a@mail.com"))
+		gomega.Expect(changes[1].Email).Should(gomega.Equal("b@mail.com"))
+
+		aliases, err := provider.ListAliases("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(aliases).To(gomega.HaveLen(2))
+		gomega.Expect(aliases[0].Email).Should(gomega.Equal("old-a@mail.com"))
+		gomega.Expect(aliases[1].Email).Should(gomega.Equal("old-b@mail.com"))
+
+		changes, err = provider.ListChanges("empty")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(changes).To(gomega.BeEmpty())
+	})
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package emailchange
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const emailChangesTable = "email_changes"
+
+const emailAliasesTable = "email_aliases"
+
+// ScyllaEmailChangeProvider is a ScyllaDB implementation of the email change provider.
+type ScyllaEmailChangeProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaEmailChangeProvider creates a provider that stores the changes and aliases in the keyspace of a session.
+func NewScyllaEmailChangeProvider(session *scylladb.Session) *ScyllaEmailChangeProvider {
+	return &ScyllaEmailChangeProvider{session: session}
+}
+
+// SetChange sets the pending change of a user. A previous change of the same user is replaced.
+func (sp *ScyllaEmailChangeProvider) SetChange(change entities.EmailChange) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+emailChangesTable+" (organization_id, email, new_email, token_hash, expires_at) VALUES (?, ?, ?, ?, ?)",
+		change.OrganizationId, change.Email, change.NewEmail, change.TokenHash, change.ExpiresAt)
+}
+
+// GetChange retrieves the pending change of a user.
+func (sp *ScyllaEmailChangeProvider) GetChange(organizationID string, email string) (*entities.EmailChange, derrors.Error) {
+	var change entities.EmailChange
+	found, err := sp.session.Scan("SELECT organization_id, email, new_email, token_hash, expires_at FROM "+emailChangesTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &change.OrganizationId, &change.Email, &change.NewEmail, &change.TokenHash, &change.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("email change").WithParams(organizationID, email)
+	}
+	return &change, nil
+}
+
+// RemoveChange removes the pending change of a user.
+func (sp *ScyllaEmailChangeProvider) RemoveChange(organizationID string, email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+emailChangesTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("email change").WithParams(organizationID, email)
+	}
+	return nil
+}
+
+// ListChanges lists the pending changes of the users of an organization sorted by email.
+func (sp *ScyllaEmailChangeProvider) ListChanges(organizationID string) ([]entities.EmailChange, derrors.Error) {
+	result := make([]entities.EmailChange, 0)
+	err := sp.session.Iterate("SELECT organization_id, email, new_email, token_hash, expires_at FROM "+emailChangesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var change entities.EmailChange
+			sErr := scanner.Scan(&change.OrganizationId, &change.Email, &change.NewEmail, &change.TokenHash, &change.ExpiresAt)
+			if sErr == nil {
+				result = append(result, change)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetAlias sets the alias of a former email. A previous alias of the same email is replaced.
+func (sp *ScyllaEmailChangeProvider) SetAlias(alias entities.EmailAlias) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+emailAliasesTable+" (organization_id, email, new_email, expires_at) VALUES (?, ?, ?, ?)",
+		alias.OrganizationId, alias.Email, alias.NewEmail, alias.ExpiresAt)
+}
+
+// GetAlias retrieves the alias of a former email.
+func (sp *ScyllaEmailChangeProvider) GetAlias(organizationID string, email string) (*entities.EmailAlias, derrors.Error) {
+	var alias entities.EmailAlias
+	found, err := sp.session.Scan("SELECT organization_id, email, new_email, expires_at FROM "+emailAliasesTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &alias.OrganizationId, &alias.Email, &alias.NewEmail, &alias.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("email alias").WithParams(organizationID, email)
+	}
+	return &alias, nil
+}
+
+// RemoveAlias removes the alias of a former email.
+func (sp *ScyllaEmailChangeProvider) RemoveAlias(organizationID string, email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+emailAliasesTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("email alias").WithParams(organizationID, email)
+	}
+	return nil
+}
+
+// ListAliases lists the aliases of the former emails of an organization sorted by email.
+func (sp *ScyllaEmailChangeProvider) ListAliases(organizationID string) ([]entities.EmailAlias, derrors.Error) {
+	result := make([]entities.EmailAlias, 0)
+	err := sp.session.Iterate("SELECT organization_id, email, new_email, expires_at FROM "+emailAliasesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var alias entities.EmailAlias
+			sErr := scanner.Scan(&alias.OrganizationId, &alias.Email, &alias.NewEmail, &alias.ExpiresAt)
+			if sErr == nil {
+				result = append(result, alias)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Clear all the changes and aliases.
+func (sp *ScyllaEmailChangeProvider) Clear() derrors.Error {
+	return sp.session.Truncate(emailChangesTable, emailAliasesTable)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package group
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sort"
+	"sync"
+)
+
+// MockupGroupProvider is an in-memory implementation of the group provider.
+type MockupGroupProvider struct {
+	sync.Mutex
+	// groups indexed by organization_id and group_id.
+	groups map[string]map[string]entities.Group
+}
+
+// NewMockupGroupProvider creates an empty in-memory provider.
+func NewMockupGroupProvider() *MockupGroupProvider {
+	return &MockupGroupProvider{
+		groups: make(map[string]map[string]entities.Group, 0),
+	}
+}
+
+// copyGroup returns a copy of a group that does not share the members with the original one.
+func copyGroup(group entities.Group) entities.Group {
+	group.Members = append([]string{}, group.Members...)
+	return group
+}
+
+// AddGroup adds a new group.
+func (m *MockupGroupProvider) AddGroup(group entities.Group) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	organization, exists := m.groups[group.OrganizationId]
+	if !exists {
+		organization = make(map[string]entities.Group, 0)
+		m.groups[group.OrganizationId] = organization
+	}
+	if _, exists := organization[group.GroupId]; exists {
+		return derrors.NewAlreadyExistsError("group").WithParams(group.OrganizationId, group.GroupId)
+	}
+	organization[group.GroupId] = copyGroup(group)
+	return nil
+}
+
+// GetGroup retrieves a group.
+func (m *MockupGroupProvider) GetGroup(organizationID string, groupID string) (*entities.Group, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	group, exists := m.groups[organizationID][groupID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("group").WithParams(organizationID, groupID)
+	}
+	result := copyGroup(group)
+	return &result, nil
+}
+
+// ListGroups lists the groups of an organization sorted by name.
+func (m *MockupGroupProvider) ListGroups(organizationID string) ([]entities.Group, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.Group, 0, len(m.groups[organizationID]))
+	for _, group := range m.groups[organizationID] {
+		result = append(result, copyGroup(group))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// UpdateGroup replaces an existing group.
+func (m *MockupGroupProvider) UpdateGroup(group entities.Group) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.groups[group.OrganizationId][group.GroupId]; !exists {
+		return derrors.NewNotFoundError("group").WithParams(group.OrganizationId, group.GroupId)
+	}
+	m.groups[group.OrganizationId][group.GroupId] = copyGroup(group)
+	return nil
+}
+
+// RemoveGroup removes a group.
+func (m *MockupGroupProvider) RemoveGroup(organizationID string, groupID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.groups[organizationID][groupID]; !exists {
+		return derrors.NewNotFoundError("group").WithParams(organizationID, groupID)
+	}
+	delete(m.groups[organizationID], groupID)
+	return nil
+}
+
+// Clear all the groups.
+func (m *MockupGroupProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.groups = make(map[string]map[string]entities.Group, 0)
+	return nil
+}
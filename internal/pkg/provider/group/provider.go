@@ -0,0 +1,38 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package group
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the groups of users of the organizations.
+type Provider interface {
+	// AddGroup adds a new group.
+	AddGroup(group entities.Group) derrors.Error
+	// GetGroup retrieves a group.
+	GetGroup(organizationID string, groupID string) (*entities.Group, derrors.Error)
+	// ListGroups lists the groups of an organization sorted by name.
+	ListGroups(organizationID string) ([]entities.Group, derrors.Error)
+	// UpdateGroup replaces an existing group.
+	UpdateGroup(group entities.Group) derrors.Error
+	// RemoveGroup removes a group.
+	RemoveGroup(organizationID string, groupID string) derrors.Error
+	// Clear all the groups.
+	Clear() derrors.Error
+}
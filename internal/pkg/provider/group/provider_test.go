@@ -0,0 +1,101 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package group
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createGroup(organizationID string, groupID string, name string) entities.Group {
+	return entities.Group{
+		OrganizationId: organizationID,
+		GroupId:        groupID,
+		Name:           name,
+		Description:    "description",
+		RoleId:         "role",
+		Members:        []string{"user1@mail.com", "user2@mail.com"},
+		Created:        1,
+	}
+}
+
+// RunTest registers the tests that every group provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to add and retrieve a group", func() {
+		group := createGroup("org", "group1", "developers")
+		gomega.Expect(provider.AddGroup(group)).To(gomega.Succeed())
+
+		retrieved, err := provider.GetGroup("org", "group1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(group))
+
+		err = provider.AddGroup(group)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+
+		_, err = provider.GetGroup("org", "missing")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should list the groups of an organization sorted by name", func() {
+		gomega.Expect(provider.AddGroup(createGroup("org", "group1", "operators"))).To(gomega.Succeed())
+		gomega.Expect(provider.AddGroup(createGroup("org", "group2", "developers"))).To(gomega.Succeed())
+		gomega.Expect(provider.AddGroup(createGroup("other", "group3", "admins"))).To(gomega.Succeed())
+
+		groups, err := provider.ListGroups("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(groups)).Should(gomega.Equal(2))
+		gomega.Expect(groups[0].Name).Should(gomega.Equal("developers"))
+		gomega.Expect(groups[1].Name).Should(gomega.Equal("operators"))
+	})
+
+	ginkgo.It("should be able to update a group", func() {
+		group := createGroup("org", "group1", "developers")
+		gomega.Expect(provider.AddGroup(group)).To(gomega.Succeed())
+
+		group.Name = "operators"
+		group.RoleId = "other"
+		group.Members = []string{"user3@mail.com"}
+		gomega.Expect(provider.UpdateGroup(group)).To(gomega.Succeed())
+		retrieved, err := provider.GetGroup("org", "group1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(group))
+
+		err = provider.UpdateGroup(createGroup("org", "missing", "missing"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to remove a group", func() {
+		gomega.Expect(provider.AddGroup(createGroup("org", "group1", "developers"))).To(gomega.Succeed())
+
+		gomega.Expect(provider.RemoveGroup("org", "group1")).To(gomega.Succeed())
+		_, err := provider.GetGroup("org", "group1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		err = provider.RemoveGroup("org", "group1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+}
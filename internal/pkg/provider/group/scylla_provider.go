@@ -0,0 +1,120 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package group
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"sort"
+)
+
+const groupsTable = "user_groups"
+
+const groupColumns = "organization_id, group_id, name, description, role_id, members, created"
+
+// ScyllaGroupProvider is a ScyllaDB implementation of the group provider.
+type ScyllaGroupProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaGroupProvider creates a provider that stores the groups in the keyspace of a session.
+func NewScyllaGroupProvider(session *scylladb.Session) *ScyllaGroupProvider {
+	return &ScyllaGroupProvider{session: session}
+}
+
+// AddGroup adds a new group.
+func (sp *ScyllaGroupProvider) AddGroup(group entities.Group) derrors.Error {
+	applied, err := sp.session.ExecCAS("INSERT INTO "+groupsTable+" ("+groupColumns+") VALUES (?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS",
+		group.OrganizationId, group.GroupId, group.Name, group.Description, group.RoleId, group.Members, group.Created)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("group").WithParams(group.OrganizationId, group.GroupId)
+	}
+	return nil
+}
+
+// GetGroup retrieves a group.
+func (sp *ScyllaGroupProvider) GetGroup(organizationID string, groupID string) (*entities.Group, derrors.Error) {
+	var group entities.Group
+	found, err := sp.session.Scan("SELECT "+groupColumns+" FROM "+groupsTable+" WHERE organization_id = ? AND group_id = ?",
+		[]interface{}{organizationID, groupID}, &group.OrganizationId, &group.GroupId, &group.Name, &group.Description,
+		&group.RoleId, &group.Members, &group.Created)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("group").WithParams(organizationID, groupID)
+	}
+	return &group, nil
+}
+
+// ListGroups lists the groups of an organization sorted by name.
+func (sp *ScyllaGroupProvider) ListGroups(organizationID string) ([]entities.Group, derrors.Error) {
+	result := make([]entities.Group, 0)
+	err := sp.session.Iterate("SELECT "+groupColumns+" FROM "+groupsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var group entities.Group
+			sErr := scanner.Scan(&group.OrganizationId, &group.GroupId, &group.Name, &group.Description, &group.RoleId,
+				&group.Members, &group.Created)
+			if sErr == nil {
+				result = append(result, group)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// UpdateGroup replaces an existing group.
+func (sp *ScyllaGroupProvider) UpdateGroup(group entities.Group) derrors.Error {
+	applied, err := sp.session.ExecCAS("UPDATE "+groupsTable+" SET name = ?, description = ?, role_id = ?, members = ?, created = ? WHERE organization_id = ? AND group_id = ? IF EXISTS",
+		group.Name, group.Description, group.RoleId, group.Members, group.Created, group.OrganizationId, group.GroupId)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("group").WithParams(group.OrganizationId, group.GroupId)
+	}
+	return nil
+}
+
+// RemoveGroup removes a group.
+func (sp *ScyllaGroupProvider) RemoveGroup(organizationID string, groupID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+groupsTable+" WHERE organization_id = ? AND group_id = ? IF EXISTS",
+		organizationID, groupID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("group").WithParams(organizationID, groupID)
+	}
+	return nil
+}
+
+// Clear all the groups.
+func (sp *ScyllaGroupProvider) Clear() derrors.Error {
+	return sp.session.Truncate(groupsTable)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package identity
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupIdentityProvider is an in-memory implementation of the identity provider.
+type MockupIdentityProvider struct {
+	sync.Mutex
+	// identities indexed by email.
+	identities map[string]entities.Identity
+}
+
+// NewMockupIdentityProvider creates an empty in-memory provider.
+func NewMockupIdentityProvider() *MockupIdentityProvider {
+	return &MockupIdentityProvider{
+		identities: make(map[string]entities.Identity, 0),
+	}
+}
+
+// copyIdentity returns a copy of the identity that does not share the memberships with the original one.
+func copyIdentity(identity entities.Identity) entities.Identity {
+	identity.Memberships = append([]entities.IdentityMembership{}, identity.Memberships...)
+	return identity
+}
+
+// Set an identity. A previous identity with the same email is replaced.
+func (m *MockupIdentityProvider) Set(identity entities.Identity) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.identities[identity.Email] = copyIdentity(identity)
+	return nil
+}
+
+// Get the identity of an email.
+func (m *MockupIdentityProvider) Get(email string) (*entities.Identity, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	identity, exists := m.identities[email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("identity").WithParams(email)
+	}
+	result := copyIdentity(identity)
+	return &result, nil
+}
+
+// Remove the identity of an email.
+func (m *MockupIdentityProvider) Remove(email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.identities[email]; !exists {
+		return derrors.NewNotFoundError("identity").WithParams(email)
+	}
+	delete(m.identities, email)
+	return nil
+}
+
+// Clear all the identities.
+func (m *MockupIdentityProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.identities = make(map[string]entities.Identity, 0)
+	return nil
+}
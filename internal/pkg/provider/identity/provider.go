@@ -0,0 +1,34 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package identity
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the identities linking the accounts of a person in several organizations.
+type Provider interface {
+	// Set an identity. A previous identity with the same email is replaced.
+	Set(identity entities.Identity) derrors.Error
+	// Get the identity of an email.
+	Get(email string) (*entities.Identity, derrors.Error)
+	// Remove the identity of an email.
+	Remove(email string) derrors.Error
+	// Clear all the identities.
+	Clear() derrors.Error
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package identity
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every identity provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove an identity", func() {
+		identity := entities.Identity{
+			Email: "user@mail.com",
+			Memberships: []entities.IdentityMembership{
+				{OrganizationId: "org2", Joined: 10},
+				{OrganizationId: "org1", Joined: 20},
+			},
+			PropagateProfile: true,
+			Created:          10,
+		}
+		gomega.Expect(provider.Set(identity)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(identity))
+
+		gomega.Expect(provider.Remove("user@mail.com")).To(gomega.Succeed())
+		_, err = provider.Get("user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.Remove("user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous identity of an email", func() {
+		identity := entities.Identity{
+			Email:       "user@mail.com",
+			Memberships: []entities.IdentityMembership{{OrganizationId: "org1", Joined: 10}},
+			Created:     10,
+		}
+		gomega.Expect(provider.Set(identity)).To(gomega.Succeed())
+		identity.Memberships = []entities.IdentityMembership{{OrganizationId: "org2", Joined: 20}}
+		gomega.Expect(provider.Set(identity)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Memberships).Should(gomega.Equal(identity.Memberships))
+	})
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package identity
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"sort"
+)
+
+const identitiesTable = "identities"
+
+// ScyllaIdentityProvider is a ScyllaDB implementation of the identity provider. The memberships are stored as a map
+// from organization to the join timestamp and are returned in the order they were joined.
+type ScyllaIdentityProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaIdentityProvider creates a provider that stores the identities in the keyspace of a session.
+func NewScyllaIdentityProvider(session *scylladb.Session) *ScyllaIdentityProvider {
+	return &ScyllaIdentityProvider{session: session}
+}
+
+// Set an identity. A previous identity with the same email is replaced.
+func (sp *ScyllaIdentityProvider) Set(identity entities.Identity) derrors.Error {
+	memberships := make(map[string]int64, len(identity.Memberships))
+	for _, membership := range identity.Memberships {
+		memberships[membership.OrganizationId] = membership.Joined
+	}
+	return sp.session.Exec("INSERT INTO "+identitiesTable+" (email, memberships, propagate_profile, created) VALUES (?, ?, ?, ?)",
+		identity.Email, memberships, identity.PropagateProfile, identity.Created)
+}
+
+// Get the identity of an email.
+func (sp *ScyllaIdentityProvider) Get(email string) (*entities.Identity, derrors.Error) {
+	identity := entities.Identity{Email: email}
+	var memberships map[string]int64
+	found, err := sp.session.Scan("SELECT memberships, propagate_profile, created FROM "+identitiesTable+" WHERE email = ?",
+		[]interface{}{email}, &memberships, &identity.PropagateProfile, &identity.Created)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("identity").WithParams(email)
+	}
+	identity.Memberships = make([]entities.IdentityMembership, 0, len(memberships))
+	for organizationID, joined := range memberships {
+		identity.Memberships = append(identity.Memberships, entities.IdentityMembership{OrganizationId: organizationID, Joined: joined})
+	}
+	sort.Slice(identity.Memberships, func(i, j int) bool {
+		if identity.Memberships[i].Joined != identity.Memberships[j].Joined {
+			return identity.Memberships[i].Joined < identity.Memberships[j].Joined
+		}
+		return identity.Memberships[i].OrganizationId < identity.Memberships[j].OrganizationId
+	})
+	return &identity, nil
+}
+
+// Remove the identity of an email.
+func (sp *ScyllaIdentityProvider) Remove(email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+identitiesTable+" WHERE email = ? IF EXISTS", email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("identity").WithParams(email)
+	}
+	return nil
+}
+
+// Clear all the identities.
+func (sp *ScyllaIdentityProvider) Clear() derrors.Error {
+	return sp.session.Truncate(identitiesTable)
+}
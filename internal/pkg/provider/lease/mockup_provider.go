@@ -0,0 +1,65 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lease
+
+import (
+	"github.com/nalej/derrors"
+	"sync"
+	"time"
+)
+
+// lease with the holder of a task and the time it expires.
+type lease struct {
+	holder    string
+	expiresAt time.Time
+}
+
+// MockupLeaseProvider is an in-memory implementation of the lease provider.
+type MockupLeaseProvider struct {
+	sync.Mutex
+	// leases indexed by name.
+	leases map[string]lease
+}
+
+// NewMockupLeaseProvider creates an empty in-memory provider.
+func NewMockupLeaseProvider() *MockupLeaseProvider {
+	return &MockupLeaseProvider{
+		leases: make(map[string]lease, 0),
+	}
+}
+
+// Acquire takes the lease of a task for a holder during a time, or extends it if the holder already has it. It
+// returns false if the lease belongs to another holder and has not expired.
+func (m *MockupLeaseProvider) Acquire(name string, holder string, ttl time.Duration) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	current, exists := m.leases[name]
+	if exists && current.holder != holder && current.expiresAt.After(now) {
+		return false, nil
+	}
+	m.leases[name] = lease{holder: holder, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// Clear all the leases.
+func (m *MockupLeaseProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.leases = make(map[string]lease, 0)
+	return nil
+}
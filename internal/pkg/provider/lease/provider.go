@@ -0,0 +1,31 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lease
+
+import (
+	"github.com/nalej/derrors"
+	"time"
+)
+
+// Provider for the leases that let a single replica run a periodic task.
+type Provider interface {
+	// Acquire takes the lease of a task for a holder during a time, or extends it if the holder already has it. It
+	// returns false if the lease belongs to another holder and has not expired.
+	Acquire(name string, holder string, ttl time.Duration) (bool, derrors.Error)
+	// Clear all the leases.
+	Clear() derrors.Error
+}
@@ -0,0 +1,63 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lease
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+// RunTest registers the tests that every lease provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should only grant a lease to one holder until it expires", func() {
+		acquired, err := provider.Acquire("task", "replica-1", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeTrue())
+		acquired, err = provider.Acquire("task", "replica-2", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeFalse())
+		acquired, err = provider.Acquire("other", "replica-2", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeTrue())
+
+		time.Sleep(1500 * time.Millisecond)
+		acquired, err = provider.Acquire("task", "replica-2", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should extend the lease of its holder", func() {
+		acquired, err := provider.Acquire("task", "replica-1", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeTrue())
+		time.Sleep(600 * time.Millisecond)
+		acquired, err = provider.Acquire("task", "replica-1", 2*time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeTrue())
+
+		time.Sleep(600 * time.Millisecond)
+		acquired, err = provider.Acquire("task", "replica-2", time.Second)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(acquired).To(gomega.BeFalse())
+	})
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lease
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"time"
+)
+
+const leasesTable = "leases"
+
+// ScyllaLeaseProvider is a ScyllaDB implementation of the lease provider. The leases are rows written with
+// lightweight transactions that expire with the TTL of the lease.
+type ScyllaLeaseProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaLeaseProvider creates a provider that stores the leases in the keyspace of a session.
+func NewScyllaLeaseProvider(session *scylladb.Session) *ScyllaLeaseProvider {
+	return &ScyllaLeaseProvider{session: session}
+}
+
+// Acquire takes the lease of a task for a holder during a time, or extends it if the holder already has it. It
+// returns false if the lease belongs to another holder and has not expired.
+func (sp *ScyllaLeaseProvider) Acquire(name string, holder string, ttl time.Duration) (bool, derrors.Error) {
+	seconds := int(ttl.Seconds())
+	if seconds < 1 {
+		return false, derrors.NewInvalidArgumentError("the lease must last at least a second").WithParams(name, ttl.String())
+	}
+	applied, err := sp.session.ExecCAS("INSERT INTO "+leasesTable+" (name, holder) VALUES (?, ?) IF NOT EXISTS USING TTL ?",
+		name, holder, seconds)
+	if err != nil || applied {
+		return applied, err
+	}
+	return sp.session.ExecCAS("UPDATE "+leasesTable+" USING TTL ? SET holder = ? WHERE name = ? IF holder = ?",
+		seconds, holder, name, holder)
+}
+
+// Clear all the leases.
+func (sp *ScyllaLeaseProvider) Clear() derrors.Error {
+	return sp.session.Truncate(leasesTable)
+}
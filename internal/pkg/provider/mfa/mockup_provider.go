@@ -0,0 +1,148 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mfa
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"reflect"
+	"sync"
+)
+
+// MockupMFAProvider is an in-memory implementation of the MFA provider.
+type MockupMFAProvider struct {
+	sync.Mutex
+	// enrollments indexed by organization_id and email.
+	enrollments map[string]map[string]entities.MFA
+	// policies indexed by organization_id.
+	policies map[string]entities.MFAPolicy
+}
+
+// NewMockupMFAProvider creates an empty in-memory provider.
+func NewMockupMFAProvider() *MockupMFAProvider {
+	return &MockupMFAProvider{
+		enrollments: make(map[string]map[string]entities.MFA, 0),
+		policies:    make(map[string]entities.MFAPolicy, 0),
+	}
+}
+
+// Set the enrollment of a user. A previous enrollment of the same user is replaced.
+func (m *MockupMFAProvider) Set(mfa entities.MFA) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.enrollments[mfa.OrganizationId]
+	if !exists {
+		users = make(map[string]entities.MFA, 0)
+		m.enrollments[mfa.OrganizationId] = users
+	}
+	mfa.RecoveryCodes = append([]string{}, mfa.RecoveryCodes...)
+	users[mfa.Email] = mfa
+	return nil
+}
+
+// CompareAndSet replaces the enrollment of a user only if the stored one is still equal to the previous one.
+func (m *MockupMFAProvider) CompareAndSet(previous entities.MFA, updated entities.MFA) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	stored, exists := m.enrollments[previous.OrganizationId][previous.Email]
+	if !exists {
+		return derrors.NewNotFoundError("mfa enrollment").WithParams(previous.OrganizationId, previous.Email)
+	}
+	if !reflect.DeepEqual(stored, previous) {
+		return derrors.NewFailedPreconditionError("mfa enrollment has been modified").WithParams(previous.OrganizationId, previous.Email)
+	}
+	updated.RecoveryCodes = append([]string{}, updated.RecoveryCodes...)
+	m.enrollments[previous.OrganizationId][previous.Email] = updated
+	return nil
+}
+
+// Get the enrollment of a user.
+func (m *MockupMFAProvider) Get(organizationID string, email string) (*entities.MFA, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	mfa, exists := m.enrollments[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("mfa enrollment").WithParams(organizationID, email)
+	}
+	mfa.RecoveryCodes = append([]string{}, mfa.RecoveryCodes...)
+	return &mfa, nil
+}
+
+// Exists checks if a user has an enrollment, confirmed or not.
+func (m *MockupMFAProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	_, exists := m.enrollments[organizationID][email]
+	return exists, nil
+}
+
+// Remove the enrollment of a user.
+func (m *MockupMFAProvider) Remove(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.enrollments[organizationID]
+	if !exists {
+		return derrors.NewNotFoundError("mfa enrollment").WithParams(organizationID, email)
+	}
+	if _, exists = users[email]; !exists {
+		return derrors.NewNotFoundError("mfa enrollment").WithParams(organizationID, email)
+	}
+	delete(users, email)
+	if len(users) == 0 {
+		delete(m.enrollments, organizationID)
+	}
+	return nil
+}
+
+// SetPolicy sets the MFA policy of an organization.
+func (m *MockupMFAProvider) SetPolicy(policy entities.MFAPolicy) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.policies[policy.OrganizationId] = policy
+	return nil
+}
+
+// GetPolicy retrieves the MFA policy of an organization.
+func (m *MockupMFAProvider) GetPolicy(organizationID string) (*entities.MFAPolicy, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	policy, exists := m.policies[organizationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("mfa policy").WithParams(organizationID)
+	}
+	return &policy, nil
+}
+
+// RemovePolicy removes the MFA policy of an organization.
+func (m *MockupMFAProvider) RemovePolicy(organizationID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.policies[organizationID]; !exists {
+		return derrors.NewNotFoundError("mfa policy").WithParams(organizationID)
+	}
+	delete(m.policies, organizationID)
+	return nil
+}
+
+// Clear all the enrollments and policies.
+func (m *MockupMFAProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.enrollments = make(map[string]map[string]entities.MFA, 0)
+	m.policies = make(map[string]entities.MFAPolicy, 0)
+	return nil
+}
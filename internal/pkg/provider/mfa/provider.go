@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mfa
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the MFA enrollments of the users and the MFA policies of the organizations.
+type Provider interface {
+	// Set the enrollment of a user. A previous enrollment of the same user is replaced.
+	Set(mfa entities.MFA) derrors.Error
+	// CompareAndSet replaces the enrollment of a user only if the stored one is still equal to the previous one, so
+	// concurrent verifications cannot accept the same code twice nor skip the count of attempts. A
+	// FailedPrecondition error is returned if the enrollment has changed.
+	CompareAndSet(previous entities.MFA, updated entities.MFA) derrors.Error
+	// Get the enrollment of a user.
+	Get(organizationID string, email string) (*entities.MFA, derrors.Error)
+	// Exists checks if a user has an enrollment, confirmed or not.
+	Exists(organizationID string, email string) (bool, derrors.Error)
+	// Remove the enrollment of a user.
+	Remove(organizationID string, email string) derrors.Error
+	// SetPolicy sets the MFA policy of an organization.
+	SetPolicy(policy entities.MFAPolicy) derrors.Error
+	// GetPolicy retrieves the MFA policy of an organization.
+	GetPolicy(organizationID string) (*entities.MFAPolicy, derrors.Error)
+	// RemovePolicy removes the MFA policy of an organization.
+	RemovePolicy(organizationID string) derrors.Error
+	// Clear all the enrollments and policies.
+	Clear() derrors.Error
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mfa
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createEnrollment(organizationID string, email string) entities.MFA {
+	return entities.MFA{
+		OrganizationId:  organizationID,
+		Email:           email,
+		EncryptedSecret: "secret",
+		RecoveryCodes:   []string{"code1", "code2"},
+		Created:         10,
+	}
+}
+
+// RunTest registers the tests that every MFA provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove an enrollment", func() {
+		gomega.Expect(provider.Set(createEnrollment("org", "user@mail.com"))).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.EncryptedSecret).Should(gomega.Equal("secret"))
+		gomega.Expect(retrieved.RecoveryCodes).Should(gomega.Equal([]string{"code1", "code2"}))
+
+		gomega.Expect(provider.Remove("org", "user@mail.com")).To(gomega.Succeed())
+		exists, err := provider.Exists("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+		gomega.Expect(provider.Remove("org", "user@mail.com")).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should replace the previous enrollment of a user", func() {
+		gomega.Expect(provider.Set(createEnrollment("org", "user@mail.com"))).To(gomega.Succeed())
+		updated := createEnrollment("org", "user@mail.com")
+		updated.Confirmed = true
+		updated.LastTimeStep = 20
+		updated.RecoveryCodes = []string{"code2"}
+		gomega.Expect(provider.Set(updated)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(updated))
+	})
+
+	ginkgo.It("should not compare with an enrollment replaced by a new one", func() {
+		previous := createEnrollment("org", "user@mail.com")
+		gomega.Expect(provider.Set(previous)).To(gomega.Succeed())
+		replaced := createEnrollment("org", "user@mail.com")
+		replaced.EncryptedSecret = "other"
+		gomega.Expect(provider.Set(replaced)).To(gomega.Succeed())
+
+		updated := previous
+		updated.Confirmed = true
+		err := provider.CompareAndSet(previous, updated)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+
+		updated = replaced
+		updated.Confirmed = true
+		gomega.Expect(provider.CompareAndSet(replaced, updated)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should not retrieve a non existing enrollment", func() {
+		_, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should only replace an enrollment that has not changed", func() {
+		gomega.Expect(provider.Set(createEnrollment("org", "user@mail.com"))).To(gomega.Succeed())
+		previous, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+
+		first := *previous
+		first.Confirmed = true
+		first.RecoveryCodes = []string{"code2"}
+		gomega.Expect(provider.CompareAndSet(*previous, first)).To(gomega.Succeed())
+
+		second := *previous
+		second.FailedAttempts = 1
+		err = provider.CompareAndSet(*previous, second)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.FailedPrecondition))
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Confirmed).To(gomega.BeTrue())
+		gomega.Expect(retrieved.FailedAttempts).Should(gomega.BeZero())
+
+		last := *retrieved
+		last.RecoveryCodes = []string{}
+		gomega.Expect(provider.CompareAndSet(*retrieved, last)).To(gomega.Succeed())
+		retrieved, err = provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.RecoveryCodes).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should not replace a non existing enrollment", func() {
+		enrollment := createEnrollment("org", "user@mail.com")
+		err := provider.CompareAndSet(enrollment, enrollment)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove a policy", func() {
+		_, err := provider.GetPolicy("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(provider.SetPolicy(entities.MFAPolicy{OrganizationId: "org", RequireForOwners: true})).To(gomega.Succeed())
+		policy, err := provider.GetPolicy("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.RequireForOwners).To(gomega.BeTrue())
+
+		gomega.Expect(provider.RemovePolicy("org")).To(gomega.Succeed())
+		_, err = provider.GetPolicy("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		gomega.Expect(provider.RemovePolicy("org")).NotTo(gomega.Succeed())
+	})
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mfa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"strings"
+)
+
+const enrollmentsTable = "mfa_enrollments"
+
+const policiesTable = "mfa_policies"
+
+const enrollmentColumns = "organization_id, email, encrypted_secret, confirmed, last_time_step, recovery_codes, failed_attempts, locked_until, created"
+
+// ScyllaMFAProvider is a ScyllaDB implementation of the MFA provider. Each enrollment is stored with a revision
+// derived from its fields that lets CompareAndSet replace it with a lightweight transaction.
+type ScyllaMFAProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaMFAProvider creates a provider that stores the enrollments and policies in the keyspace of a session.
+func NewScyllaMFAProvider(session *scylladb.Session) *ScyllaMFAProvider {
+	return &ScyllaMFAProvider{session: session}
+}
+
+// revision obtains the hash of the fields of an enrollment. Empty and missing recovery codes have the same revision
+// as the database does not keep them apart.
+func revision(mfa entities.MFA) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%s\n%t\n%d\n%s\n%d\n%d\n%d", mfa.OrganizationId, mfa.Email,
+		mfa.EncryptedSecret, mfa.Confirmed, mfa.LastTimeStep, strings.Join(mfa.RecoveryCodes, ","), mfa.FailedAttempts,
+		mfa.LockedUntil, mfa.Created)))
+	return hex.EncodeToString(hash[:])
+}
+
+// setRetries with the number of times an enrollment is replaced again when it changes concurrently.
+const setRetries = 3
+
+// Set the enrollment of a user. A previous enrollment of the same user is replaced. Both the insertion and the
+// replacement are lightweight transactions, as the conditions of CompareAndSet and Remove only hold if every write
+// of the row is one.
+func (sp *ScyllaMFAProvider) Set(mfa entities.MFA) derrors.Error {
+	for retry := 0; retry < setRetries; retry++ {
+		applied, err := sp.session.ExecCAS("INSERT INTO "+enrollmentsTable+" ("+enrollmentColumns+", revision) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS",
+			mfa.OrganizationId, mfa.Email, mfa.EncryptedSecret, mfa.Confirmed, mfa.LastTimeStep, mfa.RecoveryCodes,
+			mfa.FailedAttempts, mfa.LockedUntil, mfa.Created, revision(mfa))
+		if err != nil || applied {
+			return err
+		}
+		var current string
+		found, err := sp.session.Scan("SELECT revision FROM "+enrollmentsTable+" WHERE organization_id = ? AND email = ?",
+			[]interface{}{mfa.OrganizationId, mfa.Email}, &current)
+		if err != nil {
+			return err
+		}
+		if !found {
+			continue
+		}
+		applied, err = sp.update(current, mfa)
+		if err != nil || applied {
+			return err
+		}
+	}
+	return derrors.NewUnavailableError("mfa enrollment is being modified concurrently").WithParams(mfa.OrganizationId, mfa.Email)
+}
+
+// CompareAndSet replaces the enrollment of a user only if the stored one is still equal to the previous one.
+func (sp *ScyllaMFAProvider) CompareAndSet(previous entities.MFA, updated entities.MFA) derrors.Error {
+	updated.OrganizationId = previous.OrganizationId
+	updated.Email = previous.Email
+	applied, err := sp.update(revision(previous), updated)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+	exists, err := sp.Exists(previous.OrganizationId, previous.Email)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return derrors.NewNotFoundError("mfa enrollment").WithParams(previous.OrganizationId, previous.Email)
+	}
+	return derrors.NewFailedPreconditionError("mfa enrollment has been modified").WithParams(previous.OrganizationId, previous.Email)
+}
+
+// update replaces the enrollment of a user if the revision of the stored one matches the expected one.
+func (sp *ScyllaMFAProvider) update(expected string, mfa entities.MFA) (bool, derrors.Error) {
+	return sp.session.ExecCAS("UPDATE "+enrollmentsTable+" SET encrypted_secret = ?, confirmed = ?, last_time_step = ?, recovery_codes = ?, failed_attempts = ?, locked_until = ?, created = ?, revision = ? WHERE organization_id = ? AND email = ? IF revision = ?",
+		mfa.EncryptedSecret, mfa.Confirmed, mfa.LastTimeStep, mfa.RecoveryCodes, mfa.FailedAttempts,
+		mfa.LockedUntil, mfa.Created, revision(mfa), mfa.OrganizationId, mfa.Email, expected)
+}
+
+// Get the enrollment of a user.
+func (sp *ScyllaMFAProvider) Get(organizationID string, email string) (*entities.MFA, derrors.Error) {
+	var mfa entities.MFA
+	found, err := sp.session.Scan("SELECT "+enrollmentColumns+" FROM "+enrollmentsTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &mfa.OrganizationId, &mfa.Email, &mfa.EncryptedSecret, &mfa.Confirmed,
+		&mfa.LastTimeStep, &mfa.RecoveryCodes, &mfa.FailedAttempts, &mfa.LockedUntil, &mfa.Created)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("mfa enrollment").WithParams(organizationID, email)
+	}
+	return &mfa, nil
+}
+
+// Exists checks if a user has an enrollment, confirmed or not.
+func (sp *ScyllaMFAProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	var found string
+	return sp.session.Scan("SELECT email FROM "+enrollmentsTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &found)
+}
+
+// Remove the enrollment of a user.
+func (sp *ScyllaMFAProvider) Remove(organizationID string, email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+enrollmentsTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("mfa enrollment").WithParams(organizationID, email)
+	}
+	return nil
+}
+
+// SetPolicy sets the MFA policy of an organization.
+func (sp *ScyllaMFAProvider) SetPolicy(policy entities.MFAPolicy) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+policiesTable+" (organization_id, require_for_owners) VALUES (?, ?)",
+		policy.OrganizationId, policy.RequireForOwners)
+}
+
+// GetPolicy retrieves the MFA policy of an organization.
+func (sp *ScyllaMFAProvider) GetPolicy(organizationID string) (*entities.MFAPolicy, derrors.Error) {
+	var policy entities.MFAPolicy
+	found, err := sp.session.Scan("SELECT organization_id, require_for_owners FROM "+policiesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, &policy.OrganizationId, &policy.RequireForOwners)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("mfa policy").WithParams(organizationID)
+	}
+	return &policy, nil
+}
+
+// RemovePolicy removes the MFA policy of an organization.
+func (sp *ScyllaMFAProvider) RemovePolicy(organizationID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+policiesTable+" WHERE organization_id = ? IF EXISTS", organizationID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("mfa policy").WithParams(organizationID)
+	}
+	return nil
+}
+
+// Clear all the enrollments and policies.
+func (sp *ScyllaMFAProvider) Clear() derrors.Error {
+	return sp.session.Truncate(enrollmentsTable, policiesTable)
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboarding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupOffboardingProvider is an in-memory implementation of the offboarding provider.
+type MockupOffboardingProvider struct {
+	sync.Mutex
+	// offboardings indexed by organization_id.
+	offboardings map[string]entities.Offboarding
+}
+
+// NewMockupOffboardingProvider creates an empty in-memory provider.
+func NewMockupOffboardingProvider() *MockupOffboardingProvider {
+	return &MockupOffboardingProvider{
+		offboardings: make(map[string]entities.Offboarding, 0),
+	}
+}
+
+// Set the offboarding of an organization. A previous offboarding of the same organization is replaced.
+func (m *MockupOffboardingProvider) Set(offboarding entities.Offboarding) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	offboarding.RemovedUsers = append([]string{}, offboarding.RemovedUsers...)
+	offboarding.RemovedRoles = append([]string{}, offboarding.RemovedRoles...)
+	offboarding.RemovedServiceAccounts = append([]string{}, offboarding.RemovedServiceAccounts...)
+	offboarding.Failures = append([]string{}, offboarding.Failures...)
+	m.offboardings[offboarding.OrganizationId] = offboarding
+	return nil
+}
+
+// Get the offboarding of an organization.
+func (m *MockupOffboardingProvider) Get(organizationID string) (*entities.Offboarding, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	offboarding, exists := m.offboardings[organizationID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("offboarding").WithParams(organizationID)
+	}
+	return &offboarding, nil
+}
+
+// Clear all the offboardings.
+func (m *MockupOffboardingProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.offboardings = make(map[string]entities.Offboarding, 0)
+	return nil
+}
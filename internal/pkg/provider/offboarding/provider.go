@@ -0,0 +1,32 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboarding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the organization offboardings.
+type Provider interface {
+	// Set the offboarding of an organization. A previous offboarding of the same organization is replaced.
+	Set(offboarding entities.Offboarding) derrors.Error
+	// Get the offboarding of an organization.
+	Get(organizationID string) (*entities.Offboarding, derrors.Error)
+	// Clear all the offboardings.
+	Clear() derrors.Error
+}
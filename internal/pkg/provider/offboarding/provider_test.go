@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboarding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every offboarding provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set and retrieve an offboarding", func() {
+		offboarding := entities.Offboarding{
+			OrganizationId:         "org",
+			TokenHash:              "hash",
+			ExpiresAt:              10,
+			Status:                 grpc_user_manager_go.OffboardingStatus_IN_PROGRESS,
+			StartedAt:              1,
+			RemovedUsers:           []string{"user@mail.com"},
+			RemovedRoles:           []string{"role"},
+			RemovedServiceAccounts: []string{"account"},
+			Failures:               []string{"cannot remove user"},
+		}
+		gomega.Expect(provider.Set(offboarding)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(offboarding))
+
+		_, err = provider.Get("other")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous offboarding of an organization", func() {
+		gomega.Expect(provider.Set(entities.Offboarding{
+			OrganizationId: "org",
+			TokenHash:      "hash",
+			Status:         grpc_user_manager_go.OffboardingStatus_IN_PROGRESS,
+			RemovedUsers:   []string{"user1@mail.com"},
+		})).To(gomega.Succeed())
+		gomega.Expect(provider.Set(entities.Offboarding{
+			OrganizationId: "org",
+			TokenHash:      "hash",
+			Status:         grpc_user_manager_go.OffboardingStatus_COMPLETED,
+			CompletedAt:    20,
+			RemovedUsers:   []string{"user1@mail.com", "user2@mail.com"},
+		})).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Status).Should(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+		gomega.Expect(retrieved.CompletedAt).Should(gomega.Equal(int64(20)))
+		gomega.Expect(retrieved.RemovedUsers).Should(gomega.Equal([]string{"user1@mail.com", "user2@mail.com"}))
+	})
+}
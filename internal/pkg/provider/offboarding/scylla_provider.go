@@ -0,0 +1,69 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package offboarding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const offboardingsTable = "offboardings"
+
+const offboardingColumns = "organization_id, token_hash, expires_at, status, started_at, completed_at, removed_users, removed_roles, removed_service_accounts, failures"
+
+// ScyllaOffboardingProvider is a ScyllaDB implementation of the offboarding provider.
+type ScyllaOffboardingProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaOffboardingProvider creates a provider that stores the offboardings in the keyspace of a session.
+func NewScyllaOffboardingProvider(session *scylladb.Session) *ScyllaOffboardingProvider {
+	return &ScyllaOffboardingProvider{session: session}
+}
+
+// Set the offboarding of an organization. A previous offboarding of the same organization is replaced.
+func (sp *ScyllaOffboardingProvider) Set(offboarding entities.Offboarding) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+offboardingsTable+" ("+offboardingColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		offboarding.OrganizationId, offboarding.TokenHash, offboarding.ExpiresAt, int32(offboarding.Status),
+		offboarding.StartedAt, offboarding.CompletedAt, offboarding.RemovedUsers, offboarding.RemovedRoles,
+		offboarding.RemovedServiceAccounts, offboarding.Failures)
+}
+
+// Get the offboarding of an organization.
+func (sp *ScyllaOffboardingProvider) Get(organizationID string) (*entities.Offboarding, derrors.Error) {
+	var offboarding entities.Offboarding
+	var status int32
+	found, err := sp.session.Scan("SELECT "+offboardingColumns+" FROM "+offboardingsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, &offboarding.OrganizationId, &offboarding.TokenHash, &offboarding.ExpiresAt, &status,
+		&offboarding.StartedAt, &offboarding.CompletedAt, &offboarding.RemovedUsers, &offboarding.RemovedRoles,
+		&offboarding.RemovedServiceAccounts, &offboarding.Failures)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("offboarding").WithParams(organizationID)
+	}
+	offboarding.Status = grpc_user_manager_go.OffboardingStatus(status)
+	return &offboarding, nil
+}
+
+// Clear all the offboardings.
+func (sp *ScyllaOffboardingProvider) Clear() derrors.Error {
+	return sp.session.Truncate(offboardingsTable)
+}
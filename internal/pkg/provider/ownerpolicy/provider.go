@@ -0,0 +1,34 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ownerpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the owner policies of the organizations.
+type Provider interface {
+	// Set the policy of an organization. A previous policy of the same organization is replaced.
+	Set(policy entities.OwnerPolicy) derrors.Error
+	// Get the policy of an organization.
+	Get(organizationID string) (*entities.OwnerPolicy, derrors.Error)
+	// Remove the policy of an organization.
+	Remove(organizationID string) derrors.Error
+	// Clear all the policies.
+	Clear() derrors.Error
+}
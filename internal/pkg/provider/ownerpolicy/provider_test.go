@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ownerpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every owner policy provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove a policy", func() {
+		policy := entities.OwnerPolicy{OrganizationId: "org", MinOwners: 2}
+		gomega.Expect(provider.Set(policy)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(policy))
+
+		gomega.Expect(provider.Remove("org")).To(gomega.Succeed())
+		_, err = provider.Get("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.Remove("org")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous policy of an organization", func() {
+		gomega.Expect(provider.Set(entities.OwnerPolicy{OrganizationId: "org", MinOwners: 2})).To(gomega.Succeed())
+		gomega.Expect(provider.Set(entities.OwnerPolicy{OrganizationId: "org", MinOwners: 3})).To(gomega.Succeed())
+		gomega.Expect(provider.Set(entities.OwnerPolicy{OrganizationId: "other", MinOwners: 1})).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.MinOwners).Should(gomega.Equal(3))
+	})
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ownerpolicy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const ownerPoliciesTable = "owner_policies"
+
+// ScyllaOwnerPolicyProvider is a ScyllaDB implementation of the owner policy provider.
+type ScyllaOwnerPolicyProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaOwnerPolicyProvider creates a provider that stores the policies in the keyspace of a session.
+func NewScyllaOwnerPolicyProvider(session *scylladb.Session) *ScyllaOwnerPolicyProvider {
+	return &ScyllaOwnerPolicyProvider{session: session}
+}
+
+// Set the policy of an organization. A previous policy of the same organization is replaced.
+func (sp *ScyllaOwnerPolicyProvider) Set(policy entities.OwnerPolicy) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+ownerPoliciesTable+" (organization_id, min_owners) VALUES (?, ?)",
+		policy.OrganizationId, policy.MinOwners)
+}
+
+// Get the policy of an organization.
+func (sp *ScyllaOwnerPolicyProvider) Get(organizationID string) (*entities.OwnerPolicy, derrors.Error) {
+	var policy entities.OwnerPolicy
+	found, err := sp.session.Scan("SELECT organization_id, min_owners FROM "+ownerPoliciesTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, &policy.OrganizationId, &policy.MinOwners)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("owner policy").WithParams(organizationID)
+	}
+	return &policy, nil
+}
+
+// Remove the policy of an organization.
+func (sp *ScyllaOwnerPolicyProvider) Remove(organizationID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+ownerPoliciesTable+" WHERE organization_id = ? IF EXISTS", organizationID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("owner policy").WithParams(organizationID)
+	}
+	return nil
+}
+
+// Clear all the policies.
+func (sp *ScyllaOwnerPolicyProvider) Clear() derrors.Error {
+	return sp.session.Truncate(ownerPoliciesTable)
+}
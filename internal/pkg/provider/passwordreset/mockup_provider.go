@@ -0,0 +1,79 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package passwordreset
+
+import (
+	"github.com/nalej/derrors"
+	"sync"
+)
+
+// MockupPasswordResetProvider is an in-memory implementation of the password reset provider.
+type MockupPasswordResetProvider struct {
+	sync.Mutex
+	// pending password resets indexed by organization_id and email.
+	pending map[string]map[string]bool
+}
+
+// NewMockupPasswordResetProvider creates an empty in-memory provider.
+func NewMockupPasswordResetProvider() *MockupPasswordResetProvider {
+	return &MockupPasswordResetProvider{
+		pending: make(map[string]map[string]bool, 0),
+	}
+}
+
+// Add marks a user as requiring a password reset.
+func (m *MockupPasswordResetProvider) Add(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.pending[organizationID]
+	if !exists {
+		users = make(map[string]bool, 0)
+		m.pending[organizationID] = users
+	}
+	users[email] = true
+	return nil
+}
+
+// Exists checks if a user is required to reset its password.
+func (m *MockupPasswordResetProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.pending[organizationID][email], nil
+}
+
+// Remove the password reset mark of a user. Removing a non existing mark is not an error.
+func (m *MockupPasswordResetProvider) Remove(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.pending[organizationID]
+	if !exists {
+		return nil
+	}
+	delete(users, email)
+	if len(users) == 0 {
+		delete(m.pending, organizationID)
+	}
+	return nil
+}
+
+// Clear all the marks.
+func (m *MockupPasswordResetProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.pending = make(map[string]map[string]bool, 0)
+	return nil
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package passwordreset
+
+import (
+	"github.com/nalej/derrors"
+)
+
+// Provider keeping track of the users that must reset their password before using the platform.
+type Provider interface {
+	// Add marks a user as requiring a password reset.
+	Add(organizationID string, email string) derrors.Error
+	// Exists checks if a user is required to reset its password.
+	Exists(organizationID string, email string) (bool, derrors.Error)
+	// Remove the password reset mark of a user. Removing a non existing mark is not an error.
+	Remove(organizationID string, email string) derrors.Error
+	// Clear all the marks.
+	Clear() derrors.Error
+}
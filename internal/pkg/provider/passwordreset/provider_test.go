@@ -0,0 +1,53 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package passwordreset
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every password reset provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to mark a user and check the mark", func() {
+		gomega.Expect(provider.Add("org", "user@mail.com")).To(gomega.Succeed())
+
+		exists, err := provider.Exists("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+
+		exists, err = provider.Exists("other", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should be able to remove a mark", func() {
+		gomega.Expect(provider.Add("org", "user@mail.com")).To(gomega.Succeed())
+		gomega.Expect(provider.Remove("org", "user@mail.com")).To(gomega.Succeed())
+
+		exists, err := provider.Exists("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+
+		gomega.Expect(provider.Remove("org", "user@mail.com")).To(gomega.Succeed())
+	})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package passwordreset
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const passwordResetsTable = "password_resets"
+
+// ScyllaPasswordResetProvider is a ScyllaDB implementation of the password reset provider.
+type ScyllaPasswordResetProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaPasswordResetProvider creates a provider that stores the marks in the keyspace of a session.
+func NewScyllaPasswordResetProvider(session *scylladb.Session) *ScyllaPasswordResetProvider {
+	return &ScyllaPasswordResetProvider{session: session}
+}
+
+// Add marks a user as requiring a password reset.
+func (sp *ScyllaPasswordResetProvider) Add(organizationID string, email string) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+passwordResetsTable+" (organization_id, email) VALUES (?, ?)", organizationID, email)
+}
+
+// Exists checks if a user is required to reset its password.
+func (sp *ScyllaPasswordResetProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	var found string
+	return sp.session.Scan("SELECT email FROM "+passwordResetsTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &found)
+}
+
+// Remove the password reset mark of a user. Removing a non existing mark is not an error.
+func (sp *ScyllaPasswordResetProvider) Remove(organizationID string, email string) derrors.Error {
+	return sp.session.Exec("DELETE FROM "+passwordResetsTable+" WHERE organization_id = ? AND email = ?", organizationID, email)
+}
+
+// Clear all the marks.
+func (sp *ScyllaPasswordResetProvider) Clear() derrors.Error {
+	return sp.session.Truncate(passwordResetsTable)
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recyclebin
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupRecycleBinProvider is an in-memory implementation of the recycle bin provider.
+type MockupRecycleBinProvider struct {
+	sync.Mutex
+	// removedUsers indexed by organization_id and email.
+	removedUsers map[string]map[string]entities.RemovedUser
+}
+
+// NewMockupRecycleBinProvider creates an empty in-memory provider.
+func NewMockupRecycleBinProvider() *MockupRecycleBinProvider {
+	return &MockupRecycleBinProvider{
+		removedUsers: make(map[string]map[string]entities.RemovedUser, 0),
+	}
+}
+
+// Add a removed user snapshot. A previous snapshot of the same user is replaced.
+func (m *MockupRecycleBinProvider) Add(removed entities.RemovedUser) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.removedUsers[removed.OrganizationId]
+	if !exists {
+		users = make(map[string]entities.RemovedUser, 0)
+		m.removedUsers[removed.OrganizationId] = users
+	}
+	users[removed.Email] = removed
+	return nil
+}
+
+// Get the snapshot of a removed user.
+func (m *MockupRecycleBinProvider) Get(organizationID string, email string) (*entities.RemovedUser, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	removed, exists := m.removedUsers[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("removed user").WithParams(organizationID, email)
+	}
+	return &removed, nil
+}
+
+// Exists checks if there is a snapshot for a given user.
+func (m *MockupRecycleBinProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	_, exists := m.removedUsers[organizationID][email]
+	return exists, nil
+}
+
+// Remove the snapshot of a removed user.
+func (m *MockupRecycleBinProvider) Remove(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.removedUsers[organizationID]
+	if !exists {
+		return derrors.NewNotFoundError("removed user").WithParams(organizationID, email)
+	}
+	if _, exists = users[email]; !exists {
+		return derrors.NewNotFoundError("removed user").WithParams(organizationID, email)
+	}
+	delete(users, email)
+	if len(users) == 0 {
+		delete(m.removedUsers, organizationID)
+	}
+	return nil
+}
+
+// List the snapshots of the users removed from an organization.
+func (m *MockupRecycleBinProvider) List(organizationID string) ([]entities.RemovedUser, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RemovedUser, 0)
+	for _, removed := range m.removedUsers[organizationID] {
+		result = append(result, removed)
+	}
+	return result, nil
+}
+
+// Purge removes the snapshots that expired before a given timestamp, returning the number of purged entries.
+func (m *MockupRecycleBinProvider) Purge(timestamp int64) (int, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	purged := 0
+	for organizationID, users := range m.removedUsers {
+		for email, removed := range users {
+			if removed.ExpiresAt <= timestamp {
+				delete(users, email)
+				purged++
+			}
+		}
+		if len(users) == 0 {
+			delete(m.removedUsers, organizationID)
+		}
+	}
+	return purged, nil
+}
+
+// Clear all the snapshots.
+func (m *MockupRecycleBinProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.removedUsers = make(map[string]map[string]entities.RemovedUser, 0)
+	return nil
+}
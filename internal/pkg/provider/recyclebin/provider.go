@@ -0,0 +1,40 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recyclebin
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the snapshots of removed users.
+type Provider interface {
+	// Add a removed user snapshot. A previous snapshot of the same user is replaced.
+	Add(removed entities.RemovedUser) derrors.Error
+	// Get the snapshot of a removed user.
+	Get(organizationID string, email string) (*entities.RemovedUser, derrors.Error)
+	// Exists checks if there is a snapshot for a given user.
+	Exists(organizationID string, email string) (bool, derrors.Error)
+	// Remove the snapshot of a removed user.
+	Remove(organizationID string, email string) derrors.Error
+	// List the snapshots of the users removed from an organization.
+	List(organizationID string) ([]entities.RemovedUser, derrors.Error)
+	// Purge removes the snapshots that expired before a given timestamp, returning the number of purged entries.
+	Purge(timestamp int64) (int, derrors.Error)
+	// Clear all the snapshots.
+	Clear() derrors.Error
+}
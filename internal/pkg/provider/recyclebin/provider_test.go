@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recyclebin
+
+import (
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createRemovedUser(organizationID string, email string, expiresAt int64) entities.RemovedUser {
+	user := &grpc_user_manager_go.User{
+		OrganizationId: organizationID,
+		Email:          email,
+		Name:           "user",
+		RoleId:         "roleID",
+		RoleName:       "role",
+	}
+	return *entities.NewRemovedUser(user, 0, expiresAt)
+}
+
+// RunTest registers the tests that every recycle bin provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to add and retrieve a removed user", func() {
+		toAdd := createRemovedUser("org", "user@mail.com", 10)
+		err := provider.Add(toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.User.RoleId).Should(gomega.Equal("roleID"))
+
+		exists, err := provider.Exists("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should not be able to retrieve a non existing removed user", func() {
+		_, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to list and remove the users of an organization", func() {
+		gomega.Expect(provider.Add(createRemovedUser("org", "user1@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createRemovedUser("org", "user2@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createRemovedUser("other", "user3@mail.com", 10))).To(gomega.Succeed())
+
+		list, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(list)).Should(gomega.Equal(2))
+
+		err = provider.Remove("org", "user1@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		list, err = provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(list)).Should(gomega.Equal(1))
+
+		err = provider.Remove("org", "user1@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should purge only the expired users", func() {
+		gomega.Expect(provider.Add(createRemovedUser("org", "expired@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(createRemovedUser("org", "valid@mail.com", 100))).To(gomega.Succeed())
+
+		purged, err := provider.Purge(50)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(purged).Should(gomega.Equal(1))
+
+		exists, err := provider.Exists("org", "expired@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeFalse())
+		exists, err = provider.Exists("org", "valid@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(exists).To(gomega.BeTrue())
+	})
+}
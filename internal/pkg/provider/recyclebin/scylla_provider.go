@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package recyclebin
+
+import (
+	"encoding/json"
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const removedUsersTable = "removed_users"
+
+const removedUserColumns = "organization_id, email, snapshot, removed_at, expires_at"
+
+// ScyllaRecycleBinProvider is a ScyllaDB implementation of the recycle bin provider. The users are stored as JSON.
+type ScyllaRecycleBinProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaRecycleBinProvider creates a provider that stores the snapshots in the keyspace of a session.
+func NewScyllaRecycleBinProvider(session *scylladb.Session) *ScyllaRecycleBinProvider {
+	return &ScyllaRecycleBinProvider{session: session}
+}
+
+// Add a removed user snapshot. A previous snapshot of the same user is replaced.
+func (sp *ScyllaRecycleBinProvider) Add(removed entities.RemovedUser) derrors.Error {
+	user, err := json.Marshal(removed.User)
+	if err != nil {
+		return derrors.AsError(err, "cannot encode removed user")
+	}
+	return sp.session.Exec("INSERT INTO "+removedUsersTable+" ("+removedUserColumns+") VALUES (?, ?, ?, ?, ?)",
+		removed.OrganizationId, removed.Email, string(user), removed.RemovedAt, removed.ExpiresAt)
+}
+
+// decodeUser sets the user of a removed user from its JSON representation.
+func decodeUser(removed *entities.RemovedUser, user string) derrors.Error {
+	removed.User = &grpc_user_manager_go.User{}
+	err := json.Unmarshal([]byte(user), removed.User)
+	if err != nil {
+		return derrors.AsError(err, "cannot decode removed user")
+	}
+	return nil
+}
+
+// Get the snapshot of a removed user.
+func (sp *ScyllaRecycleBinProvider) Get(organizationID string, email string) (*entities.RemovedUser, derrors.Error) {
+	var removed entities.RemovedUser
+	var user string
+	found, err := sp.session.Scan("SELECT "+removedUserColumns+" FROM "+removedUsersTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &removed.OrganizationId, &removed.Email, &user, &removed.RemovedAt, &removed.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("removed user").WithParams(organizationID, email)
+	}
+	err = decodeUser(&removed, user)
+	if err != nil {
+		return nil, err
+	}
+	return &removed, nil
+}
+
+// Exists checks if there is a snapshot for a given user.
+func (sp *ScyllaRecycleBinProvider) Exists(organizationID string, email string) (bool, derrors.Error) {
+	var found string
+	return sp.session.Scan("SELECT email FROM "+removedUsersTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &found)
+}
+
+// Remove the snapshot of a removed user.
+func (sp *ScyllaRecycleBinProvider) Remove(organizationID string, email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+removedUsersTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("removed user").WithParams(organizationID, email)
+	}
+	return nil
+}
+
+// List the snapshots of the users removed from an organization.
+func (sp *ScyllaRecycleBinProvider) List(organizationID string) ([]entities.RemovedUser, derrors.Error) {
+	result := make([]entities.RemovedUser, 0)
+	err := sp.session.Iterate("SELECT "+removedUserColumns+" FROM "+removedUsersTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var removed entities.RemovedUser
+			var user string
+			sErr := scanner.Scan(&removed.OrganizationId, &removed.Email, &user, &removed.RemovedAt, &removed.ExpiresAt)
+			if sErr != nil {
+				return sErr
+			}
+			dErr := decodeUser(&removed, user)
+			if dErr != nil {
+				return dErr
+			}
+			result = append(result, removed)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Purge removes the snapshots that expired before a given timestamp, returning the number of purged entries. The
+// expiration is not indexed, so all the snapshots are read.
+func (sp *ScyllaRecycleBinProvider) Purge(timestamp int64) (int, derrors.Error) {
+	expired := make([][]interface{}, 0)
+	err := sp.session.Iterate("SELECT organization_id, email, expires_at FROM "+removedUsersTable, nil,
+		func(scanner gocql.Scanner) error {
+			var organizationID, email string
+			var expiresAt int64
+			sErr := scanner.Scan(&organizationID, &email, &expiresAt)
+			if sErr == nil && expiresAt <= timestamp {
+				expired = append(expired, []interface{}{organizationID, email})
+			}
+			return sErr
+		})
+	if err != nil {
+		return 0, err
+	}
+	for index, key := range expired {
+		err = sp.session.Exec("DELETE FROM "+removedUsersTable+" WHERE organization_id = ? AND email = ?", key...)
+		if err != nil {
+			return index, err
+		}
+	}
+	return len(expired), nil
+}
+
+// Clear all the snapshots.
+func (sp *ScyllaRecycleBinProvider) Clear() derrors.Error {
+	return sp.session.Truncate(removedUsersTable)
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolebinding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sort"
+	"sync"
+)
+
+// MockupRoleBindingProvider is an in-memory implementation of the role binding provider.
+type MockupRoleBindingProvider struct {
+	sync.Mutex
+	// bindings indexed by organization_id and email.
+	bindings map[string]map[string]entities.RoleBindings
+}
+
+// NewMockupRoleBindingProvider creates an empty in-memory provider.
+func NewMockupRoleBindingProvider() *MockupRoleBindingProvider {
+	return &MockupRoleBindingProvider{
+		bindings: make(map[string]map[string]entities.RoleBindings, 0),
+	}
+}
+
+// copyBindings returns a copy of the bindings that does not share the roles with the original one.
+func copyBindings(bindings entities.RoleBindings) entities.RoleBindings {
+	bindings.RoleIds = append([]string{}, bindings.RoleIds...)
+	return bindings
+}
+
+// Set the additional roles of a user. Previous roles of the same user are replaced.
+func (m *MockupRoleBindingProvider) Set(bindings entities.RoleBindings) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	organization, exists := m.bindings[bindings.OrganizationId]
+	if !exists {
+		organization = make(map[string]entities.RoleBindings, 0)
+		m.bindings[bindings.OrganizationId] = organization
+	}
+	organization[bindings.Email] = copyBindings(bindings)
+	return nil
+}
+
+// Get the additional roles of a user.
+func (m *MockupRoleBindingProvider) Get(organizationID string, email string) (*entities.RoleBindings, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	bindings, exists := m.bindings[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("role bindings").WithParams(organizationID, email)
+	}
+	result := copyBindings(bindings)
+	return &result, nil
+}
+
+// List the additional roles of the users of an organization.
+func (m *MockupRoleBindingProvider) List(organizationID string) ([]entities.RoleBindings, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RoleBindings, 0, len(m.bindings[organizationID]))
+	for _, bindings := range m.bindings[organizationID] {
+		result = append(result, copyBindings(bindings))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Email < result[j].Email
+	})
+	return result, nil
+}
+
+// Remove the additional roles of a user.
+func (m *MockupRoleBindingProvider) Remove(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.bindings[organizationID][email]; !exists {
+		return derrors.NewNotFoundError("role bindings").WithParams(organizationID, email)
+	}
+	delete(m.bindings[organizationID], email)
+	return nil
+}
+
+// Clear all the role bindings.
+func (m *MockupRoleBindingProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.bindings = make(map[string]map[string]entities.RoleBindings, 0)
+	return nil
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolebinding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the additional roles of the users.
+type Provider interface {
+	// Set the additional roles of a user. Previous roles of the same user are replaced.
+	Set(bindings entities.RoleBindings) derrors.Error
+	// Get the additional roles of a user.
+	Get(organizationID string, email string) (*entities.RoleBindings, derrors.Error)
+	// List the additional roles of the users of an organization.
+	List(organizationID string) ([]entities.RoleBindings, derrors.Error)
+	// Remove the additional roles of a user.
+	Remove(organizationID string, email string) derrors.Error
+	// Clear all the role bindings.
+	Clear() derrors.Error
+}
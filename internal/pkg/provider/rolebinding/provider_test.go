@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolebinding
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createBindings(organizationID string, email string, roleIDs ...string) entities.RoleBindings {
+	bindings := entities.NewRoleBindings(organizationID, email)
+	for _, roleID := range roleIDs {
+		bindings.Add(roleID)
+	}
+	return *bindings
+}
+
+// RunTest registers the tests that every role binding provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove the roles of a user", func() {
+		bindings := createBindings("org", "user@mail.com", "role1", "role2")
+		gomega.Expect(provider.Set(bindings)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(bindings))
+
+		gomega.Expect(provider.Remove("org", "user@mail.com")).To(gomega.Succeed())
+		_, err = provider.Get("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.Remove("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous roles of a user", func() {
+		gomega.Expect(provider.Set(createBindings("org", "user@mail.com", "role1"))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createBindings("org", "user@mail.com", "role2", "role3"))).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.RoleIds).Should(gomega.Equal([]string{"role2", "role3"}))
+	})
+
+	ginkgo.It("should list the roles of the users of an organization sorted by email", func() {
+		gomega.Expect(provider.Set(createBindings("org", "user2@mail.com", "role1"))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createBindings("org", "user1@mail.com", "role2"))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createBindings("other", "user3@mail.com", "role1"))).To(gomega.Succeed())
+
+		bindings, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(bindings)).Should(gomega.Equal(2))
+		gomega.Expect(bindings[0].Email).Should(gomega.Equal("user1@mail.com"))
+		gomega.Expect(bindings[1].Email).Should(gomega.Equal("user2@mail.com"))
+	})
+}
@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolebinding
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const roleBindingsTable = "role_bindings"
+
+// ScyllaRoleBindingProvider is a ScyllaDB implementation of the role binding provider.
+type ScyllaRoleBindingProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaRoleBindingProvider creates a provider that stores the bindings in the keyspace of a session.
+func NewScyllaRoleBindingProvider(session *scylladb.Session) *ScyllaRoleBindingProvider {
+	return &ScyllaRoleBindingProvider{session: session}
+}
+
+// Set the additional roles of a user. Previous roles of the same user are replaced.
+func (sp *ScyllaRoleBindingProvider) Set(bindings entities.RoleBindings) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+roleBindingsTable+" (organization_id, email, role_ids) VALUES (?, ?, ?)",
+		bindings.OrganizationId, bindings.Email, bindings.RoleIds)
+}
+
+// Get the additional roles of a user.
+func (sp *ScyllaRoleBindingProvider) Get(organizationID string, email string) (*entities.RoleBindings, derrors.Error) {
+	var bindings entities.RoleBindings
+	found, err := sp.session.Scan("SELECT organization_id, email, role_ids FROM "+roleBindingsTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &bindings.OrganizationId, &bindings.Email, &bindings.RoleIds)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("role bindings").WithParams(organizationID, email)
+	}
+	return &bindings, nil
+}
+
+// List the additional roles of the users of an organization sorted by email.
+func (sp *ScyllaRoleBindingProvider) List(organizationID string) ([]entities.RoleBindings, derrors.Error) {
+	result := make([]entities.RoleBindings, 0)
+	err := sp.session.Iterate("SELECT organization_id, email, role_ids FROM "+roleBindingsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var bindings entities.RoleBindings
+			sErr := scanner.Scan(&bindings.OrganizationId, &bindings.Email, &bindings.RoleIds)
+			if sErr == nil {
+				result = append(result, bindings)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Remove the additional roles of a user.
+func (sp *ScyllaRoleBindingProvider) Remove(organizationID string, email string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+roleBindingsTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("role bindings").WithParams(organizationID, email)
+	}
+	return nil
+}
+
+// Clear all the role bindings.
+func (sp *ScyllaRoleBindingProvider) Clear() derrors.Error {
+	return sp.session.Truncate(roleBindingsTable)
+}
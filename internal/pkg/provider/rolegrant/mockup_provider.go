@@ -0,0 +1,113 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolegrant
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupRoleGrantProvider is an in-memory implementation of the role grant provider.
+type MockupRoleGrantProvider struct {
+	sync.Mutex
+	// grants indexed by organization_id and email.
+	grants map[string]map[string]entities.RoleGrant
+}
+
+// NewMockupRoleGrantProvider creates an empty in-memory provider.
+func NewMockupRoleGrantProvider() *MockupRoleGrantProvider {
+	return &MockupRoleGrantProvider{
+		grants: make(map[string]map[string]entities.RoleGrant, 0),
+	}
+}
+
+// Set the active grant of a user. A previous grant of the same user is replaced.
+func (m *MockupRoleGrantProvider) Set(grant entities.RoleGrant) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.grants[grant.OrganizationId]
+	if !exists {
+		users = make(map[string]entities.RoleGrant, 0)
+		m.grants[grant.OrganizationId] = users
+	}
+	users[grant.Email] = grant
+	return nil
+}
+
+// Get the active grant of a user.
+func (m *MockupRoleGrantProvider) Get(organizationID string, email string) (*entities.RoleGrant, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	grant, exists := m.grants[organizationID][email]
+	if !exists {
+		return nil, derrors.NewNotFoundError("role grant").WithParams(organizationID, email)
+	}
+	return &grant, nil
+}
+
+// Remove the grant of a user.
+func (m *MockupRoleGrantProvider) Remove(organizationID string, email string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	users, exists := m.grants[organizationID]
+	if !exists {
+		return derrors.NewNotFoundError("role grant").WithParams(organizationID, email)
+	}
+	if _, exists = users[email]; !exists {
+		return derrors.NewNotFoundError("role grant").WithParams(organizationID, email)
+	}
+	delete(users, email)
+	if len(users) == 0 {
+		delete(m.grants, organizationID)
+	}
+	return nil
+}
+
+// List the active grants of an organization.
+func (m *MockupRoleGrantProvider) List(organizationID string) ([]entities.RoleGrant, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RoleGrant, 0)
+	for _, grant := range m.grants[organizationID] {
+		result = append(result, grant)
+	}
+	return result, nil
+}
+
+// ListExpired lists the grants of all the organizations that expired before a given timestamp.
+func (m *MockupRoleGrantProvider) ListExpired(timestamp int64) ([]entities.RoleGrant, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RoleGrant, 0)
+	for _, users := range m.grants {
+		for _, grant := range users {
+			if grant.ExpiresAt <= timestamp {
+				result = append(result, grant)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Clear all the grants.
+func (m *MockupRoleGrantProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.grants = make(map[string]map[string]entities.RoleGrant, 0)
+	return nil
+}
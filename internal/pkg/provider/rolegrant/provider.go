@@ -0,0 +1,38 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolegrant
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the temporary role assignments.
+type Provider interface {
+	// Set the active grant of a user. A previous grant of the same user is replaced.
+	Set(grant entities.RoleGrant) derrors.Error
+	// Get the active grant of a user.
+	Get(organizationID string, email string) (*entities.RoleGrant, derrors.Error)
+	// Remove the grant of a user.
+	Remove(organizationID string, email string) derrors.Error
+	// List the active grants of an organization.
+	List(organizationID string) ([]entities.RoleGrant, derrors.Error)
+	// ListExpired lists the grants of all the organizations that expired before a given timestamp.
+	ListExpired(timestamp int64) ([]entities.RoleGrant, derrors.Error)
+	// Clear all the grants.
+	Clear() derrors.Error
+}
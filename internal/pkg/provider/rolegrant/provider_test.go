@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolegrant
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+func createGrant(organizationID string, email string, expiresAt int64) entities.RoleGrant {
+	return entities.RoleGrant{
+		OrganizationId: organizationID,
+		Email:          email,
+		RoleId:         "admin",
+		PreviousRoleId: "member",
+		GrantedAt:      1,
+		ExpiresAt:      expiresAt,
+	}
+}
+
+// RunTest registers the tests that every role grant provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove a grant", func() {
+		grant := createGrant("org", "user@mail.com", 10)
+		gomega.Expect(provider.Set(grant)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(grant))
+
+		gomega.Expect(provider.Remove("org", "user@mail.com")).To(gomega.Succeed())
+		_, err = provider.Get("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		err = provider.Remove("org", "user@mail.com")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous grant of a user", func() {
+		gomega.Expect(provider.Set(createGrant("org", "user@mail.com", 10))).To(gomega.Succeed())
+		updated := createGrant("org", "user@mail.com", 20)
+		updated.RevertError = "cannot revert"
+		gomega.Expect(provider.Set(updated)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "user@mail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(updated))
+	})
+
+	ginkgo.It("should be able to list the grants of an organization", func() {
+		gomega.Expect(provider.Set(createGrant("org", "user1@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user2@mail.com", 20))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("other", "user1@mail.com", 10))).To(gomega.Succeed())
+
+		grants, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(grants)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should list the expired grants of all the organizations", func() {
+		gomega.Expect(provider.Set(createGrant("org", "user1@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user2@mail.com", 30))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("other", "user1@mail.com", 20))).To(gomega.Succeed())
+
+		expired, err := provider.ListExpired(20)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(expired)).Should(gomega.Equal(2))
+		for _, grant := range expired {
+			gomega.Expect(grant.ExpiresAt).Should(gomega.BeNumerically("<=", 20))
+		}
+	})
+
+	ginkgo.It("should list the expired grants once with their last expiration", func() {
+		const hour = 3600
+		gomega.Expect(provider.Set(createGrant("org", "user1@mail.com", 10))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user2@mail.com", 2*hour+10))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user3@mail.com", 3*hour+10))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user3@mail.com", 10*hour))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(createGrant("org", "user4@mail.com", hour+10))).To(gomega.Succeed())
+		gomega.Expect(provider.Remove("org", "user4@mail.com")).To(gomega.Succeed())
+
+		expired, err := provider.ListExpired(5 * hour)
+		gomega.Expect(err).To(gomega.Succeed())
+		emails := make([]string, 0, len(expired))
+		for _, grant := range expired {
+			emails = append(emails, grant.Email)
+		}
+		gomega.Expect(emails).To(gomega.ConsistOf("user1@mail.com", "user2@mail.com"))
+
+		// the grants stay expired until they are removed
+		failed := createGrant("org", "user1@mail.com", 10)
+		failed.RevertError = "cannot revert"
+		gomega.Expect(provider.Set(failed)).To(gomega.Succeed())
+		gomega.Expect(provider.Remove("org", "user2@mail.com")).To(gomega.Succeed())
+		expired, err = provider.ListExpired(11 * hour)
+		gomega.Expect(err).To(gomega.Succeed())
+		emails = make([]string, 0, len(expired))
+		for _, grant := range expired {
+			emails = append(emails, grant.Email)
+		}
+		gomega.Expect(emails).To(gomega.ConsistOf("user1@mail.com", "user3@mail.com"))
+		expired, err = provider.ListExpired(12 * hour)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(expired).To(gomega.HaveLen(2))
+	})
+}
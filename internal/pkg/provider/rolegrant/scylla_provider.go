@@ -0,0 +1,227 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolegrant
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const roleGrantsTable = "role_grants"
+
+const roleGrantColumns = "organization_id, email, role_id, previous_role_id, granted_at, expires_at, revert_error"
+
+const expirationsTable = "role_grant_expirations"
+
+const expirationBucketsTable = "role_grant_expiration_buckets"
+
+// expirationBucketSeconds with the time range of the grants indexed in the same expiration bucket.
+const expirationBucketSeconds = 3600
+
+// expirationBucketsShard with the partition of the expiration buckets. All of them are kept in one partition so they
+// are listed in order without reading the whole table.
+const expirationBucketsShard = 0
+
+// ScyllaRoleGrantProvider is a ScyllaDB implementation of the role grant provider. The grants are also indexed by
+// their expiration in hourly buckets, and the buckets with grants are registered, so the expired grants are listed
+// reading only the buckets that have passed.
+type ScyllaRoleGrantProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaRoleGrantProvider creates a provider that stores the grants in the keyspace of a session.
+func NewScyllaRoleGrantProvider(session *scylladb.Session) *ScyllaRoleGrantProvider {
+	return &ScyllaRoleGrantProvider{session: session}
+}
+
+// Set the active grant of a user. A previous grant of the same user is replaced.
+func (sp *ScyllaRoleGrantProvider) Set(grant entities.RoleGrant) derrors.Error {
+	previous, err := sp.Get(grant.OrganizationId, grant.Email)
+	if err != nil && err.Type() != derrors.NotFound {
+		return err
+	}
+	// the new expiration is indexed before the grant is stored so a grant is never missing from the index
+	err = sp.session.Exec("INSERT INTO "+expirationBucketsTable+" (shard, bucket) VALUES (?, ?)",
+		expirationBucketsShard, expirationBucket(grant.ExpiresAt))
+	if err != nil {
+		return err
+	}
+	err = sp.session.Exec("INSERT INTO "+expirationsTable+" (bucket, expires_at, organization_id, email) VALUES (?, ?, ?, ?)",
+		expirationBucket(grant.ExpiresAt), grant.ExpiresAt, grant.OrganizationId, grant.Email)
+	if err != nil {
+		return err
+	}
+	err = sp.session.Exec("INSERT INTO "+roleGrantsTable+" ("+roleGrantColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		grant.OrganizationId, grant.Email, grant.RoleId, grant.PreviousRoleId, grant.GrantedAt, grant.ExpiresAt, grant.RevertError)
+	if err != nil {
+		return err
+	}
+	if previous != nil && previous.ExpiresAt != grant.ExpiresAt {
+		return sp.removeExpiration(grant.OrganizationId, grant.Email, previous.ExpiresAt)
+	}
+	return nil
+}
+
+// expirationBucket obtains the bucket of the index of a grant expiring at a given timestamp.
+func expirationBucket(expiresAt int64) int64 {
+	return expiresAt / expirationBucketSeconds
+}
+
+// removeExpiration removes the entry of a grant from the expiration index.
+func (sp *ScyllaRoleGrantProvider) removeExpiration(organizationID string, email string, expiresAt int64) derrors.Error {
+	return sp.session.Exec("DELETE FROM "+expirationsTable+" WHERE bucket = ? AND expires_at = ? AND organization_id = ? AND email = ?",
+		expirationBucket(expiresAt), expiresAt, organizationID, email)
+}
+
+// Get the active grant of a user.
+func (sp *ScyllaRoleGrantProvider) Get(organizationID string, email string) (*entities.RoleGrant, derrors.Error) {
+	var grant entities.RoleGrant
+	found, err := sp.session.Scan("SELECT "+roleGrantColumns+" FROM "+roleGrantsTable+" WHERE organization_id = ? AND email = ?",
+		[]interface{}{organizationID, email}, &grant.OrganizationId, &grant.Email, &grant.RoleId, &grant.PreviousRoleId,
+		&grant.GrantedAt, &grant.ExpiresAt, &grant.RevertError)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("role grant").WithParams(organizationID, email)
+	}
+	return &grant, nil
+}
+
+// Remove the grant of a user.
+func (sp *ScyllaRoleGrantProvider) Remove(organizationID string, email string) derrors.Error {
+	grant, err := sp.Get(organizationID, email)
+	if err != nil {
+		return err
+	}
+	applied, err := sp.session.ExecCAS("DELETE FROM "+roleGrantsTable+" WHERE organization_id = ? AND email = ? IF EXISTS",
+		organizationID, email)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("role grant").WithParams(organizationID, email)
+	}
+	return sp.removeExpiration(organizationID, email, grant.ExpiresAt)
+}
+
+// list the grants returned by a query that satisfy a filter.
+func (sp *ScyllaRoleGrantProvider) list(stmt string, values []interface{}, filter func(grant entities.RoleGrant) bool) ([]entities.RoleGrant, derrors.Error) {
+	result := make([]entities.RoleGrant, 0)
+	err := sp.session.Iterate(stmt, values, func(scanner gocql.Scanner) error {
+		var grant entities.RoleGrant
+		sErr := scanner.Scan(&grant.OrganizationId, &grant.Email, &grant.RoleId, &grant.PreviousRoleId,
+			&grant.GrantedAt, &grant.ExpiresAt, &grant.RevertError)
+		if sErr == nil && filter(grant) {
+			result = append(result, grant)
+		}
+		return sErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// List the active grants of an organization.
+func (sp *ScyllaRoleGrantProvider) List(organizationID string) ([]entities.RoleGrant, derrors.Error) {
+	return sp.list("SELECT "+roleGrantColumns+" FROM "+roleGrantsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(grant entities.RoleGrant) bool {
+			return true
+		})
+}
+
+// ListExpired lists the grants of all the organizations that expired before a given timestamp. Only the expiration
+// buckets up to the timestamp are read. The index entries of grants that were replaced or removed are discarded, and
+// so are the buckets that are empty once the grants can no longer be set in them.
+func (sp *ScyllaRoleGrantProvider) ListExpired(timestamp int64) ([]entities.RoleGrant, derrors.Error) {
+	buckets := make([]int64, 0)
+	err := sp.session.Iterate("SELECT bucket FROM "+expirationBucketsTable+" WHERE shard = ? AND bucket <= ?",
+		[]interface{}{expirationBucketsShard, expirationBucket(timestamp)}, func(scanner gocql.Scanner) error {
+			var bucket int64
+			sErr := scanner.Scan(&bucket)
+			if sErr == nil {
+				buckets = append(buckets, bucket)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.RoleGrant, 0)
+	for _, bucket := range buckets {
+		expired, lErr := sp.listExpiredInBucket(bucket, timestamp)
+		if lErr != nil {
+			return nil, lErr
+		}
+		result = append(result, expired...)
+		// grants are not expected to be set with an expiration that has already passed for more than a bucket
+		if len(expired) == 0 && (bucket+2)*expirationBucketSeconds <= timestamp {
+			dErr := sp.session.Exec("DELETE FROM "+expirationBucketsTable+" WHERE shard = ? AND bucket = ?",
+				expirationBucketsShard, bucket)
+			if dErr != nil {
+				return nil, dErr
+			}
+		}
+	}
+	return result, nil
+}
+
+// listExpiredInBucket lists the grants of an expiration bucket that expired before a given timestamp.
+func (sp *ScyllaRoleGrantProvider) listExpiredInBucket(bucket int64, timestamp int64) ([]entities.RoleGrant, derrors.Error) {
+	type expiration struct {
+		organizationID string
+		email          string
+		expiresAt      int64
+	}
+	entries := make([]expiration, 0)
+	err := sp.session.Iterate("SELECT organization_id, email, expires_at FROM "+expirationsTable+" WHERE bucket = ? AND expires_at <= ?",
+		[]interface{}{bucket, timestamp}, func(scanner gocql.Scanner) error {
+			var entry expiration
+			sErr := scanner.Scan(&entry.organizationID, &entry.email, &entry.expiresAt)
+			if sErr == nil {
+				entries = append(entries, entry)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.RoleGrant, 0, len(entries))
+	for _, entry := range entries {
+		grant, gErr := sp.Get(entry.organizationID, entry.email)
+		if gErr != nil && gErr.Type() != derrors.NotFound {
+			return nil, gErr
+		}
+		if gErr != nil || grant.ExpiresAt != entry.expiresAt {
+			rErr := sp.removeExpiration(entry.organizationID, entry.email, entry.expiresAt)
+			if rErr != nil {
+				return nil, rErr
+			}
+			continue
+		}
+		result = append(result, *grant)
+	}
+	return result, nil
+}
+
+// Clear all the grants.
+func (sp *ScyllaRoleGrantProvider) Clear() derrors.Error {
+	return sp.session.Truncate(roleGrantsTable, expirationsTable, expirationBucketsTable)
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolehierarchy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sort"
+	"sync"
+)
+
+// MockupRoleHierarchyProvider is an in-memory implementation of the role hierarchy provider.
+type MockupRoleHierarchyProvider struct {
+	sync.Mutex
+	// parents indexed by organization_id and role_id.
+	parents map[string]map[string]entities.RoleParents
+}
+
+// NewMockupRoleHierarchyProvider creates an empty in-memory provider.
+func NewMockupRoleHierarchyProvider() *MockupRoleHierarchyProvider {
+	return &MockupRoleHierarchyProvider{
+		parents: make(map[string]map[string]entities.RoleParents, 0),
+	}
+}
+
+// Set the parents of a role. Previous parents of the same role are replaced.
+func (m *MockupRoleHierarchyProvider) Set(parents entities.RoleParents) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	organization, exists := m.parents[parents.OrganizationId]
+	if !exists {
+		organization = make(map[string]entities.RoleParents, 0)
+		m.parents[parents.OrganizationId] = organization
+	}
+	organization[parents.RoleId] = *entities.NewRoleParents(parents.OrganizationId, parents.RoleId, parents.ParentRoleIds, parents.Primitives)
+	return nil
+}
+
+// Get the parents of a role.
+func (m *MockupRoleHierarchyProvider) Get(organizationID string, roleID string) (*entities.RoleParents, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	parents, exists := m.parents[organizationID][roleID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("role parents").WithParams(organizationID, roleID)
+	}
+	return entities.NewRoleParents(parents.OrganizationId, parents.RoleId, parents.ParentRoleIds, parents.Primitives), nil
+}
+
+// List the parents of the roles of an organization.
+func (m *MockupRoleHierarchyProvider) List(organizationID string) ([]entities.RoleParents, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RoleParents, 0, len(m.parents[organizationID]))
+	for _, parents := range m.parents[organizationID] {
+		result = append(result, *entities.NewRoleParents(parents.OrganizationId, parents.RoleId, parents.ParentRoleIds, parents.Primitives))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RoleId < result[j].RoleId
+	})
+	return result, nil
+}
+
+// Remove the parents of a role.
+func (m *MockupRoleHierarchyProvider) Remove(organizationID string, roleID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.parents[organizationID][roleID]; !exists {
+		return derrors.NewNotFoundError("role parents").WithParams(organizationID, roleID)
+	}
+	delete(m.parents[organizationID], roleID)
+	return nil
+}
+
+// Clear all the parents.
+func (m *MockupRoleHierarchyProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.parents = make(map[string]map[string]entities.RoleParents, 0)
+	return nil
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolehierarchy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the parent roles of the roles of the organizations and the primitives those roles declare.
+type Provider interface {
+	// Set the parents of a role. Previous parents of the same role are replaced.
+	Set(parents entities.RoleParents) derrors.Error
+	// Get the parents of a role.
+	Get(organizationID string, roleID string) (*entities.RoleParents, derrors.Error)
+	// List the parents of the roles of an organization.
+	List(organizationID string) ([]entities.RoleParents, derrors.Error)
+	// Remove the parents of a role.
+	Remove(organizationID string, roleID string) derrors.Error
+	// Clear all the parents.
+	Clear() derrors.Error
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolehierarchy
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every role hierarchy provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to set, retrieve and remove the parents of a role", func() {
+		parents := entities.NewRoleParents("org", "child", []string{"parent1", "parent2"},
+			[]grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS, grpc_authx_go.AccessPrimitive_PROFILE})
+		gomega.Expect(provider.Set(*parents)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "child")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(*parents))
+
+		gomega.Expect(provider.Remove("org", "child")).To(gomega.Succeed())
+		_, err = provider.Get("org", "child")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+		err = provider.Remove("org", "child")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should replace the previous parents of a role", func() {
+		gomega.Expect(provider.Set(*entities.NewRoleParents("org", "child", []string{"parent1"},
+			[]grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(*entities.NewRoleParents("org", "child", []string{"parent2"}, nil))).To(gomega.Succeed())
+
+		retrieved, err := provider.Get("org", "child")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.ParentRoleIds).Should(gomega.Equal([]string{"parent2"}))
+		gomega.Expect(retrieved.Primitives).Should(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should list the parents of the roles of an organization sorted by role", func() {
+		gomega.Expect(provider.Set(*entities.NewRoleParents("org", "role2", []string{"parent"}, nil))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(*entities.NewRoleParents("org", "role1", []string{"parent"}, nil))).To(gomega.Succeed())
+		gomega.Expect(provider.Set(*entities.NewRoleParents("other", "role3", []string{"parent"}, nil))).To(gomega.Succeed())
+
+		parents, err := provider.List("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(parents)).Should(gomega.Equal(2))
+		gomega.Expect(parents[0].RoleId).Should(gomega.Equal("role1"))
+		gomega.Expect(parents[1].RoleId).Should(gomega.Equal("role2"))
+	})
+}
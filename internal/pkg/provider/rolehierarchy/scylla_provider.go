@@ -0,0 +1,127 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package rolehierarchy
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const roleParentsTable = "role_parents"
+
+// ScyllaRoleHierarchyProvider is a ScyllaDB implementation of the role hierarchy provider.
+type ScyllaRoleHierarchyProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaRoleHierarchyProvider creates a provider that stores the parents in the keyspace of a session.
+func NewScyllaRoleHierarchyProvider(session *scylladb.Session) *ScyllaRoleHierarchyProvider {
+	return &ScyllaRoleHierarchyProvider{session: session}
+}
+
+// primitiveNames converts the primitives into the names stored in the database.
+func primitiveNames(primitives []grpc_authx_go.AccessPrimitive) []string {
+	result := make([]string, 0, len(primitives))
+	for _, primitive := range primitives {
+		result = append(result, primitive.String())
+	}
+	return result
+}
+
+// namedPrimitives converts the names stored in the database into primitives.
+func namedPrimitives(names []string) ([]grpc_authx_go.AccessPrimitive, derrors.Error) {
+	result := make([]grpc_authx_go.AccessPrimitive, 0, len(names))
+	for _, name := range names {
+		value, exists := grpc_authx_go.AccessPrimitive_value[name]
+		if !exists {
+			return nil, derrors.NewInternalError("unknown access primitive").WithParams(name)
+		}
+		result = append(result, grpc_authx_go.AccessPrimitive(value))
+	}
+	return result, nil
+}
+
+// Set the parents of a role. Previous parents of the same role are replaced.
+func (sp *ScyllaRoleHierarchyProvider) Set(parents entities.RoleParents) derrors.Error {
+	return sp.session.Exec("INSERT INTO "+roleParentsTable+" (organization_id, role_id, parent_role_ids, primitives) VALUES (?, ?, ?, ?)",
+		parents.OrganizationId, parents.RoleId, parents.ParentRoleIds, primitiveNames(parents.Primitives))
+}
+
+// Get the parents of a role.
+func (sp *ScyllaRoleHierarchyProvider) Get(organizationID string, roleID string) (*entities.RoleParents, derrors.Error) {
+	var parentRoleIDs []string
+	var names []string
+	found, err := sp.session.Scan("SELECT parent_role_ids, primitives FROM "+roleParentsTable+" WHERE organization_id = ? AND role_id = ?",
+		[]interface{}{organizationID, roleID}, &parentRoleIDs, &names)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("role parents").WithParams(organizationID, roleID)
+	}
+	primitives, err := namedPrimitives(names)
+	if err != nil {
+		return nil, err
+	}
+	return entities.NewRoleParents(organizationID, roleID, parentRoleIDs, primitives), nil
+}
+
+// List the parents of the roles of an organization sorted by role.
+func (sp *ScyllaRoleHierarchyProvider) List(organizationID string) ([]entities.RoleParents, derrors.Error) {
+	result := make([]entities.RoleParents, 0)
+	err := sp.session.Iterate("SELECT role_id, parent_role_ids, primitives FROM "+roleParentsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var roleID string
+			var parentRoleIDs []string
+			var names []string
+			sErr := scanner.Scan(&roleID, &parentRoleIDs, &names)
+			if sErr != nil {
+				return sErr
+			}
+			primitives, pErr := namedPrimitives(names)
+			if pErr != nil {
+				return pErr
+			}
+			result = append(result, *entities.NewRoleParents(organizationID, roleID, parentRoleIDs, primitives))
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Remove the parents of a role.
+func (sp *ScyllaRoleHierarchyProvider) Remove(organizationID string, roleID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+roleParentsTable+" WHERE organization_id = ? AND role_id = ? IF EXISTS",
+		organizationID, roleID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("role parents").WithParams(organizationID, roleID)
+	}
+	return nil
+}
+
+// Clear all the parents.
+func (sp *ScyllaRoleHierarchyProvider) Clear() derrors.Error {
+	return sp.session.Truncate(roleParentsTable)
+}
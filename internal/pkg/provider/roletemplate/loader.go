@@ -0,0 +1,59 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package roletemplate
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// LoadDirectory reads the role templates of a directory and stores them in the provider. Each YAML file of the
+// directory contains one template.
+func LoadDirectory(path string, provider Provider) derrors.Error {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return derrors.AsError(err, "cannot read role templates directory")
+	}
+	for _, file := range files {
+		extension := strings.ToLower(filepath.Ext(file.Name()))
+		if file.IsDir() || (extension != ".yaml" && extension != ".yml") {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(path, file.Name()))
+		if err != nil {
+			return derrors.NewInternalError("cannot read role template file", err).WithParams(file.Name())
+		}
+		template := entities.RoleTemplate{}
+		err = yaml.UnmarshalStrict(content, &template)
+		if err != nil {
+			return derrors.NewInvalidArgumentError("cannot decode role template file", err).WithParams(file.Name())
+		}
+		vErr := entities.ValidRoleTemplate(&template)
+		if vErr != nil {
+			return vErr
+		}
+		aErr := provider.Add(template)
+		if aErr != nil {
+			return aErr
+		}
+	}
+	return nil
+}
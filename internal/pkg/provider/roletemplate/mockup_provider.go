@@ -0,0 +1,83 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package roletemplate
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sort"
+	"sync"
+)
+
+// MockupRoleTemplateProvider is an in-memory implementation of the role template provider.
+type MockupRoleTemplateProvider struct {
+	sync.Mutex
+	// templates indexed by name.
+	templates map[string]entities.RoleTemplate
+}
+
+// NewMockupRoleTemplateProvider creates an empty in-memory provider.
+func NewMockupRoleTemplateProvider() *MockupRoleTemplateProvider {
+	return &MockupRoleTemplateProvider{
+		templates: make(map[string]entities.RoleTemplate, 0),
+	}
+}
+
+// Add a new template.
+func (m *MockupRoleTemplateProvider) Add(template entities.RoleTemplate) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.templates[template.Name]; exists {
+		return derrors.NewAlreadyExistsError("role template").WithParams(template.Name)
+	}
+	template.Roles = append([]entities.TemplateRole{}, template.Roles...)
+	m.templates[template.Name] = template
+	return nil
+}
+
+// Get a template by its name.
+func (m *MockupRoleTemplateProvider) Get(name string) (*entities.RoleTemplate, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	template, exists := m.templates[name]
+	if !exists {
+		return nil, derrors.NewNotFoundError("role template").WithParams(name)
+	}
+	return &template, nil
+}
+
+// List all the templates sorted by name.
+func (m *MockupRoleTemplateProvider) List() ([]entities.RoleTemplate, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.RoleTemplate, 0, len(m.templates))
+	for _, template := range m.templates {
+		result = append(result, template)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Clear all the templates.
+func (m *MockupRoleTemplateProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.templates = make(map[string]entities.RoleTemplate, 0)
+	return nil
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package roletemplate
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the role templates.
+type Provider interface {
+	// Add a new template.
+	Add(template entities.RoleTemplate) derrors.Error
+	// Get a template by its name.
+	Get(name string) (*entities.RoleTemplate, derrors.Error)
+	// List all the templates.
+	List() ([]entities.RoleTemplate, derrors.Error)
+	// Clear all the templates.
+	Clear() derrors.Error
+}
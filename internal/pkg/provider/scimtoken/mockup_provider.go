@@ -0,0 +1,89 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scimtoken
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupScimTokenProvider is an in-memory implementation of the SCIM token provider.
+type MockupScimTokenProvider struct {
+	sync.Mutex
+	// tokens indexed by hash.
+	tokens map[string]entities.ScimToken
+}
+
+// NewMockupScimTokenProvider creates an empty in-memory provider.
+func NewMockupScimTokenProvider() *MockupScimTokenProvider {
+	return &MockupScimTokenProvider{
+		tokens: make(map[string]entities.ScimToken, 0),
+	}
+}
+
+// Add a token.
+func (m *MockupScimTokenProvider) Add(token entities.ScimToken) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.tokens[token.TokenHash]; exists {
+		return derrors.NewAlreadyExistsError("scim token").WithParams(token.OrganizationId)
+	}
+	m.tokens[token.TokenHash] = token
+	return nil
+}
+
+// Get a token by its hash.
+func (m *MockupScimTokenProvider) Get(tokenHash string) (*entities.ScimToken, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	token, exists := m.tokens[tokenHash]
+	if !exists {
+		return nil, derrors.NewNotFoundError("scim token")
+	}
+	return &token, nil
+}
+
+// List all the tokens.
+func (m *MockupScimTokenProvider) List() ([]entities.ScimToken, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.ScimToken, 0, len(m.tokens))
+	for _, token := range m.tokens {
+		result = append(result, token)
+	}
+	return result, nil
+}
+
+// Remove a token by its hash.
+func (m *MockupScimTokenProvider) Remove(tokenHash string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.tokens[tokenHash]; !exists {
+		return derrors.NewNotFoundError("scim token")
+	}
+	delete(m.tokens, tokenHash)
+	return nil
+}
+
+// Clear all the tokens.
+func (m *MockupScimTokenProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.tokens = make(map[string]entities.ScimToken, 0)
+	return nil
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scimtoken
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the tokens of the SCIM endpoint.
+type Provider interface {
+	// Add a token.
+	Add(token entities.ScimToken) derrors.Error
+	// Get a token by its hash.
+	Get(tokenHash string) (*entities.ScimToken, derrors.Error)
+	// List all the tokens.
+	List() ([]entities.ScimToken, derrors.Error)
+	// Remove a token by its hash.
+	Remove(tokenHash string) derrors.Error
+	// Clear all the tokens.
+	Clear() derrors.Error
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scimtoken
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every SCIM token provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to add and retrieve a token", func() {
+		token := entities.NewScimToken("org", "token", "role")
+		gomega.Expect(provider.Add(*token)).To(gomega.Succeed())
+
+		retrieved, err := provider.Get(token.TokenHash)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(*token))
+
+		err = provider.Add(*token)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+	})
+
+	ginkgo.It("should be able to list and remove the tokens", func() {
+		gomega.Expect(provider.Add(*entities.NewScimToken("org", "token1", "role"))).To(gomega.Succeed())
+		gomega.Expect(provider.Add(*entities.NewScimToken("other", "token2", "role"))).To(gomega.Succeed())
+
+		tokens, err := provider.List()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(tokens)).Should(gomega.Equal(2))
+
+		hash := entities.HashToken("token1")
+		gomega.Expect(provider.Remove(hash)).To(gomega.Succeed())
+		_, err = provider.Get(hash)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		err = provider.Remove(hash)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scimtoken
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const scimTokensTable = "scim_tokens"
+
+// ScyllaScimTokenProvider is a ScyllaDB implementation of the SCIM token provider.
+type ScyllaScimTokenProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaScimTokenProvider creates a provider that stores the tokens in the keyspace of a session.
+func NewScyllaScimTokenProvider(session *scylladb.Session) *ScyllaScimTokenProvider {
+	return &ScyllaScimTokenProvider{session: session}
+}
+
+// Add a token.
+func (sp *ScyllaScimTokenProvider) Add(token entities.ScimToken) derrors.Error {
+	applied, err := sp.session.ExecCAS("INSERT INTO "+scimTokensTable+" (token_hash, organization_id, default_role_id) VALUES (?, ?, ?) IF NOT EXISTS",
+		token.TokenHash, token.OrganizationId, token.DefaultRoleId)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("scim token").WithParams(token.OrganizationId)
+	}
+	return nil
+}
+
+// Get a token by its hash.
+func (sp *ScyllaScimTokenProvider) Get(tokenHash string) (*entities.ScimToken, derrors.Error) {
+	var token entities.ScimToken
+	found, err := sp.session.Scan("SELECT token_hash, organization_id, default_role_id FROM "+scimTokensTable+" WHERE token_hash = ?",
+		[]interface{}{tokenHash}, &token.TokenHash, &token.OrganizationId, &token.DefaultRoleId)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("scim token")
+	}
+	return &token, nil
+}
+
+// List all the tokens.
+func (sp *ScyllaScimTokenProvider) List() ([]entities.ScimToken, derrors.Error) {
+	result := make([]entities.ScimToken, 0)
+	err := sp.session.Iterate("SELECT token_hash, organization_id, default_role_id FROM "+scimTokensTable, nil,
+		func(scanner gocql.Scanner) error {
+			var token entities.ScimToken
+			sErr := scanner.Scan(&token.TokenHash, &token.OrganizationId, &token.DefaultRoleId)
+			if sErr == nil {
+				result = append(result, token)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Remove a token by its hash.
+func (sp *ScyllaScimTokenProvider) Remove(tokenHash string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+scimTokensTable+" WHERE token_hash = ? IF EXISTS", tokenHash)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("scim token")
+	}
+	return nil
+}
+
+// Clear all the tokens.
+func (sp *ScyllaScimTokenProvider) Clear() derrors.Error {
+	return sp.session.Truncate(scimTokensTable)
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scylladb
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+// Session with the connection to the keyspace of the user manager shared by the ScyllaDB providers. The connection is
+// opened the first time it is used and opened again if it has been closed.
+type Session struct {
+	sync.Mutex
+	// Address of a node of the cluster.
+	Address string
+	// Port of the CQL native protocol.
+	Port int
+	// Keyspace with the tables of the providers.
+	Keyspace string
+	session  *gocql.Session
+}
+
+// NewSession creates a session that is not connected yet.
+func NewSession(address string, port int, keyspace string) *Session {
+	return &Session{
+		Address:  address,
+		Port:     port,
+		Keyspace: keyspace,
+	}
+}
+
+// Connect opens the connection with the cluster if it is not open.
+func (s *Session) Connect() (*gocql.Session, derrors.Error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.session != nil && !s.session.Closed() {
+		return s.session, nil
+	}
+	cluster := gocql.NewCluster(s.Address)
+	cluster.Port = s.Port
+	cluster.Keyspace = s.Keyspace
+	session, err := cluster.CreateSession()
+	if err != nil {
+		log.Error().Str("address", s.Address).Int("port", s.Port).Str("keyspace", s.Keyspace).Err(err).
+			Msg("unable to connect to ScyllaDB")
+		return nil, derrors.AsError(err, "cannot connect to ScyllaDB")
+	}
+	s.session = session
+	return session, nil
+}
+
+// Disconnect closes the connection with the cluster.
+func (s *Session) Disconnect() {
+	s.Lock()
+	defer s.Unlock()
+	if s.session != nil {
+		s.session.Close()
+		s.session = nil
+	}
+}
+
+// Exec runs a statement that does not return rows.
+func (s *Session) Exec(stmt string, values ...interface{}) derrors.Error {
+	session, err := s.Connect()
+	if err != nil {
+		return err
+	}
+	qErr := session.Query(stmt, values...).Exec()
+	if qErr != nil {
+		return derrors.AsError(qErr, "cannot execute statement")
+	}
+	return nil
+}
+
+// ExecCAS runs a lightweight transaction and returns whether its condition held and it was applied.
+func (s *Session) ExecCAS(stmt string, values ...interface{}) (bool, derrors.Error) {
+	session, err := s.Connect()
+	if err != nil {
+		return false, err
+	}
+	applied, qErr := session.Query(stmt, values...).MapScanCAS(make(map[string]interface{}, 0))
+	if qErr != nil {
+		return false, derrors.AsError(qErr, "cannot execute conditional statement")
+	}
+	return applied, nil
+}
+
+// Scan reads the columns of a single row into dest. It returns false if there is no such row.
+func (s *Session) Scan(stmt string, values []interface{}, dest ...interface{}) (bool, derrors.Error) {
+	session, err := s.Connect()
+	if err != nil {
+		return false, err
+	}
+	qErr := session.Query(stmt, values...).Scan(dest...)
+	if qErr == gocql.ErrNotFound {
+		return false, nil
+	}
+	if qErr != nil {
+		return false, derrors.AsError(qErr, "cannot execute query")
+	}
+	return true, nil
+}
+
+// Iterate runs a query and calls scan for each of the rows it returns.
+func (s *Session) Iterate(stmt string, values []interface{}, scan func(scanner gocql.Scanner) error) derrors.Error {
+	session, err := s.Connect()
+	if err != nil {
+		return err
+	}
+	scanner := session.Query(stmt, values...).Iter().Scanner()
+	for scanner.Next() {
+		sErr := scan(scanner)
+		if sErr != nil {
+			_ = scanner.Err()
+			return derrors.AsError(sErr, "cannot read row")
+		}
+	}
+	qErr := scanner.Err()
+	if qErr != nil {
+		return derrors.AsError(qErr, "cannot execute query")
+	}
+	return nil
+}
+
+// Truncate removes all the rows of a set of tables.
+func (s *Session) Truncate(tables ...string) derrors.Error {
+	for _, table := range tables {
+		err := s.Exec("TRUNCATE " + table)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceaccount
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+)
+
+// MockupServiceAccountProvider is an in-memory implementation of the service account provider.
+type MockupServiceAccountProvider struct {
+	sync.Mutex
+	// accounts indexed by organization_id and service_account_id.
+	accounts map[string]map[string]entities.ServiceAccount
+	// keys indexed by key_id.
+	keys map[string]entities.ApiKey
+}
+
+// NewMockupServiceAccountProvider creates an empty in-memory provider.
+func NewMockupServiceAccountProvider() *MockupServiceAccountProvider {
+	return &MockupServiceAccountProvider{
+		accounts: make(map[string]map[string]entities.ServiceAccount, 0),
+		keys:     make(map[string]entities.ApiKey, 0),
+	}
+}
+
+// AddServiceAccount adds a new service account.
+func (m *MockupServiceAccountProvider) AddServiceAccount(account entities.ServiceAccount) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	accounts, exists := m.accounts[account.OrganizationId]
+	if !exists {
+		accounts = make(map[string]entities.ServiceAccount, 0)
+		m.accounts[account.OrganizationId] = accounts
+	}
+	if _, exists := accounts[account.ServiceAccountId]; exists {
+		return derrors.NewAlreadyExistsError("service account").WithParams(account.OrganizationId, account.ServiceAccountId)
+	}
+	for _, current := range accounts {
+		if current.Name == account.Name {
+			return derrors.NewAlreadyExistsError("service account name").WithParams(account.OrganizationId, account.Name)
+		}
+	}
+	accounts[account.ServiceAccountId] = account
+	return nil
+}
+
+// GetServiceAccount retrieves a service account.
+func (m *MockupServiceAccountProvider) GetServiceAccount(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	account, exists := m.accounts[organizationID][serviceAccountID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	return &account, nil
+}
+
+// ListServiceAccounts lists the service accounts of an organization.
+func (m *MockupServiceAccountProvider) ListServiceAccounts(organizationID string) ([]entities.ServiceAccount, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.ServiceAccount, 0)
+	for _, account := range m.accounts[organizationID] {
+		result = append(result, account)
+	}
+	return result, nil
+}
+
+// RemoveServiceAccount removes a service account and all its keys.
+func (m *MockupServiceAccountProvider) RemoveServiceAccount(organizationID string, serviceAccountID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	accounts, exists := m.accounts[organizationID]
+	if !exists {
+		return derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	if _, exists = accounts[serviceAccountID]; !exists {
+		return derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	delete(accounts, serviceAccountID)
+	if len(accounts) == 0 {
+		delete(m.accounts, organizationID)
+	}
+	for keyID, key := range m.keys {
+		if key.OrganizationId == organizationID && key.ServiceAccountId == serviceAccountID {
+			delete(m.keys, keyID)
+		}
+	}
+	return nil
+}
+
+// AddApiKey adds a new key.
+func (m *MockupServiceAccountProvider) AddApiKey(key entities.ApiKey) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.accounts[key.OrganizationId][key.ServiceAccountId]; !exists {
+		return derrors.NewNotFoundError("service account").WithParams(key.OrganizationId, key.ServiceAccountId)
+	}
+	if _, exists := m.keys[key.KeyId]; exists {
+		return derrors.NewAlreadyExistsError("api key").WithParams(key.KeyId)
+	}
+	key.Scopes = append([]string{}, key.Scopes...)
+	m.keys[key.KeyId] = key
+	return nil
+}
+
+// GetApiKey retrieves a key by its identifier.
+func (m *MockupServiceAccountProvider) GetApiKey(keyID string) (*entities.ApiKey, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	key, exists := m.keys[keyID]
+	if !exists {
+		return nil, derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	key.Scopes = append([]string{}, key.Scopes...)
+	return &key, nil
+}
+
+// ListApiKeys lists the keys of a service account.
+func (m *MockupServiceAccountProvider) ListApiKeys(organizationID string, serviceAccountID string) ([]entities.ApiKey, derrors.Error) {
+	m.Lock()
+	defer m.Unlock()
+	result := make([]entities.ApiKey, 0)
+	for _, key := range m.keys {
+		if key.OrganizationId == organizationID && key.ServiceAccountId == serviceAccountID {
+			key.Scopes = append([]string{}, key.Scopes...)
+			result = append(result, key)
+		}
+	}
+	return result, nil
+}
+
+// UpdateLastUsed sets the timestamp of the last use of a key.
+func (m *MockupServiceAccountProvider) UpdateLastUsed(keyID string, timestamp int64) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	key, exists := m.keys[keyID]
+	if !exists {
+		return derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	key.LastUsed = timestamp
+	m.keys[keyID] = key
+	return nil
+}
+
+// RemoveApiKey removes a key.
+func (m *MockupServiceAccountProvider) RemoveApiKey(keyID string) derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	if _, exists := m.keys[keyID]; !exists {
+		return derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	delete(m.keys, keyID)
+	return nil
+}
+
+// Clear all the service accounts and keys.
+func (m *MockupServiceAccountProvider) Clear() derrors.Error {
+	m.Lock()
+	defer m.Unlock()
+	m.accounts = make(map[string]map[string]entities.ServiceAccount, 0)
+	m.keys = make(map[string]entities.ApiKey, 0)
+	return nil
+}
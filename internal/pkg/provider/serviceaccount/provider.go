@@ -0,0 +1,46 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceaccount
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+)
+
+// Provider for the service accounts and their API keys.
+type Provider interface {
+	// AddServiceAccount adds a new service account.
+	AddServiceAccount(account entities.ServiceAccount) derrors.Error
+	// GetServiceAccount retrieves a service account.
+	GetServiceAccount(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error)
+	// ListServiceAccounts lists the service accounts of an organization.
+	ListServiceAccounts(organizationID string) ([]entities.ServiceAccount, derrors.Error)
+	// RemoveServiceAccount removes a service account and all its keys.
+	RemoveServiceAccount(organizationID string, serviceAccountID string) derrors.Error
+	// AddApiKey adds a new key.
+	AddApiKey(key entities.ApiKey) derrors.Error
+	// GetApiKey retrieves a key by its identifier.
+	GetApiKey(keyID string) (*entities.ApiKey, derrors.Error)
+	// ListApiKeys lists the keys of a service account.
+	ListApiKeys(organizationID string, serviceAccountID string) ([]entities.ApiKey, derrors.Error)
+	// UpdateLastUsed sets the timestamp of the last use of a key.
+	UpdateLastUsed(keyID string, timestamp int64) derrors.Error
+	// RemoveApiKey removes a key.
+	RemoveApiKey(keyID string) derrors.Error
+	// Clear all the service accounts and keys.
+	Clear() derrors.Error
+}
@@ -0,0 +1,160 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceaccount
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+// RunTest registers the tests that every service account provider must pass.
+func RunTest(provider Provider) {
+
+	ginkgo.BeforeEach(func() {
+		gomega.Expect(provider.Clear()).To(gomega.Succeed())
+	})
+
+	account := func(organizationID string, id string, name string) entities.ServiceAccount {
+		return entities.ServiceAccount{
+			OrganizationId:   organizationID,
+			ServiceAccountId: id,
+			Name:             name,
+			Description:      "description",
+			RoleId:           "role",
+			Created:          1,
+		}
+	}
+
+	apiKey := func(account entities.ServiceAccount, id string) entities.ApiKey {
+		return entities.ApiKey{
+			OrganizationId:   account.OrganizationId,
+			ServiceAccountId: account.ServiceAccountId,
+			KeyId:            id,
+			KeyHash:          entities.HashToken(id),
+			Scopes:           []string{"ORG"},
+			Created:          1,
+			ExpiresAt:        2,
+		}
+	}
+
+	ginkgo.It("should be able to add and retrieve a service account", func() {
+		sa := account("org", "sa1", "ci")
+		gomega.Expect(provider.AddServiceAccount(sa)).To(gomega.Succeed())
+
+		retrieved, err := provider.GetServiceAccount("org", "sa1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(sa))
+
+		err = provider.AddServiceAccount(sa)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+	})
+
+	ginkgo.It("should reject a service account with a name already in use", func() {
+		gomega.Expect(provider.AddServiceAccount(account("org", "sa1", "ci"))).To(gomega.Succeed())
+		err := provider.AddServiceAccount(account("org", "sa2", "ci"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+		gomega.Expect(provider.AddServiceAccount(account("other", "sa2", "ci"))).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should be able to list the service accounts of an organization", func() {
+		gomega.Expect(provider.AddServiceAccount(account("org", "sa1", "ci"))).To(gomega.Succeed())
+		gomega.Expect(provider.AddServiceAccount(account("org", "sa2", "backup"))).To(gomega.Succeed())
+		gomega.Expect(provider.AddServiceAccount(account("other", "sa3", "ci"))).To(gomega.Succeed())
+
+		accounts, err := provider.ListServiceAccounts("org")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(accounts)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should be able to add, retrieve and list the keys of a service account", func() {
+		sa := account("org", "sa1", "ci")
+		gomega.Expect(provider.AddServiceAccount(sa)).To(gomega.Succeed())
+		key := apiKey(sa, "key1")
+		gomega.Expect(provider.AddApiKey(key)).To(gomega.Succeed())
+		gomega.Expect(provider.AddApiKey(apiKey(sa, "key2"))).To(gomega.Succeed())
+
+		retrieved, err := provider.GetApiKey("key1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(*retrieved).Should(gomega.Equal(key))
+
+		err = provider.AddApiKey(key)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+
+		keys, err := provider.ListApiKeys("org", "sa1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(keys)).Should(gomega.Equal(2))
+	})
+
+	ginkgo.It("should reject a key of a missing service account", func() {
+		err := provider.AddApiKey(apiKey(account("org", "sa1", "ci"), "key1"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to update the last use of a key", func() {
+		sa := account("org", "sa1", "ci")
+		gomega.Expect(provider.AddServiceAccount(sa)).To(gomega.Succeed())
+		gomega.Expect(provider.AddApiKey(apiKey(sa, "key1"))).To(gomega.Succeed())
+
+		gomega.Expect(provider.UpdateLastUsed("key1", 10)).To(gomega.Succeed())
+		retrieved, err := provider.GetApiKey("key1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.LastUsed).Should(gomega.Equal(int64(10)))
+
+		err = provider.UpdateLastUsed("missing", 10)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should be able to remove a key", func() {
+		sa := account("org", "sa1", "ci")
+		gomega.Expect(provider.AddServiceAccount(sa)).To(gomega.Succeed())
+		gomega.Expect(provider.AddApiKey(apiKey(sa, "key1"))).To(gomega.Succeed())
+
+		gomega.Expect(provider.RemoveApiKey("key1")).To(gomega.Succeed())
+		_, err := provider.GetApiKey("key1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		keys, err := provider.ListApiKeys("org", "sa1")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(keys).Should(gomega.BeEmpty())
+
+		err = provider.RemoveApiKey("key1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should remove the keys of a removed service account", func() {
+		sa := account("org", "sa1", "ci")
+		gomega.Expect(provider.AddServiceAccount(sa)).To(gomega.Succeed())
+		gomega.Expect(provider.AddApiKey(apiKey(sa, "key1"))).To(gomega.Succeed())
+
+		gomega.Expect(provider.RemoveServiceAccount("org", "sa1")).To(gomega.Succeed())
+		_, err := provider.GetServiceAccount("org", "sa1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, err = provider.GetApiKey("key1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		err = provider.RemoveServiceAccount("org", "sa1")
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.NotFound))
+	})
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package serviceaccount
+
+import (
+	"github.com/gocql/gocql"
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+)
+
+const serviceAccountsTable = "service_accounts"
+
+const apiKeysTable = "api_keys"
+
+// serviceAccountKeysTable indexes the keys by service account.
+const serviceAccountKeysTable = "service_account_keys"
+
+const serviceAccountColumns = "organization_id, service_account_id, name, description, role_id, created"
+
+const apiKeyColumns = "key_id, organization_id, service_account_id, key_hash, scopes, created, expires_at, last_used"
+
+// ScyllaServiceAccountProvider is a ScyllaDB implementation of the service account provider.
+type ScyllaServiceAccountProvider struct {
+	session *scylladb.Session
+}
+
+// NewScyllaServiceAccountProvider creates a provider that stores the accounts and keys in the keyspace of a session.
+func NewScyllaServiceAccountProvider(session *scylladb.Session) *ScyllaServiceAccountProvider {
+	return &ScyllaServiceAccountProvider{session: session}
+}
+
+// AddServiceAccount adds a new service account.
+func (sp *ScyllaServiceAccountProvider) AddServiceAccount(account entities.ServiceAccount) derrors.Error {
+	accounts, err := sp.ListServiceAccounts(account.OrganizationId)
+	if err != nil {
+		return err
+	}
+	for _, current := range accounts {
+		if current.Name == account.Name {
+			return derrors.NewAlreadyExistsError("service account name").WithParams(account.OrganizationId, account.Name)
+		}
+	}
+	applied, err := sp.session.ExecCAS("INSERT INTO "+serviceAccountsTable+" ("+serviceAccountColumns+") VALUES (?, ?, ?, ?, ?, ?) IF NOT EXISTS",
+		account.OrganizationId, account.ServiceAccountId, account.Name, account.Description, account.RoleId, account.Created)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("service account").WithParams(account.OrganizationId, account.ServiceAccountId)
+	}
+	return nil
+}
+
+// GetServiceAccount retrieves a service account.
+func (sp *ScyllaServiceAccountProvider) GetServiceAccount(organizationID string, serviceAccountID string) (*entities.ServiceAccount, derrors.Error) {
+	var account entities.ServiceAccount
+	found, err := sp.session.Scan("SELECT "+serviceAccountColumns+" FROM "+serviceAccountsTable+" WHERE organization_id = ? AND service_account_id = ?",
+		[]interface{}{organizationID, serviceAccountID}, &account.OrganizationId, &account.ServiceAccountId, &account.Name,
+		&account.Description, &account.RoleId, &account.Created)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	return &account, nil
+}
+
+// ListServiceAccounts lists the service accounts of an organization.
+func (sp *ScyllaServiceAccountProvider) ListServiceAccounts(organizationID string) ([]entities.ServiceAccount, derrors.Error) {
+	result := make([]entities.ServiceAccount, 0)
+	err := sp.session.Iterate("SELECT "+serviceAccountColumns+" FROM "+serviceAccountsTable+" WHERE organization_id = ?",
+		[]interface{}{organizationID}, func(scanner gocql.Scanner) error {
+			var account entities.ServiceAccount
+			sErr := scanner.Scan(&account.OrganizationId, &account.ServiceAccountId, &account.Name, &account.Description,
+				&account.RoleId, &account.Created)
+			if sErr == nil {
+				result = append(result, account)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RemoveServiceAccount removes a service account and all its keys.
+func (sp *ScyllaServiceAccountProvider) RemoveServiceAccount(organizationID string, serviceAccountID string) derrors.Error {
+	applied, err := sp.session.ExecCAS("DELETE FROM "+serviceAccountsTable+" WHERE organization_id = ? AND service_account_id = ? IF EXISTS",
+		organizationID, serviceAccountID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("service account").WithParams(organizationID, serviceAccountID)
+	}
+	keyIDs, err := sp.listKeyIDs(organizationID, serviceAccountID)
+	if err != nil {
+		return err
+	}
+	for _, keyID := range keyIDs {
+		err = sp.session.Exec("DELETE FROM "+apiKeysTable+" WHERE key_id = ?", keyID)
+		if err != nil {
+			return err
+		}
+	}
+	return sp.session.Exec("DELETE FROM "+serviceAccountKeysTable+" WHERE organization_id = ? AND service_account_id = ?",
+		organizationID, serviceAccountID)
+}
+
+// AddApiKey adds a new key.
+func (sp *ScyllaServiceAccountProvider) AddApiKey(key entities.ApiKey) derrors.Error {
+	_, err := sp.GetServiceAccount(key.OrganizationId, key.ServiceAccountId)
+	if err != nil {
+		return err
+	}
+	applied, err := sp.session.ExecCAS("INSERT INTO "+apiKeysTable+" ("+apiKeyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?) IF NOT EXISTS",
+		key.KeyId, key.OrganizationId, key.ServiceAccountId, key.KeyHash, key.Scopes, key.Created, key.ExpiresAt, key.LastUsed)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewAlreadyExistsError("api key").WithParams(key.KeyId)
+	}
+	return sp.session.Exec("INSERT INTO "+serviceAccountKeysTable+" (organization_id, service_account_id, key_id) VALUES (?, ?, ?)",
+		key.OrganizationId, key.ServiceAccountId, key.KeyId)
+}
+
+// GetApiKey retrieves a key by its identifier.
+func (sp *ScyllaServiceAccountProvider) GetApiKey(keyID string) (*entities.ApiKey, derrors.Error) {
+	var key entities.ApiKey
+	found, err := sp.session.Scan("SELECT "+apiKeyColumns+" FROM "+apiKeysTable+" WHERE key_id = ?",
+		[]interface{}{keyID}, &key.KeyId, &key.OrganizationId, &key.ServiceAccountId, &key.KeyHash, &key.Scopes,
+		&key.Created, &key.ExpiresAt, &key.LastUsed)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	return &key, nil
+}
+
+// listKeyIDs lists the identifiers of the keys of a service account.
+func (sp *ScyllaServiceAccountProvider) listKeyIDs(organizationID string, serviceAccountID string) ([]string, derrors.Error) {
+	result := make([]string, 0)
+	err := sp.session.Iterate("SELECT key_id FROM "+serviceAccountKeysTable+" WHERE organization_id = ? AND service_account_id = ?",
+		[]interface{}{organizationID, serviceAccountID}, func(scanner gocql.Scanner) error {
+			var keyID string
+			sErr := scanner.Scan(&keyID)
+			if sErr == nil {
+				result = append(result, keyID)
+			}
+			return sErr
+		})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ListApiKeys lists the keys of a service account.
+func (sp *ScyllaServiceAccountProvider) ListApiKeys(organizationID string, serviceAccountID string) ([]entities.ApiKey, derrors.Error) {
+	keyIDs, err := sp.listKeyIDs(organizationID, serviceAccountID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.ApiKey, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		key, err := sp.GetApiKey(keyID)
+		if err != nil {
+			if err.Type() == derrors.NotFound {
+				continue
+			}
+			return nil, err
+		}
+		result = append(result, *key)
+	}
+	return result, nil
+}
+
+// UpdateLastUsed sets the timestamp of the last use of a key.
+func (sp *ScyllaServiceAccountProvider) UpdateLastUsed(keyID string, timestamp int64) derrors.Error {
+	applied, err := sp.session.ExecCAS("UPDATE "+apiKeysTable+" SET last_used = ? WHERE key_id = ? IF EXISTS", timestamp, keyID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	return nil
+}
+
+// RemoveApiKey removes a key.
+func (sp *ScyllaServiceAccountProvider) RemoveApiKey(keyID string) derrors.Error {
+	key, err := sp.GetApiKey(keyID)
+	if err != nil {
+		return err
+	}
+	applied, err := sp.session.ExecCAS("DELETE FROM "+apiKeysTable+" WHERE key_id = ? IF EXISTS", keyID)
+	if err != nil {
+		return err
+	}
+	if !applied {
+		return derrors.NewNotFoundError("api key").WithParams(keyID)
+	}
+	return sp.session.Exec("DELETE FROM "+serviceAccountKeysTable+" WHERE organization_id = ? AND service_account_id = ? AND key_id = ?",
+		key.OrganizationId, key.ServiceAccountId, keyID)
+}
+
+// Clear all the service accounts and keys.
+func (sp *ScyllaServiceAccountProvider) Clear() derrors.Error {
+	return sp.session.Truncate(serviceAccountsTable, apiKeysTable, serviceAccountKeysTable)
+}
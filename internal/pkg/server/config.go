@@ -18,6 +18,7 @@ package server
 
 import (
 	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
 	"github.com/nalej/user-manager/version"
 	"github.com/rs/zerolog/log"
 )
@@ -29,6 +30,32 @@ type Config struct {
 	AuthxAddress string
 	// SystemModelAddress with the host:port to connect to System Model
 	SystemModelAddress string
+	// ScyllaDBAddress with the address of a node of the ScyllaDB cluster.
+	ScyllaDBAddress string
+	// ScyllaDBPort with the port of the CQL native protocol of ScyllaDB.
+	ScyllaDBPort int
+	// KeySpace with the ScyllaDB keyspace of the user manager.
+	KeySpace string
+	// RemovedUserRetentionDays with the number of days a removed user can be restored.
+	RemovedUserRetentionDays int
+	// ScimPort where the SCIM endpoint will listen requests.
+	ScimPort int
+	// ScimTokensPath with the file containing the bearer tokens of the SCIM endpoint. The endpoint is disabled if empty.
+	ScimTokensPath string
+	// LdapSyncPath with the file containing the LDAP synchronization settings. The synchronization is disabled if empty.
+	LdapSyncPath string
+	// ClaimRulesPath with the file containing the rules to provision users from external identity providers.
+	ClaimRulesPath string
+	// EmailRulesPath with the file containing the provider-specific rules used to normalize the emails.
+	EmailRulesPath string
+	// MfaKeyPath with the file containing the key used to encrypt the TOTP secrets. MFA enrollment is disabled if empty.
+	MfaKeyPath string
+	// RoleTemplatesPath with the directory containing the role templates that can be applied to the organizations.
+	RoleTemplatesPath string
+	// AccessRequestApprover with the name of the access primitive required to approve access requests.
+	AccessRequestApprover string
+	// AccessRequestExpirationHours with the number of hours an access request can be resolved.
+	AccessRequestExpirationHours int
 }
 
 func (conf *Config) Validate() derrors.Error {
@@ -41,6 +68,34 @@ func (conf *Config) Validate() derrors.Error {
 		return derrors.NewInvalidArgumentError("systemModelAddress must be set")
 	}
 
+	if conf.ScyllaDBAddress == "" {
+		return derrors.NewInvalidArgumentError("scyllaDBAddress must be set")
+	}
+
+	if conf.ScyllaDBPort <= 0 {
+		return derrors.NewInvalidArgumentError("scyllaDBPort must be greater than zero")
+	}
+
+	if conf.KeySpace == "" {
+		return derrors.NewInvalidArgumentError("keyspace must be set")
+	}
+
+	if conf.RemovedUserRetentionDays <= 0 {
+		return derrors.NewInvalidArgumentError("removedUserRetentionDays must be greater than zero")
+	}
+
+	if _, exists := grpc_authx_go.AccessPrimitive_value[conf.AccessRequestApprover]; !exists {
+		return derrors.NewInvalidArgumentError("accessRequestApprover must be a valid access primitive").WithParams(conf.AccessRequestApprover)
+	}
+
+	if conf.AccessRequestExpirationHours <= 0 {
+		return derrors.NewInvalidArgumentError("accessRequestExpirationHours must be greater than zero")
+	}
+
+	if conf.ScimTokensPath != "" && conf.ScimPort <= 0 {
+		return derrors.NewInvalidArgumentError("scimPort must be set")
+	}
+
 	return nil
 }
 
@@ -49,4 +104,31 @@ func (conf *Config) Print() {
 	log.Info().Int("port", conf.Port).Msg("gRPC port")
 	log.Info().Str("URL", conf.AuthxAddress).Msg("Authx")
 	log.Info().Str("URL", conf.SystemModelAddress).Msg("System Model")
+	log.Info().Str("address", conf.ScyllaDBAddress).Int("port", conf.ScyllaDBPort).Str("keyspace", conf.KeySpace).Msg("ScyllaDB")
+	log.Info().Int("days", conf.RemovedUserRetentionDays).Msg("Removed user retention")
+	log.Info().Str("approver", conf.AccessRequestApprover).Int("hours", conf.AccessRequestExpirationHours).Msg("Access requests")
+	if conf.ScimTokensPath != "" {
+		log.Info().Int("port", conf.ScimPort).Str("tokens", conf.ScimTokensPath).Msg("SCIM endpoint")
+	} else {
+		log.Info().Msg("SCIM endpoint disabled")
+	}
+	if conf.LdapSyncPath != "" {
+		log.Info().Str("config", conf.LdapSyncPath).Msg("LDAP sync")
+	} else {
+		log.Info().Msg("LDAP sync disabled")
+	}
+	if conf.ClaimRulesPath != "" {
+		log.Info().Str("rules", conf.ClaimRulesPath).Msg("Provisioning from assertions")
+	}
+	if conf.EmailRulesPath != "" {
+		log.Info().Str("rules", conf.EmailRulesPath).Msg("Email normalization")
+	}
+	if conf.MfaKeyPath != "" {
+		log.Info().Str("key", conf.MfaKeyPath).Msg("MFA enrollment")
+	} else {
+		log.Info().Msg("MFA enrollment disabled")
+	}
+	if conf.RoleTemplatesPath != "" {
+		log.Info().Str("path", conf.RoleTemplatesPath).Msg("Role templates")
+	}
 }
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"time"
+)
+
+const (
+	// DefaultEmailAttribute with the LDAP attribute containing the email of the users.
+	DefaultEmailAttribute = "mail"
+	// DefaultGroupAttribute with the LDAP attribute containing the groups of the users.
+	DefaultGroupAttribute = "memberOf"
+	// DefaultUserFilter used when the organization does not define one.
+	DefaultUserFilter = "(objectClass=person)"
+	// DefaultPeriodMinutes between synchronizations of an organization.
+	DefaultPeriodMinutes = 60
+)
+
+// AttributeMapping with the LDAP attributes mapped to the profile of the users. Empty attributes are not synchronized.
+// Name, LastName and Title are required to add new users.
+type AttributeMapping struct {
+	Name     string `yaml:"name"`
+	LastName string `yaml:"last_name"`
+	Title    string `yaml:"title"`
+	Phone    string `yaml:"phone"`
+	Location string `yaml:"location"`
+}
+
+// GroupRole maps the members of an LDAP group to a role.
+type GroupRole struct {
+	// Group with the DN of the group.
+	Group string `yaml:"group"`
+	// RoleId with the role assigned to the members of the group.
+	RoleId string `yaml:"role_id"`
+}
+
+// Config with the synchronization settings of an organization.
+type Config struct {
+	// OrganizationId with the organization being synchronized.
+	OrganizationId string `yaml:"organization_id"`
+	// URL of the LDAP server such as ldap://host:389 or ldaps://host:636.
+	URL string `yaml:"url"`
+	// BindDN used to authenticate against the server.
+	BindDN string `yaml:"bind_dn"`
+	// BindPassword used to authenticate against the server.
+	BindPassword string `yaml:"bind_password"`
+	// BaseDN where the users are searched.
+	BaseDN string `yaml:"base_dn"`
+	// UserFilter selecting the users of the organization.
+	UserFilter string `yaml:"user_filter"`
+	// EmailAttribute with the attribute containing the email of the users.
+	EmailAttribute string `yaml:"email_attribute"`
+	// Attributes with the mapping of the profile fields.
+	Attributes AttributeMapping `yaml:"attributes"`
+	// GroupAttribute with the attribute containing the groups of the users.
+	GroupAttribute string `yaml:"group_attribute"`
+	// GroupRoles with the group to role mapping. The first matching group determines the role.
+	GroupRoles []GroupRole `yaml:"group_roles"`
+	// DefaultRoleId assigned to the users that are not members of a mapped group. Users are skipped if empty.
+	DefaultRoleId string `yaml:"default_role_id"`
+	// Prune removes the users of the organization that are not found in the directory.
+	Prune bool `yaml:"prune"`
+	// DryRun computes and reports the changes without applying them.
+	DryRun bool `yaml:"dry_run"`
+	// PeriodMinutes between synchronizations.
+	PeriodMinutes int `yaml:"period_minutes"`
+}
+
+// Period between synchronizations.
+func (c *Config) Period() time.Duration {
+	return time.Duration(c.PeriodMinutes) * time.Minute
+}
+
+// setDefaults fills the optional settings.
+func (c *Config) setDefaults() {
+	if c.UserFilter == "" {
+		c.UserFilter = DefaultUserFilter
+	}
+	if c.EmailAttribute == "" {
+		c.EmailAttribute = DefaultEmailAttribute
+	}
+	if c.GroupAttribute == "" {
+		c.GroupAttribute = DefaultGroupAttribute
+	}
+	if c.PeriodMinutes == 0 {
+		c.PeriodMinutes = DefaultPeriodMinutes
+	}
+}
+
+// Validate checks that the configuration is complete.
+func (c *Config) Validate() derrors.Error {
+	if c.OrganizationId == "" {
+		return derrors.NewInvalidArgumentError("organization_id must be set")
+	}
+	if c.URL == "" {
+		return derrors.NewInvalidArgumentError("url must be set").WithParams(c.OrganizationId)
+	}
+	if c.BaseDN == "" {
+		return derrors.NewInvalidArgumentError("base_dn must be set").WithParams(c.OrganizationId)
+	}
+	if c.PeriodMinutes <= 0 {
+		return derrors.NewInvalidArgumentError("period_minutes must be greater than zero").WithParams(c.OrganizationId)
+	}
+	for _, groupRole := range c.GroupRoles {
+		if groupRole.Group == "" || groupRole.RoleId == "" {
+			return derrors.NewInvalidArgumentError("group_roles entries require group and role_id").WithParams(c.OrganizationId)
+		}
+	}
+	if len(c.GroupRoles) == 0 && c.DefaultRoleId == "" {
+		return derrors.NewInvalidArgumentError("group_roles or default_role_id must be set").WithParams(c.OrganizationId)
+	}
+	return nil
+}
+
+// LoadConfigs reads a YAML file with the synchronization settings of each organization.
+func LoadConfigs(path string) ([]Config, derrors.Error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read LDAP sync configuration")
+	}
+	configs := make([]Config, 0)
+	err = yaml.UnmarshalStrict(content, &configs)
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot decode LDAP sync configuration", err)
+	}
+	organizations := make(map[string]bool, len(configs))
+	for index := range configs {
+		configs[index].setDefaults()
+		vErr := configs[index].Validate()
+		if vErr != nil {
+			return nil, vErr
+		}
+		if organizations[configs[index].OrganizationId] {
+			return nil, derrors.NewInvalidArgumentError(fmt.Sprintf("organization %s is configured twice", configs[index].OrganizationId))
+		}
+		organizations[configs[index].OrganizationId] = true
+	}
+	return configs, nil
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"github.com/nalej/derrors"
+	"gopkg.in/ldap.v3"
+	"strings"
+)
+
+// Entry of the directory with its attributes indexed by their lowercase name.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Get the first value of an attribute.
+func (e *Entry) Get(attribute string) string {
+	values := e.Attributes[strings.ToLower(attribute)]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetAll obtains all the values of an attribute.
+func (e *Entry) GetAll(attribute string) []string {
+	return e.Attributes[strings.ToLower(attribute)]
+}
+
+// Directory retrieving the users of an organization.
+type Directory interface {
+	// Search the users matching the filter of the configuration.
+	Search(config *Config) ([]Entry, derrors.Error)
+}
+
+// LDAPDirectory retrieves the users from an LDAP server.
+type LDAPDirectory struct {
+}
+
+// NewLDAPDirectory creates a directory backed by the LDAP servers of the configurations.
+func NewLDAPDirectory() *LDAPDirectory {
+	return &LDAPDirectory{}
+}
+
+// Search the users matching the filter of the configuration.
+func (d *LDAPDirectory) Search(config *Config) ([]Entry, derrors.Error) {
+	conn, err := ldap.DialURL(config.URL)
+	if err != nil {
+		return nil, derrors.NewUnavailableError("cannot connect to LDAP server", err).WithParams(config.URL)
+	}
+	defer conn.Close()
+
+	if config.BindDN != "" {
+		err = conn.Bind(config.BindDN, config.BindPassword)
+		if err != nil {
+			return nil, derrors.NewUnauthenticatedError("cannot bind to LDAP server", err).WithParams(config.BindDN)
+		}
+	}
+
+	request := ldap.NewSearchRequest(config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		config.UserFilter, requestedAttributes(config), nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		return nil, derrors.NewInternalError("cannot search LDAP users", err).WithParams(config.BaseDN, config.UserFilter)
+	}
+
+	entries := make([]Entry, 0, len(result.Entries))
+	for _, ldapEntry := range result.Entries {
+		entry := Entry{DN: ldapEntry.DN, Attributes: make(map[string][]string, len(ldapEntry.Attributes))}
+		for _, attribute := range ldapEntry.Attributes {
+			name := strings.ToLower(attribute.Name)
+			entry.Attributes[name] = append(entry.Attributes[name], attribute.Values...)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// requestedAttributes obtains the attributes required by the mapping of a configuration.
+func requestedAttributes(config *Config) []string {
+	attributes := []string{config.EmailAttribute, config.GroupAttribute}
+	for _, attribute := range []string{config.Attributes.Name, config.Attributes.LastName, config.Attributes.Title,
+		config.Attributes.Phone, config.Attributes.Location} {
+		if attribute != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
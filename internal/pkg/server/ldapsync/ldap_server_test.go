@@ -0,0 +1,244 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"github.com/onsi/gomega"
+	"gopkg.in/asn1-ber.v1"
+	"net"
+	"strings"
+	"sync"
+)
+
+// LDAP protocol operations and result codes used by the test server.
+const (
+	applicationBindRequest       = 0
+	applicationBindResponse      = 1
+	applicationUnbindRequest     = 2
+	applicationSearchRequest     = 3
+	applicationSearchResultEntry = 4
+	applicationSearchResultDone  = 5
+	resultSuccess                = 0
+	resultInvalidCredentials     = 49
+	resultUnwillingToPerform     = 53
+	filterAnd                    = 0
+	filterOr                     = 1
+	filterNot                    = 2
+	filterEqualityMatch          = 3
+	filterSubstrings             = 4
+	filterPresent                = 7
+	substringInitial             = 0
+	substringAny                 = 1
+	substringFinal               = 2
+)
+
+// testLDAPServer is an in-process LDAP server supporting simple binds and subtree searches with equality, presence,
+// substring and boolean filters.
+type testLDAPServer struct {
+	sync.Mutex
+	listener     net.Listener
+	bindDN       string
+	bindPassword string
+	entries      []Entry
+}
+
+// newTestLDAPServer launches a server listening on a random local port.
+func newTestLDAPServer(bindDN string, bindPassword string) *testLDAPServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	gomega.Expect(err).To(gomega.Succeed())
+	server := &testLDAPServer{listener: listener, bindDN: bindDN, bindPassword: bindPassword}
+	go server.serve()
+	return server
+}
+
+// URL of the server.
+func (s *testLDAPServer) URL() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+// Close the server.
+func (s *testLDAPServer) Close() {
+	_ = s.listener.Close()
+}
+
+// SetEntries replaces the entries of the directory.
+func (s *testLDAPServer) SetEntries(entries ...Entry) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries = entries
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	bound := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID := packet.Children[0].Value.(int64)
+		operation := packet.Children[1]
+		switch operation.Tag {
+		case applicationBindRequest:
+			name := operation.Children[1].Value.(string)
+			password := operation.Children[2].Data.String()
+			code := resultInvalidCredentials
+			if name == s.bindDN && password == s.bindPassword {
+				code = resultSuccess
+				bound = true
+			}
+			s.write(conn, messageID, result(applicationBindResponse, code))
+		case applicationSearchRequest:
+			if !bound {
+				s.write(conn, messageID, result(applicationSearchResultDone, resultUnwillingToPerform))
+				continue
+			}
+			baseDN := strings.ToLower(operation.Children[0].Value.(string))
+			filter := operation.Children[6]
+			for _, entry := range s.search(baseDN, filter) {
+				s.write(conn, messageID, searchResultEntry(entry))
+			}
+			s.write(conn, messageID, result(applicationSearchResultDone, resultSuccess))
+		case applicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *testLDAPServer) search(baseDN string, filter *ber.Packet) []Entry {
+	s.Lock()
+	defer s.Unlock()
+	result := make([]Entry, 0)
+	for _, entry := range s.entries {
+		if strings.HasSuffix(strings.ToLower(entry.DN), baseDN) && matches(entry, filter) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+func (s *testLDAPServer) write(conn net.Conn, messageID int64, operation *ber.Packet) {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "operation")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "message id"))
+	envelope.AppendChild(operation)
+	_, _ = conn.Write(envelope.Bytes())
+}
+
+// matches evaluates an LDAP filter against an entry.
+func matches(entry Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return !matches(entry, filter.Children[0])
+	case filterEqualityMatch:
+		attribute := filter.Children[0].Data.String()
+		value := filter.Children[1].Data.String()
+		for _, current := range entry.GetAll(attribute) {
+			if strings.EqualFold(current, value) {
+				return true
+			}
+		}
+		return false
+	case filterSubstrings:
+		attribute := filter.Children[0].Data.String()
+		for _, current := range entry.GetAll(attribute) {
+			if matchesSubstrings(strings.ToLower(current), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	case filterPresent:
+		return len(entry.GetAll(filter.Data.String())) > 0
+	}
+	return false
+}
+
+// matchesSubstrings evaluates the components of a substrings filter.
+func matchesSubstrings(value string, components []*ber.Packet) bool {
+	for _, component := range components {
+		part := strings.ToLower(component.Data.String())
+		switch component.Tag {
+		case substringInitial:
+			if !strings.HasPrefix(value, part) {
+				return false
+			}
+			value = value[len(part):]
+		case substringAny:
+			index := strings.Index(value, part)
+			if index == -1 {
+				return false
+			}
+			value = value[index+len(part):]
+		case substringFinal:
+			if !strings.HasSuffix(value, part) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// result encodes an LDAPResult operation.
+func result(application ber.Tag, code int) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "operation")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "result code"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matched dn"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnostic message"))
+	return packet
+}
+
+// searchResultEntry encodes an entry with all its attributes.
+func searchResultEntry(entry Entry) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, applicationSearchResultEntry, nil, "entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "dn"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.Attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	packet.AppendChild(attributes)
+	return packet
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// Scheduler periodically synchronizes a set of organizations.
+type Scheduler struct {
+	syncer  *Syncer
+	configs []Config
+	// lock protecting the last reports.
+	lock sync.Mutex
+	// reports with the last report of each organization.
+	reports map[string]*Report
+}
+
+// NewScheduler creates a scheduler for a set of configurations.
+func NewScheduler(syncer *Syncer, configs []Config) *Scheduler {
+	return &Scheduler{syncer: syncer, configs: configs, reports: make(map[string]*Report, 0)}
+}
+
+// Run launches the synchronization of each organization, which is repeated after its period until the stop channel is
+// closed.
+func (s *Scheduler) Run(stop <-chan struct{}) {
+	var wg sync.WaitGroup
+	for index := range s.configs {
+		wg.Add(1)
+		go func(config *Config) {
+			defer wg.Done()
+			s.run(config, stop)
+		}(&s.configs[index])
+	}
+	wg.Wait()
+}
+
+// run synchronizes an organization periodically.
+func (s *Scheduler) run(config *Config, stop <-chan struct{}) {
+	ticker := time.NewTicker(config.Period())
+	defer ticker.Stop()
+	for {
+		s.SyncOnce(config)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce synchronizes an organization storing its report.
+func (s *Scheduler) SyncOnce(config *Config) *Report {
+	report, err := s.syncer.Sync(config)
+	if err != nil {
+		log.Warn().Str("organizationID", config.OrganizationId).Str("err", err.DebugReport()).Msg("cannot synchronize LDAP users")
+		return nil
+	}
+	if report.HasChanges() || len(report.Failures) > 0 {
+		log.Info().Str("organizationID", config.OrganizationId).Bool("dryRun", report.DryRun).
+			Strs("added", report.Added).Strs("updated", report.Updated).Int("roleChanges", len(report.RoleChanges)).
+			Strs("removed", report.Removed).Int("skipped", len(report.Skipped)).Int("failed", len(report.Failures)).
+			Msg("LDAP sync report")
+	}
+	for _, failure := range report.Failures {
+		log.Warn().Str("organizationID", config.OrganizationId).Str("email", failure.Email).
+			Str("operation", failure.Operation).Str("err", failure.Error).Msg("LDAP sync operation failed")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reports[config.OrganizationId] = report
+	return report
+}
+
+// LastReport obtains the report of the last successful synchronization of an organization.
+func (s *Scheduler) LastReport(organizationID string) (*Report, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	report, exists := s.reports[organizationID]
+	return report, exists
+}
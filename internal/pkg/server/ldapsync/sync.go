@@ -0,0 +1,349 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoleChange of a synchronized user.
+type RoleChange struct {
+	Email    string
+	FromRole string
+	ToRole   string
+}
+
+// SkippedEntry of the directory that cannot be synchronized.
+type SkippedEntry struct {
+	DN     string
+	Reason string
+}
+
+// Failure of an operation applied during a synchronization.
+type Failure struct {
+	Email     string
+	Operation string
+	Error     string
+}
+
+// Report with the outcome of a synchronization.
+type Report struct {
+	OrganizationId string
+	DryRun         bool
+	StartedAt      int64
+	FinishedAt     int64
+	// Added with the emails of the users invited to the organization.
+	Added []string
+	// Updated with the emails of the users whose profile changed.
+	Updated []string
+	// RoleChanges with the users whose role changed.
+	RoleChanges []RoleChange
+	// Removed with the emails of the users removed from the organization.
+	Removed []string
+	// Skipped with the entries of the directory that cannot be synchronized.
+	Skipped []SkippedEntry
+	// Failures with the operations that could not be applied.
+	Failures []Failure
+}
+
+// HasChanges checks if the synchronization changed or would change the organization.
+func (r *Report) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Updated) > 0 || len(r.RoleChanges) > 0 || len(r.Removed) > 0
+}
+
+// Print the report in a human readable format.
+func (r *Report) Print(output io.Writer) {
+	mode := "applied"
+	if r.DryRun {
+		mode = "dry-run"
+	}
+	fmt.Fprintf(output, "LDAP sync of organization %s (%s)\n", r.OrganizationId, mode)
+	for _, email := range r.Added {
+		fmt.Fprintf(output, "  + %s\n", email)
+	}
+	for _, email := range r.Updated {
+		fmt.Fprintf(output, "  ~ %s\n", email)
+	}
+	for _, change := range r.RoleChanges {
+		fmt.Fprintf(output, "  ~ %s role %s -> %s\n", change.Email, change.FromRole, change.ToRole)
+	}
+	for _, email := range r.Removed {
+		fmt.Fprintf(output, "  - %s\n", email)
+	}
+	for _, skipped := range r.Skipped {
+		fmt.Fprintf(output, "  ! skipped %s: %s\n", skipped.DN, skipped.Reason)
+	}
+	for _, failure := range r.Failures {
+		fmt.Fprintf(output, "  ! %s %s failed: %s\n", failure.Operation, failure.Email, failure.Error)
+	}
+	fmt.Fprintf(output, "%d added, %d updated, %d role changes, %d removed, %d skipped, %d failed\n",
+		len(r.Added), len(r.Updated), len(r.RoleChanges), len(r.Removed), len(r.Skipped), len(r.Failures))
+}
+
+// Syncer reconciles the users of an organization with a directory through the user manager.
+type Syncer struct {
+	manager   *user.Manager
+	directory Directory
+}
+
+// NewSyncer creates a Syncer using a user manager and a directory.
+func NewSyncer(manager *user.Manager, directory Directory) *Syncer {
+	return &Syncer{manager: manager, directory: directory}
+}
+
+// desiredUser with the state of a user according to the directory.
+type desiredUser struct {
+	dn      string
+	request *grpc_user_manager_go.AddUserRequest
+}
+
+// Sync reconciles the users of the organization of a configuration. Users are added, updated, assigned a new role and,
+// if prune is enabled, removed. Role changes granting the ORG primitive are applied before the rest so the
+// organization never runs out of owners. Nothing is changed on dry-run. The failure of an operation does not stop the
+// synchronization and is recorded in the report.
+func (s *Syncer) Sync(config *Config) (*Report, derrors.Error) {
+	report := &Report{OrganizationId: config.OrganizationId, DryRun: config.DryRun, StartedAt: time.Now().Unix(),
+		Added: make([]string, 0), Updated: make([]string, 0), RoleChanges: make([]RoleChange, 0),
+		Removed: make([]string, 0), Skipped: make([]SkippedEntry, 0), Failures: make([]Failure, 0)}
+
+	entries, err := s.directory.Search(config)
+	if err != nil {
+		return nil, err
+	}
+	organizationID := &grpc_organization_go.OrganizationId{OrganizationId: config.OrganizationId}
+	roles, rErr := s.manager.ListRoles(organizationID)
+	if rErr != nil {
+		return nil, derrors.NewInternalError("cannot list roles", rErr).WithParams(config.OrganizationId)
+	}
+	roleNames := make(map[string]string, len(roles.Roles))
+	ownerRoles := make(map[string]bool, len(roles.Roles))
+	for _, role := range roles.Roles {
+		if !role.Internal {
+			roleNames[role.RoleId] = role.Name
+		}
+		for _, primitive := range role.Primitives {
+			if primitive == grpc_authx_go.AccessPrimitive_ORG {
+				ownerRoles[role.RoleId] = true
+			}
+		}
+	}
+	for _, groupRole := range config.GroupRoles {
+		if _, exists := roleNames[groupRole.RoleId]; !exists {
+			return nil, derrors.NewNotFoundError("role of group").WithParams(groupRole.Group, groupRole.RoleId)
+		}
+	}
+	if _, exists := roleNames[config.DefaultRoleId]; config.DefaultRoleId != "" && !exists {
+		return nil, derrors.NewNotFoundError("default role").WithParams(config.DefaultRoleId)
+	}
+	users, uErr := s.manager.ListUsers(organizationID)
+	if uErr != nil {
+		return nil, derrors.NewInternalError("cannot list users", uErr).WithParams(config.OrganizationId)
+	}
+	// users are indexed by the canonical form of their email, as the ones added before the emails were normalized
+	// keep their original email
+	current := make(map[string]*grpc_user_manager_go.User, len(users.Users))
+	for _, u := range users.Users {
+		email, nErr := s.manager.NormalizeEmail(u.Email)
+		if nErr != nil {
+			email = u.Email
+		}
+		current[email] = u
+	}
+
+	desired := s.desiredUsers(config, entries, report)
+	emails := make([]string, 0, len(desired))
+	for email := range desired {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	assignments := make([]*grpc_user_manager_go.AssignRoleRequest, 0)
+	for _, email := range emails {
+		target := desired[email]
+		existing, exists := current[email]
+		if !exists {
+			report.Added = append(report.Added, email)
+			if !config.DryRun {
+				_, err := s.manager.InviteUser(target.request)
+				if err != nil {
+					report.Failures = append(report.Failures, Failure{Email: email, Operation: "add", Error: err.Error()})
+				}
+			}
+			continue
+		}
+		if existing.InternalRole {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: target.dn, Reason: "user has an internal role"})
+			continue
+		}
+		update := profileUpdate(config, existing, target.request)
+		if update != nil {
+			report.Updated = append(report.Updated, email)
+			if !config.DryRun {
+				_, err := s.manager.UpdateUser(update)
+				if err != nil {
+					report.Failures = append(report.Failures, Failure{Email: email, Operation: "update", Error: err.Error()})
+				}
+			}
+		}
+		if existing.RoleId != target.request.RoleId {
+			report.RoleChanges = append(report.RoleChanges, RoleChange{Email: email, FromRole: existing.RoleName,
+				ToRole: roleNames[target.request.RoleId]})
+			assignments = append(assignments, &grpc_user_manager_go.AssignRoleRequest{
+				OrganizationId: config.OrganizationId,
+				Email:          existing.Email,
+				RoleId:         target.request.RoleId,
+			})
+		}
+	}
+	// promotions before demotions
+	sort.SliceStable(assignments, func(i, j int) bool {
+		return ownerRoles[assignments[i].RoleId] && !ownerRoles[assignments[j].RoleId]
+	})
+	if !config.DryRun {
+		for _, assignment := range assignments {
+			_, err := s.manager.AssignRole(assignment)
+			if err != nil {
+				report.Failures = append(report.Failures, Failure{Email: assignment.Email, Operation: "assign role", Error: err.Error()})
+			}
+		}
+	}
+
+	if config.Prune {
+		toRemove := make([]string, 0)
+		for email, u := range current {
+			if _, exists := desired[email]; !exists && !u.InternalRole {
+				toRemove = append(toRemove, u.Email)
+			}
+		}
+		sort.Strings(toRemove)
+		for _, email := range toRemove {
+			report.Removed = append(report.Removed, email)
+			if !config.DryRun {
+				err := s.manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: config.OrganizationId, Email: email})
+				if err != nil {
+					report.Failures = append(report.Failures, Failure{Email: email, Operation: "remove", Error: err.Error()})
+				}
+			}
+		}
+	}
+
+	report.FinishedAt = time.Now().Unix()
+	log.Debug().Str("organizationID", config.OrganizationId).Bool("dryRun", config.DryRun).
+		Int("added", len(report.Added)).Int("updated", len(report.Updated)).Int("roleChanges", len(report.RoleChanges)).
+		Int("removed", len(report.Removed)).Int("failed", len(report.Failures)).Msg("ldap sync")
+	return report, nil
+}
+
+// desiredUsers maps the entries of the directory into users, skipping the invalid ones.
+func (s *Syncer) desiredUsers(config *Config, entries []Entry, report *Report) map[string]*desiredUser {
+	result := make(map[string]*desiredUser, len(entries))
+	for _, entry := range entries {
+		email, nErr := s.manager.NormalizeEmail(entry.Get(config.EmailAttribute))
+		if nErr != nil {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: entry.DN, Reason: nErr.Error()})
+			continue
+		}
+		if email == "" {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: entry.DN, Reason: fmt.Sprintf("missing %s", config.EmailAttribute)})
+			continue
+		}
+		roleID := mapRole(config, entry.GetAll(config.GroupAttribute))
+		if roleID == "" {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: entry.DN, Reason: "no mapped group"})
+			continue
+		}
+		request := &grpc_user_manager_go.AddUserRequest{
+			OrganizationId: config.OrganizationId,
+			Email:          email,
+			Name:           mappedValue(entry, config.Attributes.Name),
+			LastName:       mappedValue(entry, config.Attributes.LastName),
+			Title:          mappedValue(entry, config.Attributes.Title),
+			Phone:          mappedValue(entry, config.Attributes.Phone),
+			Location:       mappedValue(entry, config.Attributes.Location),
+			RoleId:         roleID,
+		}
+		vErr := entities.ValidInviteUserRequest(request)
+		if vErr != nil {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: entry.DN, Reason: vErr.Error()})
+			continue
+		}
+		if previous, exists := result[email]; exists {
+			report.Skipped = append(report.Skipped, SkippedEntry{DN: entry.DN, Reason: fmt.Sprintf("email already used by %s", previous.dn)})
+			continue
+		}
+		result[email] = &desiredUser{dn: entry.DN, request: request}
+	}
+	return result
+}
+
+// mapRole obtains the role of the first mapped group the user is a member of, or the default role.
+func mapRole(config *Config, groups []string) string {
+	for _, groupRole := range config.GroupRoles {
+		for _, group := range groups {
+			if strings.EqualFold(strings.TrimSpace(group), groupRole.Group) {
+				return groupRole.RoleId
+			}
+		}
+	}
+	return config.DefaultRoleId
+}
+
+// mappedValue obtains the value of a mapped attribute.
+func mappedValue(entry Entry, attribute string) string {
+	if attribute == "" {
+		return ""
+	}
+	return entry.Get(attribute)
+}
+
+// profileUpdate obtains the update required to synchronize the mapped attributes of a user, or nil if the user is up
+// to date.
+func profileUpdate(config *Config, existing *grpc_user_manager_go.User, target *grpc_user_manager_go.AddUserRequest) *grpc_user_go.UpdateUserRequest {
+	update := &grpc_user_go.UpdateUserRequest{OrganizationId: config.OrganizationId, Email: existing.Email}
+	changed := false
+	if config.Attributes.Name != "" && existing.Name != target.Name {
+		update.UpdateName, update.Name, changed = true, target.Name, true
+	}
+	if config.Attributes.LastName != "" && existing.LastName != target.LastName {
+		update.UpdateLastName, update.LastName, changed = true, target.LastName, true
+	}
+	if config.Attributes.Title != "" && existing.Title != target.Title {
+		update.UpdateTitle, update.Title, changed = true, target.Title, true
+	}
+	if config.Attributes.Phone != "" && existing.Phone != target.Phone {
+		update.UpdatePhone, update.Phone, changed = true, target.Phone, true
+	}
+	if config.Attributes.Location != "" && existing.Location != target.Location {
+		update.UpdateLocation, update.Location, changed = true, target.Location, true
+	}
+	if !changed {
+		return nil
+	}
+	return update
+}
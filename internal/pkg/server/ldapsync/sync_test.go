@@ -0,0 +1,323 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ldapsync
+
+import (
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/server/user"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+const (
+	testOrganizationID = "org-1"
+	testBindDN         = "cn=admin,dc=example,dc=com"
+	testBindPassword   = "secret"
+	testBaseDN         = "ou=people,dc=example,dc=com"
+	testAdminsGroup    = "cn=admins,ou=groups,dc=example,dc=com"
+)
+
+// person creates a directory entry.
+func person(uid string, mail string, givenName string, title string, groups ...string) Entry {
+	return Entry{
+		DN: "uid=" + uid + "," + testBaseDN,
+		Attributes: map[string][]string{
+			"objectclass": {"person", "inetOrgPerson"},
+			"mail":        {mail},
+			"givenname":   {givenName},
+			"sn":          {"Doe"},
+			"title":       {title},
+			"memberof":    groups,
+		},
+	}
+}
+
+var _ = ginkgo.Describe("Syncer", func() {
+
+	var ldapServer *testLDAPServer
+	var manager user.Manager
+	var syncer *Syncer
+	var config *Config
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+
+	addRole := func(name string, internal bool, primitives ...grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: testOrganizationID,
+			Name:           name,
+			Description:    name,
+			Internal:       internal,
+			Primitives:     primitives,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: testOrganizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "Doe",
+			Title:          "Title",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	getUser := func(email string) (*grpc_user_manager_go.User, error) {
+		return manager.GetUser(&grpc_user_go.UserId{OrganizationId: testOrganizationID, Email: email})
+	}
+
+	ginkgo.BeforeEach(func() {
+		ldapServer = newTestLDAPServer(testBindDN, testBindPassword)
+		manager = user.NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			user.NewMockupProviders(), user.Settings{RemovedUserRetention: time.Hour})
+		syncer = NewSyncer(&manager, NewLDAPDirectory())
+		ownerRole = addRole("owner", false, grpc_authx_go.AccessPrimitive_ORG)
+		memberRole = addRole("member", false, grpc_authx_go.AccessPrimitive_PROFILE)
+		addUser("owner@example.com", ownerRole.RoleId)
+		config = &Config{
+			OrganizationId: testOrganizationID,
+			URL:            ldapServer.URL(),
+			BindDN:         testBindDN,
+			BindPassword:   testBindPassword,
+			BaseDN:         testBaseDN,
+			UserFilter:     "(&(objectClass=person)(mail=*@example.com))",
+			Attributes:     AttributeMapping{Name: "givenName", LastName: "sn", Title: "title"},
+			GroupRoles:     []GroupRole{{Group: testAdminsGroup, RoleId: ownerRole.RoleId}},
+			DefaultRoleId:  memberRole.RoleId,
+		}
+		config.setDefaults()
+		gomega.Expect(config.Validate()).To(gomega.Succeed())
+	})
+
+	ginkgo.AfterEach(func() {
+		ldapServer.Close()
+	})
+
+	ginkgo.It("should report the changes without applying them on dry-run", func() {
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Jane", "Engineer"))
+		config.DryRun = true
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.DryRun).To(gomega.BeTrue())
+		gomega.Expect(report.Added).To(gomega.ConsistOf("jane@example.com"))
+		_, gErr := getUser("jane@example.com")
+		gomega.Expect(gErr).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should invite the users of the directory mapping their groups", func() {
+		ldapServer.SetEntries(
+			person("jane", "jane@example.com", "Jane", "Engineer"),
+			person("john", "john@example.com", "John", "Manager", testAdminsGroup),
+			person("other", "other@other.com", "Other", "Engineer"),
+		)
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Added).To(gomega.Equal([]string{"jane@example.com", "john@example.com"}))
+		gomega.Expect(report.Failures).To(gomega.BeEmpty())
+
+		jane, gErr := getUser("jane@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(jane.RoleId).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(jane.Name).To(gomega.Equal("Jane"))
+		gomega.Expect(jane.PasswordResetRequired).To(gomega.BeTrue())
+		john, gErr := getUser("john@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(john.RoleId).To(gomega.Equal(ownerRole.RoleId))
+	})
+
+	ginkgo.It("should skip the entries that cannot be mapped", func() {
+		incomplete := person("jane", "jane@example.com", "Jane", "")
+		noMail := person("john", "", "John", "Engineer")
+		ldapServer.SetEntries(incomplete, noMail, person("jim", "jim@example.com", "Jim", "Engineer"))
+		config.UserFilter = "(objectClass=person)"
+		config.DefaultRoleId = ""
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Added).To(gomega.BeEmpty())
+		gomega.Expect(report.Skipped).To(gomega.HaveLen(3))
+	})
+
+	ginkgo.It("should update profiles and roles and be idempotent", func() {
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Jane", "Engineer"))
+		_, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Janet", "Director", testAdminsGroup))
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Added).To(gomega.BeEmpty())
+		gomega.Expect(report.Updated).To(gomega.ConsistOf("jane@example.com"))
+		gomega.Expect(report.RoleChanges).To(gomega.ConsistOf(RoleChange{Email: "jane@example.com", FromRole: "member", ToRole: "owner"}))
+		jane, gErr := getUser("jane@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(jane.Name).To(gomega.Equal("Janet"))
+		gomega.Expect(jane.Title).To(gomega.Equal("Director"))
+		gomega.Expect(jane.RoleId).To(gomega.Equal(ownerRole.RoleId))
+
+		report, err = syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.HasChanges()).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should promote the new owners before demoting the previous ones", func() {
+		addUser("zoe@example.com", memberRole.RoleId)
+		ldapServer.SetEntries(
+			person("owner", "owner@example.com", "Name", "Title"),
+			person("zoe", "zoe@example.com", "Zoe", "Director", testAdminsGroup),
+		)
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.RoleChanges).To(gomega.HaveLen(2))
+		gomega.Expect(report.Failures).To(gomega.BeEmpty())
+		owner, gErr := getUser("owner@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(owner.RoleId).To(gomega.Equal(memberRole.RoleId))
+		zoe, gErr := getUser("zoe@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(zoe.RoleId).To(gomega.Equal(ownerRole.RoleId))
+	})
+
+	ginkgo.It("should prune the users missing from the directory keeping the last owner", func() {
+		addUser("gone@example.com", memberRole.RoleId)
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Jane", "Engineer"))
+		config.Prune = true
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Removed).To(gomega.Equal([]string{"gone@example.com", "owner@example.com"}))
+		gomega.Expect(report.Failures).To(gomega.HaveLen(1))
+		gomega.Expect(report.Failures[0].Email).To(gomega.Equal("owner@example.com"))
+		_, gErr := getUser("gone@example.com")
+		gomega.Expect(gErr).NotTo(gomega.Succeed())
+		_, gErr = getUser("owner@example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should match the users added before normalizing the emails", func() {
+		addUser("Jane@Example.com", memberRole.RoleId)
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Jane", "Engineer", testAdminsGroup))
+		config.Prune = true
+		report, err := syncer.Sync(config)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Added).To(gomega.BeEmpty())
+		gomega.Expect(report.Removed).To(gomega.Equal([]string{"owner@example.com"}))
+		gomega.Expect(report.Failures).To(gomega.BeEmpty())
+		jane, gErr := getUser("Jane@Example.com")
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(jane.RoleId).To(gomega.Equal(ownerRole.RoleId))
+	})
+
+	ginkgo.It("should fail with invalid credentials", func() {
+		config.BindPassword = "wrong"
+		_, err := syncer.Sync(config)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should fail if a mapped role does not exist", func() {
+		config.GroupRoles = []GroupRole{{Group: testAdminsGroup, RoleId: "unknown"}}
+		_, err := syncer.Sync(config)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the last report of each organization", func() {
+		ldapServer.SetEntries(person("jane", "jane@example.com", "Jane", "Engineer"))
+		scheduler := NewScheduler(syncer, []Config{*config})
+		gomega.Expect(scheduler.SyncOnce(config)).NotTo(gomega.BeNil())
+		report, exists := scheduler.LastReport(testOrganizationID)
+		gomega.Expect(exists).To(gomega.BeTrue())
+		gomega.Expect(report.Added).To(gomega.ConsistOf("jane@example.com"))
+	})
+})
+
+var _ = ginkgo.Describe("LoadConfigs", func() {
+
+	write := func(content string) string {
+		file, err := ioutil.TempFile("", "ldapsync-*.yaml")
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = file.WriteString(content)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(file.Close()).To(gomega.Succeed())
+		return file.Name()
+	}
+
+	ginkgo.It("should load the configurations filling the defaults", func() {
+		path := write(`
+- organization_id: org-1
+  url: ldap://localhost:389
+  base_dn: ou=people,dc=example,dc=com
+  attributes:
+    name: givenName
+    last_name: sn
+    title: title
+  default_role_id: member
+`)
+		defer os.Remove(path)
+		configs, err := LoadConfigs(path)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(configs).To(gomega.HaveLen(1))
+		gomega.Expect(configs[0].EmailAttribute).To(gomega.Equal(DefaultEmailAttribute))
+		gomega.Expect(configs[0].UserFilter).To(gomega.Equal(DefaultUserFilter))
+		gomega.Expect(configs[0].Period()).To(gomega.Equal(time.Hour))
+	})
+
+	ginkgo.It("should reject configurations without role mapping", func() {
+		path := write(`
+- organization_id: org-1
+  url: ldap://localhost:389
+  base_dn: ou=people,dc=example,dc=com
+`)
+		defer os.Remove(path)
+		_, err := LoadConfigs(path)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
+	ginkgo.It("should reject duplicated organizations and unknown settings", func() {
+		path := write(`
+- organization_id: org-1
+  url: ldap://localhost:389
+  base_dn: dc=example,dc=com
+  default_role_id: member
+- organization_id: org-1
+  url: ldap://localhost:389
+  base_dn: dc=example,dc=com
+  default_role_id: member
+`)
+		defer os.Remove(path)
+		_, err := LoadConfigs(path)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+
+		unknown := write(`
+- organization_id: org-1
+  url: ldap://localhost:389
+  base_dn: dc=example,dc=com
+  default_role_id: member
+  unknown: true
+`)
+		defer os.Remove(unknown)
+		_, err = LoadConfigs(unknown)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+})
@@ -0,0 +1,294 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"github.com/nalej/derrors"
+	"strings"
+	"unicode"
+)
+
+// Attributes of a resource indexed by their lowercase path, such as "username" or "name.givenname".
+type Attributes map[string][]string
+
+// Filter evaluated against the attributes of a resource.
+type Filter interface {
+	Matches(attributes Attributes) bool
+}
+
+// andFilter matches if both filters match.
+type andFilter struct {
+	left  Filter
+	right Filter
+}
+
+func (f *andFilter) Matches(attributes Attributes) bool {
+	return f.left.Matches(attributes) && f.right.Matches(attributes)
+}
+
+// orFilter matches if any of the filters match.
+type orFilter struct {
+	left  Filter
+	right Filter
+}
+
+func (f *orFilter) Matches(attributes Attributes) bool {
+	return f.left.Matches(attributes) || f.right.Matches(attributes)
+}
+
+// notFilter matches if the filter does not match.
+type notFilter struct {
+	filter Filter
+}
+
+func (f *notFilter) Matches(attributes Attributes) bool {
+	return !f.filter.Matches(attributes)
+}
+
+// comparisonFilter compares the values of an attribute. String comparisons are case insensitive.
+type comparisonFilter struct {
+	path     string
+	operator string
+	value    string
+}
+
+func (f *comparisonFilter) Matches(attributes Attributes) bool {
+	values := attributes[f.path]
+	if f.operator == "pr" {
+		for _, value := range values {
+			if value != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if f.operator == "ne" {
+		for _, value := range values {
+			if strings.EqualFold(value, f.value) {
+				return false
+			}
+		}
+		return true
+	}
+	expected := strings.ToLower(f.value)
+	for _, value := range values {
+		current := strings.ToLower(value)
+		var matches bool
+		switch f.operator {
+		case "eq":
+			matches = current == expected
+		case "co":
+			matches = strings.Contains(current, expected)
+		case "sw":
+			matches = strings.HasPrefix(current, expected)
+		case "ew":
+			matches = strings.HasSuffix(current, expected)
+		case "gt":
+			matches = current > expected
+		case "ge":
+			matches = current >= expected
+		case "lt":
+			matches = current < expected
+		case "le":
+			matches = current <= expected
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+// operators supported by the filters.
+var operators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true, "pr": true, "gt": true, "ge": true, "lt": true, "le": true,
+}
+
+// token of a filter expression.
+type token struct {
+	value  string
+	quoted bool
+}
+
+// tokenize splits a filter expression into tokens.
+func tokenize(expression string) ([]token, derrors.Error) {
+	tokens := make([]token, 0)
+	runes := []rune(expression)
+	for index := 0; index < len(runes); {
+		r := runes[index]
+		switch {
+		case unicode.IsSpace(r):
+			index++
+		case r == '(' || r == ')':
+			tokens = append(tokens, token{value: string(r)})
+			index++
+		case r == '"':
+			builder := strings.Builder{}
+			index++
+			closed := false
+			for index < len(runes) {
+				if runes[index] == '\\' && index+1 < len(runes) {
+					builder.WriteRune(runes[index+1])
+					index += 2
+					continue
+				}
+				if runes[index] == '"' {
+					closed = true
+					index++
+					break
+				}
+				builder.WriteRune(runes[index])
+				index++
+			}
+			if !closed {
+				return nil, derrors.NewInvalidArgumentError("unterminated string in filter")
+			}
+			tokens = append(tokens, token{value: builder.String(), quoted: true})
+		default:
+			start := index
+			for index < len(runes) && !unicode.IsSpace(runes[index]) && runes[index] != '(' && runes[index] != ')' {
+				index++
+			}
+			tokens = append(tokens, token{value: string(runes[start:index])})
+		}
+	}
+	return tokens, nil
+}
+
+// parser with the state of the recursive descent parsing of a filter.
+type parser struct {
+	tokens   []token
+	position int
+}
+
+// ParseFilter parses a SCIM filter expression supporting the comparison operators, and, or, not and parentheses.
+func ParseFilter(expression string) (Filter, derrors.Error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, derrors.NewInvalidArgumentError("empty filter")
+	}
+	p := &parser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.position != len(p.tokens) {
+		return nil, derrors.NewInvalidArgumentError("unexpected token in filter").WithParams(p.tokens[p.position].value)
+	}
+	return filter, nil
+}
+
+// peek checks if the next token is a keyword.
+func (p *parser) peek(keyword string) bool {
+	return p.position < len(p.tokens) && !p.tokens[p.position].quoted && strings.EqualFold(p.tokens[p.position].value, keyword)
+}
+
+func (p *parser) parseOr() (Filter, derrors.Error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("or") {
+		p.position++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, derrors.Error) {
+	left, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("and") {
+		p.position++
+		right, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		left = &andFilter{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseFactor() (Filter, derrors.Error) {
+	if p.peek("not") {
+		p.position++
+		if !p.peek("(") {
+			return nil, derrors.NewInvalidArgumentError("expected ( after not in filter")
+		}
+		inner, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &notFilter{filter: inner}, nil
+	}
+	if p.peek("(") {
+		p.position++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, derrors.NewInvalidArgumentError("expected ) in filter")
+		}
+		p.position++
+		return inner, nil
+	}
+	if p.position+1 >= len(p.tokens) {
+		return nil, derrors.NewInvalidArgumentError("incomplete filter")
+	}
+	path := p.tokens[p.position]
+	operator := strings.ToLower(p.tokens[p.position+1].value)
+	if path.quoted || !operators[operator] {
+		return nil, derrors.NewInvalidArgumentError("invalid comparison in filter").WithParams(path.value, operator)
+	}
+	p.position += 2
+	filter := &comparisonFilter{path: normalizePath(path.value), operator: operator}
+	if operator == "pr" {
+		return filter, nil
+	}
+	if p.position >= len(p.tokens) {
+		return nil, derrors.NewInvalidArgumentError("missing value in filter").WithParams(path.value)
+	}
+	value := p.tokens[p.position]
+	p.position++
+	if !value.quoted && value.value == "null" {
+		value.value = ""
+	}
+	filter.value = value.value
+	return filter, nil
+}
+
+// normalizePath obtains the lowercase path of an attribute removing the schema prefix if present.
+func normalizePath(path string) string {
+	normalized := strings.ToLower(path)
+	for _, schema := range []string{UserSchema, GroupSchema} {
+		prefix := strings.ToLower(schema) + ":"
+		if strings.HasPrefix(normalized, prefix) {
+			return strings.TrimPrefix(normalized, prefix)
+		}
+	}
+	return normalized
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Filter", func() {
+
+	attributes := Attributes{
+		"username":       {"jane@example.com"},
+		"name.givenname": {"Jane"},
+		"title":          {"Engineer"},
+		"emails.value":   {"jane@example.com", "jane.doe@example.com"},
+		"phonenumbers":   {""},
+	}
+
+	table.DescribeTable("matches the attributes of a resource",
+		func(expression string, expected bool) {
+			filter, err := ParseFilter(expression)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(filter.Matches(attributes)).To(gomega.Equal(expected))
+		},
+		table.Entry("eq is case insensitive", `userName eq "JANE@example.com"`, true),
+		table.Entry("eq with schema prefix", `urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jane@example.com"`, true),
+		table.Entry("ne", `userName ne "john@example.com"`, true),
+		table.Entry("co", `name.givenName co "an"`, true),
+		table.Entry("sw", `title sw "eng"`, true),
+		table.Entry("ew", `userName ew "@other.com"`, false),
+		table.Entry("pr with value", `title pr`, true),
+		table.Entry("pr without value", `phoneNumbers pr`, false),
+		table.Entry("pr on missing attribute", `addresses pr`, false),
+		table.Entry("multi-valued attribute", `emails.value eq "jane.doe@example.com"`, true),
+		table.Entry("and", `title eq "Engineer" and name.givenName eq "John"`, false),
+		table.Entry("or", `title eq "Engineer" or name.givenName eq "John"`, true),
+		table.Entry("not", `not (title eq "Engineer")`, false),
+		table.Entry("precedence of and over or", `title eq "Manager" and userName pr or name.givenName eq "Jane"`, true),
+		table.Entry("grouping", `title eq "Manager" and (userName pr or name.givenName eq "Jane")`, false),
+		table.Entry("gt", `name.givenName gt "A"`, true),
+		table.Entry("lt", `name.givenName lt "A"`, false),
+	)
+
+	table.DescribeTable("rejects invalid expressions",
+		func(expression string) {
+			_, err := ParseFilter(expression)
+			gomega.Expect(err).NotTo(gomega.Succeed())
+		},
+		table.Entry("empty", ``),
+		table.Entry("missing value", `userName eq`),
+		table.Entry("unknown operator", `userName is "jane"`),
+		table.Entry("unterminated string", `userName eq "jane`),
+		table.Entry("unbalanced parenthesis", `(userName eq "jane"`),
+		table.Entry("trailing tokens", `userName eq "jane" title`),
+	)
+})
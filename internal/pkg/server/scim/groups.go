@@ -0,0 +1,373 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"strings"
+)
+
+// toGroup converts a role and the users holding it into a SCIM group.
+func toGroup(role *grpc_authx_go.Role, users []*grpc_user_manager_go.User) *Group {
+	members := make([]MultiValued, 0)
+	for _, user := range users {
+		if user.RoleId == role.RoleId {
+			members = append(members, MultiValued{Value: user.Email, Display: user.Email, Ref: BasePath + "/Users/" + user.Email})
+		}
+	}
+	return &Group{
+		Schemas:     []string{GroupSchema},
+		Id:          role.RoleId,
+		DisplayName: role.Name,
+		Members:     members,
+		Meta: &Meta{
+			ResourceType: "Group",
+			Location:     BasePath + "/Groups/" + role.RoleId,
+		},
+	}
+}
+
+// groupAttributes obtains the attributes of a SCIM group that can be used in filters.
+func groupAttributes(group *Group) Attributes {
+	members := make([]string, 0, len(group.Members))
+	for _, member := range group.Members {
+		members = append(members, member.Value)
+	}
+	return Attributes{
+		"id":            {group.Id},
+		"displayname":   {group.DisplayName},
+		"members":       members,
+		"members.value": members,
+	}
+}
+
+// organizationState retrieves the roles and the users of the organization that are exposed through SCIM.
+func (h *Handler) organizationState(w http.ResponseWriter, token *entities.ScimToken) ([]*grpc_authx_go.Role, []*grpc_user_manager_go.User, bool) {
+	organizationID := &grpc_organization_go.OrganizationId{OrganizationId: token.OrganizationId}
+	roles, err := h.manager.ListRoles(organizationID)
+	if err != nil {
+		h.writeManagerError(w, err)
+		return nil, nil, false
+	}
+	users, err := h.manager.ListUsers(organizationID)
+	if err != nil {
+		h.writeManagerError(w, err)
+		return nil, nil, false
+	}
+	resultRoles := make([]*grpc_authx_go.Role, 0, len(roles.Roles))
+	for _, role := range roles.Roles {
+		if !role.Internal {
+			resultRoles = append(resultRoles, role)
+		}
+	}
+	resultUsers := make([]*grpc_user_manager_go.User, 0, len(users.Users))
+	for _, user := range users.Users {
+		if !user.InternalRole {
+			resultUsers = append(resultUsers, user)
+		}
+	}
+	return resultRoles, resultUsers, true
+}
+
+// lookupGroup retrieves a group of the organization together with the users of the organization.
+func (h *Handler) lookupGroup(w http.ResponseWriter, token *entities.ScimToken, id string) (*grpc_authx_go.Role, []*grpc_user_manager_go.User, bool) {
+	roles, users, ok := h.organizationState(w, token)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, role := range roles {
+		if role.RoleId == id {
+			return role, users, true
+		}
+	}
+	h.writeError(w, http.StatusNotFound, "", "group not found")
+	return nil, nil, false
+}
+
+// listGroups lists the groups of the organization.
+func (h *Handler) listGroups(w http.ResponseWriter, r *http.Request, token *entities.ScimToken) {
+	roles, users, ok := h.organizationState(w, token)
+	if !ok {
+		return
+	}
+	resources := make([]resource, 0, len(roles))
+	for _, role := range roles {
+		group := toGroup(role, users)
+		resources = append(resources, resource{id: group.Id, attributes: groupAttributes(group), value: group})
+	}
+	h.writeList(w, r, resources)
+}
+
+// getGroup retrieves a group.
+func (h *Handler) getGroup(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	role, users, ok := h.lookupGroup(w, token, id)
+	if !ok {
+		return
+	}
+	h.writeJSON(w, http.StatusOK, toGroup(role, users))
+}
+
+// replaceGroup replaces the members of a group. Users removed from a group are assigned the default role of the token.
+func (h *Handler) replaceGroup(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	var group Group
+	if !h.readJSON(w, r, &group) {
+		return
+	}
+	role, users, ok := h.lookupGroup(w, token, id)
+	if !ok {
+		return
+	}
+	if group.DisplayName != "" && group.DisplayName != role.Name {
+		h.writeError(w, http.StatusBadRequest, "mutability", "groups cannot be renamed")
+		return
+	}
+	h.updateMembers(w, token, role, users, h.memberValues(group.Members), h.currentMembers(role, users))
+}
+
+// patchGroup adds or removes members of a group.
+func (h *Handler) patchGroup(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	var patch PatchRequest
+	if !h.readJSON(w, r, &patch) {
+		return
+	}
+	role, users, ok := h.lookupGroup(w, token, id)
+	if !ok {
+		return
+	}
+	current := h.currentMembers(role, users)
+	desired := make(map[string]bool, len(current))
+	for email := range current {
+		desired[email] = true
+	}
+	for _, operation := range patch.Operations {
+		err := h.applyGroupOperation(role, desired, operation)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalidValue", err.Error())
+			return
+		}
+	}
+	h.updateMembers(w, token, role, users, desired, current)
+}
+
+// applyGroupOperation applies a PATCH operation to the set of members of a group.
+func (h *Handler) applyGroupOperation(role *grpc_authx_go.Role, members map[string]bool, operation PatchOperation) derrors.Error {
+	op := strings.ToLower(operation.Op)
+	attribute, _ := splitPath(operation.Path)
+	switch {
+	case operation.Path == "" && (op == "add" || op == "replace"):
+		var values struct {
+			DisplayName string        `json:"displayName"`
+			Members     []MultiValued `json:"members"`
+		}
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return derrors.NewInvalidArgumentError("value must be an object when path is empty")
+		}
+		if values.DisplayName != "" && values.DisplayName != role.Name {
+			return derrors.NewInvalidArgumentError("groups cannot be renamed")
+		}
+		if op == "replace" && values.Members != nil {
+			clearMembers(members)
+		}
+		for email := range h.memberValues(values.Members) {
+			members[email] = true
+		}
+	case attribute == "displayname":
+		var name string
+		if op == "remove" || json.Unmarshal(operation.Value, &name) != nil || name != role.Name {
+			return derrors.NewInvalidArgumentError("groups cannot be renamed")
+		}
+	case attribute == "members" && (op == "add" || op == "replace"):
+		var values []MultiValued
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return derrors.NewInvalidArgumentError("members must be a list")
+		}
+		if op == "replace" {
+			clearMembers(members)
+		}
+		for email := range h.memberValues(values) {
+			members[email] = true
+		}
+	case attribute == "members" && op == "remove":
+		return h.removeMembers(members, operation)
+	default:
+		return derrors.NewInvalidArgumentError(fmt.Sprintf("unsupported operation %s on %s", operation.Op, operation.Path))
+	}
+	return nil
+}
+
+// removeMembers removes the members selected by the path filter or the value of a remove operation. An operation
+// without filter or value removes all the members.
+func (h *Handler) removeMembers(members map[string]bool, operation PatchOperation) derrors.Error {
+	start := strings.Index(operation.Path, "[")
+	end := strings.LastIndex(operation.Path, "]")
+	if start != -1 && end > start {
+		filter, err := ParseFilter(operation.Path[start+1 : end])
+		if err != nil {
+			return err
+		}
+		for email := range members {
+			if filter.Matches(Attributes{"value": {email}}) {
+				delete(members, email)
+			}
+		}
+		return nil
+	}
+	if len(operation.Value) > 0 {
+		var values []MultiValued
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return derrors.NewInvalidArgumentError("members must be a list")
+		}
+		for email := range h.memberValues(values) {
+			delete(members, email)
+		}
+		return nil
+	}
+	clearMembers(members)
+	return nil
+}
+
+// memberChange with the role assigned to a member of a group and the role it had before.
+type memberChange struct {
+	request        *grpc_user_manager_go.AssignRoleRequest
+	previousRoleID string
+}
+
+// updateMembers assigns the role of the group to the new members and the default role to the removed ones. Members
+// are identified by their normalized email. All the changes are validated before the first one is applied, and the
+// applied ones are reverted if any of them fails.
+func (h *Handler) updateMembers(w http.ResponseWriter, token *entities.ScimToken, role *grpc_authx_go.Role,
+	users []*grpc_user_manager_go.User, desired map[string]bool, current map[string]bool) {
+	known := make(map[string]*grpc_user_manager_go.User, len(users))
+	for _, user := range users {
+		known[h.memberKey(user.Email)] = user
+	}
+	for email := range desired {
+		if _, exists := known[email]; !exists {
+			h.writeError(w, http.StatusNotFound, "", fmt.Sprintf("member %s not found", email))
+			return
+		}
+	}
+	for email := range current {
+		if !desired[email] && role.RoleId == token.DefaultRoleId {
+			h.writeError(w, http.StatusBadRequest, "mutability", "members cannot be removed from the default group")
+			return
+		}
+	}
+	// new members first, so a group granting the ORG primitive does not run out of owners
+	changes := make([]memberChange, 0)
+	for email := range desired {
+		if !current[email] {
+			changes = append(changes, memberChange{
+				request:        &grpc_user_manager_go.AssignRoleRequest{OrganizationId: token.OrganizationId, Email: known[email].Email, RoleId: role.RoleId},
+				previousRoleID: known[email].RoleId,
+			})
+		}
+	}
+	for email := range current {
+		if !desired[email] {
+			changes = append(changes, memberChange{
+				request:        &grpc_user_manager_go.AssignRoleRequest{OrganizationId: token.OrganizationId, Email: known[email].Email, RoleId: token.DefaultRoleId},
+				previousRoleID: role.RoleId,
+			})
+		}
+	}
+	for _, change := range changes {
+		vErr := entities.ValidAssignRoleRequest(change.request)
+		if vErr != nil {
+			h.writeManagerError(w, vErr)
+			return
+		}
+	}
+	for index, change := range changes {
+		_, err := h.manager.AssignRole(change.request)
+		if err != nil {
+			h.revertMembers(changes[:index])
+			h.writeManagerError(w, err)
+			return
+		}
+	}
+	updated, err := h.manager.ListUsers(&grpc_organization_go.OrganizationId{OrganizationId: token.OrganizationId})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, toGroup(role, updated.Users))
+}
+
+// revertMembers assigns back the previous roles of the members changed before a failure, in reverse order.
+func (h *Handler) revertMembers(applied []memberChange) {
+	for index := len(applied) - 1; index >= 0; index-- {
+		change := applied[index]
+		_, err := h.manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: change.request.OrganizationId,
+			Email:          change.request.Email,
+			RoleId:         change.previousRoleID,
+		})
+		if err != nil {
+			log.Error().Str("organizationID", change.request.OrganizationId).Str("email", change.request.Email).
+				Str("err", conversions.ToDerror(err).DebugReport()).Msg("cannot revert the role of a group member")
+		}
+	}
+}
+
+// currentMembers obtains the normalized emails of the users holding a role.
+func (h *Handler) currentMembers(role *grpc_authx_go.Role, users []*grpc_user_manager_go.User) map[string]bool {
+	result := make(map[string]bool, 0)
+	for _, user := range users {
+		if user.RoleId == role.RoleId {
+			result[h.memberKey(user.Email)] = true
+		}
+	}
+	return result
+}
+
+// memberValues obtains the normalized emails of a list of members.
+func (h *Handler) memberValues(values []MultiValued) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, value := range values {
+		if value.Value != "" {
+			result[h.memberKey(value.Value)] = true
+		}
+	}
+	return result
+}
+
+// memberKey obtains the normalized email used to compare the members of a group. Values that cannot be normalized
+// are kept as they are and will not match any user.
+func (h *Handler) memberKey(email string) string {
+	normalized, err := h.manager.NormalizeEmail(email)
+	if err != nil {
+		return email
+	}
+	return normalized
+}
+
+// clearMembers removes all the members of a set.
+func clearMembers(members map[string]bool) {
+	for email := range members {
+		delete(members, email)
+	}
+}
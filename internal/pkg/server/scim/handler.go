@@ -0,0 +1,317 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scimtoken"
+	"github.com/nalej/user-manager/internal/pkg/server/user"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BasePath where the SCIM endpoint is served.
+	BasePath = "/scim/v2"
+	// MaxResults with the maximum number of resources returned in a page.
+	MaxResults = 200
+	// maxBodySize with the maximum size of a request body.
+	maxBodySize = 1 << 20
+)
+
+// Handler serving the SCIM 2.0 endpoint. Users are mapped to the users of the organization of the bearer token and
+// groups are mapped to its roles.
+type Handler struct {
+	manager *user.Manager
+	tokens  scimtoken.Provider
+}
+
+// NewHandler creates a SCIM handler using a user manager.
+func NewHandler(manager *user.Manager, tokens scimtoken.Provider) *Handler {
+	return &Handler{manager: manager, tokens: tokens}
+}
+
+// ServeHTTP dispatches the requests to the resource handlers after checking the bearer token.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token, err := h.authenticate(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+		h.writeError(w, http.StatusUnauthorized, "", err.Error())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, BasePath), "/")
+	segments := strings.SplitN(path, "/", 2)
+	id := ""
+	if len(segments) == 2 {
+		id = segments[1]
+	}
+	log.Debug().Str("organizationID", token.OrganizationId).Str("method", r.Method).Str("path", path).Msg("scim request")
+
+	switch segments[0] {
+	case "ServiceProviderConfig":
+		h.serviceProviderConfig(w, r)
+	case "ResourceTypes":
+		h.resourceTypes(w, r)
+	case "Users":
+		h.users(w, r, token, id)
+	case "Groups":
+		h.groups(w, r, token, id)
+	default:
+		h.writeError(w, http.StatusNotFound, "", "unknown resource")
+	}
+}
+
+// authenticate retrieves the organization token of the request.
+func (h *Handler) authenticate(r *http.Request) (*entities.ScimToken, derrors.Error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return nil, derrors.NewUnauthenticatedError("bearer token required")
+	}
+	bearer := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	if bearer == "" {
+		return nil, derrors.NewUnauthenticatedError("bearer token required")
+	}
+	token, err := h.tokens.Get(entities.HashToken(bearer))
+	if err != nil {
+		return nil, derrors.NewUnauthenticatedError("invalid bearer token")
+	}
+	return token, nil
+}
+
+// users dispatches the requests on the users resource.
+func (h *Handler) users(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listUsers(w, r, token)
+		case http.MethodPost:
+			h.createUser(w, r, token)
+		default:
+			h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.getUser(w, r, token, id)
+	case http.MethodPut:
+		h.replaceUser(w, r, token, id)
+	case http.MethodPatch:
+		h.patchUser(w, r, token, id)
+	case http.MethodDelete:
+		h.deleteUser(w, r, token, id)
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// groups dispatches the requests on the groups resource.
+func (h *Handler) groups(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	if id == "" {
+		switch r.Method {
+		case http.MethodGet:
+			h.listGroups(w, r, token)
+		case http.MethodPost:
+			h.writeError(w, http.StatusNotImplemented, "", "groups are the roles of the organization and cannot be created")
+		default:
+			h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+		}
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		h.getGroup(w, r, token, id)
+	case http.MethodPut:
+		h.replaceGroup(w, r, token, id)
+	case http.MethodPatch:
+		h.patchGroup(w, r, token, id)
+	case http.MethodDelete:
+		h.writeError(w, http.StatusNotImplemented, "", "groups are the roles of the organization and cannot be removed")
+	default:
+		h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+}
+
+// serviceProviderConfig describes the capabilities of the endpoint.
+func (h *Handler) serviceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+		return
+	}
+	config := &ServiceProviderConfig{
+		Schemas: []string{ServiceProviderConfigSchema},
+		Patch:   supported{Supported: true},
+		Bulk:    bulkSupported{Supported: false},
+		Filter:  filterSupported{Supported: true, MaxResults: MaxResults},
+		AuthenticationSchemes: []authenticationScheme{{
+			Type:        "oauthbearertoken",
+			Name:        "OAuth Bearer Token",
+			Description: "Authentication with the bearer token of the organization",
+			Primary:     true,
+		}},
+		Meta: &Meta{ResourceType: "ServiceProviderConfig", Location: BasePath + "/ServiceProviderConfig"},
+	}
+	h.writeJSON(w, http.StatusOK, config)
+}
+
+// resourceTypes describes the resources of the endpoint.
+func (h *Handler) resourceTypes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.writeError(w, http.StatusMethodNotAllowed, "", "method not allowed")
+		return
+	}
+	types := []interface{}{
+		&ResourceType{Schemas: []string{ResourceTypeSchema}, Id: "User", Name: "User", Endpoint: "/Users", Schema: UserSchema,
+			Meta: &Meta{ResourceType: "ResourceType", Location: BasePath + "/ResourceTypes/User"}},
+		&ResourceType{Schemas: []string{ResourceTypeSchema}, Id: "Group", Name: "Group", Endpoint: "/Groups", Schema: GroupSchema,
+			Meta: &Meta{ResourceType: "ResourceType", Location: BasePath + "/ResourceTypes/Group"}},
+	}
+	h.writeJSON(w, http.StatusOK, &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: len(types),
+		StartIndex:   1,
+		ItemsPerPage: len(types),
+		Resources:    types,
+	})
+}
+
+// resource that can be filtered and listed.
+type resource struct {
+	id         string
+	attributes Attributes
+	value      interface{}
+}
+
+// writeList filters and paginates a list of resources.
+func (h *Handler) writeList(w http.ResponseWriter, r *http.Request, resources []resource) {
+	query := r.URL.Query()
+	if expression := query.Get("filter"); expression != "" {
+		filter, err := ParseFilter(expression)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		filtered := make([]resource, 0)
+		for _, res := range resources {
+			if filter.Matches(res.attributes) {
+				filtered = append(filtered, res)
+			}
+		}
+		resources = filtered
+	}
+	sort.Slice(resources, func(i, j int) bool { return resources[i].id < resources[j].id })
+
+	startIndex := 1
+	if value := query.Get("startIndex"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalidValue", "invalid startIndex")
+			return
+		}
+		if parsed > 1 {
+			startIndex = parsed
+		}
+	}
+	count := MaxResults
+	if value := query.Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalidValue", "invalid count")
+			return
+		}
+		if parsed < 0 {
+			parsed = 0
+		}
+		if parsed < count {
+			count = parsed
+		}
+	}
+
+	page := make([]interface{}, 0)
+	for index := startIndex - 1; index < len(resources) && len(page) < count; index++ {
+		page = append(page, resources[index].value)
+	}
+	h.writeJSON(w, http.StatusOK, &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	})
+}
+
+// readJSON decodes the body of a request.
+func (h *Handler) readJSON(w http.ResponseWriter, r *http.Request, target interface{}) bool {
+	err := json.NewDecoder(r.Body).Decode(target)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("cannot decode request: %s", err.Error()))
+		return false
+	}
+	return true
+}
+
+// writeJSON writes a SCIM response.
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Warn().Str("err", err.Error()).Msg("cannot write scim response")
+	}
+}
+
+// writeError writes a SCIM error response.
+func (h *Handler) writeError(w http.ResponseWriter, status int, scimType string, detail string) {
+	h.writeJSON(w, status, &Error{
+		Schemas:  []string{ErrorSchema},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	})
+}
+
+// writeManagerError writes the error returned by the user manager with the matching HTTP status.
+func (h *Handler) writeManagerError(w http.ResponseWriter, err error) {
+	dErr, isDerror := err.(derrors.Error)
+	if !isDerror {
+		dErr = conversions.ToDerror(err)
+	}
+	switch dErr.Type() {
+	case derrors.InvalidArgument:
+		h.writeError(w, http.StatusBadRequest, "invalidValue", dErr.Error())
+	case derrors.NotFound:
+		h.writeError(w, http.StatusNotFound, "", dErr.Error())
+	case derrors.AlreadyExists:
+		h.writeError(w, http.StatusConflict, "uniqueness", dErr.Error())
+	case derrors.FailedPrecondition:
+		h.writeError(w, http.StatusConflict, "", dErr.Error())
+	case derrors.PermissionDenied:
+		h.writeError(w, http.StatusForbidden, "", dErr.Error())
+	default:
+		log.Error().Str("err", dErr.DebugReport()).Msg("scim request failed")
+		h.writeError(w, http.StatusInternalServerError, "", dErr.Error())
+	}
+}
@@ -0,0 +1,459 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scimtoken"
+	"github.com/nalej/user-manager/internal/pkg/server/user"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+const (
+	testOrganizationID = "org-1"
+	testToken          = "secret-token"
+	testOwner          = "owner@example.com"
+)
+
+var _ = ginkgo.Describe("Handler", func() {
+
+	var manager user.Manager
+	var authxClient *utils.FakeAuthxClient
+	var server *httptest.Server
+	var ownerRole *grpc_authx_go.Role
+	var defaultRole *grpc_authx_go.Role
+	var internalRole *grpc_authx_go.Role
+
+	addRole := func(name string, internal bool, primitives ...grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: testOrganizationID,
+			Name:           name,
+			Description:    name,
+			Internal:       internal,
+			Primitives:     primitives,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: testOrganizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	do := func(method string, path string, body interface{}, token string) *http.Response {
+		var reader *bytes.Reader
+		if body != nil {
+			content, err := json.Marshal(body)
+			gomega.Expect(err).To(gomega.Succeed())
+			reader = bytes.NewReader(content)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		request, err := http.NewRequest(method, server.URL+BasePath+path, reader)
+		gomega.Expect(err).To(gomega.Succeed())
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		request.Header.Set("Content-Type", ContentType)
+		response, err := http.DefaultClient.Do(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		return response
+	}
+
+	call := func(method string, path string, body interface{}) *http.Response {
+		return do(method, path, body, testToken)
+	}
+
+	decode := func(response *http.Response, target interface{}) {
+		defer response.Body.Close()
+		gomega.Expect(json.NewDecoder(response.Body).Decode(target)).To(gomega.Succeed())
+	}
+
+	newUser := func(email string) *User {
+		return &User{
+			Schemas:  []string{UserSchema},
+			UserName: email,
+			Name:     &Name{GivenName: "Jane", FamilyName: "Doe"},
+			Title:    "Engineer",
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		manager = user.NewManager(authxClient, utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			user.NewMockupProviders(), user.Settings{RemovedUserRetention: time.Hour})
+		ownerRole = addRole("owner", false, grpc_authx_go.AccessPrimitive_ORG)
+		defaultRole = addRole("member", false, grpc_authx_go.AccessPrimitive_PROFILE)
+		internalRole = addRole("nalej", true, grpc_authx_go.AccessPrimitive_APPCLUSTEROPS)
+		addUser(testOwner, ownerRole.RoleId)
+		addUser("internal@example.com", internalRole.RoleId)
+
+		tokens := scimtoken.NewMockupScimTokenProvider()
+		gomega.Expect(tokens.Add(*entities.NewScimToken(testOrganizationID, testToken, defaultRole.RoleId))).To(gomega.Succeed())
+		server = httptest.NewServer(NewHandler(&manager, tokens))
+	})
+
+	ginkgo.AfterEach(func() {
+		server.Close()
+	})
+
+	ginkgo.Context("authentication", func() {
+		ginkgo.It("should reject requests without token", func() {
+			response := do(http.MethodGet, "/Users", nil, "")
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusUnauthorized))
+			gomega.Expect(response.Header.Get("WWW-Authenticate")).To(gomega.ContainSubstring("Bearer"))
+		})
+		ginkgo.It("should reject unknown tokens", func() {
+			response := do(http.MethodGet, "/Users", nil, "other-token")
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusUnauthorized))
+			var scimError Error
+			decode(response, &scimError)
+			gomega.Expect(scimError.Schemas).To(gomega.ConsistOf(ErrorSchema))
+			gomega.Expect(scimError.Status).To(gomega.Equal("401"))
+		})
+	})
+
+	ginkgo.Context("configuration endpoints", func() {
+		ginkgo.It("should describe the service provider", func() {
+			response := call(http.MethodGet, "/ServiceProviderConfig", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			gomega.Expect(response.Header.Get("Content-Type")).To(gomega.Equal(ContentType))
+			var config ServiceProviderConfig
+			decode(response, &config)
+			gomega.Expect(config.Schemas).To(gomega.ConsistOf(ServiceProviderConfigSchema))
+			gomega.Expect(config.Patch.Supported).To(gomega.BeTrue())
+			gomega.Expect(config.Filter.Supported).To(gomega.BeTrue())
+			gomega.Expect(config.Bulk.Supported).To(gomega.BeFalse())
+		})
+		ginkgo.It("should list the resource types", func() {
+			response := call(http.MethodGet, "/ResourceTypes", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var list ListResponse
+			decode(response, &list)
+			gomega.Expect(list.TotalResults).To(gomega.Equal(2))
+		})
+		ginkgo.It("should return not found on unknown resources", func() {
+			response := call(http.MethodGet, "/Devices", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+	})
+
+	ginkgo.Context("users", func() {
+		ginkgo.It("should create an invited user with the default role", func() {
+			response := call(http.MethodPost, "/Users", newUser("jane@example.com"))
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusCreated))
+			gomega.Expect(response.Header.Get("Location")).To(gomega.Equal(BasePath + "/Users/jane@example.com"))
+			var created User
+			decode(response, &created)
+			gomega.Expect(created.Id).To(gomega.Equal("jane@example.com"))
+			gomega.Expect(*created.Active).To(gomega.BeTrue())
+			gomega.Expect(created.Groups[0].Value).To(gomega.Equal(defaultRole.RoleId))
+
+			retrieved, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: testOrganizationID, Email: "jane@example.com"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.PasswordResetRequired).To(gomega.BeTrue())
+		})
+		ginkgo.It("should reject duplicated users", func() {
+			response := call(http.MethodPost, "/Users", newUser(testOwner))
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusConflict))
+			var scimError Error
+			decode(response, &scimError)
+			gomega.Expect(scimError.ScimType).To(gomega.Equal("uniqueness"))
+		})
+		ginkgo.It("should reject invalid users", func() {
+			invalid := newUser("not-an-email")
+			response := call(http.MethodPost, "/Users", invalid)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should retrieve a user", func() {
+			response := call(http.MethodGet, "/Users/"+testOwner, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var retrieved User
+			decode(response, &retrieved)
+			gomega.Expect(retrieved.UserName).To(gomega.Equal(testOwner))
+			gomega.Expect(retrieved.Groups[0].Display).To(gomega.Equal("owner"))
+		})
+		ginkgo.It("should hide users with internal roles", func() {
+			response := call(http.MethodGet, "/Users/internal@example.com", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+		ginkgo.It("should list users filtering and paginating", func() {
+			for index := 0; index < 5; index++ {
+				addUser(fmt.Sprintf("user%d@example.com", index), defaultRole.RoleId)
+			}
+			response := call(http.MethodGet, "/Users?startIndex=2&count=2", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var list ListResponse
+			decode(response, &list)
+			gomega.Expect(list.TotalResults).To(gomega.Equal(6))
+			gomega.Expect(list.StartIndex).To(gomega.Equal(2))
+			gomega.Expect(list.ItemsPerPage).To(gomega.Equal(2))
+			gomega.Expect(list.Resources[0].(map[string]interface{})["id"]).To(gomega.Equal("user0@example.com"))
+
+			response = call(http.MethodGet, `/Users?filter=userName+eq+%22USER3@example.com%22`, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			decode(response, &list)
+			gomega.Expect(list.TotalResults).To(gomega.Equal(1))
+			gomega.Expect(list.Resources[0].(map[string]interface{})["id"]).To(gomega.Equal("user3@example.com"))
+		})
+		ginkgo.It("should reject invalid filters", func() {
+			response := call(http.MethodGet, `/Users?filter=userName+eq`, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+			var scimError Error
+			decode(response, &scimError)
+			gomega.Expect(scimError.ScimType).To(gomega.Equal("invalidFilter"))
+		})
+		ginkgo.It("should replace a user", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			replacement := newUser("jane@example.com")
+			replacement.Title = "Manager"
+			replacement.PhoneNumbers = []MultiValued{{Value: "555-1234"}}
+			response := call(http.MethodPut, "/Users/jane@example.com", replacement)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var updated User
+			decode(response, &updated)
+			gomega.Expect(updated.Title).To(gomega.Equal("Manager"))
+			gomega.Expect(updated.Name.GivenName).To(gomega.Equal("Jane"))
+			gomega.Expect(updated.PhoneNumbers[0].Value).To(gomega.Equal("555-1234"))
+		})
+		ginkgo.It("should not change the userName", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			response := call(http.MethodPut, "/Users/jane@example.com", newUser("john@example.com"))
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should patch a user", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			patch := &PatchRequest{
+				Schemas: []string{PatchOpSchema},
+				Operations: []PatchOperation{
+					{Op: "replace", Path: "name.givenName", Value: json.RawMessage(`"Janet"`)},
+					{Op: "Add", Path: `phoneNumbers[type eq "work"].value`, Value: json.RawMessage(`"555-0000"`)},
+					{Op: "replace", Value: json.RawMessage(`{"title": "Director"}`)},
+				},
+			}
+			response := call(http.MethodPatch, "/Users/jane@example.com", patch)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var updated User
+			decode(response, &updated)
+			gomega.Expect(updated.Name.GivenName).To(gomega.Equal("Janet"))
+			gomega.Expect(updated.Name.FamilyName).To(gomega.Equal("LastName"))
+			gomega.Expect(updated.Title).To(gomega.Equal("Director"))
+			gomega.Expect(updated.PhoneNumbers[0].Value).To(gomega.Equal("555-0000"))
+		})
+		ginkgo.It("should reject patches on unsupported attributes", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			patch := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "replace", Path: "nickName", Value: json.RawMessage(`"JJ"`)}},
+			}
+			response := call(http.MethodPatch, "/Users/jane@example.com", patch)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should remove a user deactivated with a patch", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			patch := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "replace", Path: "active", Value: json.RawMessage(`"False"`)}},
+			}
+			response := call(http.MethodPatch, "/Users/jane@example.com", patch)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var updated User
+			decode(response, &updated)
+			gomega.Expect(*updated.Active).To(gomega.BeFalse())
+			response = call(http.MethodGet, "/Users/jane@example.com", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+		ginkgo.It("should delete a user", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			response := call(http.MethodDelete, "/Users/jane@example.com", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNoContent))
+			response = call(http.MethodGet, "/Users/jane@example.com", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+		ginkgo.It("should not delete the last owner", func() {
+			response := call(http.MethodDelete, "/Users/"+testOwner, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+	})
+
+	ginkgo.Context("groups", func() {
+		ginkgo.It("should list the roles that are not internal", func() {
+			response := call(http.MethodGet, "/Groups", nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var list ListResponse
+			decode(response, &list)
+			gomega.Expect(list.TotalResults).To(gomega.Equal(2))
+
+			response = call(http.MethodGet, `/Groups?filter=displayName+eq+%22owner%22`, nil)
+			decode(response, &list)
+			gomega.Expect(list.TotalResults).To(gomega.Equal(1))
+		})
+		ginkgo.It("should retrieve a group with its members", func() {
+			response := call(http.MethodGet, "/Groups/"+ownerRole.RoleId, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var group Group
+			decode(response, &group)
+			gomega.Expect(group.DisplayName).To(gomega.Equal("owner"))
+			gomega.Expect(group.Members).To(gomega.HaveLen(1))
+			gomega.Expect(group.Members[0].Value).To(gomega.Equal(testOwner))
+		})
+		ginkgo.It("should not expose internal roles", func() {
+			response := call(http.MethodGet, "/Groups/"+internalRole.RoleId, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+		ginkgo.It("should add and remove members with a patch", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			add := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "jane@example.com"}]`)}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, add)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var group Group
+			decode(response, &group)
+			gomega.Expect(group.Members).To(gomega.HaveLen(2))
+
+			remove := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "remove", Path: `members[value eq "jane@example.com"]`}},
+			}
+			response = call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, remove)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			decode(response, &group)
+			gomega.Expect(group.Members).To(gomega.HaveLen(1))
+
+			retrieved, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: testOrganizationID, Email: "jane@example.com"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(retrieved.RoleId).To(gomega.Equal(defaultRole.RoleId))
+		})
+		ginkgo.It("should match members regardless of the case of their email", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			replacement := &Group{
+				Schemas:     []string{GroupSchema},
+				DisplayName: "owner",
+				Members:     []MultiValued{{Value: "Owner@Example.com"}, {Value: "JANE@example.com"}},
+			}
+			response := call(http.MethodPut, "/Groups/"+ownerRole.RoleId, replacement)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var group Group
+			decode(response, &group)
+			gomega.Expect(group.Members).To(gomega.HaveLen(2))
+
+			remove := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "remove", Path: "members", Value: json.RawMessage(`[{"value": "Jane@Example.com"}]`)}},
+			}
+			response = call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, remove)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			decode(response, &group)
+			gomega.Expect(group.Members).To(gomega.HaveLen(1))
+			gomega.Expect(group.Members[0].Value).To(gomega.Equal(testOwner))
+		})
+		ginkgo.It("should replace the members of a group", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			replacement := &Group{
+				Schemas:     []string{GroupSchema},
+				DisplayName: "owner",
+				Members:     []MultiValued{{Value: "jane@example.com"}},
+			}
+			response := call(http.MethodPut, "/Groups/"+ownerRole.RoleId, replacement)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusOK))
+			var group Group
+			decode(response, &group)
+			gomega.Expect(group.Members).To(gomega.HaveLen(1))
+			gomega.Expect(group.Members[0].Value).To(gomega.Equal("jane@example.com"))
+		})
+		ginkgo.It("should not remove members from the default group", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			remove := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "remove", Path: "members"}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+defaultRole.RoleId, remove)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should not leave the organization without owners", func() {
+			remove := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "remove", Path: "members"}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, remove)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should revert the members changed before a failure", func() {
+			addUser("jane@example.com", defaultRole.RoleId)
+			addUser("john@example.com", defaultRole.RoleId)
+			authxClient.FailOn("EditUserRole/john@example.com", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+			add := &PatchRequest{
+				Schemas: []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "add", Path: "members",
+					Value: json.RawMessage(`[{"value": "jane@example.com"}, {"value": "john@example.com"}]`)}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, add)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusInternalServerError))
+			for _, email := range []string{"jane@example.com", "john@example.com"} {
+				retrieved, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: testOrganizationID, Email: email})
+				gomega.Expect(err).To(gomega.Succeed())
+				gomega.Expect(retrieved.RoleId).To(gomega.Equal(defaultRole.RoleId))
+			}
+		})
+		ginkgo.It("should reject unknown members", func() {
+			add := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "ghost@example.com"}]`)}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, add)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotFound))
+		})
+		ginkgo.It("should not rename groups", func() {
+			rename := &PatchRequest{
+				Schemas:    []string{PatchOpSchema},
+				Operations: []PatchOperation{{Op: "replace", Path: "displayName", Value: json.RawMessage(`"admins"`)}},
+			}
+			response := call(http.MethodPatch, "/Groups/"+ownerRole.RoleId, rename)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusBadRequest))
+		})
+		ginkgo.It("should not create or delete groups", func() {
+			response := call(http.MethodPost, "/Groups", &Group{Schemas: []string{GroupSchema}, DisplayName: "new"})
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotImplemented))
+			response = call(http.MethodDelete, "/Groups/"+ownerRole.RoleId, nil)
+			gomega.Expect(response.StatusCode).To(gomega.Equal(http.StatusNotImplemented))
+		})
+	})
+})
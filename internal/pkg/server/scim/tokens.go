@@ -0,0 +1,78 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scimtoken"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+)
+
+// TokenEntry with a bearer token of the tokens file.
+type TokenEntry struct {
+	OrganizationId string `yaml:"organization_id"`
+	Token          string `yaml:"token"`
+	DefaultRoleId  string `yaml:"default_role_id"`
+}
+
+// LoadTokens reads a YAML file with a list of tokens and stores their hashes in the provider. The file is the source
+// of the tokens: stored tokens that are no longer listed or that changed are removed.
+func LoadTokens(path string, provider scimtoken.Provider) derrors.Error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return derrors.AsError(err, "cannot read SCIM tokens file")
+	}
+	entries := make([]TokenEntry, 0)
+	err = yaml.UnmarshalStrict(content, &entries)
+	if err != nil {
+		return derrors.NewInvalidArgumentError("cannot decode SCIM tokens file", err)
+	}
+	tokens := make(map[string]entities.ScimToken, len(entries))
+	for index, entry := range entries {
+		if entry.OrganizationId == "" || entry.Token == "" || entry.DefaultRoleId == "" {
+			return derrors.NewInvalidArgumentError("organization_id, token and default_role_id are required").WithParams(index)
+		}
+		token := entities.NewScimToken(entry.OrganizationId, entry.Token, entry.DefaultRoleId)
+		if _, exists := tokens[token.TokenHash]; exists {
+			return derrors.NewAlreadyExistsError("scim token").WithParams(entry.OrganizationId)
+		}
+		tokens[token.TokenHash] = *token
+	}
+	stored, lErr := provider.List()
+	if lErr != nil {
+		return lErr
+	}
+	for _, token := range stored {
+		if listed, exists := tokens[token.TokenHash]; exists && listed == token {
+			delete(tokens, token.TokenHash)
+			continue
+		}
+		rErr := provider.Remove(token.TokenHash)
+		if rErr != nil && rErr.Type() != derrors.NotFound {
+			return rErr
+		}
+	}
+	for _, token := range tokens {
+		aErr := provider.Add(token)
+		if aErr != nil && aErr.Type() != derrors.AlreadyExists {
+			return aErr
+		}
+	}
+	return nil
+}
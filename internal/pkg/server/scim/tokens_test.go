@@ -0,0 +1,90 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/scimtoken"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+)
+
+var _ = ginkgo.Describe("SCIM tokens file", func() {
+
+	var path string
+	var provider *scimtoken.MockupScimTokenProvider
+
+	writeTokens := func(content string) {
+		gomega.Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(gomega.Succeed())
+	}
+
+	ginkgo.BeforeEach(func() {
+		file, err := ioutil.TempFile("", "scim-tokens")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(file.Close()).To(gomega.Succeed())
+		path = file.Name()
+		provider = scimtoken.NewMockupScimTokenProvider()
+	})
+
+	ginkgo.AfterEach(func() {
+		_ = os.Remove(path)
+	})
+
+	ginkgo.It("should store the listed tokens and remove the ones no longer listed", func() {
+		writeTokens(`
+- organization_id: org-1
+  token: token-1
+  default_role_id: role-1
+- organization_id: org-2
+  token: token-2
+  default_role_id: role-2
+`)
+		gomega.Expect(LoadTokens(path, provider)).To(gomega.Succeed())
+		tokens, err := provider.List()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(tokens)).Should(gomega.Equal(2))
+
+		writeTokens(`
+- organization_id: org-1
+  token: token-1
+  default_role_id: role-3
+`)
+		gomega.Expect(LoadTokens(path, provider)).To(gomega.Succeed())
+		_, err = provider.Get(entities.HashToken("token-2"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		token, err := provider.Get(entities.HashToken("token-1"))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(token.DefaultRoleId).Should(gomega.Equal("role-3"))
+	})
+
+	ginkgo.It("should reject a file listing the same token twice", func() {
+		writeTokens(`
+- organization_id: org-1
+  token: token-1
+  default_role_id: role-1
+- organization_id: org-2
+  token: token-1
+  default_role_id: role-2
+`)
+		err := LoadTokens(path, provider)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(err.Type()).Should(gomega.Equal(derrors.AlreadyExists))
+	})
+})
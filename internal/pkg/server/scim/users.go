@@ -0,0 +1,489 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// toUser converts a user of the platform into a SCIM user. Users present in the platform are always active.
+func toUser(user *grpc_user_manager_go.User) *User {
+	active := true
+	result := &User{
+		Schemas:  []string{UserSchema},
+		Id:       user.Email,
+		UserName: user.Email,
+		Name: &Name{
+			Formatted:  strings.TrimSpace(fmt.Sprintf("%s %s", user.Name, user.LastName)),
+			GivenName:  user.Name,
+			FamilyName: user.LastName,
+		},
+		DisplayName: strings.TrimSpace(fmt.Sprintf("%s %s", user.Name, user.LastName)),
+		Title:       user.Title,
+		Active:      &active,
+		Emails:      []MultiValued{{Value: user.Email, Type: "work", Primary: true}},
+		Groups:      []MultiValued{{Value: user.RoleId, Display: user.RoleName, Ref: BasePath + "/Groups/" + user.RoleId}},
+		Meta: &Meta{
+			ResourceType: "User",
+			Location:     BasePath + "/Users/" + user.Email,
+		},
+	}
+	if user.MemberSince > 0 {
+		result.Meta.Created = time.Unix(user.MemberSince, 0).UTC().Format(time.RFC3339)
+	}
+	if user.Phone != "" {
+		result.PhoneNumbers = []MultiValued{{Value: user.Phone, Type: "work", Primary: true}}
+	}
+	if user.Location != "" {
+		result.Addresses = []Address{{Formatted: user.Location, Type: "work", Primary: true}}
+	}
+	return result
+}
+
+// userAttributes obtains the attributes of a SCIM user that can be used in filters.
+func userAttributes(user *User) Attributes {
+	return Attributes{
+		"id":              {user.Id},
+		"username":        {user.UserName},
+		"displayname":     {user.DisplayName},
+		"name.givenname":  {user.Name.GivenName},
+		"name.familyname": {user.Name.FamilyName},
+		"name.formatted":  {user.Name.Formatted},
+		"title":           {user.Title},
+		"active":          {"true"},
+		"emails":          {user.UserName},
+		"emails.value":    {user.UserName},
+		"groups":          {user.Groups[0].Value},
+		"groups.value":    {user.Groups[0].Value},
+		"groups.display":  {user.Groups[0].Display},
+	}
+}
+
+// lookupUser retrieves a user of the organization. Users with internal roles are not exposed.
+func (h *Handler) lookupUser(w http.ResponseWriter, token *entities.ScimToken, id string) (*grpc_user_manager_go.User, bool) {
+	email, nErr := h.manager.ResolveEmail(token.OrganizationId, id)
+	if nErr != nil {
+		h.writeError(w, http.StatusNotFound, "", "user not found")
+		return nil, false
+	}
+	user, err := h.manager.GetUser(&grpc_user_go.UserId{OrganizationId: token.OrganizationId, Email: email})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return nil, false
+	}
+	if user.InternalRole {
+		h.writeError(w, http.StatusNotFound, "", "user not found")
+		return nil, false
+	}
+	return user, true
+}
+
+// listUsers lists the users of the organization.
+func (h *Handler) listUsers(w http.ResponseWriter, r *http.Request, token *entities.ScimToken) {
+	users, err := h.manager.ListUsers(&grpc_organization_go.OrganizationId{OrganizationId: token.OrganizationId})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	resources := make([]resource, 0, len(users.Users))
+	for _, user := range users.Users {
+		if user.InternalRole {
+			continue
+		}
+		scimUser := toUser(user)
+		resources = append(resources, resource{id: scimUser.Id, attributes: userAttributes(scimUser), value: scimUser})
+	}
+	h.writeList(w, r, resources)
+}
+
+// getUser retrieves a user.
+func (h *Handler) getUser(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	user, found := h.lookupUser(w, token, id)
+	if !found {
+		return
+	}
+	h.writeJSON(w, http.StatusOK, toUser(user))
+}
+
+// createUser adds a user with the default role of the token. Users without password are invited.
+func (h *Handler) createUser(w http.ResponseWriter, r *http.Request, token *entities.ScimToken) {
+	var scimUser User
+	if !h.readJSON(w, r, &scimUser) {
+		return
+	}
+	if scimUser.Active != nil && !*scimUser.Active {
+		h.writeError(w, http.StatusBadRequest, "invalidValue", "inactive users cannot be provisioned")
+		return
+	}
+	email, nErr := h.manager.NormalizeEmail(scimUser.UserName)
+	if nErr != nil {
+		h.writeManagerError(w, nErr)
+		return
+	}
+	request := &grpc_user_manager_go.AddUserRequest{
+		OrganizationId: token.OrganizationId,
+		Email:          email,
+		Password:       scimUser.Password,
+		Title:          scimUser.Title,
+		RoleId:         token.DefaultRoleId,
+	}
+	if scimUser.Name != nil {
+		request.Name = scimUser.Name.GivenName
+		request.LastName = scimUser.Name.FamilyName
+	}
+	if len(scimUser.PhoneNumbers) > 0 {
+		request.Phone = scimUser.PhoneNumbers[0].Value
+	}
+	if len(scimUser.Addresses) > 0 {
+		request.Location = scimUser.Addresses[0].Formatted
+	}
+	var vErr derrors.Error
+	if request.Password == "" {
+		vErr = entities.ValidInviteUserRequest(request)
+	} else {
+		vErr = entities.ValidAddUserRequest(request)
+	}
+	if vErr != nil {
+		h.writeManagerError(w, vErr)
+		return
+	}
+	var user *grpc_user_manager_go.User
+	var err error
+	if request.Password == "" {
+		user, err = h.manager.InviteUser(request)
+	} else {
+		user, err = h.manager.AddUser(request)
+	}
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	result := toUser(user)
+	w.Header().Set("Location", result.Meta.Location)
+	h.writeJSON(w, http.StatusCreated, result)
+}
+
+// replaceUser replaces the profile of a user. Setting active to false removes the user.
+func (h *Handler) replaceUser(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	var scimUser User
+	if !h.readJSON(w, r, &scimUser) {
+		return
+	}
+	if scimUser.UserName != "" && !strings.EqualFold(scimUser.UserName, id) {
+		h.writeError(w, http.StatusBadRequest, "mutability", "userName cannot be modified")
+		return
+	}
+	user, found := h.lookupUser(w, token, id)
+	if !found {
+		return
+	}
+	if scimUser.Active != nil && !*scimUser.Active {
+		h.deactivateUser(w, user)
+		return
+	}
+	update := &grpc_user_go.UpdateUserRequest{
+		OrganizationId: token.OrganizationId,
+		Email:          user.Email,
+		UpdateTitle:    true,
+		Title:          scimUser.Title,
+		UpdatePhone:    true,
+		UpdateLocation: true,
+	}
+	if scimUser.Name != nil {
+		update.UpdateName = true
+		update.Name = scimUser.Name.GivenName
+		update.UpdateLastName = true
+		update.LastName = scimUser.Name.FamilyName
+	}
+	if len(scimUser.PhoneNumbers) > 0 {
+		update.Phone = scimUser.PhoneNumbers[0].Value
+	}
+	if len(scimUser.Addresses) > 0 {
+		update.Location = scimUser.Addresses[0].Formatted
+	}
+	h.updateUser(w, update)
+}
+
+// patchUser applies a set of modifications to the profile of a user.
+func (h *Handler) patchUser(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	var patch PatchRequest
+	if !h.readJSON(w, r, &patch) {
+		return
+	}
+	user, found := h.lookupUser(w, token, id)
+	if !found {
+		return
+	}
+	update := &grpc_user_go.UpdateUserRequest{
+		OrganizationId: token.OrganizationId,
+		Email:          user.Email,
+	}
+	deactivate := false
+	for _, operation := range patch.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			h.writeError(w, http.StatusBadRequest, "invalidSyntax", fmt.Sprintf("unsupported operation %s", operation.Op))
+			return
+		}
+		if operation.Path == "" {
+			if op == "remove" {
+				h.writeError(w, http.StatusBadRequest, "noTarget", "remove operations require a path")
+				return
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				h.writeError(w, http.StatusBadRequest, "invalidValue", "value must be an object when path is empty")
+				return
+			}
+			for path, value := range values {
+				inactive, err := applyUserAttribute(update, path, value, false)
+				if err != nil {
+					h.writeError(w, http.StatusBadRequest, "invalidPath", err.Error())
+					return
+				}
+				deactivate = deactivate || inactive
+			}
+			continue
+		}
+		inactive, err := applyUserAttribute(update, operation.Path, operation.Value, op == "remove")
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalidPath", err.Error())
+			return
+		}
+		deactivate = deactivate || inactive
+	}
+	if deactivate {
+		h.deactivateUser(w, user)
+		return
+	}
+	h.updateUser(w, update)
+}
+
+// deleteUser removes a user.
+func (h *Handler) deleteUser(w http.ResponseWriter, r *http.Request, token *entities.ScimToken, id string) {
+	user, found := h.lookupUser(w, token, id)
+	if !found {
+		return
+	}
+	err := h.manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: user.OrganizationId, Email: user.Email})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deactivateUser removes a user returning its last representation as inactive.
+func (h *Handler) deactivateUser(w http.ResponseWriter, user *grpc_user_manager_go.User) {
+	err := h.manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: user.OrganizationId, Email: user.Email})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	result := toUser(user)
+	inactive := false
+	result.Active = &inactive
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// updateUser validates and applies an update returning the updated user.
+func (h *Handler) updateUser(w http.ResponseWriter, update *grpc_user_go.UpdateUserRequest) {
+	vErr := entities.ValidUpdateUserRequest(update)
+	if vErr != nil {
+		h.writeManagerError(w, vErr)
+		return
+	}
+	_, err := h.manager.UpdateUser(update)
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	user, err := h.manager.GetUser(&grpc_user_go.UserId{OrganizationId: update.OrganizationId, Email: update.Email})
+	if err != nil {
+		h.writeManagerError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, toUser(user))
+}
+
+// splitPath splits an attribute path into the attribute and the sub-attribute, ignoring value filters such as
+// phoneNumbers[type eq "work"].value.
+func splitPath(path string) (string, string) {
+	normalized := normalizePath(path)
+	if start := strings.Index(normalized, "["); start != -1 {
+		end := strings.Index(normalized, "]")
+		if end == -1 || end < start {
+			return normalized[:start], ""
+		}
+		return normalized[:start], strings.TrimPrefix(normalized[end+1:], ".")
+	}
+	if dot := strings.Index(normalized, "."); dot != -1 {
+		return normalized[:dot], normalized[dot+1:]
+	}
+	return normalized, ""
+}
+
+// applyUserAttribute sets or clears an attribute of an update request. It returns true if the operation deactivates
+// the user.
+func applyUserAttribute(update *grpc_user_go.UpdateUserRequest, path string, value json.RawMessage, remove bool) (bool, derrors.Error) {
+	attribute, subAttribute := splitPath(path)
+	switch attribute {
+	case "name":
+		if subAttribute == "" {
+			var name Name
+			if !remove {
+				if err := json.Unmarshal(value, &name); err != nil {
+					return false, derrors.NewInvalidArgumentError("invalid name value")
+				}
+			}
+			if remove || name.GivenName != "" {
+				update.UpdateName, update.Name = true, name.GivenName
+			}
+			if remove || name.FamilyName != "" {
+				update.UpdateLastName, update.LastName = true, name.FamilyName
+			}
+			return false, nil
+		}
+		text, err := stringValue(value, remove)
+		if err != nil {
+			return false, err
+		}
+		switch subAttribute {
+		case "givenname":
+			update.UpdateName, update.Name = true, text
+		case "familyname":
+			update.UpdateLastName, update.LastName = true, text
+		case "formatted":
+			// formatted is derived from the given and family names
+		default:
+			return false, derrors.NewInvalidArgumentError("unsupported attribute").WithParams(path)
+		}
+	case "title":
+		text, err := stringValue(value, remove)
+		if err != nil {
+			return false, err
+		}
+		update.UpdateTitle, update.Title = true, text
+	case "displayname", "externalid":
+		// displayName is derived from the given and family names and external identifiers are not stored
+	case "phonenumbers":
+		text, err := multiValuedValue(value, remove, subAttribute)
+		if err != nil {
+			return false, err
+		}
+		update.UpdatePhone, update.Phone = true, text
+	case "addresses":
+		text, err := addressValue(value, remove, subAttribute)
+		if err != nil {
+			return false, err
+		}
+		update.UpdateLocation, update.Location = true, text
+	case "active":
+		if remove {
+			return false, derrors.NewInvalidArgumentError("active cannot be removed")
+		}
+		active, err := boolValue(value)
+		if err != nil {
+			return false, err
+		}
+		return !active, nil
+	default:
+		return false, derrors.NewInvalidArgumentError("unsupported attribute").WithParams(path)
+	}
+	return false, nil
+}
+
+// stringValue decodes a string value. Removed attributes are empty.
+func stringValue(value json.RawMessage, remove bool) (string, derrors.Error) {
+	if remove {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return "", derrors.NewInvalidArgumentError("value must be a string")
+	}
+	return text, nil
+}
+
+// boolValue decodes a boolean value. Some identity providers send booleans as strings.
+func boolValue(value json.RawMessage) (bool, derrors.Error) {
+	var result bool
+	if err := json.Unmarshal(value, &result); err == nil {
+		return result, nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		switch strings.ToLower(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+	}
+	return false, derrors.NewInvalidArgumentError("value must be a boolean")
+}
+
+// multiValuedValue decodes the first value of a multi-valued attribute.
+func multiValuedValue(value json.RawMessage, remove bool, subAttribute string) (string, derrors.Error) {
+	if remove {
+		return "", nil
+	}
+	if subAttribute != "" {
+		if subAttribute != "value" {
+			return "", derrors.NewInvalidArgumentError("unsupported sub-attribute").WithParams(subAttribute)
+		}
+		return stringValue(value, false)
+	}
+	var values []MultiValued
+	if err := json.Unmarshal(value, &values); err != nil {
+		return "", derrors.NewInvalidArgumentError("value must be a list")
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	return values[0].Value, nil
+}
+
+// addressValue decodes the formatted value of the first address.
+func addressValue(value json.RawMessage, remove bool, subAttribute string) (string, derrors.Error) {
+	if remove {
+		return "", nil
+	}
+	if subAttribute != "" {
+		if subAttribute != "formatted" {
+			return "", derrors.NewInvalidArgumentError("unsupported sub-attribute").WithParams(subAttribute)
+		}
+		return stringValue(value, false)
+	}
+	var values []Address
+	if err := json.Unmarshal(value, &values); err != nil {
+		return "", derrors.NewInvalidArgumentError("value must be a list")
+	}
+	if len(values) == 0 {
+		return "", nil
+	}
+	return values[0].Formatted, nil
+}
@@ -23,22 +23,64 @@ import (
 	"github.com/nalej/grpc-role-go"
 	"github.com/nalej/grpc-user-go"
 	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/accessrequest"
+	"github.com/nalej/user-manager/internal/pkg/provider/audit"
+	"github.com/nalej/user-manager/internal/pkg/provider/claimrules"
+	"github.com/nalej/user-manager/internal/pkg/provider/domainpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/emailchange"
+	"github.com/nalej/user-manager/internal/pkg/provider/group"
+	"github.com/nalej/user-manager/internal/pkg/provider/identity"
+	"github.com/nalej/user-manager/internal/pkg/provider/lease"
+	"github.com/nalej/user-manager/internal/pkg/provider/mfa"
+	"github.com/nalej/user-manager/internal/pkg/provider/offboarding"
+	"github.com/nalej/user-manager/internal/pkg/provider/ownerpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/passwordreset"
+	"github.com/nalej/user-manager/internal/pkg/provider/recyclebin"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolebinding"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolegrant"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"github.com/nalej/user-manager/internal/pkg/provider/roletemplate"
+	"github.com/nalej/user-manager/internal/pkg/provider/scimtoken"
+	"github.com/nalej/user-manager/internal/pkg/provider/scylladb"
+	"github.com/nalej/user-manager/internal/pkg/provider/serviceaccount"
+	"github.com/nalej/user-manager/internal/pkg/server/ldapsync"
+	"github.com/nalej/user-manager/internal/pkg/server/scim"
 	"github.com/nalej/user-manager/internal/pkg/server/user"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
+// RecycleBinPurgePeriod with the period between purges of the expired removed users.
+const RecycleBinPurgePeriod = time.Hour
+
+// RoleGrantRevertPeriod with the period between checks of the expired temporary role assignments.
+const RoleGrantRevertPeriod = time.Minute
+
+// AccessRequestExpirePeriod with the period between checks of the expired access requests.
+const AccessRequestExpirePeriod = 10 * time.Minute
+
 // Service structure with the configuration and the gRPC server.
 type Service struct {
 	Configuration Config
+	// leases of the periodic tasks, so only one replica runs each of them.
+	leases lease.Provider
+	// holder identifying this replica in the leases.
+	holder string
 }
 
 // NewService creates a new system model service.
 func NewService(conf Config) *Service {
 	return &Service{
-		conf,
+		Configuration: conf,
 	}
 }
 
@@ -85,10 +127,99 @@ func (s *Service) Run() error {
 		log.Fatal().Errs("failed to listen: %v", []error{err})
 	}
 
+	// Create providers
+	session := scylladb.NewSession(s.Configuration.ScyllaDBAddress, s.Configuration.ScyllaDBPort, s.Configuration.KeySpace)
+	_, cErr = session.Connect()
+	if cErr != nil {
+		log.Fatal().Str("err", cErr.DebugReport()).Msg("cannot connect to ScyllaDB")
+	}
+	defer session.Disconnect()
+
+	retention := time.Duration(s.Configuration.RemovedUserRetentionDays) * 24 * time.Hour
+	claimRules := claimrules.NewMockupClaimRulesProvider()
+	if s.Configuration.ClaimRulesPath != "" {
+		err := claimrules.Load(s.Configuration.ClaimRulesPath, claimRules)
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot load claim rules")
+		}
+	}
+
+	roleTemplates := roletemplate.NewMockupRoleTemplateProvider()
+	if s.Configuration.RoleTemplatesPath != "" {
+		err := roletemplate.LoadDirectory(s.Configuration.RoleTemplatesPath, roleTemplates)
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot load role templates")
+		}
+	}
+
+	var mfaCipher *entities.SecretCipher
+	if s.Configuration.MfaKeyPath != "" {
+		mfaCipher, cErr = s.loadMfaCipher()
+		if cErr != nil {
+			log.Fatal().Str("err", cErr.DebugReport()).Msg("cannot load MFA key")
+		}
+	}
+
+	var emailNormalizer *entities.EmailNormalizer
+	if s.Configuration.EmailRulesPath != "" {
+		emailNormalizer, cErr = s.loadEmailNormalizer()
+		if cErr != nil {
+			log.Fatal().Str("err", cErr.DebugReport()).Msg("cannot load email rules")
+		}
+	}
+
+	approver := grpc_authx_go.AccessPrimitive(grpc_authx_go.AccessPrimitive_value[s.Configuration.AccessRequestApprover])
+	accessRequestTTL := time.Duration(s.Configuration.AccessRequestExpirationHours) * time.Hour
+
 	// Create handlers
-	manager := user.NewManager(clients.AuthxClient, clients.UsersClient, clients.RolesClient)
+	providers := user.Providers{
+		RecycleBin:      recyclebin.NewScyllaRecycleBinProvider(session),
+		PasswordResets:  passwordreset.NewScyllaPasswordResetProvider(session),
+		ClaimRules:      claimRules,
+		ServiceAccounts: serviceaccount.NewScyllaServiceAccountProvider(session),
+		MFA:             mfa.NewScyllaMFAProvider(session),
+		RoleGrants:      rolegrant.NewScyllaRoleGrantProvider(session),
+		AuditLog:        audit.NewScyllaAuditProvider(session),
+		AccessRequests:  accessrequest.NewScyllaAccessRequestProvider(session),
+		RoleTemplates:   roleTemplates,
+		Offboardings:    offboarding.NewScyllaOffboardingProvider(session),
+		OwnerPolicies:   ownerpolicy.NewScyllaOwnerPolicyProvider(session),
+		RoleHierarchy:   rolehierarchy.NewScyllaRoleHierarchyProvider(session),
+		Groups:          group.NewScyllaGroupProvider(session),
+		RoleBindings:    rolebinding.NewScyllaRoleBindingProvider(session),
+		Identities:      identity.NewScyllaIdentityProvider(session),
+		EmailChanges:    emailchange.NewScyllaEmailChangeProvider(session),
+		DomainPolicies:  domainpolicy.NewScyllaEmailDomainPolicyProvider(session),
+	}
+	settings := user.Settings{
+		RemovedUserRetention: retention,
+		MfaCipher:            mfaCipher,
+		ApproverPrimitive:    approver,
+		AccessRequestTTL:     accessRequestTTL,
+		EmailNormalizer:      emailNormalizer,
+	}
+	manager := user.NewManager(clients.AuthxClient, clients.UsersClient, clients.RolesClient, providers, settings)
 	handler := user.NewHandler(manager)
 
+	s.leases = lease.NewScyllaLeaseProvider(session)
+	s.holder = leaseHolder()
+	go s.purgeRemovedUsers(manager)
+	go s.revertRoleGrants(manager)
+	go s.expireAccessRequests(manager)
+
+	if s.Configuration.ScimTokensPath != "" {
+		go s.serveScim(&manager, scimtoken.NewScyllaScimTokenProvider(session))
+	}
+
+	if s.Configuration.LdapSyncPath != "" {
+		configs, err := ldapsync.LoadConfigs(s.Configuration.LdapSyncPath)
+		if err != nil {
+			log.Fatal().Str("err", err.DebugReport()).Msg("cannot load LDAP sync configuration")
+		}
+		scheduler := ldapsync.NewScheduler(ldapsync.NewSyncer(&manager, ldapsync.NewLDAPDirectory()), configs)
+		go scheduler.Run(make(chan struct{}))
+	}
+
 	grpcServer := grpc.NewServer()
 
 	grpc_user_manager_go.RegisterUserManagerServer(grpcServer, handler)
@@ -101,3 +232,110 @@ func (s *Service) Run() error {
 	}
 	return nil
 }
+
+// loadMfaCipher creates the cipher of the TOTP secrets with the key of the configuration.
+func (s *Service) loadMfaCipher() (*entities.SecretCipher, derrors.Error) {
+	content, err := ioutil.ReadFile(s.Configuration.MfaKeyPath)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read MFA key file")
+	}
+	return entities.NewSecretCipher(strings.TrimSpace(string(content)))
+}
+
+// loadEmailNormalizer creates the email normalizer with the provider rules of the configuration.
+func (s *Service) loadEmailNormalizer() (*entities.EmailNormalizer, derrors.Error) {
+	content, err := ioutil.ReadFile(s.Configuration.EmailRulesPath)
+	if err != nil {
+		return nil, derrors.AsError(err, "cannot read email rules file")
+	}
+	rules := make([]entities.EmailRule, 0)
+	err = yaml.UnmarshalStrict(content, &rules)
+	if err != nil {
+		return nil, derrors.NewInvalidArgumentError("cannot decode email rules file", err)
+	}
+	return entities.NewEmailNormalizer(rules)
+}
+
+// leaseHolder identifies this replica with its host name, which is the name of the pod, and its process.
+func leaseHolder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "user-manager"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// holdsLease checks if this replica runs a periodic task, taking or extending its lease. The lease lasts two periods
+// so its holder keeps it while it is alive, and another replica takes over once it has been gone for that time.
+func (s *Service) holdsLease(name string, period time.Duration) bool {
+	acquired, err := s.leases.Acquire(name, s.holder, 2*period)
+	if err != nil {
+		log.Warn().Str("task", name).Str("err", err.DebugReport()).Msg("cannot acquire the lease of the task")
+		return false
+	}
+	return acquired
+}
+
+// purgeRemovedUsers periodically removes the expired users from the recycle bin in the replica holding its lease.
+func (s *Service) purgeRemovedUsers(manager user.Manager) {
+	ticker := time.NewTicker(RecycleBinPurgePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !s.holdsLease("purgeRemovedUsers", RecycleBinPurgePeriod) {
+			continue
+		}
+		err := manager.PurgeRemovedUsers()
+		if err != nil {
+			log.Warn().Str("err", err.DebugReport()).Msg("cannot purge removed users")
+		}
+	}
+}
+
+// revertRoleGrants periodically reverts the users whose temporary role has expired in the replica holding its lease.
+func (s *Service) revertRoleGrants(manager user.Manager) {
+	ticker := time.NewTicker(RoleGrantRevertPeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !s.holdsLease("revertRoleGrants", RoleGrantRevertPeriod) {
+			continue
+		}
+		err := manager.RevertExpiredRoleGrants()
+		if err != nil {
+			log.Warn().Str("err", err.DebugReport()).Msg("cannot revert expired role grants")
+		}
+	}
+}
+
+// expireAccessRequests periodically expires the access requests that were not resolved in time in the replica
+// holding its lease.
+func (s *Service) expireAccessRequests(manager user.Manager) {
+	ticker := time.NewTicker(AccessRequestExpirePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !s.holdsLease("expireAccessRequests", AccessRequestExpirePeriod) {
+			continue
+		}
+		err := manager.ExpireAccessRequests()
+		if err != nil {
+			log.Warn().Str("err", err.DebugReport()).Msg("cannot expire access requests")
+		}
+	}
+}
+
+// serveScim launches the SCIM endpoint after storing the tokens of the configuration in the provider.
+func (s *Service) serveScim(manager *user.Manager, tokens scimtoken.Provider) {
+	err := scim.LoadTokens(s.Configuration.ScimTokensPath, tokens)
+	if err != nil {
+		log.Fatal().Str("err", err.DebugReport()).Msg("cannot load SCIM tokens")
+	}
+	mux := http.NewServeMux()
+	mux.Handle(scim.BasePath+"/", scim.NewHandler(manager, tokens))
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.Configuration.ScimPort),
+		Handler: mux,
+	}
+	log.Info().Int("port", s.Configuration.ScimPort).Msg("Launching SCIM endpoint")
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Fatal().Errs("failed to serve SCIM: %v", []error{err})
+	}
+}
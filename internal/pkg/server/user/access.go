@@ -0,0 +1,92 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+)
+
+// CheckAccess evaluates whether a user is granted each of the requested primitives by its effective role, which is
+// either its own role or the role of one of its groups. Users or roles that cannot be found are denied every
+// primitive with the reason instead of failing the request.
+func (m *Manager) CheckAccess(request *grpc_user_manager_go.CheckAccessRequest) (*grpc_user_manager_go.CheckAccessResponse, error) {
+	response := &grpc_user_manager_go.CheckAccessResponse{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+	}
+	credentials, err := m.accessClient.GetUserRole(context.Background(), &grpc_user_go.UserId{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+	})
+	if err != nil {
+		if ignoreNotFound(err) != nil {
+			return nil, err
+		}
+		response.Decisions = denyAll(request.Primitives, "user not found in the organization")
+		return response, nil
+	}
+	effective, err := m.effectiveUserRole(request.OrganizationId, request.Email, credentials.RoleId)
+	if err != nil {
+		if ignoreNotFound(err) != nil {
+			return nil, err
+		}
+		response.RoleId = credentials.RoleId
+		response.Decisions = denyAll(request.Primitives, fmt.Sprintf("role %s not found", credentials.RoleId))
+		return response, nil
+	}
+	role := effective.Role
+	response.RoleId = role.RoleId
+	response.RoleName = role.Name
+	response.Decisions = make([]*grpc_user_manager_go.AccessDecision, 0, len(request.Primitives))
+	for _, primitive := range request.Primitives {
+		decision := &grpc_user_manager_go.AccessDecision{Primitive: primitive}
+		if hasPrimitive(role, primitive) {
+			decision.Allowed = true
+			decision.Reason = grantReason(effective, primitive)
+		} else {
+			decision.Reason = fmt.Sprintf("role %s does not grant %s", role.Name, primitive)
+		}
+		response.Decisions = append(response.Decisions, decision)
+	}
+	return response, nil
+}
+
+// CheckAccessBatch evaluates a set of access checks. The responses follow the order of the requests.
+func (m *Manager) CheckAccessBatch(request *grpc_user_manager_go.CheckAccessBatchRequest) (*grpc_user_manager_go.CheckAccessBatchResponse, error) {
+	responses := make([]*grpc_user_manager_go.CheckAccessResponse, 0, len(request.Requests))
+	for _, check := range request.Requests {
+		response, err := m.CheckAccess(check)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	return &grpc_user_manager_go.CheckAccessBatchResponse{Responses: responses}, nil
+}
+
+// denyAll denies a set of primitives with the same reason.
+func denyAll(primitives []grpc_authx_go.AccessPrimitive, reason string) []*grpc_user_manager_go.AccessDecision {
+	decisions := make([]*grpc_user_manager_go.AccessDecision, 0, len(primitives))
+	for _, primitive := range primitives {
+		decisions = append(decisions, &grpc_user_manager_go.AccessDecision{Primitive: primitive, Reason: reason})
+	}
+	return decisions
+}
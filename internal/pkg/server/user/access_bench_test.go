@@ -0,0 +1,122 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"fmt"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"testing"
+)
+
+// benchmarkOrganization with the organization used by the access check benchmarks.
+const benchmarkOrganization = "bench-org"
+
+// newBenchmarkManager creates a Manager with a bootstrapped organization and a number of members.
+func newBenchmarkManager(b *testing.B, members int) Manager {
+	manager := NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+		NewMockupProviders(), testSettings())
+	response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+		OrganizationId: benchmarkOrganization,
+		Email:          "owner@example.com",
+		Password:       "password",
+		Name:           "Name",
+		LastName:       "LastName",
+		Title:          "Title",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < members; i++ {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: benchmarkOrganization,
+			Email:          benchmarkEmail(i),
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         response.Roles[1+i%2].RoleId,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	return manager
+}
+
+func benchmarkEmail(index int) string {
+	return fmt.Sprintf("member-%d@example.com", index)
+}
+
+func benchmarkCheck(email string) *grpc_user_manager_go.CheckAccessRequest {
+	return &grpc_user_manager_go.CheckAccessRequest{
+		OrganizationId: benchmarkOrganization,
+		Email:          email,
+		Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG,
+			grpc_authx_go.AccessPrimitive_APPS, grpc_authx_go.AccessPrimitive_RESOURCES},
+	}
+}
+
+func BenchmarkCheckAccess(b *testing.B) {
+	manager := newBenchmarkManager(b, 10)
+	request := benchmarkCheck(benchmarkEmail(0))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.CheckAccess(request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckAccessUncached(b *testing.B) {
+	manager := newBenchmarkManager(b, 10)
+	request := benchmarkCheck(benchmarkEmail(0))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = manager.rolesCache.Clear(benchmarkOrganization)
+		if _, err := manager.CheckAccess(request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckAccessParallel(b *testing.B) {
+	manager := newBenchmarkManager(b, 10)
+	request := benchmarkCheck(benchmarkEmail(0))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := manager.CheckAccess(request); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCheckAccessBatch(b *testing.B) {
+	const members = 100
+	manager := newBenchmarkManager(b, members)
+	request := &grpc_user_manager_go.CheckAccessBatchRequest{}
+	for i := 0; i < members; i++ {
+		request.Requests = append(request.Requests, benchmarkCheck(benchmarkEmail(i)))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := manager.CheckAccessBatch(request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
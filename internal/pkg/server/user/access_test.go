@@ -0,0 +1,135 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Access checks", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var memberRole *grpc_authx_go.Role
+
+	checkAccess := func(email string, primitives ...grpc_authx_go.AccessPrimitive) *grpc_user_manager_go.CheckAccessResponse {
+		response, err := manager.CheckAccess(&grpc_user_manager_go.CheckAccessRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Primitives:     primitives,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Decisions).To(gomega.HaveLen(len(primitives)))
+		return response
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		memberRole = response.Roles[1]
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         memberRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should allow the primitives of the role of the user", func() {
+		response := checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_ORG)
+		gomega.Expect(response.RoleId).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(response.RoleName).To(gomega.Equal(memberRole.Name))
+		gomega.Expect(response.Decisions[0].Primitive).To(gomega.Equal(grpc_authx_go.AccessPrimitive_RESOURCES))
+		gomega.Expect(response.Decisions[0].Allowed).To(gomega.BeTrue())
+		gomega.Expect(response.Decisions[0].Reason).To(gomega.ContainSubstring(memberRole.Name))
+		gomega.Expect(response.Decisions[1].Primitive).To(gomega.Equal(grpc_authx_go.AccessPrimitive_ORG))
+		gomega.Expect(response.Decisions[1].Allowed).To(gomega.BeFalse())
+		gomega.Expect(response.Decisions[1].Reason).To(gomega.ContainSubstring("does not grant ORG"))
+	})
+
+	ginkgo.It("should deny every primitive to unknown users", func() {
+		response := checkAccess("unknown@example.com", grpc_authx_go.AccessPrimitive_PROFILE, grpc_authx_go.AccessPrimitive_APPS)
+		for _, decision := range response.Decisions {
+			gomega.Expect(decision.Allowed).To(gomega.BeFalse())
+			gomega.Expect(decision.Reason).To(gomega.ContainSubstring("user not found"))
+		}
+	})
+
+	ginkgo.It("should follow the changes of the role", func() {
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_APPS).Decisions[0].Allowed).To(gomega.BeFalse())
+		_, err := manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           memberRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_APPS).Decisions[0].Allowed).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should follow the role assigned to the user", func() {
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_APPS).Decisions[0].Allowed).To(gomega.BeFalse())
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           "Deployer",
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			RoleId:         role.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		response := checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_APPS)
+		gomega.Expect(response.RoleId).To(gomega.Equal(role.RoleId))
+		gomega.Expect(response.Decisions[0].Allowed).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should evaluate batches in order", func() {
+		response, err := manager.CheckAccessBatch(&grpc_user_manager_go.CheckAccessBatchRequest{
+			Requests: []*grpc_user_manager_go.CheckAccessRequest{
+				{OrganizationId: organizationID, Email: ownerEmail, Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}},
+				{OrganizationId: organizationID, Email: memberEmail, Primitives: []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG}},
+			},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Responses).To(gomega.HaveLen(2))
+		gomega.Expect(response.Responses[0].Email).To(gomega.Equal(ownerEmail))
+		gomega.Expect(response.Responses[0].Decisions[0].Allowed).To(gomega.BeTrue())
+		gomega.Expect(response.Responses[1].Email).To(gomega.Equal(memberEmail))
+		gomega.Expect(response.Responses[1].Decisions[0].Allowed).To(gomega.BeFalse())
+	})
+})
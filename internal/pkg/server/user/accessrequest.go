@@ -0,0 +1,244 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// CreateAccessRequest files a request of a user to be assigned a role. A user cannot have two pending requests for
+// the same role.
+func (m *Manager) CreateAccessRequest(request *grpc_user_manager_go.CreateAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	info, err := m.accessClient.GetUserAuthxInfo(context.Background(), &grpc_user_go.UserId{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if info.RoleId == request.RoleId {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("the user already has the requested role").WithParams(request.Email, request.RoleId))
+	}
+	_, rErr := m.organizationRole(request.OrganizationId, request.RoleId)
+	if rErr != nil {
+		return nil, rErr
+	}
+	pending, pErr := m.pendingAccessRequests(request.OrganizationId)
+	if pErr != nil {
+		return nil, pErr
+	}
+	for _, existing := range pending {
+		if existing.Email == request.Email && existing.RoleId == request.RoleId {
+			return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("pending access request").WithParams(request.Email, request.RoleId, existing.AccessRequestId))
+		}
+	}
+	accessRequest, aErr := entities.NewAccessRequest(request, m.accessRequestTTL)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	aErr = m.accessRequests.Add(*accessRequest)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	m.audit(entities.NewAuditEntry(accessRequest.OrganizationId, entities.AuditAccessRequested, accessRequest.Email,
+		"access request %s for role %s: %s", accessRequest.AccessRequestId, accessRequest.RoleId, accessRequest.Justification))
+	return accessRequest.ToGRPC(), nil
+}
+
+// ListPendingAccessRequests lists the requests of an organization waiting for a resolution.
+func (m *Manager) ListPendingAccessRequests(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.AccessRequestList, error) {
+	pending, err := m.pendingAccessRequests(organizationID.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*grpc_user_manager_go.AccessRequest, 0, len(pending))
+	for _, request := range pending {
+		result = append(result, request.ToGRPC())
+	}
+	return &grpc_user_manager_go.AccessRequestList{AccessRequests: result}, nil
+}
+
+// ApproveAccessRequest assigns the requested role to the user. The approver must hold the approver primitive and
+// cannot approve its own requests. The request remains pending if the role cannot be assigned.
+func (m *Manager) ApproveAccessRequest(request *grpc_user_manager_go.ResolveAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	accessRequest, err := m.resolvableAccessRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	assignRequest := &grpc_user_manager_go.AssignRoleRequest{
+		OrganizationId: accessRequest.OrganizationId,
+		Email:          accessRequest.Email,
+		RoleId:         accessRequest.RoleId,
+	}
+	if accessRequest.GrantDuration > 0 {
+		assignRequest.ExpiresAt = now.Add(time.Duration(accessRequest.GrantDuration) * time.Second).Unix()
+	}
+	_, aErr := m.AssignRole(assignRequest)
+	if aErr != nil {
+		return nil, aErr
+	}
+	return m.resolveAccessRequest(accessRequest, grpc_user_manager_go.AccessRequestState_APPROVED, request.ApproverEmail,
+		request.Comment, entities.AuditAccessApproved)
+}
+
+// DenyAccessRequest rejects a pending request.
+func (m *Manager) DenyAccessRequest(request *grpc_user_manager_go.ResolveAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	accessRequest, err := m.resolvableAccessRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	return m.resolveAccessRequest(accessRequest, grpc_user_manager_go.AccessRequestState_DENIED, request.ApproverEmail,
+		request.Comment, entities.AuditAccessDenied)
+}
+
+// CancelAccessRequest withdraws a pending request. Only the user that filed the request can cancel it.
+func (m *Manager) CancelAccessRequest(request *grpc_user_manager_go.CancelAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	accessRequest, err := m.pendingAccessRequest(request.OrganizationId, request.AccessRequestId)
+	if err != nil {
+		return nil, err
+	}
+	if accessRequest.Email != request.Email {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("only the requester can cancel an access request").WithParams(request.AccessRequestId))
+	}
+	return m.resolveAccessRequest(accessRequest, grpc_user_manager_go.AccessRequestState_CANCELLED, request.Email,
+		"", entities.AuditAccessCancelled)
+}
+
+// ExpireAccessRequests moves the pending requests whose time to be resolved has passed to the expired state.
+func (m *Manager) ExpireAccessRequests() derrors.Error {
+	expired, err := m.accessRequests.ListExpired(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	for _, request := range expired {
+		eErr := m.expireAccessRequest(&request)
+		if eErr != nil {
+			return eErr
+		}
+	}
+	return nil
+}
+
+// pendingAccessRequests lists the pending requests of an organization expiring the ones whose time has passed.
+func (m *Manager) pendingAccessRequests(organizationID string) ([]entities.AccessRequest, error) {
+	requests, err := m.accessRequests.List(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	now := time.Now().Unix()
+	result := make([]entities.AccessRequest, 0)
+	for _, request := range requests {
+		if request.IsExpired(now) {
+			eErr := m.expireAccessRequest(&request)
+			if eErr != nil {
+				return nil, conversions.ToGRPCError(eErr)
+			}
+			continue
+		}
+		if request.IsPending() {
+			result = append(result, request)
+		}
+	}
+	return result, nil
+}
+
+// pendingAccessRequest retrieves a request failing if it is no longer pending.
+func (m *Manager) pendingAccessRequest(organizationID string, accessRequestID string) (*entities.AccessRequest, error) {
+	accessRequest, err := m.accessRequests.Get(organizationID, accessRequestID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	if accessRequest.IsExpired(time.Now().Unix()) {
+		err = m.expireAccessRequest(accessRequest)
+		if err != nil {
+			return nil, conversions.ToGRPCError(err)
+		}
+	}
+	if !accessRequest.IsPending() {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("access request is not pending").WithParams(accessRequestID, accessRequest.State.String()))
+	}
+	return accessRequest, nil
+}
+
+// resolvableAccessRequest retrieves a pending request checking that the approver can resolve it.
+func (m *Manager) resolvableAccessRequest(request *grpc_user_manager_go.ResolveAccessRequestRequest) (*entities.AccessRequest, error) {
+	accessRequest, err := m.pendingAccessRequest(request.OrganizationId, request.AccessRequestId)
+	if err != nil {
+		return nil, err
+	}
+	if accessRequest.Email == request.ApproverEmail {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("users cannot resolve their own access requests").WithParams(request.AccessRequestId))
+	}
+	role, rErr := m.accessClient.GetUserRole(context.Background(), &grpc_user_go.UserId{
+		OrganizationId: request.OrganizationId,
+		Email:          request.ApproverEmail,
+	})
+	if rErr != nil {
+		return nil, rErr
+	}
+	effective, rErr := m.effectiveUserRole(request.OrganizationId, request.ApproverEmail, role.RoleId)
+	if rErr != nil {
+		return nil, rErr
+	}
+	if !hasPrimitive(effective.Role, m.approverPrimitive) {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("approver does not hold the approver primitive").WithParams(request.ApproverEmail, m.approverPrimitive.String()))
+	}
+	return accessRequest, nil
+}
+
+// resolveAccessRequest moves a request to a final state.
+func (m *Manager) resolveAccessRequest(accessRequest *entities.AccessRequest, state grpc_user_manager_go.AccessRequestState,
+	resolvedBy string, comment string, action string) (*grpc_user_manager_go.AccessRequest, error) {
+	err := accessRequest.Transition(state, resolvedBy, comment, time.Now().Unix())
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	err = m.accessRequests.Update(*accessRequest)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	m.audit(entities.NewAuditEntry(accessRequest.OrganizationId, action, accessRequest.Email,
+		"access request %s for role %s %s by %s", accessRequest.AccessRequestId, accessRequest.RoleId,
+		accessRequest.State.String(), resolvedBy))
+	return accessRequest.ToGRPC(), nil
+}
+
+// expireAccessRequest moves a pending request to the expired state.
+func (m *Manager) expireAccessRequest(accessRequest *entities.AccessRequest) derrors.Error {
+	err := accessRequest.Transition(grpc_user_manager_go.AccessRequestState_EXPIRED, "", "", time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	err = m.accessRequests.Update(*accessRequest)
+	if err != nil {
+		return err
+	}
+	log.Debug().Str("organizationID", accessRequest.OrganizationId).Str("accessRequestID", accessRequest.AccessRequestId).
+		Msg("access request has expired")
+	m.audit(entities.NewAuditEntry(accessRequest.OrganizationId, entities.AuditAccessExpired, accessRequest.Email,
+		"access request %s for role %s expired", accessRequest.AccessRequestId, accessRequest.RoleId))
+	return nil
+}
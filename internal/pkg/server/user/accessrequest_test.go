@@ -0,0 +1,191 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Access requests", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+	const otherEmail = "other@example.com"
+
+	var manager Manager
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+	var organization *grpc_organization_go.OrganizationId
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addRole := func(name string, primitive grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			Description:    name,
+			Primitives:     []grpc_authx_go.AccessPrimitive{primitive},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	create := func(email string, roleID string, grantDuration int64) (*grpc_user_manager_go.AccessRequest, error) {
+		return manager.CreateAccessRequest(&grpc_user_manager_go.CreateAccessRequestRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			RoleId:         roleID,
+			Justification:  "on-call",
+			GrantDuration:  grantDuration,
+		})
+	}
+
+	resolve := func(request *grpc_user_manager_go.AccessRequest, approver string) *grpc_user_manager_go.ResolveAccessRequestRequest {
+		return &grpc_user_manager_go.ResolveAccessRequestRequest{
+			OrganizationId:  organizationID,
+			AccessRequestId: request.AccessRequestId,
+			ApproverEmail:   approver,
+			Comment:         "ok",
+		}
+	}
+
+	roleOf := func(email string) string {
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+		gomega.Expect(err).To(gomega.Succeed())
+		return user.RoleId
+	}
+
+	pending := func() []*grpc_user_manager_go.AccessRequest {
+		list, err := manager.ListPendingAccessRequests(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		return list.AccessRequests
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		ownerRole = addRole("owner", grpc_authx_go.AccessPrimitive_ORG)
+		memberRole = addRole("member", grpc_authx_go.AccessPrimitive_PROFILE)
+		addUser(ownerEmail, ownerRole.RoleId)
+		addUser(memberEmail, memberRole.RoleId)
+		addUser(otherEmail, memberRole.RoleId)
+		organization = &grpc_organization_go.OrganizationId{OrganizationId: organizationID}
+	})
+
+	ginkgo.It("should assign the role once approved", func() {
+		request, err := create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(request.State).To(gomega.Equal(grpc_user_manager_go.AccessRequestState_PENDING))
+		gomega.Expect(pending()).To(gomega.HaveLen(1))
+
+		approved, err := manager.ApproveAccessRequest(resolve(request, ownerEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(approved.State).To(gomega.Equal(grpc_user_manager_go.AccessRequestState_APPROVED))
+		gomega.Expect(approved.ResolvedBy).To(gomega.Equal(ownerEmail))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(ownerRole.RoleId))
+		gomega.Expect(pending()).To(gomega.BeEmpty())
+
+		_, err = manager.DenyAccessRequest(resolve(request, ownerEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should grant the role temporarily if requested", func() {
+		request, err := create(memberEmail, ownerRole.RoleId, 3600)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.ApproveAccessRequest(resolve(request, ownerEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		grants, err := manager.ListRoleGrants(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(grants.RoleGrants).To(gomega.HaveLen(1))
+		gomega.Expect(grants.RoleGrants[0].PreviousRoleId).To(gomega.Equal(memberRole.RoleId))
+	})
+
+	ginkgo.It("should reject duplicated and useless requests", func() {
+		_, err := create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		_, err = create(memberEmail, memberRole.RoleId, 0)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should only be resolved by approvers other than the requester", func() {
+		request, err := create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.ApproveAccessRequest(resolve(request, otherEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+		_, err = manager.ApproveAccessRequest(resolve(request, memberEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		denied, err := manager.DenyAccessRequest(resolve(request, ownerEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(denied.State).To(gomega.Equal(grpc_user_manager_go.AccessRequestState_DENIED))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(memberRole.RoleId))
+	})
+
+	ginkgo.It("should only be cancelled by the requester", func() {
+		request, err := create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		cancel := &grpc_user_manager_go.CancelAccessRequestRequest{
+			OrganizationId:  organizationID,
+			AccessRequestId: request.AccessRequestId,
+			Email:           otherEmail,
+		}
+		_, err = manager.CancelAccessRequest(cancel)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+		cancel.Email = memberEmail
+		cancelled, err := manager.CancelAccessRequest(cancel)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(cancelled.State).To(gomega.Equal(grpc_user_manager_go.AccessRequestState_CANCELLED))
+	})
+
+	ginkgo.It("should expire requests that are not resolved in time", func() {
+		manager.accessRequestTTL = -time.Minute
+		request, err := create(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.ExpireAccessRequests()).To(gomega.Succeed())
+		gomega.Expect(pending()).To(gomega.BeEmpty())
+		_, err = manager.ApproveAccessRequest(resolve(request, ownerEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		stored, gErr := manager.accessRequests.Get(organizationID, request.AccessRequestId)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(stored.State).To(gomega.Equal(grpc_user_manager_go.AccessRequestState_EXPIRED))
+	})
+})
@@ -0,0 +1,152 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// BootstrapOrganization creates the roles of a template and the first owner of an empty organization. The default
+// roles are used if no template is requested. Everything created is removed if a step fails, so the organization is
+// either ready to use or left as it was.
+func (m *Manager) BootstrapOrganization(request *grpc_user_manager_go.BootstrapOrganizationRequest) (*grpc_user_manager_go.BootstrapOrganizationResponse, error) {
+	template, tErr := m.bootstrapTemplate(request.TemplateName)
+	if tErr != nil {
+		return nil, conversions.ToGRPCError(tErr)
+	}
+	ownerRole := template.OwnerRole()
+	if ownerRole == nil {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("the template has no role with the ORG primitive").WithParams(template.Name))
+	}
+
+	// 1. The organization must be empty
+	organizationID := &grpc_organization_go.OrganizationId{OrganizationId: request.OrganizationId}
+	roles, err := m.accessClient.ListRoles(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles.Roles) > 0 {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("the organization already has roles").WithParams(request.OrganizationId))
+	}
+	users, err := m.usersClient.GetUsers(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if len(users.Users) > 0 {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("the organization already has users").WithParams(request.OrganizationId))
+	}
+
+	// 2. Create the roles
+	created := make([]*grpc_authx_go.Role, 0, len(template.Roles))
+	ownerRoleID := ""
+	for index := range template.Roles {
+		templateRole := &template.Roles[index]
+		primitives, pErr := templateRole.AccessPrimitives()
+		if pErr != nil {
+			m.rollbackBootstrap(request, created, false)
+			return nil, conversions.ToGRPCError(pErr)
+		}
+		role, err := m.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: request.OrganizationId,
+			Name:           templateRole.Name,
+			Description:    templateRole.Description,
+			Primitives:     primitives,
+		})
+		if err != nil {
+			m.rollbackBootstrap(request, created, false)
+			return nil, err
+		}
+		created = append(created, role)
+		if templateRole.Name == ownerRole.Name {
+			ownerRoleID = role.RoleId
+		}
+	}
+
+	// 3. Create the owner
+	owner, err := m.AddUser(&grpc_user_manager_go.AddUserRequest{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+		Password:       request.Password,
+		Name:           request.Name,
+		PhotoBase64:    request.PhotoBase64,
+		LastName:       request.LastName,
+		Location:       request.Location,
+		Phone:          request.Phone,
+		Title:          request.Title,
+		RoleId:         ownerRoleID,
+	})
+	if err != nil {
+		m.rollbackBootstrap(request, created, true)
+		return nil, err
+	}
+	m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditOrganizationBootstrapped, request.Email,
+		"organization bootstrapped with template %s", template.Name))
+	return &grpc_user_manager_go.BootstrapOrganizationResponse{
+		OrganizationId: request.OrganizationId,
+		Roles:          created,
+		Owner:          owner,
+	}, nil
+}
+
+// bootstrapTemplate retrieves the template used to bootstrap an organization.
+func (m *Manager) bootstrapTemplate(name string) (*entities.RoleTemplate, derrors.Error) {
+	if name == "" {
+		template := entities.DefaultRoleTemplate()
+		return &template, nil
+	}
+	return m.roleTemplates.Get(name)
+}
+
+// rollbackBootstrap removes what a failed bootstrap created. The removal is best effort, the failures are logged so
+// the organization can be fixed manually.
+func (m *Manager) rollbackBootstrap(request *grpc_user_manager_go.BootstrapOrganizationRequest, created []*grpc_authx_go.Role, withOwner bool) {
+	defer m.usersCache.Clear(request.OrganizationId)
+	defer m.emailsCache.Clear(request.OrganizationId)
+	if withOwner {
+		_, err := m.accessClient.DeleteCredentials(context.Background(), &grpc_authx_go.DeleteCredentialsRequest{
+			OrganizationId: request.OrganizationId,
+			Username:       request.Email,
+		})
+		if ignoreNotFound(err) != nil {
+			log.Error().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+				Msg("cannot remove the owner credentials while rolling back the bootstrap")
+		}
+		_, err = m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+			OrganizationId: request.OrganizationId,
+			Email:          request.Email,
+		})
+		if ignoreNotFound(err) != nil {
+			log.Error().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+				Msg("cannot remove the owner from system model while rolling back the bootstrap")
+		}
+	}
+	for _, role := range created {
+		err := m.removeRole(role.OrganizationId, role.RoleId)
+		if err != nil {
+			log.Error().Str("organizationID", role.OrganizationId).Str("roleID", role.RoleId).
+				Msg("cannot remove the role while rolling back the bootstrap")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/roletemplate"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Organization bootstrap", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var usersClient *utils.FakeUsersClient
+	var rolesClient *utils.FakeRolesClient
+	var templates *roletemplate.MockupRoleTemplateProvider
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	bootstrapRequest := func(templateName string) *grpc_user_manager_go.BootstrapOrganizationRequest {
+		return &grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			TemplateName:   templateName,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		}
+	}
+
+	expectEmpty := func() {
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles.Roles).To(gomega.BeEmpty())
+		smRoles, err := rolesClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(smRoles.Roles).To(gomega.BeEmpty())
+		users, err := usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(users.Users).To(gomega.BeEmpty())
+		_, exists := authxClient.Password(organizationID, ownerEmail)
+		gomega.Expect(exists).To(gomega.BeFalse())
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		usersClient = utils.NewFakeUsersClient()
+		rolesClient = utils.NewFakeRolesClient()
+		templates = roletemplate.NewMockupRoleTemplateProvider()
+		providers := NewMockupProviders()
+		providers.RoleTemplates = templates
+		manager = NewManager(authxClient, usersClient, rolesClient,
+			providers, testSettings())
+	})
+
+	ginkgo.It("should create the default roles and the owner", func() {
+		response, err := manager.BootstrapOrganization(bootstrapRequest(""))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Roles).To(gomega.HaveLen(len(entities.DefaultRoleTemplate().Roles)))
+		gomega.Expect(response.Owner.Email).To(gomega.Equal(ownerEmail))
+		gomega.Expect(response.Owner.RoleName).To(gomega.Equal("Owner"))
+
+		role, err := authxClient.GetUserRole(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: ownerEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(role.Primitives).To(gomega.ContainElement(grpc_authx_go.AccessPrimitive_ORG))
+
+		entries, err := manager.ListAuditEntries(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(entries.AuditEntries).To(gomega.HaveLen(1))
+		gomega.Expect(entries.AuditEntries[0].Action).To(gomega.Equal(entities.AuditOrganizationBootstrapped))
+	})
+
+	ginkgo.It("should use the requested template", func() {
+		gomega.Expect(templates.Add(entities.RoleTemplate{
+			Name: "small",
+			Roles: []entities.TemplateRole{
+				{Name: "Admin", Primitives: []string{"ORG", "PROFILE"}},
+			},
+		})).To(gomega.Succeed())
+		response, err := manager.BootstrapOrganization(bootstrapRequest("small"))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Roles).To(gomega.HaveLen(1))
+		gomega.Expect(response.Owner.RoleName).To(gomega.Equal("Admin"))
+	})
+
+	ginkgo.It("should reject a template without an owner role", func() {
+		gomega.Expect(templates.Add(entities.RoleTemplate{
+			Name:  "members",
+			Roles: []entities.TemplateRole{{Name: "Member", Primitives: []string{"PROFILE"}}},
+		})).To(gomega.Succeed())
+		_, err := manager.BootstrapOrganization(bootstrapRequest("members"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		expectEmpty()
+	})
+
+	ginkgo.It("should fail with an unknown template", func() {
+		_, err := manager.BootstrapOrganization(bootstrapRequest("unknown"))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should not bootstrap an organization twice", func() {
+		_, err := manager.BootstrapOrganization(bootstrapRequest(""))
+		gomega.Expect(err).To(gomega.Succeed())
+		request := bootstrapRequest("")
+		request.Email = "other@example.com"
+		_, err = manager.BootstrapOrganization(request)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should remove the roles if the owner cannot be created", func() {
+		authxClient.FailOn("AddBasicCredentials", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err := manager.BootstrapOrganization(bootstrapRequest(""))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		expectEmpty()
+
+		authxClient.FailOn("AddBasicCredentials", nil)
+		_, err = manager.BootstrapOrganization(bootstrapRequest(""))
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should leave the organization empty if a role cannot be created", func() {
+		authxClient.FailOn("AddRole", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err := manager.BootstrapOrganization(bootstrapRequest(""))
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		expectEmpty()
+	})
+})
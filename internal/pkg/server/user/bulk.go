@@ -0,0 +1,155 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+// DefaultBulkConcurrency with the number of users added in parallel when the request does not specify it.
+const DefaultBulkConcurrency = 4
+
+// BulkAddUsers adds a set of users to an organization. Each row is validated and added independently, so the
+// failure of a row does not prevent the rest from being added. Rows without password are invited if the request
+// enables it: they are created with a random password and are required to reset it.
+func (m *Manager) BulkAddUsers(request *grpc_user_manager_go.BulkAddUsersRequest) (*grpc_user_manager_go.BulkAddUsersResponse, error) {
+	roles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{
+		OrganizationId: request.OrganizationId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	roleIDs := make(map[string]bool, 0)
+	for _, role := range roles.Roles {
+		roleIDs[role.RoleId] = true
+	}
+
+	// 1. Validate all the rows
+	results := make([]*grpc_user_manager_go.BulkAddUserResult, len(request.Users))
+	toAdd := make([]int, 0)
+	emails := make(map[string]bool, 0)
+	for index, row := range request.Users {
+		result := &grpc_user_manager_go.BulkAddUserResult{
+			Row:   int32(index + 1),
+			Email: row.Email,
+		}
+		results[index] = result
+		vErr := m.validBulkRow(request, row, roleIDs, emails)
+		if vErr != nil {
+			result.Error = vErr.Error()
+			continue
+		}
+		emails[row.Email] = true
+		result.Invited = row.Password == ""
+		toAdd = append(toAdd, index)
+	}
+
+	// 2. Add the valid rows with bounded concurrency
+	if !request.DryRun {
+		concurrency := int(request.MaxConcurrency)
+		if concurrency == 0 {
+			concurrency = DefaultBulkConcurrency
+		}
+		semaphore := make(chan struct{}, concurrency)
+		var wg sync.WaitGroup
+		for _, index := range toAdd {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(result *grpc_user_manager_go.BulkAddUserResult, row *grpc_user_manager_go.AddUserRequest) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+				m.addBulkRow(request, result, row)
+			}(results[index], request.Users[index])
+		}
+		wg.Wait()
+	} else {
+		for _, index := range toAdd {
+			results[index].Success = true
+		}
+	}
+
+	response := &grpc_user_manager_go.BulkAddUsersResponse{
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Success {
+			response.Added++
+		} else {
+			response.Failed++
+		}
+	}
+	log.Debug().Str("organizationID", request.OrganizationId).Bool("dryRun", request.DryRun).
+		Int32("added", response.Added).Int32("failed", response.Failed).Msg("bulk add users")
+	return response, nil
+}
+
+// validBulkRow checks if a row of a bulk request can be added.
+func (m *Manager) validBulkRow(request *grpc_user_manager_go.BulkAddUsersRequest, row *grpc_user_manager_go.AddUserRequest,
+	roleIDs map[string]bool, emails map[string]bool) error {
+	if row.OrganizationId == "" {
+		row.OrganizationId = request.OrganizationId
+	}
+	if row.OrganizationId != request.OrganizationId {
+		return conversions.ToGRPCError(derrors.NewInvalidArgumentError("organization_id does not match the request").WithParams(row.OrganizationId))
+	}
+	nErr := m.normalizeEmails(&row.Email)
+	if nErr != nil {
+		return conversions.ToGRPCError(nErr)
+	}
+	var vErr derrors.Error
+	if row.Password == "" && request.Invite {
+		vErr = entities.ValidInviteUserRequest(row)
+	} else {
+		vErr = entities.ValidAddUserRequest(row)
+	}
+	if vErr != nil {
+		return conversions.ToGRPCError(vErr)
+	}
+	if !roleIDs[row.RoleId] {
+		return conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(row.RoleId))
+	}
+	if emails[row.Email] {
+		return conversions.ToGRPCError(derrors.NewAlreadyExistsError("email is duplicated in the request").WithParams(row.Email))
+	}
+	// the checks of AddUser and InviteUser, so a dry run reports the rows they would reject
+	return m.checkEmailAvailable(row.OrganizationId, row.Email, "")
+}
+
+// addBulkRow adds the user of a validated row, storing the outcome in the result.
+func (m *Manager) addBulkRow(request *grpc_user_manager_go.BulkAddUsersRequest, result *grpc_user_manager_go.BulkAddUserResult,
+	row *grpc_user_manager_go.AddUserRequest) {
+	var user *grpc_user_manager_go.User
+	var err error
+	if result.Invited {
+		user, err = m.InviteUser(row)
+	} else {
+		user, err = m.AddUser(row)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	result.Success = true
+	result.User = user
+}
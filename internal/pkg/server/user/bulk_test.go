@@ -0,0 +1,116 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Bulk add users", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+
+	var manager Manager
+	var roleID string
+
+	row := func(email string, password string) *grpc_user_manager_go.AddUserRequest {
+		return &grpc_user_manager_go.AddUserRequest{
+			Email:    email,
+			Password: password,
+			Name:     "Name",
+			LastName: "LastName",
+			Title:    "Title",
+			RoleId:   roleID,
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		roleID = response.Roles[1].RoleId
+		_, err = manager.SetEmailDomainPolicy(&grpc_user_manager_go.EmailDomainPolicy{
+			OrganizationId: organizationID,
+			AllowedDomains: []string{"example.com"},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should report in a dry run the rows that would be rejected when added", func() {
+		response, err := manager.BulkAddUsers(&grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: organizationID,
+			Users: []*grpc_user_manager_go.AddUserRequest{
+				row("new@example.com", ""),
+				row(ownerEmail, ""),
+				row("outsider@other.com", ""),
+				row("missing@example.com", ""),
+			},
+			DryRun: true,
+			Invite: false,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Added).To(gomega.Equal(int32(0)))
+		gomega.Expect(response.Failed).To(gomega.Equal(int32(4)))
+
+		response, err = manager.BulkAddUsers(&grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: organizationID,
+			Users: []*grpc_user_manager_go.AddUserRequest{
+				row("new@example.com", ""),
+				row(ownerEmail, ""),
+				row("outsider@other.com", ""),
+				row("member@example.com", "password"),
+			},
+			DryRun: true,
+			Invite: true,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Added).To(gomega.Equal(int32(2)))
+		gomega.Expect(response.Results[0].Success).To(gomega.BeTrue())
+		gomega.Expect(response.Results[0].Invited).To(gomega.BeTrue())
+		gomega.Expect(response.Results[1].Success).To(gomega.BeFalse())
+		gomega.Expect(response.Results[2].Success).To(gomega.BeFalse())
+		gomega.Expect(response.Results[3].Success).To(gomega.BeTrue())
+		gomega.Expect(response.Results[3].Invited).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should invite the rows without password", func() {
+		response, err := manager.BulkAddUsers(&grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: organizationID,
+			Users: []*grpc_user_manager_go.AddUserRequest{
+				row("invited@example.com", ""),
+				row("member@example.com", "password"),
+			},
+			Invite: true,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Added).To(gomega.Equal(int32(2)))
+		gomega.Expect(response.Results[0].User.PasswordResetRequired).To(gomega.BeTrue())
+		gomega.Expect(response.Results[1].User.PasswordResetRequired).To(gomega.BeFalse())
+	})
+})
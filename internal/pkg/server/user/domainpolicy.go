@@ -0,0 +1,115 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"sort"
+)
+
+// SetEmailDomainPolicy sets the email domains whose users can be members of an organization. The existing members are
+// not removed, and the report lists those who violate the new policy so they can be reviewed.
+func (m *Manager) SetEmailDomainPolicy(request *grpc_user_manager_go.EmailDomainPolicy) (*grpc_user_manager_go.DomainPolicyReport, error) {
+	policy, err := entities.NewEmailDomainPolicy(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	err = m.domainPolicies.Set(*policy)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	log.Debug().Str("organizationID", policy.OrganizationId).Strs("allowed", policy.AllowedDomains).
+		Strs("denied", policy.DeniedDomains).Msg("email domain policy has been set")
+	return m.domainPolicyReport(policy)
+}
+
+// GetEmailDomainPolicy retrieves the email domain policy of an organization, an empty one allowing every domain if it
+// is not set.
+func (m *Manager) GetEmailDomainPolicy(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.EmailDomainPolicy, error) {
+	policy, err := m.domainPolicies.Get(organizationID.OrganizationId)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return nil, conversions.ToGRPCError(err)
+		}
+		policy = &entities.EmailDomainPolicy{
+			OrganizationId: organizationID.OrganizationId,
+			AllowedDomains: make([]string, 0),
+			DeniedDomains:  make([]string, 0),
+		}
+	}
+	return policy.ToGRPC(), nil
+}
+
+// PreviewEmailDomainPolicy lists the members of an organization that would violate a policy without setting it.
+func (m *Manager) PreviewEmailDomainPolicy(request *grpc_user_manager_go.EmailDomainPolicy) (*grpc_user_manager_go.DomainPolicyReport, error) {
+	policy, err := entities.NewEmailDomainPolicy(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return m.domainPolicyReport(policy)
+}
+
+// domainPolicyReport lists the members of the organization of a policy whose email is not accepted by it.
+func (m *Manager) domainPolicyReport(policy *entities.EmailDomainPolicy) (*grpc_user_manager_go.DomainPolicyReport, error) {
+	users, err := m.usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: policy.OrganizationId})
+	if err != nil {
+		return nil, err
+	}
+	violations := make([]*grpc_user_manager_go.DomainPolicyViolation, 0)
+	for _, user := range users.Users {
+		email, nErr := m.emailNormalizer.Normalize(user.Email)
+		if nErr != nil {
+			email = user.Email
+		}
+		violation := policy.Violation(email)
+		if violation != "" {
+			violations = append(violations, &grpc_user_manager_go.DomainPolicyViolation{Email: user.Email, Reason: violation})
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Email < violations[j].Email
+	})
+	return &grpc_user_manager_go.DomainPolicyReport{
+		OrganizationId: policy.OrganizationId,
+		Violations:     violations,
+	}, nil
+}
+
+// checkEmailDomain checks that the email domain policy of an organization accepts an email.
+func (m *Manager) checkEmailDomain(organizationID string, email string) error {
+	policy, err := m.domainPolicies.Get(organizationID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil
+		}
+		return conversions.ToGRPCError(err)
+	}
+	normalized, err := m.emailNormalizer.Normalize(email)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	err = policy.Check(normalized)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	return nil
+}
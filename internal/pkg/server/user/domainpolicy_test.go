@@ -0,0 +1,159 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Email domain policy", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var handler *Handler
+	var developerRole *grpc_authx_go.Role
+	var organization = &grpc_organization_go.OrganizationId{OrganizationId: organizationID}
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addUser := func(email string) error {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         developerRole.RoleId,
+		})
+		return err
+	}
+
+	setPolicy := func(allowed []string, denied []string) *grpc_user_manager_go.DomainPolicyReport {
+		report, err := handler.SetEmailDomainPolicy(context.Background(), &grpc_user_manager_go.EmailDomainPolicy{
+			OrganizationId: organizationID,
+			AllowedDomains: allowed,
+			DeniedDomains:  denied,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return report
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		handler = NewHandler(manager)
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		developerRole = response.Roles[2]
+		gomega.Expect(addUser(memberEmail)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should store the policy with the domains in ASCII form", func() {
+		policy, err := handler.GetEmailDomainPolicy(context.Background(), organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.AllowedDomains).To(gomega.BeEmpty())
+
+		setPolicy([]string{"Example.COM", "*.Bücher.de"}, []string{"guests.example.com"})
+		policy, err = handler.GetEmailDomainPolicy(context.Background(), organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.AllowedDomains).To(gomega.Equal([]string{"example.com", "*.xn--bcher-kva.de"}))
+		gomega.Expect(policy.DeniedDomains).To(gomega.Equal([]string{"guests.example.com"}))
+
+		for _, invalid := range [][]string{{"*"}, {"*."}, {"com"}, {"sub.*.example.com"}, {"example.com", "EXAMPLE.com"}} {
+			_, err = handler.SetEmailDomainPolicy(context.Background(), &grpc_user_manager_go.EmailDomainPolicy{
+				OrganizationId: organizationID,
+				AllowedDomains: invalid,
+			})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		}
+	})
+
+	ginkgo.It("should only add users of the allowed domains", func() {
+		setPolicy([]string{"example.com", "*.example.org"}, []string{"contractors.example.org"})
+		gomega.Expect(addUser("dev@eng.example.org")).To(gomega.Succeed())
+		for _, email := range []string{"apex@example.org", "temp@contractors.example.org", "someone@other.com", "sub@eng.example.com"} {
+			gomega.Expect(errorType(addUser(email))).To(gomega.Equal(derrors.PermissionDenied))
+		}
+		_, err := manager.InviteUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          "invited@other.com",
+			Name:           "Name",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should only change the email to an allowed domain", func() {
+		token, err := manager.RequestEmailChange(&grpc_user_manager_go.EmailChangeRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			NewEmail:       "member@other.com",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		setPolicy(nil, []string{"other.com"})
+		_, err = manager.ChangeEmail(&grpc_user_manager_go.ChangeEmailRequest{
+			OrganizationId:    organizationID,
+			Email:             memberEmail,
+			ConfirmationToken: token.Token,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+		_, err = manager.RequestEmailChange(&grpc_user_manager_go.EmailChangeRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			NewEmail:       "member@other.com",
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should report the members that violate the policy", func() {
+		gomega.Expect(addUser("dev@example.org")).To(gomega.Succeed())
+		preview, err := handler.PreviewEmailDomainPolicy(context.Background(), &grpc_user_manager_go.EmailDomainPolicy{
+			OrganizationId: organizationID,
+			AllowedDomains: []string{"example.org"},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(preview.Violations).To(gomega.HaveLen(2))
+		gomega.Expect(preview.Violations[0].Email).To(gomega.Equal(memberEmail))
+		gomega.Expect(preview.Violations[1].Email).To(gomega.Equal(ownerEmail))
+		policy, err := handler.GetEmailDomainPolicy(context.Background(), organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.AllowedDomains).To(gomega.BeEmpty())
+
+		report := setPolicy(nil, []string{"*.org", "example.org"})
+		gomega.Expect(report.Violations).To(gomega.HaveLen(1))
+		gomega.Expect(report.Violations[0].Email).To(gomega.Equal("dev@example.org"))
+		gomega.Expect(report.Violations[0].Reason).To(gomega.ContainSubstring("example.org"))
+	})
+})
@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"sync"
+	"time"
+)
+
+// emailsCacheTTL with the time the emails of an organization are kept before reloading them from system model, so
+// users added or removed by other instances are eventually picked up.
+const emailsCacheTTL = time.Minute
+
+// organizationEmails with the emails of the users of an organization indexed by their canonical form.
+type organizationEmails struct {
+	emails   map[string][]string
+	loadedAt time.Time
+}
+
+// EmailsCache keeps the emails of the users of the organizations indexed by their canonical form, so the users can be
+// looked up regardless of the form their email was stored with without listing all the users of the organization.
+type EmailsCache struct {
+	// lock shared by all the copies of the cache
+	lock *sync.Mutex
+	// emails indexed by organization_id
+	organizations map[string]*organizationEmails
+
+	usersClient     grpc_user_go.UsersClient
+	emailNormalizer *entities.EmailNormalizer
+}
+
+func NewEmailsCache(usersClient grpc_user_go.UsersClient, emailNormalizer *entities.EmailNormalizer) EmailsCache {
+	return EmailsCache{lock: &sync.Mutex{},
+		organizations:   make(map[string]*organizationEmails, 0),
+		usersClient:     usersClient,
+		emailNormalizer: emailNormalizer}
+}
+
+// Clear removes the emails of an organization from the cache.
+func (ec *EmailsCache) Clear(organizationID string) derrors.Error {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+	delete(ec.organizations, organizationID)
+	return nil
+}
+
+// Find retrieves the emails of the users of an organization with the same canonical form as the given one.
+func (ec *EmailsCache) Find(organizationID string, email string) ([]string, derrors.Error) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	normalized, err := ec.emailNormalizer.Normalize(email)
+	if err != nil {
+		return nil, err
+	}
+	cached, exists := ec.organizations[organizationID]
+	if !exists || time.Since(cached.loadedAt) >= emailsCacheTTL {
+		err = ec.load(organizationID)
+		if err != nil {
+			return nil, err
+		}
+		cached = ec.organizations[organizationID]
+	}
+	return append([]string{}, cached.emails[normalized]...), nil
+}
+
+// Add registers the email of a user added to an organization. Organizations that are not loaded are ignored as the
+// email is retrieved with the rest of the users.
+func (ec *EmailsCache) Add(organizationID string, email string) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	cached, exists := ec.organizations[organizationID]
+	if !exists {
+		return
+	}
+	normalized, err := ec.emailNormalizer.Normalize(email)
+	if err != nil {
+		return
+	}
+	for _, existing := range cached.emails[normalized] {
+		if existing == email {
+			return
+		}
+	}
+	cached.emails[normalized] = append(cached.emails[normalized], email)
+}
+
+// Remove discards the email of a user removed from an organization.
+func (ec *EmailsCache) Remove(organizationID string, email string) {
+	ec.lock.Lock()
+	defer ec.lock.Unlock()
+
+	cached, exists := ec.organizations[organizationID]
+	if !exists {
+		return
+	}
+	normalized, err := ec.emailNormalizer.Normalize(email)
+	if err != nil {
+		return
+	}
+	remaining := make([]string, 0, len(cached.emails[normalized]))
+	for _, existing := range cached.emails[normalized] {
+		if existing != email {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == 0 {
+		delete(cached.emails, normalized)
+		return
+	}
+	cached.emails[normalized] = remaining
+}
+
+// load retrieves the users of an organization from system model and indexes their emails. Emails that cannot be
+// normalized are skipped.
+func (ec *EmailsCache) load(organizationID string) derrors.Error {
+	users, err := ec.usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{
+		OrganizationId: organizationID,
+	})
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	loaded := &organizationEmails{emails: make(map[string][]string, len(users.Users)), loadedAt: time.Now()}
+	for _, user := range users.Users {
+		normalized, nErr := ec.emailNormalizer.Normalize(user.Email)
+		if nErr != nil {
+			continue
+		}
+		loaded.emails[normalized] = append(loaded.emails[normalized], user.Email)
+	}
+	ec.organizations[organizationID] = loaded
+	return nil
+}
@@ -0,0 +1,300 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// EmailChangeTokenTTL with the time a confirmation token can be used to change the email of a user.
+const EmailChangeTokenTTL = 24 * time.Hour
+
+// EmailAliasGracePeriod with the time the former email of a user resolves to the new one after a change.
+const EmailAliasGracePeriod = 30 * 24 * time.Hour
+
+// RequestEmailChange issues the token that confirms the change of the email of a user. The token is meant to be sent
+// to the new address so the change proves the user owns it. Requesting a new token replaces the previous one.
+func (m *Manager) RequestEmailChange(request *grpc_user_manager_go.EmailChangeRequest) (*grpc_user_manager_go.EmailChangeToken, error) {
+	_, err := m.usersClient.GetUser(context.Background(), &grpc_user_go.UserId{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = m.checkEmailAvailable(request.OrganizationId, request.NewEmail, request.Email)
+	if err != nil {
+		return nil, err
+	}
+	change, token, cErr := entities.NewEmailChange(request, EmailChangeTokenTTL)
+	if cErr != nil {
+		return nil, conversions.ToGRPCError(cErr)
+	}
+	sErr := m.emailChanges.SetChange(*change)
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	return change.ToGRPC(token), nil
+}
+
+// ChangeEmail completes a confirmed email change. The user is renamed keeping its role, password and state, and the
+// former email becomes an alias of the new one during a grace period.
+func (m *Manager) ChangeEmail(request *grpc_user_manager_go.ChangeEmailRequest) (*grpc_user_manager_go.User, error) {
+	change, gErr := m.emailChanges.GetChange(request.OrganizationId, request.Email)
+	if gErr != nil {
+		if gErr.Type() == derrors.NotFound {
+			return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("invalid confirmation token").WithParams(request.OrganizationId, request.Email))
+		}
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	if !change.Confirms(request.ConfirmationToken, time.Now().Unix()) {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("invalid confirmation token").WithParams(request.OrganizationId, request.Email))
+	}
+	// the new email may have been taken since the change was requested
+	err := m.checkEmailAvailable(change.OrganizationId, change.NewEmail, change.Email)
+	if err != nil {
+		return nil, err
+	}
+	err = m.renameUser(change)
+	if err != nil {
+		return nil, err
+	}
+	_ = m.emailChanges.RemoveChange(change.OrganizationId, change.Email)
+	_ = m.emailChanges.RemoveAlias(change.OrganizationId, change.NewEmail)
+	alias := entities.NewEmailAlias(change, EmailAliasGracePeriod)
+	sErr := m.emailChanges.SetAlias(*alias)
+	if sErr != nil {
+		log.Warn().Str("organizationID", change.OrganizationId).Str("email", change.Email).Str("err", sErr.DebugReport()).
+			Msg("cannot keep the alias of the former email")
+	}
+	m.audit(entities.NewAuditEntry(change.OrganizationId, entities.AuditEmailChanged, change.NewEmail,
+		"email changed from %s, alias kept until %d", change.Email, alias.ExpiresAt))
+	return m.GetUser(&grpc_user_go.UserId{OrganizationId: change.OrganizationId, Email: change.NewEmail})
+}
+
+// renameUser moves a user to a new email. The system model record is recreated with the new email and the authx
+// credentials are renamed, so the role and the password are preserved, and the state kept by the user manager
+// follows the user. Failures migrating the system model or authx roll back the steps already done.
+func (m *Manager) renameUser(change *entities.EmailChange) error {
+	smUser, err := m.usersClient.GetUser(context.Background(), &grpc_user_go.UserId{
+		OrganizationId: change.OrganizationId,
+		Email:          change.Email,
+	})
+	if err != nil {
+		return err
+	}
+
+	_ = m.usersCache.Clear(change.OrganizationId)
+	defer m.usersCache.Clear(change.OrganizationId)
+
+	// 1. Rename the credentials on authx
+	_, err = m.accessClient.ChangeUsername(context.Background(), &grpc_authx_go.ChangeUsernameRequest{
+		OrganizationId: change.OrganizationId,
+		Username:       change.Email,
+		NewUsername:    change.NewEmail,
+	})
+	if err != nil {
+		return err
+	}
+	// 2. Add the user with the new email to system model
+	_, err = m.usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{
+		OrganizationId: smUser.OrganizationId,
+		Email:          change.NewEmail,
+		Name:           smUser.Name,
+		PhotoBase64:    smUser.PhotoBase64,
+		LastName:       smUser.LastName,
+		Title:          smUser.Title,
+		Phone:          smUser.Phone,
+		Location:       smUser.Location,
+	})
+	if err != nil {
+		m.rollbackEmailChange(change, false)
+		return err
+	}
+	// 3. Remove the user with the former email from system model
+	_, err = m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+		OrganizationId: change.OrganizationId,
+		Email:          change.Email,
+	})
+	if err != nil {
+		m.rollbackEmailChange(change, true)
+		return err
+	}
+	m.emailsCache.Remove(change.OrganizationId, change.Email)
+	m.emailsCache.Add(change.OrganizationId, change.NewEmail)
+	// 4. Move the state of the user manager
+	m.migrateUserState(change.OrganizationId, change.Email, change.NewEmail)
+	return nil
+}
+
+// ResolveEmailAlias retrieves the current email of a user from its former email during the grace period.
+func (m *Manager) ResolveEmailAlias(userID *grpc_user_go.UserId) (*grpc_user_manager_go.EmailAlias, error) {
+	alias, err := m.activeEmailAlias(userID.OrganizationId, userID.Email)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	if alias == nil {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("email alias").WithParams(userID.OrganizationId, userID.Email))
+	}
+	return alias.ToGRPC(), nil
+}
+
+// checkEmailAvailable checks that an email can be taken by a user: its domain is accepted by the organization and no
+// other user has it, either as its email or as the alias of its former email.
+func (m *Manager) checkEmailAvailable(organizationID string, email string, owner string) error {
+	err := m.checkEmailDomain(organizationID, email)
+	if err != nil {
+		return err
+	}
+	_, err = m.usersClient.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+	if err == nil {
+		return conversions.ToGRPCError(derrors.NewAlreadyExistsError("user").WithParams(organizationID, email))
+	}
+	if ignoreNotFound(err) != nil {
+		return err
+	}
+	err = m.checkEmailUnique(organizationID, email, owner)
+	if err != nil {
+		return err
+	}
+	return m.checkEmailAlias(organizationID, email, owner)
+}
+
+// checkEmailAlias checks that an email is not the active alias of a user other than the owner.
+func (m *Manager) checkEmailAlias(organizationID string, email string, owner string) error {
+	alias, err := m.activeEmailAlias(organizationID, email)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	if alias != nil && alias.NewEmail != owner {
+		return conversions.ToGRPCError(derrors.NewAlreadyExistsError("the email is the alias of another user").WithParams(organizationID, email))
+	}
+	return nil
+}
+
+// activeEmailAlias retrieves the alias of a former email, or nil if there is none. Expired aliases are removed.
+func (m *Manager) activeEmailAlias(organizationID string, email string) (*entities.EmailAlias, derrors.Error) {
+	alias, err := m.emailChanges.GetAlias(organizationID, email)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !alias.IsActive(time.Now().Unix()) {
+		_ = m.emailChanges.RemoveAlias(organizationID, email)
+		return nil, nil
+	}
+	return alias, nil
+}
+
+// rollbackEmailChange restores the former email on authx and, if it was already added, removes the new system model
+// record. The rollback is best effort, the failures are logged so the user can be fixed manually.
+func (m *Manager) rollbackEmailChange(change *entities.EmailChange, withUser bool) {
+	if withUser {
+		_, err := m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+			OrganizationId: change.OrganizationId,
+			Email:          change.NewEmail,
+		})
+		if err != nil {
+			log.Error().Str("organizationID", change.OrganizationId).Str("email", change.NewEmail).
+				Str("err", conversions.ToDerror(err).DebugReport()).Msg("cannot remove the new email from system model while rolling back the email change")
+		}
+	}
+	_, err := m.accessClient.ChangeUsername(context.Background(), &grpc_authx_go.ChangeUsernameRequest{
+		OrganizationId: change.OrganizationId,
+		Username:       change.NewEmail,
+		NewUsername:    change.Email,
+	})
+	if err != nil {
+		log.Error().Str("organizationID", change.OrganizationId).Str("email", change.Email).
+			Str("err", conversions.ToDerror(err).DebugReport()).Msg("cannot restore the credentials while rolling back the email change")
+	}
+}
+
+// migrateUserState moves the state the user manager keeps for a user to its new email: password resets, MFA
+// enrollment, temporary grants, additional roles, groups, access requests and identity. Failures are logged as the
+// user has already been moved.
+func (m *Manager) migrateUserState(organizationID string, email string, newEmail string) {
+	logFailure := func(what string, err derrors.Error) {
+		if err != nil {
+			log.Warn().Str("organizationID", organizationID).Str("email", email).Str("newEmail", newEmail).
+				Str("err", err.DebugReport()).Msgf("cannot move the %s of the user", what)
+		}
+	}
+	resetRequired, err := m.passwordResets.Exists(organizationID, email)
+	if err == nil && resetRequired {
+		err = m.passwordResets.Add(organizationID, newEmail)
+		if err == nil {
+			err = m.passwordResets.Remove(organizationID, email)
+		}
+	}
+	logFailure("password reset", err)
+
+	if enrollment, gErr := m.mfa.Get(organizationID, email); gErr == nil {
+		enrollment.Email = newEmail
+		err = m.mfa.Set(*enrollment)
+		if err == nil {
+			err = m.mfa.Remove(organizationID, email)
+		}
+		logFailure("MFA enrollment", err)
+	}
+	if grant, gErr := m.roleGrants.Get(organizationID, email); gErr == nil {
+		grant.Email = newEmail
+		err = m.roleGrants.Set(*grant)
+		if err == nil {
+			err = m.roleGrants.Remove(organizationID, email)
+		}
+		logFailure("temporary role grant", err)
+	}
+	if bindings, gErr := m.roleBindings.Get(organizationID, email); gErr == nil {
+		bindings.Email = newEmail
+		err = m.roleBindings.Set(*bindings)
+		if err == nil {
+			err = m.roleBindings.Remove(organizationID, email)
+		}
+		logFailure("additional roles", err)
+	}
+
+	groups, err := m.userGroups(organizationID, email)
+	logFailure("groups", err)
+	for index := range groups {
+		group := &groups[index]
+		group.RemoveMembers([]string{email})
+		group.AddMembers([]string{newEmail})
+		logFailure("groups", m.groups.UpdateGroup(*group))
+	}
+	requests, err := m.accessRequests.List(organizationID)
+	logFailure("access requests", err)
+	for _, request := range requests {
+		if request.Email == email {
+			request.Email = newEmail
+			logFailure("access requests", m.accessRequests.Update(request))
+		}
+	}
+
+	m.unlinkIdentity(organizationID, email)
+	m.linkIdentity(organizationID, newEmail)
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Email change", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+	const newEmail = "member@example.org"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var usersClient *utils.FakeUsersClient
+	var operatorRole *grpc_authx_go.Role
+	var developerRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	requestChange := func(email string, newEmail string) (*grpc_user_manager_go.EmailChangeToken, error) {
+		return manager.RequestEmailChange(&grpc_user_manager_go.EmailChangeRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			NewEmail:       newEmail,
+		})
+	}
+
+	changeEmail := func(token string) (*grpc_user_manager_go.User, error) {
+		return manager.ChangeEmail(&grpc_user_manager_go.ChangeEmailRequest{
+			OrganizationId:    organizationID,
+			Email:             memberEmail,
+			ConfirmationToken: token,
+		})
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		usersClient = utils.NewFakeUsersClient()
+		manager = NewManager(authxClient, usersClient, utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		operatorRole = response.Roles[1]
+		developerRole = response.Roles[2]
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "member-password",
+			Name:           "Name",
+			Title:          "Developer",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should move the user to the new email keeping its role and password", func() {
+		_, err := manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			RoleId:         operatorRole.RoleId,
+			Operation:      grpc_user_manager_go.AssignRoleOperation_ADD,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		token, err := requestChange(memberEmail, newEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(token.Token).ShouldNot(gomega.BeEmpty())
+
+		user, err := changeEmail(token.Token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.Email).To(gomega.Equal(newEmail))
+		gomega.Expect(user.Title).To(gomega.Equal("Developer"))
+		gomega.Expect(user.RoleId).To(gomega.Equal(developerRole.RoleId))
+		gomega.Expect(user.Roles).To(gomega.HaveLen(2))
+		password, exists := authxClient.Password(organizationID, newEmail)
+		gomega.Expect(exists).To(gomega.BeTrue())
+		gomega.Expect(password).To(gomega.Equal("member-password"))
+		_, err = manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+
+		alias, err := manager.ResolveEmailAlias(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(alias.NewEmail).To(gomega.Equal(newEmail))
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		// the token cannot be reused
+		_, err = changeEmail(token.Token)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should require a valid token and an available email", func() {
+		_, err := requestChange(memberEmail, ownerEmail)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		_, err = requestChange("unknown@example.com", newEmail)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+
+		_, err = requestChange(memberEmail, newEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = changeEmail("invalid")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should roll back a partial failure", func() {
+		token, err := requestChange(memberEmail, newEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+		usersClient.FailOn("RemoveUser/"+memberEmail, conversions.ToGRPCError(derrors.NewUnavailableError("system model")))
+		_, err = changeEmail(token.Token)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.RoleId).To(gomega.Equal(developerRole.RoleId))
+		_, err = manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: newEmail})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+		_, exists := authxClient.Password(organizationID, newEmail)
+		gomega.Expect(exists).To(gomega.BeFalse())
+
+		// the change can be retried with the same token
+		usersClient.FailOn("RemoveUser/"+memberEmail, nil)
+		user, err = changeEmail(token.Token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.Email).To(gomega.Equal(newEmail))
+	})
+})
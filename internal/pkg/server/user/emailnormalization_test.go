@@ -0,0 +1,225 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Email normalization", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+
+	var manager Manager
+	var handler *Handler
+	var usersClient *utils.FakeUsersClient
+	var developerRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addUser := func(email string) (*grpc_user_manager_go.User, error) {
+		return handler.AddUser(context.Background(), &grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+			RoleId:         developerRole.RoleId,
+		})
+	}
+
+	ginkgo.BeforeEach(func() {
+		normalizer, err := entities.NewEmailNormalizer([]entities.EmailRule{{
+			Domains:             []string{"gmail.com", "googlemail.com"},
+			CanonicalDomain:     "gmail.com",
+			IgnoreDots:          true,
+			SubaddressSeparator: "+",
+		}})
+		gomega.Expect(err).To(gomega.Succeed())
+		usersClient = utils.NewFakeUsersClient()
+		settings := testSettings()
+		settings.EmailNormalizer = normalizer
+		manager = NewManager(utils.NewFakeAuthxClient(), usersClient, utils.NewFakeRolesClient(),
+			NewMockupProviders(), settings)
+		handler = NewHandler(manager)
+		response, bErr := handler.BootstrapOrganization(context.Background(), &grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          "Owner@Example.COM",
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(bErr).To(gomega.Succeed())
+		developerRole = response.Roles[2]
+	})
+
+	ginkgo.It("should identify the users case-insensitively", func() {
+		user, err := handler.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: " OWNER@example.com "})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.Email).To(gomega.Equal(ownerEmail))
+
+		added, err := addUser("Bob@Example.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).To(gomega.Equal("bob@example.com"))
+		_, err = addUser("bob@EXAMPLE.com")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+	})
+
+	ginkgo.It("should keep the index of the emails up to date", func() {
+		_, err := addUser("bob@example.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = handler.RemoveUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: "bob@example.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = addUser("Bob@Example.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = addUser("BOB@example.com")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+	})
+
+	ginkgo.It("should store internationalized domains in punycode", func() {
+		added, err := addUser("Anna@Bücher.de")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).To(gomega.Equal("anna@xn--bcher-kva.de"))
+		_, err = handler.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: "anna@BÜCHER.de"})
+		gomega.Expect(err).To(gomega.Succeed())
+		added, err = addUser("user@例え.テスト")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).To(gomega.Equal("user@xn--r8jz45g.xn--zckzah"))
+	})
+
+	ginkgo.It("should apply the rules of the provider", func() {
+		added, err := addUser("John.Doe+news@GoogleMail.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).To(gomega.Equal("johndoe@gmail.com"))
+		_, err = addUser("j.o.h.n.doe@gmail.com")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		added, err = addUser("John.Doe+news@example.com")
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(added.Email).To(gomega.Equal("john.doe+news@example.com"))
+	})
+
+	ginkgo.It("should reject the emails that cannot be normalized", func() {
+		_, err := addUser("bob@")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		_, err = addUser("+@gmail.com")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+	})
+
+	ginkgo.It("should find the collisions of the users added before normalizing the emails", func() {
+		for _, email := range []string{"Legacy@Example.com", "legacy@example.COM", "Other@Example.com"} {
+			_, err := usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{OrganizationId: organizationID, Email: email})
+			gomega.Expect(err).To(gomega.Succeed())
+		}
+		// the legacy users were stored before the emails of the organization were indexed
+		_ = manager.emailsCache.Clear(organizationID)
+		_, err := addUser("other@example.com")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+
+		report, err := handler.FindEmailCollisions(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Collisions).To(gomega.HaveLen(1))
+		gomega.Expect(report.Collisions[0].NormalizedEmail).To(gomega.Equal("legacy@example.com"))
+		gomega.Expect(report.Collisions[0].Emails).To(gomega.Equal([]string{"Legacy@Example.com", "legacy@example.COM"}))
+		gomega.Expect(report.UnnormalizedEmails).To(gomega.Equal([]string{"Legacy@Example.com", "Other@Example.com", "legacy@example.COM"}))
+		gomega.Expect(report.InvalidEmails).To(gomega.BeEmpty())
+	})
+
+	ginkgo.Context("with users added before normalizing the emails", func() {
+
+		const legacyEmail = "Bob@Example.com"
+
+		ginkgo.BeforeEach(func() {
+			_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+				OrganizationId: organizationID,
+				Email:          legacyEmail,
+				Password:       "password",
+				Name:           "Name",
+				RoleId:         developerRole.RoleId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should look up the users by their stored email", func() {
+			user, err := handler.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: "bob@example.com"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(user.Email).To(gomega.Equal(legacyEmail))
+
+			token, err := handler.RequestEmailChange(context.Background(), &grpc_user_manager_go.EmailChangeRequest{
+				OrganizationId: organizationID,
+				Email:          "BOB@example.com",
+				NewEmail:       "robert@example.com",
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			changed, err := handler.ChangeEmail(context.Background(), &grpc_user_manager_go.ChangeEmailRequest{
+				OrganizationId:    organizationID,
+				Email:             "bob@example.com",
+				ConfirmationToken: token.Token,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(changed.Email).To(gomega.Equal("robert@example.com"))
+		})
+
+		ginkgo.It("should migrate the users to the canonical form of their email", func() {
+			_, err := usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{OrganizationId: organizationID, Email: "Ann@Example.com"})
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{OrganizationId: organizationID, Email: "ann@example.COM"})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			migrate := func(dryRun bool) *grpc_user_manager_go.EmailMigrationReport {
+				report, err := handler.MigrateEmails(context.Background(), &grpc_user_manager_go.MigrateEmailsRequest{
+					OrganizationId: organizationID,
+					DryRun:         dryRun,
+				})
+				gomega.Expect(err).To(gomega.Succeed())
+				return report
+			}
+			report := migrate(true)
+			gomega.Expect(report.Migrated).To(gomega.HaveLen(1))
+			gomega.Expect(report.Migrated[0].NewEmail).To(gomega.Equal("bob@example.com"))
+			gomega.Expect(report.Failures).To(gomega.HaveLen(2))
+			_, err = usersClient.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: legacyEmail})
+			gomega.Expect(err).To(gomega.Succeed())
+
+			report = migrate(false)
+			gomega.Expect(report.Migrated).To(gomega.HaveLen(1))
+			_, err = usersClient.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: "bob@example.com"})
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = usersClient.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: legacyEmail})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			user, err := handler.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: legacyEmail})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(user.Email).To(gomega.Equal("bob@example.com"))
+			gomega.Expect(user.RoleId).To(gomega.Equal(developerRole.RoleId))
+
+			gomega.Expect(migrate(false).Migrated).To(gomega.BeEmpty())
+		})
+	})
+})
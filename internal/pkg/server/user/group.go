@@ -0,0 +1,366 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// AddGroup creates an empty group in an organization, optionally bound to a role.
+func (m *Manager) AddGroup(request *grpc_user_manager_go.AddGroupRequest) (*grpc_user_manager_go.Group, error) {
+	err := m.checkGroupName(request.OrganizationId, "", request.Name)
+	if err != nil {
+		return nil, err
+	}
+	roleName, err := m.groupRoleName(request.OrganizationId, request.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	group, gErr := entities.NewGroup(request)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	gErr = m.groups.AddGroup(*group)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	m.audit(entities.NewAuditEntry(group.OrganizationId, entities.AuditGroupCreated, "",
+		"group %s (%s) created with role %s", group.Name, group.GroupId, group.RoleId))
+	return group.ToGRPC(roleName), nil
+}
+
+// GetGroup retrieves a group of an organization.
+func (m *Manager) GetGroup(groupID *grpc_user_manager_go.GroupId) (*grpc_user_manager_go.Group, error) {
+	group, err := m.groups.GetGroup(groupID.OrganizationId, groupID.GroupId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	roleNames, rErr := m.roleNames(groupID.OrganizationId)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return group.ToGRPC(roleNames[group.RoleId]), nil
+}
+
+// ListGroups lists the groups of an organization.
+func (m *Manager) ListGroups(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.GroupList, error) {
+	groups, err := m.groups.ListGroups(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	roleNames, rErr := m.roleNames(organizationID.OrganizationId)
+	if rErr != nil {
+		return nil, rErr
+	}
+	result := make([]*grpc_user_manager_go.Group, 0, len(groups))
+	for index := range groups {
+		result = append(result, groups[index].ToGRPC(roleNames[groups[index].RoleId]))
+	}
+	return &grpc_user_manager_go.GroupList{Groups: result}, nil
+}
+
+// UpdateGroup updates the name, description and role binding of a group. Unbinding an owner role is rejected if the
+// organization would be left below its minimum number of owners.
+func (m *Manager) UpdateGroup(request *grpc_user_manager_go.UpdateGroupRequest) (*grpc_user_manager_go.Group, error) {
+	group, gErr := m.groups.GetGroup(request.OrganizationId, request.GroupId)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	if request.UpdateName {
+		err := m.checkGroupName(request.OrganizationId, request.GroupId, request.Name)
+		if err != nil {
+			return nil, err
+		}
+		group.Name = request.Name
+	}
+	if request.UpdateDescription {
+		group.Description = request.Description
+	}
+	if request.UpdateRole && request.RoleId != group.RoleId {
+		_, err := m.groupRoleName(request.OrganizationId, request.RoleId)
+		if err != nil {
+			return nil, err
+		}
+		grantsOwner, err := m.roleGrantsOwner(request.OrganizationId, request.RoleId)
+		if err != nil {
+			return nil, err
+		}
+		if !grantsOwner {
+			err = m.checkGroupOwnership(request.OrganizationId, request.GroupId, "can not change the role of the group")
+			if err != nil {
+				return nil, err
+			}
+		}
+		group.RoleId = request.RoleId
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(request.OrganizationId)
+	defer m.usersCache.Clear(request.OrganizationId)
+
+	gErr = m.groups.UpdateGroup(*group)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	m.audit(entities.NewAuditEntry(group.OrganizationId, entities.AuditGroupUpdated, "",
+		"group %s (%s) updated with role %s", group.Name, group.GroupId, group.RoleId))
+	return m.GetGroup(&grpc_user_manager_go.GroupId{OrganizationId: group.OrganizationId, GroupId: group.GroupId})
+}
+
+// RemoveGroup removes a group. Removing a group bound to an owner role is rejected if the organization would be left
+// below its minimum number of owners.
+func (m *Manager) RemoveGroup(groupID *grpc_user_manager_go.GroupId) (*grpc_common_go.Success, error) {
+	group, gErr := m.groups.GetGroup(groupID.OrganizationId, groupID.GroupId)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	err := m.checkGroupOwnership(groupID.OrganizationId, groupID.GroupId, "can not remove group")
+	if err != nil {
+		return nil, err
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(groupID.OrganizationId)
+	defer m.usersCache.Clear(groupID.OrganizationId)
+
+	gErr = m.groups.RemoveGroup(groupID.OrganizationId, groupID.GroupId)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	m.audit(entities.NewAuditEntry(group.OrganizationId, entities.AuditGroupRemoved, "",
+		"group %s (%s) removed", group.Name, group.GroupId))
+	return &grpc_common_go.Success{}, nil
+}
+
+// AddGroupMembers adds users of the organization to a group. Users that are members already are ignored.
+func (m *Manager) AddGroupMembers(request *grpc_user_manager_go.GroupMembersRequest) (*grpc_user_manager_go.Group, error) {
+	group, gErr := m.groups.GetGroup(request.OrganizationId, request.GroupId)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	for _, email := range request.Emails {
+		_, err := m.usersClient.GetUser(context.Background(), &grpc_user_go.UserId{
+			OrganizationId: request.OrganizationId,
+			Email:          email,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(request.OrganizationId)
+	defer m.usersCache.Clear(request.OrganizationId)
+
+	added := group.AddMembers(request.Emails)
+	if len(added) > 0 {
+		gErr = m.groups.UpdateGroup(*group)
+		if gErr != nil {
+			return nil, conversions.ToGRPCError(gErr)
+		}
+		for _, email := range added {
+			m.audit(entities.NewAuditEntry(group.OrganizationId, entities.AuditGroupMembersAdded, email,
+				"added to group %s (%s)", group.Name, group.GroupId))
+		}
+	}
+	return m.GetGroup(&grpc_user_manager_go.GroupId{OrganizationId: group.OrganizationId, GroupId: group.GroupId})
+}
+
+// RemoveGroupMembers removes users from a group. Removing members that are owners through the group is rejected if
+// the organization would be left below its minimum number of owners.
+func (m *Manager) RemoveGroupMembers(request *grpc_user_manager_go.GroupMembersRequest) (*grpc_user_manager_go.Group, error) {
+	group, gErr := m.groups.GetGroup(request.OrganizationId, request.GroupId)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	for _, email := range request.Emails {
+		if !group.HasMember(email) {
+			return nil, conversions.ToGRPCError(derrors.NewNotFoundError("group member").WithParams(request.GroupId, email))
+		}
+	}
+	err := m.checkGroupOwnership(request.OrganizationId, request.GroupId, "can not remove group members", request.Emails...)
+	if err != nil {
+		return nil, err
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(request.OrganizationId)
+	defer m.usersCache.Clear(request.OrganizationId)
+
+	removed := group.RemoveMembers(request.Emails)
+	gErr = m.groups.UpdateGroup(*group)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	for _, email := range removed {
+		m.audit(entities.NewAuditEntry(group.OrganizationId, entities.AuditGroupMembersRemoved, email,
+			"removed from group %s (%s)", group.Name, group.GroupId))
+	}
+	return m.GetGroup(&grpc_user_manager_go.GroupId{OrganizationId: group.OrganizationId, GroupId: group.GroupId})
+}
+
+// userGroups retrieves the groups a user belongs to, sorted by name.
+func (m *Manager) userGroups(organizationID string, email string) ([]entities.Group, derrors.Error) {
+	groups, err := m.groups.ListGroups(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entities.Group, 0)
+	for _, group := range groups {
+		if group.HasMember(email) {
+			result = append(result, group)
+		}
+	}
+	return result, nil
+}
+
+// effectiveUserRole resolves the role a user acts with from the roles assigned to the user and the roles bound to its
+// groups, following the precedence of entities.EffectiveRole. Roles are returned with their effective primitives.
+func (m *Manager) effectiveUserRole(organizationID string, email string, roleID string) (*entities.RoleCandidate, error) {
+	roles, err := m.assignedRoles(organizationID, email, roleID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []entities.RoleCandidate{*entities.NewRoleCandidate(roles, "", "")}
+	groups, gErr := m.userGroups(organizationID, email)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	for _, group := range groups {
+		if group.RoleId == "" {
+			continue
+		}
+		groupRole, rErr := m.rolesCache.GetRole(organizationID, group.RoleId)
+		if rErr != nil {
+			if rErr.Type() == derrors.NotFound {
+				continue
+			}
+			return nil, conversions.ToGRPCError(rErr)
+		}
+		candidates = append(candidates, *entities.NewRoleCandidate([]*grpc_authx_go.Role{groupRole}, group.GroupId, group.Name))
+	}
+	return entities.EffectiveRole(candidates), nil
+}
+
+// removeFromGroups removes a user from all the groups of the organization.
+func (m *Manager) removeFromGroups(organizationID string, email string) {
+	groups, err := m.userGroups(organizationID, email)
+	if err != nil {
+		log.Warn().Str("organizationID", organizationID).Str("email", email).Str("err", err.DebugReport()).
+			Msg("cannot list the groups of a removed user")
+		return
+	}
+	for index := range groups {
+		group := &groups[index]
+		group.RemoveMembers([]string{email})
+		uErr := m.groups.UpdateGroup(*group)
+		if uErr != nil {
+			log.Warn().Str("organizationID", organizationID).Str("email", email).Str("groupID", group.GroupId).
+				Msg("cannot remove a removed user from a group")
+			continue
+		}
+		m.audit(entities.NewAuditEntry(organizationID, entities.AuditGroupMembersRemoved, email,
+			"removed from group %s (%s) with the user", group.Name, group.GroupId))
+	}
+}
+
+// checkGroupOwnership checks that the organization keeps its minimum number of owners if the group stops making some
+// of its members, or all of them if no email is given, owners.
+func (m *Manager) checkGroupOwnership(organizationID string, groupID string, operation string, emails ...string) error {
+	canRevoke, err := m.usersCache.CanRevokeGroupOwnership(organizationID, groupID, emails...)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	if !canRevoke {
+		return conversions.ToGRPCError(derrors.NewFailedPreconditionError(m.ownerQuorumMessage(organizationID, operation)).WithParams(groupID))
+	}
+	return nil
+}
+
+// checkGroupName checks that no other group of the organization has the same name.
+func (m *Manager) checkGroupName(organizationID string, groupID string, name string) error {
+	groups, err := m.groups.ListGroups(organizationID)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	for _, group := range groups {
+		if group.GroupId != groupID && strings.EqualFold(group.Name, name) {
+			return conversions.ToGRPCError(derrors.NewAlreadyExistsError("group").WithParams(organizationID, name))
+		}
+	}
+	return nil
+}
+
+// groupRoleName checks that a role can be bound to a group and returns its name. Groups can have no role.
+func (m *Manager) groupRoleName(organizationID string, roleID string) (string, error) {
+	if roleID == "" {
+		return "", nil
+	}
+	role, err := m.organizationRole(organizationID, roleID)
+	if err != nil {
+		return "", err
+	}
+	return role.Name, nil
+}
+
+// roleGrantsOwner checks if a role grants the ORG primitive, directly or through its parents.
+func (m *Manager) roleGrantsOwner(organizationID string, roleID string) (bool, error) {
+	if roleID == "" {
+		return false, nil
+	}
+	role, err := m.effectiveRole(organizationID, roleID)
+	if err != nil {
+		return false, err
+	}
+	return hasPrimitive(role, grpc_authx_go.AccessPrimitive_ORG), nil
+}
+
+// roleNames retrieves the names of the roles of an organization indexed by role_id.
+func (m *Manager) roleNames(organizationID string) (map[string]string, error) {
+	roles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(roles.Roles))
+	for _, role := range roles.Roles {
+		result[role.RoleId] = role.Name
+	}
+	return result, nil
+}
+
+// grantReason describes where the role granting a primitive comes from.
+func grantReason(candidate *entities.RoleCandidate, primitive grpc_authx_go.AccessPrimitive) string {
+	role := candidate.GrantedBy(primitive)
+	if role == nil {
+		role = candidate.Role
+	}
+	if candidate.GroupId == "" {
+		return fmt.Sprintf("granted by role %s", role.Name)
+	}
+	return fmt.Sprintf("granted by role %s of group %s", role.Name, candidate.GroupName)
+}
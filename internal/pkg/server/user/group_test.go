@@ -0,0 +1,210 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Groups", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var ownerRole *grpc_authx_go.Role
+	var operatorRole *grpc_authx_go.Role
+	var developerRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addGroup := func(name string, roleID string, members ...string) *grpc_user_manager_go.Group {
+		added, err := manager.AddGroup(&grpc_user_manager_go.AddGroupRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		if len(members) == 0 {
+			return added
+		}
+		updated, err := manager.AddGroupMembers(&grpc_user_manager_go.GroupMembersRequest{
+			OrganizationId: organizationID,
+			GroupId:        added.GroupId,
+			Emails:         members,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return updated
+	}
+
+	getUser := func(email string) *grpc_user_manager_go.User {
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+		gomega.Expect(err).To(gomega.Succeed())
+		return user
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		ownerRole = response.Roles[0]
+		operatorRole = response.Roles[1]
+		developerRole = response.Roles[2]
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should create, update, list and remove groups", func() {
+		added := addGroup("Platform", operatorRole.RoleId)
+		gomega.Expect(added.GroupId).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(added.RoleName).To(gomega.Equal(operatorRole.Name))
+		_, err := manager.AddGroup(&grpc_user_manager_go.AddGroupRequest{OrganizationId: organizationID, Name: "platform"})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+
+		updated, err := manager.UpdateGroup(&grpc_user_manager_go.UpdateGroupRequest{
+			OrganizationId:    organizationID,
+			GroupId:           added.GroupId,
+			UpdateDescription: true,
+			Description:       "Platform team",
+			UpdateRole:        true,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(updated.Description).To(gomega.Equal("Platform team"))
+		gomega.Expect(updated.RoleId).To(gomega.BeEmpty())
+
+		groups, err := manager.ListGroups(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(groups.Groups).To(gomega.HaveLen(1))
+
+		_, err = manager.RemoveGroup(&grpc_user_manager_go.GroupId{OrganizationId: organizationID, GroupId: added.GroupId})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.GetGroup(&grpc_user_manager_go.GroupId{OrganizationId: organizationID, GroupId: added.GroupId})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should only add users of the organization", func() {
+		added := addGroup("Platform", "")
+		_, err := manager.AddGroupMembers(&grpc_user_manager_go.GroupMembersRequest{
+			OrganizationId: organizationID,
+			GroupId:        added.GroupId,
+			Emails:         []string{"unknown@example.com"},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should resolve the effective role of the members", func() {
+		platform := addGroup("Platform", ownerRole.RoleId, memberEmail)
+		gomega.Expect(platform.Members).To(gomega.Equal([]string{memberEmail}))
+
+		user := getUser(memberEmail)
+		gomega.Expect(user.RoleId).To(gomega.Equal(developerRole.RoleId))
+		gomega.Expect(user.GroupIds).To(gomega.Equal([]string{platform.GroupId}))
+		gomega.Expect(user.EffectiveRoleId).To(gomega.Equal(ownerRole.RoleId))
+
+		access, err := manager.CheckAccess(&grpc_user_manager_go.CheckAccessRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(access.RoleId).To(gomega.Equal(ownerRole.RoleId))
+		gomega.Expect(access.Decisions[0].Allowed).To(gomega.BeTrue())
+		gomega.Expect(access.Decisions[0].Reason).To(gomega.ContainSubstring("group Platform"))
+	})
+
+	ginkgo.It("should keep the assigned role on ties", func() {
+		addGroup("Platform", operatorRole.RoleId, memberEmail)
+		gomega.Expect(getUser(memberEmail).EffectiveRoleId).To(gomega.Equal(developerRole.RoleId))
+	})
+
+	ginkgo.It("should remove the members that are removed from the organization", func() {
+		platform := addGroup("Platform", operatorRole.RoleId, memberEmail)
+		gomega.Expect(manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})).To(gomega.Succeed())
+		retrieved, err := manager.GetGroup(&grpc_user_manager_go.GroupId{OrganizationId: organizationID, GroupId: platform.GroupId})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(retrieved.Members).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should not remove a role bound to a group", func() {
+		addGroup("Platform", operatorRole.RoleId)
+		err := manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: operatorRole.RoleId})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.Context("with owners through groups", func() {
+		var admins *grpc_user_manager_go.Group
+
+		ginkgo.BeforeEach(func() {
+			admins = addGroup("Admins", ownerRole.RoleId, memberEmail)
+			gomega.Expect(manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: ownerEmail})).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should count the group owners", func() {
+			err := manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+			_, err = manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+				OrganizationId: organizationID,
+				Email:          memberEmail,
+				RoleId:         operatorRole.RoleId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should keep the last owner in the group", func() {
+			_, err := manager.RemoveGroupMembers(&grpc_user_manager_go.GroupMembersRequest{
+				OrganizationId: organizationID,
+				GroupId:        admins.GroupId,
+				Emails:         []string{memberEmail},
+			})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+			_, err = manager.UpdateGroup(&grpc_user_manager_go.UpdateGroupRequest{
+				OrganizationId: organizationID,
+				GroupId:        admins.GroupId,
+				UpdateRole:     true,
+				RoleId:         operatorRole.RoleId,
+			})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+			_, err = manager.RemoveGroup(&grpc_user_manager_go.GroupId{OrganizationId: organizationID, GroupId: admins.GroupId})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		})
+	})
+})
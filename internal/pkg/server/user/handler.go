@@ -42,6 +42,10 @@ func NewHandler(manager Manager) *Handler {
 func (h *Handler) AddUser(ctx context.Context, addUserRequest *grpc_user_manager_go.AddUserRequest) (*grpc_user_manager_go.User, error) {
 	log.Debug().Str("organizationID", addUserRequest.OrganizationId).Str("roleID", addUserRequest.RoleId).
 		Str("email", addUserRequest.Email).Msg("add user")
+	nErr := h.Manager.normalizeEmails(&addUserRequest.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidAddUserRequest(addUserRequest)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -55,8 +59,33 @@ func (h *Handler) AddUser(ctx context.Context, addUserRequest *grpc_user_manager
 	return user, nil
 }
 
+// BulkAddUsers adds a set of users to an organization reporting the result of each one.
+func (h *Handler) BulkAddUsers(ctx context.Context, request *grpc_user_manager_go.BulkAddUsersRequest) (*grpc_user_manager_go.BulkAddUsersResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Int("users", len(request.Users)).
+		Bool("dryRun", request.DryRun).Msg("bulk add users")
+	err := entities.ValidBulkAddUsersRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.BulkAddUsers(request)
+}
+
+// ProvisionFromAssertion creates or updates a user from the verified claims of an external identity provider.
+func (h *Handler) ProvisionFromAssertion(ctx context.Context, request *grpc_user_manager_go.ProvisionFromAssertionRequest) (*grpc_user_manager_go.ProvisionFromAssertionResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("issuer", request.Issuer).Msg("provision from assertion")
+	err := entities.ValidProvisionFromAssertionRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ProvisionFromAssertion(request)
+}
+
 // GetUser retrieves the information of a user including role information.
 func (h *Handler) GetUser(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_user_manager_go.User, error) {
+	nErr := h.Manager.resolveEmails(userID.OrganizationId, &userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidUserID(userID)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -67,6 +96,10 @@ func (h *Handler) GetUser(ctx context.Context, userID *grpc_user_go.UserId) (*gr
 // RemoveUser removes a given user from the system.
 func (h *Handler) RemoveUser(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_common_go.Success, error) {
 	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("remove user")
+	nErr := h.Manager.resolveEmails(userID.OrganizationId, &userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidUserID(userID)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -78,6 +111,34 @@ func (h *Handler) RemoveUser(ctx context.Context, userID *grpc_user_go.UserId) (
 	return &grpc_common_go.Success{}, nil
 }
 
+// RestoreUser restores a removed user whose retention has not expired yet.
+func (h *Handler) RestoreUser(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_user_manager_go.User, error) {
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("restore user")
+	nErr := h.Manager.normalizeEmails(&userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidUserID(userID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	user, rErr := h.Manager.RestoreUser(userID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("user has been restored")
+	return user, nil
+}
+
+// ListRemovedUsers retrieves the removed users of an organization that can be restored.
+func (h *Handler) ListRemovedUsers(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RemovedUserList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListRemovedUsers(organizationID)
+}
+
 func (h *Handler) ListUsers(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.UserList, error) {
 	err := entities.ValidOrganizationID(organizationID)
 	if err != nil {
@@ -116,6 +177,10 @@ func (h *Handler) RemoveRole(ctx context.Context, roleID *grpc_authx_go.RoleId)
 
 // AssignRole assigns a role to an existing user.
 func (h *Handler) AssignRole(ctx context.Context, assignRoleRequest *grpc_user_manager_go.AssignRoleRequest) (*grpc_user_manager_go.User, error) {
+	nErr := h.Manager.resolveEmails(assignRoleRequest.OrganizationId, &assignRoleRequest.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidAssignRoleRequest(assignRoleRequest)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -140,6 +205,10 @@ func (h *Handler) ListRoles(ctx context.Context, organizationID *grpc_organizati
 	return roles, nil
 }
 func (h *Handler) Update(ctx context.Context, request *grpc_user_go.UpdateUserRequest) (*grpc_common_go.Success, error) {
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidUpdateUserRequest(request)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -154,6 +223,10 @@ func (h *Handler) Update(ctx context.Context, request *grpc_user_go.UpdateUserRe
 
 // ChangePassword updates the password of a user.
 func (h *Handler) ChangePassword(ctx context.Context, request *grpc_user_manager_go.ChangePasswordRequest) (*grpc_common_go.Success, error) {
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
 	err := entities.ValidChangePasswordRequest(request)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
@@ -164,3 +237,644 @@ func (h *Handler) ChangePassword(ctx context.Context, request *grpc_user_manager
 	}
 	return &grpc_common_go.Success{}, nil
 }
+
+// AddServiceAccount adds a service account to an organization.
+func (h *Handler) AddServiceAccount(ctx context.Context, request *grpc_user_manager_go.AddServiceAccountRequest) (*grpc_user_manager_go.ServiceAccount, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("name", request.Name).Msg("add service account")
+	err := entities.ValidAddServiceAccountRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.AddServiceAccount(request)
+}
+
+// ListServiceAccounts lists the service accounts of an organization.
+func (h *Handler) ListServiceAccounts(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.ServiceAccountList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListServiceAccounts(organizationID)
+}
+
+// RemoveServiceAccount removes a service account and all its keys.
+func (h *Handler) RemoveServiceAccount(ctx context.Context, serviceAccountID *grpc_user_manager_go.ServiceAccountId) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", serviceAccountID.OrganizationId).Str("serviceAccountID", serviceAccountID.ServiceAccountId).Msg("remove service account")
+	err := entities.ValidServiceAccountID(serviceAccountID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	rErr := h.Manager.RemoveServiceAccount(serviceAccountID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// CreateApiKey creates a key for a service account.
+func (h *Handler) CreateApiKey(ctx context.Context, request *grpc_user_manager_go.CreateApiKeyRequest) (*grpc_user_manager_go.NewApiKey, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("serviceAccountID", request.ServiceAccountId).Msg("create api key")
+	err := entities.ValidCreateApiKeyRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CreateApiKey(request)
+}
+
+// RotateApiKey replaces a key with a new one.
+func (h *Handler) RotateApiKey(ctx context.Context, keyID *grpc_user_manager_go.ApiKeyId) (*grpc_user_manager_go.NewApiKey, error) {
+	log.Debug().Str("organizationID", keyID.OrganizationId).Str("keyID", keyID.KeyId).Msg("rotate api key")
+	err := entities.ValidApiKeyID(keyID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.RotateApiKey(keyID)
+}
+
+// ListApiKeys lists the keys of a service account.
+func (h *Handler) ListApiKeys(ctx context.Context, serviceAccountID *grpc_user_manager_go.ServiceAccountId) (*grpc_user_manager_go.ApiKeyList, error) {
+	err := entities.ValidServiceAccountID(serviceAccountID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListApiKeys(serviceAccountID)
+}
+
+// RevokeApiKey revokes a key.
+func (h *Handler) RevokeApiKey(ctx context.Context, keyID *grpc_user_manager_go.ApiKeyId) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", keyID.OrganizationId).Str("keyID", keyID.KeyId).Msg("revoke api key")
+	err := entities.ValidApiKeyID(keyID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	rErr := h.Manager.RevokeApiKey(keyID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// AuthenticateApiKey checks a key returning the service account and the primitives it grants.
+func (h *Handler) AuthenticateApiKey(ctx context.Context, request *grpc_user_manager_go.AuthenticateApiKeyRequest) (*grpc_user_manager_go.ApiKeyAuthentication, error) {
+	err := entities.ValidAuthenticateApiKeyRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.AuthenticateApiKey(request)
+}
+
+// BeginMFAEnrollment generates the TOTP secret of a user.
+func (h *Handler) BeginMFAEnrollment(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_user_manager_go.MFAEnrollment, error) {
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("begin mfa enrollment")
+	nErr := h.Manager.resolveEmails(userID.OrganizationId, &userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidUserID(userID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.BeginMFAEnrollment(userID)
+}
+
+// ConfirmMFAEnrollment enables MFA for a user with a valid code.
+func (h *Handler) ConfirmMFAEnrollment(ctx context.Context, request *grpc_user_manager_go.MFACodeRequest) (*grpc_user_manager_go.RecoveryCodes, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).Msg("confirm mfa enrollment")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidMFACodeRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ConfirmMFAEnrollment(request)
+}
+
+// VerifyMFACode checks a TOTP or recovery code of a user.
+func (h *Handler) VerifyMFACode(ctx context.Context, request *grpc_user_manager_go.MFACodeRequest) (*grpc_common_go.Success, error) {
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidMFACodeRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	vErr := h.Manager.VerifyMFACode(request)
+	if vErr != nil {
+		return nil, vErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// DisableMFA removes the MFA enrollment of a user.
+func (h *Handler) DisableMFA(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("disable mfa")
+	nErr := h.Manager.resolveEmails(userID.OrganizationId, &userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidUserID(userID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	dErr := h.Manager.DisableMFA(userID)
+	if dErr != nil {
+		return nil, dErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of a user.
+func (h *Handler) GenerateRecoveryCodes(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_user_manager_go.RecoveryCodes, error) {
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("generate recovery codes")
+	nErr := h.Manager.resolveEmails(userID.OrganizationId, &userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidUserID(userID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.GenerateRecoveryCodes(userID)
+}
+
+// SetMFAPolicy sets the MFA policy of an organization.
+func (h *Handler) SetMFAPolicy(ctx context.Context, policy *grpc_user_manager_go.MFAPolicy) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", policy.OrganizationId).Msg("set mfa policy")
+	err := entities.ValidMFAPolicy(policy)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	sErr := h.Manager.SetMFAPolicy(policy)
+	if sErr != nil {
+		return nil, sErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// GetMFAPolicy retrieves the MFA policy of an organization.
+func (h *Handler) GetMFAPolicy(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.MFAPolicy, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.GetMFAPolicy(organizationID)
+}
+
+// SetOwnerPolicy sets the minimum number of owners of an organization.
+func (h *Handler) SetOwnerPolicy(ctx context.Context, policy *grpc_user_manager_go.OwnerPolicy) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", policy.OrganizationId).Int32("minOwners", policy.MinOwners).Msg("set owner policy")
+	err := entities.ValidOwnerPolicy(policy)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	sErr := h.Manager.SetOwnerPolicy(policy)
+	if sErr != nil {
+		return nil, sErr
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// GetOwnerPolicy retrieves the minimum number of owners of an organization.
+func (h *Handler) GetOwnerPolicy(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.OwnerPolicy, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.GetOwnerPolicy(organizationID)
+}
+
+// AddGroup creates a group in an organization.
+func (h *Handler) AddGroup(ctx context.Context, request *grpc_user_manager_go.AddGroupRequest) (*grpc_user_manager_go.Group, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("name", request.Name).Msg("add group")
+	err := entities.ValidAddGroupRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.AddGroup(request)
+}
+
+// GetGroup retrieves a group of an organization.
+func (h *Handler) GetGroup(ctx context.Context, groupID *grpc_user_manager_go.GroupId) (*grpc_user_manager_go.Group, error) {
+	err := entities.ValidGroupID(groupID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.GetGroup(groupID)
+}
+
+// ListGroups lists the groups of an organization.
+func (h *Handler) ListGroups(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.GroupList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListGroups(organizationID)
+}
+
+// UpdateGroup updates the name, description and role binding of a group.
+func (h *Handler) UpdateGroup(ctx context.Context, request *grpc_user_manager_go.UpdateGroupRequest) (*grpc_user_manager_go.Group, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("groupID", request.GroupId).Msg("update group")
+	err := entities.ValidUpdateGroupRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.UpdateGroup(request)
+}
+
+// RemoveGroup removes a group.
+func (h *Handler) RemoveGroup(ctx context.Context, groupID *grpc_user_manager_go.GroupId) (*grpc_common_go.Success, error) {
+	log.Debug().Str("organizationID", groupID.OrganizationId).Str("groupID", groupID.GroupId).Msg("remove group")
+	err := entities.ValidGroupID(groupID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.RemoveGroup(groupID)
+}
+
+// AddGroupMembers adds users to a group.
+func (h *Handler) AddGroupMembers(ctx context.Context, request *grpc_user_manager_go.GroupMembersRequest) (*grpc_user_manager_go.Group, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("groupID", request.GroupId).Int("members", len(request.Emails)).Msg("add group members")
+	nErr := h.Manager.resolveEmailList(request.OrganizationId, request.Emails)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidGroupMembersRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.AddGroupMembers(request)
+}
+
+// RemoveGroupMembers removes users from a group.
+func (h *Handler) RemoveGroupMembers(ctx context.Context, request *grpc_user_manager_go.GroupMembersRequest) (*grpc_user_manager_go.Group, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("groupID", request.GroupId).Int("members", len(request.Emails)).Msg("remove group members")
+	nErr := h.Manager.resolveEmailList(request.OrganizationId, request.Emails)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidGroupMembersRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.RemoveGroupMembers(request)
+}
+
+// ListRoleHierarchy lists the roles of an organization with their parents and effective primitives.
+func (h *Handler) ListRoleHierarchy(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RoleHierarchyList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListRoleHierarchy(organizationID)
+}
+
+// CheckAccess evaluates whether a user is granted a set of primitives.
+func (h *Handler) CheckAccess(ctx context.Context, request *grpc_user_manager_go.CheckAccessRequest) (*grpc_user_manager_go.CheckAccessResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).Msg("check access")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidCheckAccessRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CheckAccess(request)
+}
+
+// CheckAccessBatch evaluates a set of access checks in a single request.
+func (h *Handler) CheckAccessBatch(ctx context.Context, request *grpc_user_manager_go.CheckAccessBatchRequest) (*grpc_user_manager_go.CheckAccessBatchResponse, error) {
+	log.Debug().Int("checks", len(request.Requests)).Msg("check access batch")
+	for _, check := range request.Requests {
+		nErr := h.Manager.resolveEmails(check.OrganizationId, &check.Email)
+		if nErr != nil {
+			return nil, conversions.ToGRPCError(nErr)
+		}
+	}
+	err := entities.ValidCheckAccessBatchRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CheckAccessBatch(request)
+}
+
+// ListRoleGrants lists the active temporary role assignments of an organization.
+func (h *Handler) ListRoleGrants(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RoleGrantList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListRoleGrants(organizationID)
+}
+
+// ListAuditEntries lists the audit log of an organization.
+func (h *Handler) ListAuditEntries(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.AuditEntryList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListAuditEntries(organizationID)
+}
+
+// CreateAccessRequest files a request of a user to be assigned a role.
+func (h *Handler) CreateAccessRequest(ctx context.Context, request *grpc_user_manager_go.CreateAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).Str("roleID", request.RoleId).Msg("create access request")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidCreateAccessRequestRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CreateAccessRequest(request)
+}
+
+// ListPendingAccessRequests lists the access requests of an organization waiting for a resolution.
+func (h *Handler) ListPendingAccessRequests(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.AccessRequestList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListPendingAccessRequests(organizationID)
+}
+
+// ApproveAccessRequest approves an access request assigning the requested role.
+func (h *Handler) ApproveAccessRequest(ctx context.Context, request *grpc_user_manager_go.ResolveAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("accessRequestID", request.AccessRequestId).Msg("approve access request")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.ApproverEmail)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidResolveAccessRequestRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ApproveAccessRequest(request)
+}
+
+// DenyAccessRequest denies an access request.
+func (h *Handler) DenyAccessRequest(ctx context.Context, request *grpc_user_manager_go.ResolveAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("accessRequestID", request.AccessRequestId).Msg("deny access request")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.ApproverEmail)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidResolveAccessRequestRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.DenyAccessRequest(request)
+}
+
+// CancelAccessRequest withdraws an access request.
+func (h *Handler) CancelAccessRequest(ctx context.Context, request *grpc_user_manager_go.CancelAccessRequestRequest) (*grpc_user_manager_go.AccessRequest, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("accessRequestID", request.AccessRequestId).Msg("cancel access request")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidCancelAccessRequestRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CancelAccessRequest(request)
+}
+
+// UpdateRole updates the name, description and primitives of a role.
+func (h *Handler) UpdateRole(ctx context.Context, updateRoleRequest *grpc_user_manager_go.UpdateRoleRequest) (*grpc_authx_go.Role, error) {
+	log.Debug().Str("organizationID", updateRoleRequest.OrganizationId).Str("roleID", updateRoleRequest.RoleId).Msg("update role")
+	err := entities.ValidUpdateRoleRequest(updateRoleRequest)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.UpdateRole(updateRoleRequest)
+}
+
+// ListRoleTemplates retrieves the role templates that can be applied to the organizations.
+func (h *Handler) ListRoleTemplates(ctx context.Context, empty *grpc_common_go.Empty) (*grpc_user_manager_go.RoleTemplateList, error) {
+	log.Debug().Msg("list role templates")
+	return h.Manager.ListRoleTemplates(empty)
+}
+
+// ApplyRoleTemplate creates the roles of a template that do not exist yet in an organization.
+func (h *Handler) ApplyRoleTemplate(ctx context.Context, request *grpc_user_manager_go.ApplyRoleTemplateRequest) (*grpc_user_manager_go.ApplyRoleTemplateResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("template", request.TemplateName).Msg("apply role template")
+	err := entities.ValidApplyRoleTemplateRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ApplyRoleTemplate(request)
+}
+
+// CloneRole creates a copy of a role in the same or in another organization.
+func (h *Handler) CloneRole(ctx context.Context, request *grpc_user_manager_go.CloneRoleRequest) (*grpc_authx_go.Role, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("roleID", request.RoleId).
+		Str("targetOrganizationID", request.TargetOrganizationId).Msg("clone role")
+	err := entities.ValidCloneRoleRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.CloneRole(request)
+}
+
+// BootstrapOrganization creates the default roles and the first owner of an empty organization.
+func (h *Handler) BootstrapOrganization(ctx context.Context, request *grpc_user_manager_go.BootstrapOrganizationRequest) (*grpc_user_manager_go.BootstrapOrganizationResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+		Str("template", request.TemplateName).Msg("bootstrap organization")
+	nErr := h.Manager.normalizeEmails(&request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidBootstrapOrganizationRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.BootstrapOrganization(request)
+}
+
+// RequestOffboardingToken issues the token required to offboard an organization.
+func (h *Handler) RequestOffboardingToken(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.OffboardingToken, error) {
+	log.Debug().Str("organizationID", organizationID.OrganizationId).Msg("request offboarding token")
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.RequestOffboardingToken(organizationID)
+}
+
+// OffboardOrganization removes all the users and roles of an organization.
+func (h *Handler) OffboardOrganization(ctx context.Context, request *grpc_user_manager_go.OffboardOrganizationRequest) (*grpc_user_manager_go.OffboardingReport, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Msg("offboard organization")
+	err := entities.ValidOffboardOrganizationRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.OffboardOrganization(request)
+}
+
+// TransferOwnership gives the owner role of a user to another one, demoting the former owner.
+func (h *Handler) TransferOwnership(ctx context.Context, request *grpc_user_manager_go.TransferOwnershipRequest) (*grpc_user_manager_go.TransferOwnershipResponse, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("from", request.FromEmail).
+		Str("to", request.ToEmail).Msg("transfer ownership")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.FromEmail, &request.ToEmail)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidTransferOwnershipRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.TransferOwnership(request)
+}
+
+// ListMemberships retrieves the organizations the identity of an email belongs to.
+func (h *Handler) ListMemberships(ctx context.Context, request *grpc_user_manager_go.ListMembershipsRequest) (*grpc_user_manager_go.MembershipList, error) {
+	log.Debug().Str("email", request.Email).Msg("list memberships")
+	nErr := h.Manager.normalizeEmails(&request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidListMembershipsRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ListMemberships(request)
+}
+
+// InviteMember invites a member of an organization into another organization reusing its identity.
+func (h *Handler) InviteMember(ctx context.Context, request *grpc_user_manager_go.InviteMemberRequest) (*grpc_user_manager_go.User, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("sourceOrganizationID", request.SourceOrganizationId).
+		Str("email", request.Email).Msg("invite member")
+	nErr := h.Manager.normalizeEmails(&request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidInviteMemberRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.InviteMember(request)
+}
+
+// SetProfilePropagation sets whether the profile updates of an identity are applied to all its organizations.
+func (h *Handler) SetProfilePropagation(ctx context.Context, request *grpc_user_manager_go.ProfilePropagationRequest) (*grpc_common_go.Success, error) {
+	log.Debug().Str("email", request.Email).Bool("enabled", request.Enabled).Msg("set profile propagation")
+	nErr := h.Manager.normalizeEmails(&request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidProfilePropagationRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.SetProfilePropagation(request)
+}
+
+// BackfillIdentities links the accounts of an organization created before the identities were introduced.
+func (h *Handler) BackfillIdentities(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.IdentityBackfillReport, error) {
+	log.Debug().Str("organizationID", organizationID.OrganizationId).Msg("backfill identities")
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.BackfillIdentities(organizationID)
+}
+
+// RequestEmailChange issues the token that confirms the change of the email of a user.
+func (h *Handler) RequestEmailChange(ctx context.Context, request *grpc_user_manager_go.EmailChangeRequest) (*grpc_user_manager_go.EmailChangeToken, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+		Str("newEmail", request.NewEmail).Msg("request email change")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	nErr = h.Manager.normalizeEmails(&request.NewEmail)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidEmailChangeRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.RequestEmailChange(request)
+}
+
+// ChangeEmail completes a confirmed email change.
+func (h *Handler) ChangeEmail(ctx context.Context, request *grpc_user_manager_go.ChangeEmailRequest) (*grpc_user_manager_go.User, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).Msg("change email")
+	nErr := h.Manager.resolveEmails(request.OrganizationId, &request.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidChangeEmailRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ChangeEmail(request)
+}
+
+// ResolveEmailAlias retrieves the current email of a user from its former email.
+func (h *Handler) ResolveEmailAlias(ctx context.Context, userID *grpc_user_go.UserId) (*grpc_user_manager_go.EmailAlias, error) {
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("resolve email alias")
+	nErr := h.Manager.normalizeEmails(&userID.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	err := entities.ValidUserID(userID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.ResolveEmailAlias(userID)
+}
+
+// FindEmailCollisions reports the users of an organization whose emails collide once normalized.
+func (h *Handler) FindEmailCollisions(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.EmailCollisionList, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.FindEmailCollisions(organizationID)
+}
+
+// MigrateEmails moves the users added before the emails were normalized to the canonical form of their email.
+func (h *Handler) MigrateEmails(ctx context.Context, request *grpc_user_manager_go.MigrateEmailsRequest) (*grpc_user_manager_go.EmailMigrationReport, error) {
+	log.Debug().Str("organizationID", request.OrganizationId).Bool("dryRun", request.DryRun).Msg("migrate emails")
+	err := entities.ValidMigrateEmailsRequest(request)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.MigrateEmails(request)
+}
+
+// SetEmailDomainPolicy sets the email domains whose users can be members of an organization.
+func (h *Handler) SetEmailDomainPolicy(ctx context.Context, policy *grpc_user_manager_go.EmailDomainPolicy) (*grpc_user_manager_go.DomainPolicyReport, error) {
+	log.Debug().Str("organizationID", policy.OrganizationId).Strs("allowed", policy.AllowedDomains).
+		Strs("denied", policy.DeniedDomains).Msg("set email domain policy")
+	err := entities.ValidEmailDomainPolicy(policy)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.SetEmailDomainPolicy(policy)
+}
+
+// GetEmailDomainPolicy retrieves the email domain policy of an organization.
+func (h *Handler) GetEmailDomainPolicy(ctx context.Context, organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.EmailDomainPolicy, error) {
+	err := entities.ValidOrganizationID(organizationID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.GetEmailDomainPolicy(organizationID)
+}
+
+// PreviewEmailDomainPolicy lists the members of an organization that would violate an email domain policy.
+func (h *Handler) PreviewEmailDomainPolicy(ctx context.Context, policy *grpc_user_manager_go.EmailDomainPolicy) (*grpc_user_manager_go.DomainPolicyReport, error) {
+	err := entities.ValidEmailDomainPolicy(policy)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return h.Manager.PreviewEmailDomainPolicy(policy)
+}
@@ -32,6 +32,10 @@ import (
 	"github.com/nalej/grpc-user-manager-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/grpc-utils/pkg/test"
+	"github.com/nalej/user-manager/internal/pkg/provider/group"
+	"github.com/nalej/user-manager/internal/pkg/provider/ownerpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolebinding"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
 	"github.com/nalej/user-manager/internal/pkg/utils"
 	"github.com/onsi/ginkgo"
 	"github.com/onsi/gomega"
@@ -148,7 +152,7 @@ var _ = ginkgo.Describe("User service", func() {
 		authxClient = grpc_authx_go.NewAuthxClient(authxConn)
 
 		// Register the service
-		manager := NewManager(authxClient, userClient, roleClient)
+		manager := NewManager(authxClient, userClient, roleClient, NewMockupProviders(), testSettings())
 		handler := NewHandler(manager)
 		grpc_user_manager_go.RegisterUserManagerServer(server, handler)
 		test.LaunchServer(server, listener)
@@ -192,6 +196,57 @@ var _ = ginkgo.Describe("User service", func() {
 		gomega.Expect(added.RoleName).Should(gomega.Equal(targetRole.Name))
 	})
 
+	ginkgo.It("should be able to add users in bulk", func() {
+		valid := &grpc_user_manager_go.AddUserRequest{
+			Email:    GetRandomEmail(),
+			Password: "password",
+			Name:     "user",
+			LastName: "lastName",
+			Title:    "title",
+			RoleId:   targetRole.RoleId,
+		}
+		invited := &grpc_user_manager_go.AddUserRequest{
+			Email:    GetRandomEmail(),
+			Name:     "user",
+			LastName: "lastName",
+			Title:    "title",
+			RoleId:   targetRole.RoleId,
+		}
+		wrongRole := &grpc_user_manager_go.AddUserRequest{
+			Email:    GetRandomEmail(),
+			Password: "password",
+			Name:     "user",
+			LastName: "lastName",
+			Title:    "title",
+			RoleId:   "wrongRole",
+		}
+		request := &grpc_user_manager_go.BulkAddUsersRequest{
+			OrganizationId: targetOrganization.OrganizationId,
+			Users:          []*grpc_user_manager_go.AddUserRequest{valid, invited, wrongRole},
+			DryRun:         true,
+			Invite:         true,
+		}
+		response, err := client.BulkAddUsers(context.Background(), request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Added).Should(gomega.Equal(int32(2)))
+		gomega.Expect(response.Failed).Should(gomega.Equal(int32(1)))
+
+		organizationID := &grpc_organization_go.OrganizationId{
+			OrganizationId: targetOrganization.OrganizationId,
+		}
+		users, err := client.ListUsers(context.Background(), organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(users.Users).To(gomega.BeEmpty())
+
+		request.DryRun = false
+		response, err = client.BulkAddUsers(context.Background(), request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Added).Should(gomega.Equal(int32(2)))
+		gomega.Expect(response.Results[1].Invited).To(gomega.BeTrue())
+		gomega.Expect(response.Results[1].User.PasswordResetRequired).To(gomega.BeTrue())
+		gomega.Expect(response.Results[2].Success).To(gomega.BeFalse())
+	})
+
 	ginkgo.It("should be able to retrieve a user", func() {
 		toAdd := &grpc_user_manager_go.AddUserRequest{
 			OrganizationId: targetOrganization.OrganizationId,
@@ -283,6 +338,55 @@ var _ = ginkgo.Describe("User service", func() {
 		gomega.Expect(err).NotTo(gomega.Succeed())
 	})
 
+	ginkgo.It("should be able to restore a removed user", func() {
+		// owner user (ORG user must exist in the system)
+		toAdd := &grpc_user_manager_go.AddUserRequest{
+			OrganizationId: targetOrganization.OrganizationId,
+			Email:          GetRandomEmail(),
+			Password:       "password",
+			Name:           "user",
+			RoleId:         targetRole.RoleId,
+		}
+		_, err := client.AddUser(context.Background(), toAdd)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		newRole := CreateResourcesRole("newResourceTestRole", targetOrganization.OrganizationId, roleClient, authxClient)
+		toAdd2 := &grpc_user_manager_go.AddUserRequest{
+			OrganizationId: targetOrganization.OrganizationId,
+			Email:          GetRandomEmail(),
+			Password:       "password",
+			Name:           "user",
+			LastName:       "lastName",
+			Title:          "title",
+			RoleId:         newRole.RoleId,
+		}
+		added2, err := client.AddUser(context.Background(), toAdd2)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		userID := &grpc_user_go.UserId{
+			OrganizationId: added2.OrganizationId,
+			Email:          added2.Email,
+		}
+		_, err = client.RemoveUser(context.Background(), userID)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		removed, err := client.ListRemovedUsers(context.Background(), &grpc_organization_go.OrganizationId{
+			OrganizationId: targetOrganization.OrganizationId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(len(removed.RemovedUsers)).Should(gomega.Equal(1))
+
+		restored, err := client.RestoreUser(context.Background(), userID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(restored.Email).Should(gomega.Equal(added2.Email))
+		gomega.Expect(restored.RoleId).Should(gomega.Equal(newRole.RoleId))
+		gomega.Expect(restored.Title).Should(gomega.Equal(added2.Title))
+		gomega.Expect(restored.PasswordResetRequired).To(gomega.BeTrue())
+
+		_, err = client.RestoreUser(context.Background(), userID)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+	})
+
 	ginkgo.It("should be able to change the password of a user", func() {
 		toAdd := &grpc_user_manager_go.AddUserRequest{
 			OrganizationId: targetOrganization.OrganizationId,
@@ -457,7 +561,8 @@ var _ = ginkgo.Describe("User service", func() {
 			gomega.Expect(err).To(gomega.Succeed())
 			gomega.Expect(added.Email).ShouldNot(gomega.BeEmpty())
 
-			userCache := NewUsersCache(authxClient, userClient, roleClient)
+			userCache := NewUsersCache(authxClient, userClient, roleClient, ownerpolicy.NewMockupOwnerPolicyProvider(), rolehierarchy.NewMockupRoleHierarchyProvider(), group.NewMockupGroupProvider(),
+				rolebinding.NewMockupRoleBindingProvider())
 			isOwner, err := userCache.roleIsOwner(targetOrganization.OrganizationId, targetRole.RoleId)
 			gomega.Expect(err).To(gomega.Succeed())
 			gomega.Expect(isOwner).To(gomega.BeTrue())
@@ -0,0 +1,239 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"sort"
+)
+
+// ListMemberships retrieves the organizations the identity of an email belongs to with the role it holds in each one.
+// Memberships whose account no longer exists are skipped. Accounts created before the identities were introduced are
+// only listed once their organization is backfilled with BackfillIdentities or the account is used with a request of
+// its organization.
+func (m *Manager) ListMemberships(request *grpc_user_manager_go.ListMembershipsRequest) (*grpc_user_manager_go.MembershipList, error) {
+	identity, err := m.identities.Get(request.Email)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	result := make([]*grpc_user_manager_go.Membership, 0, len(identity.Memberships))
+	for _, membership := range identity.Memberships {
+		user, uErr := m.GetUser(&grpc_user_go.UserId{OrganizationId: membership.OrganizationId, Email: identity.Email})
+		if uErr != nil {
+			if ignoreNotFound(uErr) == nil {
+				continue
+			}
+			return nil, uErr
+		}
+		result = append(result, &grpc_user_manager_go.Membership{
+			OrganizationId: membership.OrganizationId,
+			Email:          identity.Email,
+			RoleId:         user.RoleId,
+			RoleName:       user.RoleName,
+			MemberSince:    user.MemberSince,
+			Joined:         membership.Joined,
+		})
+	}
+	return &grpc_user_manager_go.MembershipList{
+		Email:            identity.Email,
+		PropagateProfile: identity.PropagateProfile,
+		Memberships:      result,
+	}, nil
+}
+
+// InviteMember invites the identity of a member of an organization into another organization. Only the email is
+// taken from the source organization, the profile of the member is not disclosed to the other organization. As
+// credentials are kept per organization, the new account gets a random password that must be reset.
+func (m *Manager) InviteMember(request *grpc_user_manager_go.InviteMemberRequest) (*grpc_user_manager_go.User, error) {
+	identity, err := m.memberIdentity(request.SourceOrganizationId, request.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !identity.HasMembership(request.SourceOrganizationId) {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("membership").WithParams(request.Email, request.SourceOrganizationId))
+	}
+	if identity.HasMembership(request.OrganizationId) {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("membership").WithParams(request.Email, request.OrganizationId))
+	}
+	_, rErr := m.organizationRole(request.OrganizationId, request.RoleId)
+	if rErr != nil {
+		return nil, rErr
+	}
+	user, iErr := m.InviteUser(&grpc_user_manager_go.AddUserRequest{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+		RoleId:         request.RoleId,
+	})
+	if iErr != nil {
+		return nil, iErr
+	}
+	m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditMemberInvited, request.Email,
+		"invited from organization %s with role %s", request.SourceOrganizationId, user.RoleName))
+	return user, nil
+}
+
+// SetProfilePropagation sets whether the profile updates of an identity in one organization are applied to the
+// others. Organizations whose accounts are not backfilled yet are only updated once they are linked.
+func (m *Manager) SetProfilePropagation(request *grpc_user_manager_go.ProfilePropagationRequest) (*grpc_common_go.Success, error) {
+	identity, err := m.identities.Get(request.Email)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	identity.PropagateProfile = request.Enabled
+	err = m.identities.Set(*identity)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// BackfillIdentities links the accounts of an organization created before the identities were introduced to the
+// identity of their email.
+func (m *Manager) BackfillIdentities(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.IdentityBackfillReport, error) {
+	users, err := m.usersClient.GetUsers(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	report := &grpc_user_manager_go.IdentityBackfillReport{
+		OrganizationId: organizationID.OrganizationId,
+		Linked:         make([]string, 0),
+		Failures:       make([]*grpc_user_manager_go.IdentityBackfillFailure, 0),
+	}
+	for _, user := range users.Users {
+		linked, lErr := m.addIdentityMembership(organizationID.OrganizationId, user.Email)
+		if lErr != nil {
+			report.Failures = append(report.Failures, &grpc_user_manager_go.IdentityBackfillFailure{Email: user.Email, Reason: lErr.Error()})
+			continue
+		}
+		if linked {
+			report.Linked = append(report.Linked, user.Email)
+		}
+	}
+	sort.Strings(report.Linked)
+	return report, nil
+}
+
+// memberIdentity retrieves the identity of an account of an organization. Accounts created before the identities were
+// introduced are linked to their identity the first time they are looked up.
+func (m *Manager) memberIdentity(organizationID string, email string) (*entities.Identity, error) {
+	identity, err := m.identities.Get(email)
+	if err != nil && err.Type() != derrors.NotFound {
+		return nil, conversions.ToGRPCError(err)
+	}
+	if identity != nil && identity.HasMembership(organizationID) {
+		return identity, nil
+	}
+	_, uErr := m.usersClient.GetUser(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+	if uErr != nil {
+		if ignoreNotFound(uErr) != nil {
+			return nil, uErr
+		}
+		if identity == nil {
+			return nil, conversions.ToGRPCError(err)
+		}
+		return identity, nil
+	}
+	_, err = m.addIdentityMembership(organizationID, email)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	identity, err = m.identities.Get(email)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return identity, nil
+}
+
+// linkIdentity links an account to the identity of its email, creating the identity if needed. Failures are logged as
+// the account is already usable.
+func (m *Manager) linkIdentity(organizationID string, email string) {
+	_, err := m.addIdentityMembership(organizationID, email)
+	if err != nil {
+		log.Warn().Str("organizationID", organizationID).Str("email", email).Str("err", err.DebugReport()).
+			Msg("cannot link the user to its identity")
+	}
+}
+
+// addIdentityMembership links an account to the identity of its email, creating the identity if needed. It returns
+// false if the account was already linked.
+func (m *Manager) addIdentityMembership(organizationID string, email string) (bool, derrors.Error) {
+	identity, err := m.identities.Get(email)
+	if err != nil {
+		if err.Type() != derrors.NotFound {
+			return false, err
+		}
+		identity = entities.NewIdentity(email)
+	}
+	if !identity.AddMembership(organizationID) {
+		return false, nil
+	}
+	err = m.identities.Set(*identity)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// unlinkIdentity unlinks a removed account from the identity of its email, removing the identity with its last
+// membership.
+func (m *Manager) unlinkIdentity(organizationID string, email string) {
+	identity, err := m.identities.Get(email)
+	if err != nil {
+		return
+	}
+	if !identity.RemoveMembership(organizationID) {
+		return
+	}
+	if len(identity.Memberships) == 0 {
+		err = m.identities.Remove(email)
+	} else {
+		err = m.identities.Set(*identity)
+	}
+	if err != nil {
+		log.Warn().Str("organizationID", organizationID).Str("email", email).Str("err", err.DebugReport()).
+			Msg("cannot unlink the user from its identity")
+	}
+}
+
+// propagateProfile applies a profile update to the accounts of the other organizations of the identity if it
+// propagates its profile. Failures are logged as the update of the original account already succeeded.
+func (m *Manager) propagateProfile(request *grpc_user_go.UpdateUserRequest) {
+	identity, err := m.memberIdentity(request.OrganizationId, request.Email)
+	if err != nil || !identity.PropagateProfile {
+		return
+	}
+	for _, membership := range identity.Memberships {
+		if membership.OrganizationId == request.OrganizationId {
+			continue
+		}
+		propagated := *request
+		propagated.OrganizationId = membership.OrganizationId
+		_, uErr := m.usersClient.Update(context.Background(), &propagated)
+		if uErr != nil {
+			log.Warn().Str("organizationID", membership.OrganizationId).Str("email", request.Email).
+				Str("err", conversions.ToDerror(uErr).DebugReport()).Msg("cannot propagate the profile update")
+		}
+	}
+}
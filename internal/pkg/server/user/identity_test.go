@@ -0,0 +1,203 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Identities", func() {
+
+	const organizationID = "org-1"
+	const otherOrganizationID = "org-2"
+	const consultantEmail = "consultant@example.com"
+
+	var manager Manager
+	var developerRole *grpc_authx_go.Role
+	var otherDeveloperRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	bootstrap := func(organizationID string) *grpc_authx_go.Role {
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          "owner@" + organizationID + ".com",
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return response.Roles[2]
+	}
+
+	inviteMember := func() (*grpc_user_manager_go.User, error) {
+		return manager.InviteMember(&grpc_user_manager_go.InviteMemberRequest{
+			SourceOrganizationId: organizationID,
+			OrganizationId:       otherOrganizationID,
+			Email:                consultantEmail,
+			RoleId:               otherDeveloperRole.RoleId,
+		})
+	}
+
+	listMemberships := func() *grpc_user_manager_go.MembershipList {
+		memberships, err := manager.ListMemberships(&grpc_user_manager_go.ListMembershipsRequest{Email: consultantEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		return memberships
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		developerRole = bootstrap(organizationID)
+		otherDeveloperRole = bootstrap(otherOrganizationID)
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          consultantEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Consultant",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should invite a member into another organization reusing its identity", func() {
+		gomega.Expect(listMemberships().Memberships).To(gomega.HaveLen(1))
+
+		invited, err := inviteMember()
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(invited.OrganizationId).To(gomega.Equal(otherOrganizationID))
+		gomega.Expect(invited.Email).To(gomega.Equal(consultantEmail))
+		gomega.Expect(invited.Title).To(gomega.BeEmpty())
+		gomega.Expect(invited.RoleId).To(gomega.Equal(otherDeveloperRole.RoleId))
+		gomega.Expect(invited.PasswordResetRequired).To(gomega.BeTrue())
+
+		memberships := listMemberships()
+		gomega.Expect(memberships.Memberships).To(gomega.HaveLen(2))
+		gomega.Expect(memberships.Memberships[0].OrganizationId).To(gomega.Equal(organizationID))
+		gomega.Expect(memberships.Memberships[1].OrganizationId).To(gomega.Equal(otherOrganizationID))
+		gomega.Expect(memberships.Memberships[1].RoleName).To(gomega.Equal(otherDeveloperRole.Name))
+
+		_, err = inviteMember()
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+
+		gomega.Expect(manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: otherOrganizationID, Email: consultantEmail})).To(gomega.Succeed())
+		gomega.Expect(listMemberships().Memberships).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("should only invite from an organization the identity belongs to", func() {
+		_, err := manager.InviteMember(&grpc_user_manager_go.InviteMemberRequest{
+			SourceOrganizationId: otherOrganizationID,
+			OrganizationId:       organizationID,
+			Email:                consultantEmail,
+			RoleId:               developerRole.RoleId,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+		_, err = manager.ListMemberships(&grpc_user_manager_go.ListMembershipsRequest{Email: "unknown@example.com"})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should propagate profile updates when enabled", func() {
+		_, err := inviteMember()
+		gomega.Expect(err).To(gomega.Succeed())
+		update := &grpc_user_go.UpdateUserRequest{
+			OrganizationId: organizationID,
+			Email:          consultantEmail,
+			UpdateTitle:    true,
+			Title:          "Architect",
+		}
+		_, err = manager.UpdateUser(update)
+		gomega.Expect(err).To(gomega.Succeed())
+		other, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: otherOrganizationID, Email: consultantEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(other.Title).To(gomega.BeEmpty())
+
+		_, err = manager.SetProfilePropagation(&grpc_user_manager_go.ProfilePropagationRequest{Email: consultantEmail, Enabled: true})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(listMemberships().PropagateProfile).To(gomega.BeTrue())
+		update.Title = "Principal"
+		_, err = manager.UpdateUser(update)
+		gomega.Expect(err).To(gomega.Succeed())
+		other, err = manager.GetUser(&grpc_user_go.UserId{OrganizationId: otherOrganizationID, Email: consultantEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(other.Title).To(gomega.Equal("Principal"))
+	})
+
+	ginkgo.Context("with users added before the identities", func() {
+		const legacyEmail = "legacy@example.com"
+
+		ginkgo.BeforeEach(func() {
+			_, err := manager.usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{
+				OrganizationId: organizationID,
+				Email:          legacyEmail,
+				Title:          "Legacy",
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			_, err = manager.accessClient.AddBasicCredentials(context.Background(), &grpc_authx_go.AddBasicCredentialRequest{
+				OrganizationId: organizationID,
+				Username:       legacyEmail,
+				Password:       "password",
+				RoleId:         developerRole.RoleId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should link the identity when the user is invited", func() {
+			_, err := manager.ListMemberships(&grpc_user_manager_go.ListMembershipsRequest{Email: legacyEmail})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+
+			invited, err := manager.InviteMember(&grpc_user_manager_go.InviteMemberRequest{
+				SourceOrganizationId: organizationID,
+				OrganizationId:       otherOrganizationID,
+				Email:                legacyEmail,
+				RoleId:               otherDeveloperRole.RoleId,
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(invited.Title).To(gomega.BeEmpty())
+			memberships, err := manager.ListMemberships(&grpc_user_manager_go.ListMembershipsRequest{Email: legacyEmail})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(memberships.Memberships).To(gomega.HaveLen(2))
+		})
+
+		ginkgo.It("should backfill the identities of an organization", func() {
+			report, err := manager.BackfillIdentities(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(report.Linked).To(gomega.Equal([]string{legacyEmail}))
+			gomega.Expect(report.Failures).To(gomega.BeEmpty())
+			memberships, err := manager.ListMemberships(&grpc_user_manager_go.ListMembershipsRequest{Email: legacyEmail})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(memberships.Memberships).To(gomega.HaveLen(1))
+
+			report, err = manager.BackfillIdentities(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(report.Linked).To(gomega.BeEmpty())
+		})
+	})
+})
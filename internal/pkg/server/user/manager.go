@@ -18,6 +18,8 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"github.com/nalej/derrors"
 	"github.com/nalej/grpc-authx-go"
@@ -28,25 +30,126 @@ import (
 	"github.com/nalej/grpc-user-manager-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
 	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/accessrequest"
+	"github.com/nalej/user-manager/internal/pkg/provider/audit"
+	"github.com/nalej/user-manager/internal/pkg/provider/claimrules"
+	"github.com/nalej/user-manager/internal/pkg/provider/domainpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/emailchange"
+	"github.com/nalej/user-manager/internal/pkg/provider/group"
+	"github.com/nalej/user-manager/internal/pkg/provider/identity"
+	"github.com/nalej/user-manager/internal/pkg/provider/mfa"
+	"github.com/nalej/user-manager/internal/pkg/provider/offboarding"
+	"github.com/nalej/user-manager/internal/pkg/provider/ownerpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/passwordreset"
+	"github.com/nalej/user-manager/internal/pkg/provider/recyclebin"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolebinding"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolegrant"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"github.com/nalej/user-manager/internal/pkg/provider/roletemplate"
+	"github.com/nalej/user-manager/internal/pkg/provider/serviceaccount"
+	"github.com/rs/zerolog/log"
+	"time"
 )
 
+// randomPasswordLength with the number of random bytes used to generate passwords that nobody knows.
+const randomPasswordLength = 32
+
 // Manager structure with the required clients for roles operations.
 type Manager struct {
 	accessClient grpc_authx_go.AuthxClient
 	usersClient  grpc_user_go.UsersClient
 	roleClient   grpc_role_go.RolesClient
 
-	usersCache UsersCache
+	// recycleBin with the snapshots of the removed users.
+	recycleBin recyclebin.Provider
+	// passwordResets with the users that must reset their password.
+	passwordResets passwordreset.Provider
+	// removedUserRetention with the time a removed user can be restored.
+	removedUserRetention time.Duration
+	// claimRules with the rules to provision users from the assertions of external identity providers.
+	claimRules claimrules.Provider
+	// serviceAccounts with the service accounts and their API keys.
+	serviceAccounts serviceaccount.Provider
+	// mfa with the MFA enrollments and policies.
+	mfa mfa.Provider
+	// mfaCipher encrypts the TOTP secrets. MFA enrollment is disabled if not set.
+	mfaCipher *entities.SecretCipher
+	// roleGrants with the temporary role assignments.
+	roleGrants rolegrant.Provider
+	// auditLog with the record of the operations on the users.
+	auditLog audit.Provider
+	// accessRequests with the requests of the users to be assigned a role.
+	accessRequests accessrequest.Provider
+	// approverPrimitive with the primitive required to approve or deny access requests.
+	approverPrimitive grpc_authx_go.AccessPrimitive
+	// accessRequestTTL with the time an access request can be resolved before it expires.
+	accessRequestTTL time.Duration
+	// roleTemplates with the sets of roles that can be created in the organizations.
+	roleTemplates roletemplate.Provider
+	// offboardings with the progress of the organizations being removed.
+	offboardings offboarding.Provider
+	// ownerPolicies with the minimum number of owners of the organizations.
+	ownerPolicies ownerpolicy.Provider
+	// roleHierarchy with the parent roles the roles inherit their primitives from.
+	roleHierarchy rolehierarchy.Provider
+	// groups with the groups of users and their role bindings.
+	groups group.Provider
+	// roleBindings with the roles the users hold besides their primary role.
+	roleBindings rolebinding.Provider
+	// identities with the accounts of the same person in several organizations.
+	identities identity.Provider
+	// emailChanges with the pending email changes and the aliases of the former emails.
+	emailChanges emailchange.Provider
+	// emailNormalizer with the canonical form of the emails used to store and look up the users.
+	emailNormalizer *entities.EmailNormalizer
+	// domainPolicies with the email domains whose users can be members of the organizations.
+	domainPolicies domainpolicy.Provider
+
+	usersCache  UsersCache
+	rolesCache  RolesCache
+	emailsCache EmailsCache
 }
 
-// NewManager creates a Manager using a set of clients.
+// NewManager creates a Manager using a set of clients, the stores of its state and its settings.
 func NewManager(
 	accessClient grpc_authx_go.AuthxClient,
 	usersClient grpc_user_go.UsersClient,
 	roleClient grpc_role_go.RolesClient,
+	providers Providers,
+	settings Settings,
 ) Manager {
+	emailNormalizer := settings.EmailNormalizer
+	if emailNormalizer == nil {
+		// the default normalizer has no provider rules and cannot fail
+		emailNormalizer, _ = entities.NewEmailNormalizer(nil)
+	}
+	usersCache := NewUsersCache(accessClient, usersClient, roleClient, providers.OwnerPolicies, providers.RoleHierarchy,
+		providers.Groups, providers.RoleBindings)
 	return Manager{accessClient: accessClient, usersClient: usersClient, roleClient: roleClient,
-		usersCache: NewUsersCache(accessClient, usersClient, roleClient)}
+		recycleBin: providers.RecycleBin, passwordResets: providers.PasswordResets,
+		removedUserRetention: settings.RemovedUserRetention,
+		claimRules:           providers.ClaimRules,
+		serviceAccounts:      providers.ServiceAccounts,
+		mfa:                  providers.MFA,
+		mfaCipher:            settings.MfaCipher,
+		roleGrants:           providers.RoleGrants,
+		auditLog:             providers.AuditLog,
+		accessRequests:       providers.AccessRequests,
+		approverPrimitive:    settings.ApproverPrimitive,
+		accessRequestTTL:     settings.AccessRequestTTL,
+		roleTemplates:        providers.RoleTemplates,
+		offboardings:         providers.Offboardings,
+		ownerPolicies:        providers.OwnerPolicies,
+		roleHierarchy:        providers.RoleHierarchy,
+		groups:               providers.Groups,
+		roleBindings:         providers.RoleBindings,
+		identities:           providers.Identities,
+		emailChanges:         providers.EmailChanges,
+		emailNormalizer:      emailNormalizer,
+		domainPolicies:       providers.DomainPolicies,
+		usersCache:           usersCache,
+		rolesCache:           NewRolesCache(accessClient, providers.RoleHierarchy),
+		emailsCache:          NewEmailsCache(usersClient, emailNormalizer)}
 }
 
 // AddUser adds a new user to an organization.
@@ -55,6 +158,18 @@ func (m *Manager) AddUser(addUserRequest *grpc_user_manager_go.AddUserRequest) (
 	// clear userCache
 	_ = m.usersCache.Clear(addUserRequest.OrganizationId)
 
+	dErr := m.checkEmailDomain(addUserRequest.OrganizationId, addUserRequest.Email)
+	if dErr != nil {
+		return nil, dErr
+	}
+	uErr := m.checkEmailUnique(addUserRequest.OrganizationId, addUserRequest.Email, "")
+	if uErr != nil {
+		return nil, uErr
+	}
+	aErr := m.checkEmailAlias(addUserRequest.OrganizationId, addUserRequest.Email, "")
+	if aErr != nil {
+		return nil, aErr
+	}
 	addRequest := &grpc_user_go.AddUserRequest{
 		OrganizationId: addUserRequest.OrganizationId,
 		Email:          addUserRequest.Email,
@@ -70,6 +185,7 @@ func (m *Manager) AddUser(addUserRequest *grpc_user_manager_go.AddUserRequest) (
 	if err != nil {
 		return nil, err
 	}
+	m.emailsCache.Add(user.OrganizationId, user.Email)
 	// 2. Register the credentials on authx
 	addBasicCredentialsRequest := &grpc_authx_go.AddBasicCredentialRequest{
 		OrganizationId: addUserRequest.OrganizationId,
@@ -79,8 +195,19 @@ func (m *Manager) AddUser(addUserRequest *grpc_user_manager_go.AddUserRequest) (
 	}
 	_, err = m.accessClient.AddBasicCredentials(context.Background(), addBasicCredentialsRequest)
 	if err != nil {
+		_, rErr := m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+			OrganizationId: user.OrganizationId,
+			Email:          user.Email,
+		})
+		if rErr != nil {
+			log.Error().Str("organizationID", user.OrganizationId).Str("email", user.Email).
+				Msg("cannot remove the user from system model after authx failed")
+		} else {
+			m.emailsCache.Remove(user.OrganizationId, user.Email)
+		}
 		return nil, err
 	}
+	m.linkIdentity(user.OrganizationId, user.Email)
 	userID := &grpc_user_go.UserId{
 		OrganizationId: user.OrganizationId,
 		Email:          user.Email,
@@ -88,7 +215,28 @@ func (m *Manager) AddUser(addUserRequest *grpc_user_manager_go.AddUserRequest) (
 	return m.GetUser(userID)
 }
 
-// RemoveUser removes a given user from the system.
+// InviteUser adds a new user with a random password that must be reset before using the platform.
+func (m *Manager) InviteUser(addUserRequest *grpc_user_manager_go.AddUserRequest) (*grpc_user_manager_go.User, error) {
+	password, pErr := generateRandomPassword()
+	if pErr != nil {
+		return nil, conversions.ToGRPCError(pErr)
+	}
+	toAdd := *addUserRequest
+	toAdd.Password = password
+	user, err := m.AddUser(&toAdd)
+	if err != nil {
+		return nil, err
+	}
+	rErr := m.passwordResets.Add(user.OrganizationId, user.Email)
+	if rErr != nil {
+		return nil, conversions.ToGRPCError(rErr)
+	}
+	user.PasswordResetRequired = true
+	return user, nil
+}
+
+// RemoveUser removes a given user from the system. The snapshot of the user is stored in the recycle bin first, and
+// it is discarded if the user cannot be removed.
 func (m *Manager) RemoveUser(userID *grpc_user_go.UserId) error {
 
 	// check if the operation can be done
@@ -97,31 +245,217 @@ func (m *Manager) RemoveUser(userID *grpc_user_go.UserId) error {
 		return conversions.ToGRPCError(vErr)
 	}
 	if !canRemove {
-		return derrors.NewInvalidArgumentError(fmt.Sprintf("can not remove user, last %d user in the system", grpc_authx_go.AccessPrimitive_ORG))
+		return conversions.ToGRPCError(derrors.NewInvalidArgumentError(m.ownerQuorumMessage(userID.OrganizationId, "can not remove user")))
+	}
+	// take the snapshot before removing the user so it can be restored later
+	snapshot, err := m.GetUser(userID)
+	if err != nil {
+		return err
 	}
+
+	// 1. Keep the snapshot in the recycle bin before removing anything, so the user can always be restored
+	previous, gErr := m.recycleBin.Get(userID.OrganizationId, userID.Email)
+	if gErr != nil && gErr.Type() != derrors.NotFound {
+		return conversions.ToGRPCError(gErr)
+	}
+	removedAt := time.Now()
+	removed := entities.NewRemovedUser(snapshot, removedAt.Unix(), removedAt.Add(m.removedUserRetention).Unix())
+	aErr := m.recycleBin.Add(*removed)
+	if aErr != nil {
+		return conversions.ToGRPCError(aErr)
+	}
+
 	// clear userCache
 	_ = m.usersCache.Clear(userID.OrganizationId)
 
-	// 1. Remove user from authx
-	deleteCredentialsRequest := &grpc_authx_go.DeleteCredentialsRequest{
-		Username: userID.Email,
+	// 2. Remove user from system model. It goes first as its record can be restored as it was, unlike the password
+	removeUserRequest := &grpc_user_go.RemoveUserRequest{
+		OrganizationId: userID.OrganizationId,
+		Email:          userID.Email,
 	}
-	_, err := m.accessClient.DeleteCredentials(context.Background(), deleteCredentialsRequest)
+	_, err = m.usersClient.RemoveUser(context.Background(), removeUserRequest)
 	if err != nil {
+		m.rollbackRemovedUser(userID, previous)
 		return err
 	}
-	// 2. Remove user from system model
-	removeUserRequest := &grpc_user_go.RemoveUserRequest{
+	// 3. Remove user from authx
+	deleteCredentialsRequest := &grpc_authx_go.DeleteCredentialsRequest{
 		OrganizationId: userID.OrganizationId,
-		Email:          userID.Email,
+		Username:       userID.Email,
 	}
-	_, err = m.usersClient.RemoveUser(context.Background(), removeUserRequest)
+	_, err = m.accessClient.DeleteCredentials(context.Background(), deleteCredentialsRequest)
 	if err != nil {
+		m.restoreUserRecord(snapshot)
+		m.rollbackRemovedUser(userID, previous)
 		return err
 	}
+	m.emailsCache.Remove(userID.OrganizationId, userID.Email)
+	_ = m.passwordResets.Remove(userID.OrganizationId, userID.Email)
+	_ = m.mfa.Remove(userID.OrganizationId, userID.Email)
+	_ = m.roleGrants.Remove(userID.OrganizationId, userID.Email)
+	m.removeFromGroups(userID.OrganizationId, userID.Email)
+	_ = m.roleBindings.Remove(userID.OrganizationId, userID.Email)
+	m.unlinkIdentity(userID.OrganizationId, userID.Email)
+	_ = m.emailChanges.RemoveChange(userID.OrganizationId, userID.Email)
 	return nil
 }
 
+// restoreUserRecord adds again to system model the record of a user whose credentials could not be removed.
+func (m *Manager) restoreUserRecord(snapshot *grpc_user_manager_go.User) {
+	_, err := m.usersClient.AddUser(context.Background(), &grpc_user_go.AddUserRequest{
+		OrganizationId: snapshot.OrganizationId,
+		Email:          snapshot.Email,
+		Name:           snapshot.Name,
+		PhotoBase64:    snapshot.PhotoBase64,
+		LastName:       snapshot.LastName,
+		Location:       snapshot.Location,
+		Phone:          snapshot.Phone,
+		Title:          snapshot.Title,
+	})
+	if err != nil {
+		log.Error().Str("organizationID", snapshot.OrganizationId).Str("email", snapshot.Email).
+			Str("err", conversions.ToDerror(err).DebugReport()).Msg("cannot restore the user that could not be removed on system model")
+	}
+}
+
+// rollbackRemovedUser discards the snapshot of a user that could not be removed, keeping the previous snapshot of
+// the same email if there was one.
+func (m *Manager) rollbackRemovedUser(userID *grpc_user_go.UserId, previous *entities.RemovedUser) {
+	var err derrors.Error
+	if previous != nil {
+		err = m.recycleBin.Add(*previous)
+	} else {
+		err = m.recycleBin.Remove(userID.OrganizationId, userID.Email)
+	}
+	if err != nil {
+		log.Error().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).
+			Str("err", err.DebugReport()).Msg("cannot rollback the snapshot of the removed user")
+	}
+}
+
+// RestoreUser restores a removed user from the recycle bin. The original password cannot be recovered so the
+// credentials are created with a random password and the user is required to reset it.
+func (m *Manager) RestoreUser(userID *grpc_user_go.UserId) (*grpc_user_manager_go.User, error) {
+	removed, rErr := m.recycleBin.Get(userID.OrganizationId, userID.Email)
+	if rErr != nil {
+		return nil, conversions.ToGRPCError(rErr)
+	}
+	if removed.ExpiresAt <= time.Now().Unix() {
+		_ = m.recycleBin.Remove(userID.OrganizationId, userID.Email)
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("removed user retention has expired").WithParams(userID.OrganizationId, userID.Email))
+	}
+	snapshot := removed.User
+
+	// the email must still be accepted by the organization and not be taken by another user
+	err := m.checkEmailAvailable(snapshot.OrganizationId, snapshot.Email, "")
+	if err != nil {
+		return nil, err
+	}
+	// the role of the user must still exist
+	_, err = m.roleClient.GetRole(context.Background(), &grpc_role_go.RoleId{
+		OrganizationId: snapshot.OrganizationId,
+		RoleId:         snapshot.RoleId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	password, pErr := generateRandomPassword()
+	if pErr != nil {
+		return nil, conversions.ToGRPCError(pErr)
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(userID.OrganizationId)
+
+	// 1. Add the user to system model
+	addRequest := &grpc_user_go.AddUserRequest{
+		OrganizationId: snapshot.OrganizationId,
+		Email:          snapshot.Email,
+		Name:           snapshot.Name,
+		PhotoBase64:    snapshot.PhotoBase64,
+		LastName:       snapshot.LastName,
+		Location:       snapshot.Location,
+		Phone:          snapshot.Phone,
+		Title:          snapshot.Title,
+	}
+	_, err = m.usersClient.AddUser(context.Background(), addRequest)
+	if err != nil {
+		return nil, err
+	}
+	m.emailsCache.Add(snapshot.OrganizationId, snapshot.Email)
+	// 2. Register the credentials on authx
+	addBasicCredentialsRequest := &grpc_authx_go.AddBasicCredentialRequest{
+		OrganizationId: snapshot.OrganizationId,
+		Username:       snapshot.Email,
+		Password:       password,
+		RoleId:         snapshot.RoleId,
+	}
+	_, err = m.accessClient.AddBasicCredentials(context.Background(), addBasicCredentialsRequest)
+	if err != nil {
+		// rollback the user creation on system model
+		_, rbErr := m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+			OrganizationId: snapshot.OrganizationId,
+			Email:          snapshot.Email,
+		})
+		if rbErr != nil {
+			log.Error().Str("organizationID", snapshot.OrganizationId).Str("email", snapshot.Email).
+				Str("err", conversions.ToDerror(rbErr).DebugReport()).Msg("cannot rollback restored user on system model")
+		} else {
+			m.emailsCache.Remove(snapshot.OrganizationId, snapshot.Email)
+		}
+		return nil, err
+	}
+	// 3. Force the password reset and empty the recycle bin entry
+	aErr := m.passwordResets.Add(snapshot.OrganizationId, snapshot.Email)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	_ = m.recycleBin.Remove(userID.OrganizationId, userID.Email)
+	m.linkIdentity(userID.OrganizationId, userID.Email)
+	return m.GetUser(userID)
+}
+
+// ListRemovedUsers retrieves the users of an organization that can be restored.
+func (m *Manager) ListRemovedUsers(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RemovedUserList, error) {
+	removed, err := m.recycleBin.List(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	now := time.Now().Unix()
+	result := make([]*grpc_user_manager_go.RemovedUser, 0)
+	for _, r := range removed {
+		if r.ExpiresAt > now {
+			result = append(result, r.ToGRPC())
+		}
+	}
+	return &grpc_user_manager_go.RemovedUserList{
+		RemovedUsers: result,
+	}, nil
+}
+
+// PurgeRemovedUsers removes from the recycle bin the users whose retention has expired.
+func (m *Manager) PurgeRemovedUsers() derrors.Error {
+	purged, err := m.recycleBin.Purge(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Debug().Int("purged", purged).Msg("removed users purged from the recycle bin")
+	}
+	return nil
+}
+
+// generateRandomPassword creates a random password for credentials that must be reset before use.
+func generateRandomPassword() (string, derrors.Error) {
+	buffer := make([]byte, randomPasswordLength)
+	_, err := rand.Read(buffer)
+	if err != nil {
+		return "", derrors.AsError(err, "cannot generate random password")
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
 func (m *Manager) ListUsers(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.UserList, error) {
 	users, err := m.usersClient.GetUsers(context.Background(), organizationID)
 	if err != nil {
@@ -148,11 +482,24 @@ func (m *Manager) ListUsers(organizationID *grpc_organization_go.OrganizationId)
 func (m *Manager) ChangePassword(request *grpc_user_manager_go.ChangePasswordRequest) error {
 	authxRequest := entities.ToChangePasswordRequest(request)
 	_, err := m.accessClient.ChangePassword(context.Background(), authxRequest)
-	return err
+	if err != nil {
+		return err
+	}
+	_ = m.passwordResets.Remove(request.OrganizationId, request.Email)
+	return nil
 }
 
-// AddRole adds a new role to an organization.
+// AddRole adds a new role to an organization. The role inherits the primitives of its parent roles, if any, and it is
+// added to authx with its effective primitives.
 func (m *Manager) AddRole(addRoleRequest *grpc_user_manager_go.AddRoleRequest) (*grpc_authx_go.Role, error) {
+	err := m.checkParentRoles(addRoleRequest.OrganizationId, addRoleRequest.ParentRoleIds)
+	if err != nil {
+		return nil, err
+	}
+	primitives, err := m.inheritedPrimitives(addRoleRequest.OrganizationId, addRoleRequest.Primitives, addRoleRequest.ParentRoleIds)
+	if err != nil {
+		return nil, err
+	}
 
 	// clear userCache
 	_ = m.usersCache.Clear(addRoleRequest.OrganizationId)
@@ -168,38 +515,404 @@ func (m *Manager) AddRole(addRoleRequest *grpc_user_manager_go.AddRoleRequest) (
 	if err != nil {
 		return nil, err
 	}
-	// 2. Add the role in Authx
+	// 2. Add the role in Authx with its effective primitives
 	toAdd := &grpc_authx_go.Role{
 		OrganizationId: role.OrganizationId,
 		RoleId:         role.RoleId,
 		Name:           role.Name,
 		Internal:       role.Internal,
-		Primitives:     addRoleRequest.Primitives,
+		Primitives:     primitives,
 	}
 	_, err = m.accessClient.AddRole(context.Background(), toAdd)
 	if err != nil {
+		_, rErr := m.roleClient.RemoveRole(context.Background(), &grpc_role_go.RemoveRoleRequest{
+			OrganizationId: role.OrganizationId,
+			RoleId:         role.RoleId,
+		})
+		if rErr != nil {
+			log.Error().Str("organizationID", role.OrganizationId).Str("roleID", role.RoleId).
+				Msg("cannot remove the role from system model after authx failed")
+		}
 		return nil, err
 	}
+	// 3. Add the role to the hierarchy
+	hErr := m.setRoleParents(role.OrganizationId, role.RoleId, addRoleRequest.ParentRoleIds, addRoleRequest.Primitives)
+	if hErr != nil {
+		rErr := m.removeRole(role.OrganizationId, role.RoleId)
+		if rErr != nil {
+			log.Error().Str("organizationID", role.OrganizationId).Str("roleID", role.RoleId).
+				Msg("cannot remove the role after its parents could not be stored")
+		}
+		return nil, conversions.ToGRPCError(hErr)
+	}
 	return toAdd, nil
 }
 
-// RemoveRole removes a role from an organization.
+// UpdateRole updates the name, description, primitives and parents of a role both in system model and authx. Authx
+// receives the effective primitives of the role and of the roles inheriting from it. Removing the ORG primitive from
+// the role or any role inheriting from it is rejected if the organization would be left below its minimum number of
+// owners. The previous parents and roles are restored if authx cannot be updated.
+func (m *Manager) UpdateRole(updateRoleRequest *grpc_user_manager_go.UpdateRoleRequest) (*grpc_authx_go.Role, error) {
+	_, err := m.organizationRole(updateRoleRequest.OrganizationId, updateRoleRequest.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	smRole, err := m.roleClient.GetRole(context.Background(), &grpc_role_go.RoleId{
+		OrganizationId: updateRoleRequest.OrganizationId,
+		RoleId:         updateRoleRequest.RoleId,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{
+		OrganizationId: updateRoleRequest.OrganizationId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	declared, hierarchy, hErr := declaredRoles(m.roleHierarchy, updateRoleRequest.OrganizationId, roles.Roles)
+	if hErr != nil {
+		return nil, conversions.ToGRPCError(hErr)
+	}
+	updatedHierarchy := hierarchy
+	if updateRoleRequest.UpdateParents {
+		updatedHierarchy, err = m.checkRoleParents(hierarchy, updateRoleRequest.OrganizationId, updateRoleRequest.RoleId, updateRoleRequest.ParentRoleIds)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var current, updated *grpc_authx_go.Role
+	updatedRoles := make([]*grpc_authx_go.Role, 0, len(declared))
+	for _, role := range declared {
+		if role.RoleId == updateRoleRequest.RoleId {
+			current = role
+			replaced := *role
+			if updateRoleRequest.UpdateName {
+				replaced.Name = updateRoleRequest.Name
+			}
+			if updateRoleRequest.UpdatePrimitives {
+				replaced.Primitives = updateRoleRequest.Primitives
+			}
+			role = &replaced
+			updated = role
+		}
+		updatedRoles = append(updatedRoles, role)
+	}
+	if current == nil {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(updateRoleRequest.OrganizationId, updateRoleRequest.RoleId))
+	}
+	// Removing the ORG primitive from a role also removes it from the roles that inherit it.
+	revoked := revokedOwnerRoles(declared, hierarchy, updatedRoles, updatedHierarchy)
+	if len(revoked) > 0 {
+		canRevoke, cErr := m.usersCache.CanRevokeOwnerRole(updateRoleRequest.OrganizationId, revoked...)
+		if cErr != nil {
+			return nil, conversions.ToGRPCError(cErr)
+		}
+		if !canRevoke {
+			return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError(m.ownerQuorumMessage(updateRoleRequest.OrganizationId,
+				fmt.Sprintf("can not remove %s primitive", grpc_authx_go.AccessPrimitive_ORG))).WithParams(updateRoleRequest.RoleId, revoked))
+		}
+	}
+	previousRoles := hierarchy.EffectiveRoles(declared)
+	toUpdate := changedRoles(previousRoles, updatedHierarchy.EffectiveRoles(updatedRoles), updateRoleRequest.RoleId)
+
+	// clear userCache
+	_ = m.usersCache.Clear(updateRoleRequest.OrganizationId)
+	defer m.usersCache.Clear(updateRoleRequest.OrganizationId)
+	defer m.rolesCache.Clear(updateRoleRequest.OrganizationId)
+
+	// restoreParents restores the previous parents and declared primitives of the role if the update fails
+	declarationUpdated := updateRoleRequest.UpdateParents || updateRoleRequest.UpdatePrimitives
+	restoreParents := func() {
+		if declarationUpdated {
+			rErr := m.setRoleParents(updateRoleRequest.OrganizationId, updateRoleRequest.RoleId, hierarchy[updateRoleRequest.RoleId], current.Primitives)
+			if rErr != nil {
+				log.Error().Str("organizationID", updateRoleRequest.OrganizationId).Str("roleID", updateRoleRequest.RoleId).
+					Msg("cannot restore the parents of the role after the update failed")
+			}
+		}
+	}
+	// 1. Update the parents and the declared primitives of the role
+	if declarationUpdated {
+		sErr := m.setRoleParents(updateRoleRequest.OrganizationId, updateRoleRequest.RoleId, updatedHierarchy[updateRoleRequest.RoleId], updated.Primitives)
+		if sErr != nil {
+			return nil, conversions.ToGRPCError(sErr)
+		}
+	}
+	// 2. Update the role in SM
+	smUpdated := updateRoleRequest.UpdateName || updateRoleRequest.UpdateDescription
+	if smUpdated {
+		_, err = m.roleClient.UpdateRole(context.Background(), &grpc_role_go.UpdateRoleRequest{
+			OrganizationId:    updateRoleRequest.OrganizationId,
+			RoleId:            updateRoleRequest.RoleId,
+			UpdateName:        updateRoleRequest.UpdateName,
+			Name:              updateRoleRequest.Name,
+			UpdateDescription: updateRoleRequest.UpdateDescription,
+			Description:       updateRoleRequest.Description,
+		})
+		if err != nil {
+			restoreParents()
+			return nil, err
+		}
+	}
+	// 3. Update the role and the roles inheriting from it in Authx
+	err = m.updateAuthxRoles(toUpdate, previousRoles)
+	if err != nil {
+		if smUpdated {
+			_, rErr := m.roleClient.UpdateRole(context.Background(), &grpc_role_go.UpdateRoleRequest{
+				OrganizationId:    smRole.OrganizationId,
+				RoleId:            smRole.RoleId,
+				UpdateName:        true,
+				Name:              smRole.Name,
+				UpdateDescription: true,
+				Description:       smRole.Description,
+			})
+			if rErr != nil {
+				log.Error().Str("organizationID", smRole.OrganizationId).Str("roleID", smRole.RoleId).
+					Msg("cannot restore the role in system model after authx failed")
+			}
+		}
+		restoreParents()
+		return nil, err
+	}
+	log.Debug().Str("organizationID", updateRoleRequest.OrganizationId).Str("roleID", updateRoleRequest.RoleId).Msg("role has been updated")
+	return toUpdate[0], nil
+}
+
+// RemoveRole removes a role from an organization. Roles assigned to users, service accounts or groups cannot be removed, nor
+// parents of other roles or owner roles whose removal would leave the organization below its minimum number of owners.
 func (m *Manager) RemoveRole(roleID *grpc_authx_go.RoleId) error {
+	_, err := m.organizationRole(roleID.OrganizationId, roleID.RoleId)
+	if err != nil {
+		return err
+	}
+	hierarchy, hErr := loadRoleHierarchy(m.roleHierarchy, roleID.OrganizationId)
+	if hErr != nil {
+		return conversions.ToGRPCError(hErr)
+	}
+	children := hierarchy.Children(roleID.RoleId)
+	if len(children) > 0 {
+		return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is the parent of other roles").WithParams(roleID.RoleId, children))
+	}
+	canRevoke, cErr := m.usersCache.CanRevokeOwnerRole(roleID.OrganizationId, roleID.RoleId)
+	if cErr != nil {
+		return conversions.ToGRPCError(cErr)
+	}
+	if !canRevoke {
+		return conversions.ToGRPCError(derrors.NewFailedPreconditionError(m.ownerQuorumMessage(roleID.OrganizationId, "can not remove role")).WithParams(roleID.RoleId))
+	}
 	// 1. Check if users with the role exists
-	// 2. Remove role from SM
-	// 3. Remove role from authx
-	panic("RemoveRole")
+	users, err := m.ListUsers(&grpc_organization_go.OrganizationId{OrganizationId: roleID.OrganizationId})
+	if err != nil {
+		return err
+	}
+	for _, user := range users.Users {
+		if user.RoleId == roleID.RoleId {
+			return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is assigned to users").WithParams(roleID.RoleId, user.Email))
+		}
+	}
+	bindings, bErr := m.roleBindings.List(roleID.OrganizationId)
+	if bErr != nil {
+		return conversions.ToGRPCError(bErr)
+	}
+	for _, binding := range bindings {
+		if binding.Has(roleID.RoleId) {
+			return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is assigned to users").WithParams(roleID.RoleId, binding.Email))
+		}
+	}
+	accounts, aErr := m.serviceAccounts.ListServiceAccounts(roleID.OrganizationId)
+	if aErr != nil {
+		return conversions.ToGRPCError(aErr)
+	}
+	for _, account := range accounts {
+		if account.RoleId == roleID.RoleId {
+			return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is assigned to service accounts").WithParams(roleID.RoleId, account.ServiceAccountId))
+		}
+	}
+	groups, gErr := m.groups.ListGroups(roleID.OrganizationId)
+	if gErr != nil {
+		return conversions.ToGRPCError(gErr)
+	}
+	for _, group := range groups {
+		if group.RoleId == roleID.RoleId {
+			return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is bound to groups").WithParams(roleID.RoleId, group.GroupId))
+		}
+	}
+	grants, gErr := m.roleGrants.List(roleID.OrganizationId)
+	if gErr != nil {
+		return conversions.ToGRPCError(gErr)
+	}
+	for _, grant := range grants {
+		if grant.PreviousRoleId == roleID.RoleId {
+			return conversions.ToGRPCError(derrors.NewFailedPreconditionError("the role is restored when a temporary grant expires").WithParams(roleID.RoleId, grant.Email))
+		}
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(roleID.OrganizationId)
+	defer m.usersCache.Clear(roleID.OrganizationId)
+
+	// 2. Remove role from authx, SM and the hierarchy
+	err = m.removeRole(roleID.OrganizationId, roleID.RoleId)
+	if err != nil {
+		return err
+	}
+	sErr := m.setRoleParents(roleID.OrganizationId, roleID.RoleId, nil, nil)
+	if sErr != nil {
+		return conversions.ToGRPCError(sErr)
+	}
+	log.Debug().Str("organizationID", roleID.OrganizationId).Str("roleID", roleID.RoleId).Msg("role has been removed")
+	return nil
 }
 
-// AssignRole assigns a role to an existing user.
+// AssignRole assigns a role to an existing user. The REPLACE operation changes the primary role of the user, while ADD
+// and REMOVE manage the additional roles whose primitives are merged with the ones of the primary role. If a
+// replacement has an expiration the role is granted temporarily and the user is reverted to the current role once it
+// expires. Assigning a new temporary role while a grant is active keeps the role the user had before the first grant,
+// and a permanent assignment cancels the active grant.
 func (m *Manager) AssignRole(assignRoleRequest *grpc_user_manager_go.AssignRoleRequest) (*grpc_user_manager_go.User, error) {
+	switch assignRoleRequest.Operation {
+	case grpc_user_manager_go.AssignRoleOperation_ADD:
+		return m.addUserRole(assignRoleRequest)
+	case grpc_user_manager_go.AssignRoleOperation_REMOVE:
+		return m.removeUserRole(assignRoleRequest)
+	}
+	now := time.Now().Unix()
+	if assignRoleRequest.ExpiresAt != 0 && assignRoleRequest.ExpiresAt <= now {
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError("expires_at must be in the future"))
+	}
+	active, gErr := m.roleGrants.Get(assignRoleRequest.OrganizationId, assignRoleRequest.Email)
+	if gErr != nil {
+		if gErr.Type() != derrors.NotFound {
+			return nil, conversions.ToGRPCError(gErr)
+		}
+		active = nil
+	}
+	previousRoleID := ""
+	if assignRoleRequest.ExpiresAt != 0 {
+		if active != nil {
+			previousRoleID = active.PreviousRoleId
+		} else {
+			info, err := m.accessClient.GetUserAuthxInfo(context.Background(), &grpc_user_go.UserId{
+				OrganizationId: assignRoleRequest.OrganizationId,
+				Email:          assignRoleRequest.Email,
+			})
+			if err != nil {
+				return nil, err
+			}
+			previousRoleID = info.RoleId
+		}
+	}
+
+	user, err := m.assignRole(assignRoleRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if assignRoleRequest.ExpiresAt != 0 {
+		grant := entities.NewRoleGrant(assignRoleRequest, previousRoleID, now)
+		sErr := m.roleGrants.Set(*grant)
+		if sErr != nil {
+			return nil, conversions.ToGRPCError(sErr)
+		}
+		m.audit(entities.NewAuditEntry(grant.OrganizationId, entities.AuditRoleGranted, grant.Email,
+			"role %s granted until %d, previous role %s", grant.RoleId, grant.ExpiresAt, grant.PreviousRoleId))
+	} else if active != nil {
+		m.replaceRoleGrant(active, assignRoleRequest.RoleId)
+	}
+	return user, nil
+}
+
+// replaceRoleGrant removes an active grant whose role has been permanently replaced, so it is not reverted later.
+func (m *Manager) replaceRoleGrant(active *entities.RoleGrant, roleID string) {
+	_ = m.roleGrants.Remove(active.OrganizationId, active.Email)
+	m.audit(entities.NewAuditEntry(active.OrganizationId, entities.AuditRoleGrantReplaced, active.Email,
+		"temporary role %s replaced by role %s", active.RoleId, roleID))
+}
+
+// ListRoleGrants lists the active temporary role assignments of an organization.
+func (m *Manager) ListRoleGrants(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RoleGrantList, error) {
+	grants, err := m.roleGrants.List(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	result := make([]*grpc_user_manager_go.RoleGrant, 0, len(grants))
+	for _, grant := range grants {
+		result = append(result, grant.ToGRPC())
+	}
+	return &grpc_user_manager_go.RoleGrantList{RoleGrants: result}, nil
+}
+
+// RevertExpiredRoleGrants reverts the users whose temporary role has expired to their previous role. Reverts go through
+// the same checks as any other assignment, so a revert that would leave the organization without owners is retried
+// later. Grants whose user or previous role no longer exist are discarded.
+func (m *Manager) RevertExpiredRoleGrants() derrors.Error {
+	grants, err := m.roleGrants.ListExpired(time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	for _, grant := range grants {
+		_, aErr := m.assignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: grant.OrganizationId,
+			Email:          grant.Email,
+			RoleId:         grant.PreviousRoleId,
+		})
+		if aErr != nil {
+			dErr := conversions.ToDerror(aErr)
+			log.Warn().Str("organizationID", grant.OrganizationId).Str("email", grant.Email).
+				Str("err", dErr.DebugReport()).Msg("cannot revert temporary role")
+			if dErr.Type() == derrors.NotFound {
+				_ = m.roleGrants.Remove(grant.OrganizationId, grant.Email)
+				m.audit(entities.NewAuditEntry(grant.OrganizationId, entities.AuditRoleRevertFailed, grant.Email,
+					"cannot revert role %s to %s, grant discarded: %s", grant.RoleId, grant.PreviousRoleId, dErr.Error()))
+			} else if grant.RevertError != dErr.Error() {
+				grant.RevertError = dErr.Error()
+				_ = m.roleGrants.Set(grant)
+				m.audit(entities.NewAuditEntry(grant.OrganizationId, entities.AuditRoleRevertFailed, grant.Email,
+					"cannot revert role %s to %s, retrying: %s", grant.RoleId, grant.PreviousRoleId, dErr.Error()))
+			}
+			continue
+		}
+		_ = m.roleGrants.Remove(grant.OrganizationId, grant.Email)
+		m.audit(entities.NewAuditEntry(grant.OrganizationId, entities.AuditRoleReverted, grant.Email,
+			"temporary role %s expired, reverted to %s", grant.RoleId, grant.PreviousRoleId))
+	}
+	return nil
+}
+
+// ListAuditEntries lists the audit log of an organization.
+func (m *Manager) ListAuditEntries(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.AuditEntryList, error) {
+	entries, err := m.auditLog.List(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	result := make([]*grpc_user_manager_go.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry.ToGRPC())
+	}
+	return &grpc_user_manager_go.AuditEntryList{AuditEntries: result}, nil
+}
+
+// audit adds an entry to the audit log. Failures are logged but do not fail the operation.
+func (m *Manager) audit(entry *entities.AuditEntry) {
+	log.Info().Str("organizationID", entry.OrganizationId).Str("action", entry.Action).Str("email", entry.Email).
+		Msg(entry.Details)
+	err := m.auditLog.Add(*entry)
+	if err != nil {
+		log.Warn().Str("err", err.DebugReport()).Msg("cannot add audit entry")
+	}
+}
+
+// assignRole changes the role of a user checking that the organization keeps at least one owner.
+func (m *Manager) assignRole(assignRoleRequest *grpc_user_manager_go.AssignRoleRequest) (*grpc_user_manager_go.User, error) {
 
 	canAssign, err := m.usersCache.CanAssignRole(assignRoleRequest)
 	if err != nil {
 		return nil, conversions.ToGRPCError(err)
 	}
 	if !canAssign {
-		return nil, conversions.ToDerror(derrors.NewInvalidArgumentError(fmt.Sprintf("can not assign role, last %d user in the system", grpc_authx_go.AccessPrimitive_ORG)))
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError(m.ownerQuorumMessage(assignRoleRequest.OrganizationId, "can not assign role")))
 	}
 
 	// clear userCache
@@ -207,13 +920,16 @@ func (m *Manager) AssignRole(assignRoleRequest *grpc_user_manager_go.AssignRoleR
 
 	// 1. Update on authx
 	editRequest := &grpc_authx_go.EditUserRoleRequest{
-		Username:  assignRoleRequest.Email,
-		NewRoleId: assignRoleRequest.RoleId,
+		OrganizationId: assignRoleRequest.OrganizationId,
+		Username:       assignRoleRequest.Email,
+		NewRoleId:      assignRoleRequest.RoleId,
 	}
 	_, eErr := m.accessClient.EditUserRole(context.Background(), editRequest)
 	if eErr != nil {
 		return nil, eErr
 	}
+	// the new primary role is no longer an additional one
+	m.dropUserRole(assignRoleRequest.OrganizationId, assignRoleRequest.Email, assignRoleRequest.RoleId)
 	userID := &grpc_user_go.UserId{
 		OrganizationId: assignRoleRequest.OrganizationId,
 		Email:          assignRoleRequest.Email,
@@ -221,7 +937,8 @@ func (m *Manager) AssignRole(assignRoleRequest *grpc_user_manager_go.AssignRoleR
 	return m.GetUser(userID)
 }
 
-// GetUser retrieves the information of a user including role information.
+// GetUser retrieves the information of a user including role information, all the roles assigned to it with the
+// primary one first, its groups and the effective role it acts with.
 func (m *Manager) GetUser(userID *grpc_user_go.UserId) (*grpc_user_manager_go.User, error) {
 	smUser, err := m.usersClient.GetUser(context.Background(), userID)
 	if err != nil {
@@ -238,29 +955,129 @@ func (m *Manager) GetUser(userID *grpc_user_go.UserId) (*grpc_user_manager_go.Us
 	if err != nil {
 		return nil, err
 	}
+	resetRequired, rErr := m.passwordResets.Exists(userID.OrganizationId, userID.Email)
+	if rErr != nil {
+		return nil, conversions.ToGRPCError(rErr)
+	}
+	mfaEnabled, mfaRequired, mErr := m.mfaStatus(userID)
+	if mErr != nil {
+		return nil, mErr
+	}
+	groups, gErr := m.userGroups(userID.OrganizationId, userID.Email)
+	if gErr != nil {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.GroupId)
+	}
+	roles, err := m.assignedRoles(userID.OrganizationId, userID.Email, authxUserInfo.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	effective, err := m.effectiveUserRole(userID.OrganizationId, userID.Email, authxUserInfo.RoleId)
+	if err != nil {
+		return nil, err
+	}
 
 	return &grpc_user_manager_go.User{
-		OrganizationId: smUser.OrganizationId,
-		Email:          smUser.Email,
-		Name:           smUser.Name,
-		PhotoBase64:    smUser.PhotoBase64,
-		MemberSince:    smUser.MemberSince,
-		RoleId:         authxUserInfo.RoleId,
-		RoleName:       role.Name,
-		InternalRole:   authxUserInfo.InternalRole,
-		LastName:       smUser.LastName,
-		Title:          smUser.Title,
-		LastLogin:      authxUserInfo.LastLogin,
-		Phone:          smUser.Phone,
-		Location:       smUser.Location,
+		OrganizationId:        smUser.OrganizationId,
+		Email:                 smUser.Email,
+		Name:                  smUser.Name,
+		PhotoBase64:           smUser.PhotoBase64,
+		MemberSince:           smUser.MemberSince,
+		RoleId:                authxUserInfo.RoleId,
+		RoleName:              role.Name,
+		InternalRole:          authxUserInfo.InternalRole,
+		LastName:              smUser.LastName,
+		Title:                 smUser.Title,
+		LastLogin:             authxUserInfo.LastLogin,
+		Phone:                 smUser.Phone,
+		Location:              smUser.Location,
+		PasswordResetRequired: resetRequired,
+		MfaEnabled:            mfaEnabled,
+		MfaRequired:           mfaRequired,
+		GroupIds:              groupIDs,
+		EffectiveRoleId:       effective.Role.RoleId,
+		EffectiveRoleName:     effective.Role.Name,
+		Roles:                 userRoles(roles),
 	}, nil
 }
 
-// ListRoles obtains a list of roles in an organization.
+// ListRoles obtains a list of roles in an organization with their effective primitives, including the ones inherited
+// from their parent roles.
 func (m *Manager) ListRoles(organizationID *grpc_organization_go.OrganizationId) (*grpc_authx_go.RoleList, error) {
-	return m.accessClient.ListRoles(context.Background(), organizationID)
+	roles, err := m.accessClient.ListRoles(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	effective, hErr := effectiveRoles(m.roleHierarchy, organizationID.OrganizationId, roles.Roles)
+	if hErr != nil {
+		return nil, conversions.ToGRPCError(hErr)
+	}
+	roles.Roles = effective
+	return roles, nil
 }
 
+// UpdateUser updates the profile of a user. If the identity of the user propagates its profile, the update is applied
+// to the accounts of the other organizations as well.
 func (m *Manager) UpdateUser(updateUserRequest *grpc_user_go.UpdateUserRequest) (*grpc_common_go.Success, error) {
-	return m.usersClient.Update(context.Background(), updateUserRequest)
+	success, err := m.usersClient.Update(context.Background(), updateUserRequest)
+	if err != nil {
+		return nil, err
+	}
+	m.propagateProfile(updateUserRequest)
+	return success, nil
+}
+
+// organizationRole retrieves a role of an organization from authx. Internal roles cannot be used.
+func (m *Manager) organizationRole(organizationID string, roleID string) (*grpc_authx_go.Role, error) {
+	roles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range roles.Roles {
+		if role.RoleId == roleID {
+			if role.Internal {
+				return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("internal roles cannot be used").WithParams(roleID))
+			}
+			return role, nil
+		}
+	}
+	return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(organizationID, roleID))
+}
+
+// removeRole removes a role both from authx and system model.
+func (m *Manager) removeRole(organizationID string, roleID string) error {
+	defer m.rolesCache.Clear(organizationID)
+	_, err := m.accessClient.RemoveRole(context.Background(), &grpc_authx_go.RoleId{
+		OrganizationId: organizationID,
+		RoleId:         roleID,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.roleClient.RemoveRole(context.Background(), &grpc_role_go.RemoveRoleRequest{
+		OrganizationId: organizationID,
+		RoleId:         roleID,
+	})
+	return err
+}
+
+// ignoreNotFound discards the error returned when the element to remove does not exist.
+func ignoreNotFound(err error) error {
+	if err != nil && conversions.ToDerror(err).Type() == derrors.NotFound {
+		return nil
+	}
+	return err
+}
+
+// hasPrimitive checks if a role includes a given primitive.
+func hasPrimitive(role *grpc_authx_go.Role, primitive grpc_authx_go.AccessPrimitive) bool {
+	for _, current := range role.Primitives {
+		if current == primitive {
+			return true
+		}
+	}
+	return false
 }
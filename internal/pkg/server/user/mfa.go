@@ -0,0 +1,309 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// mfaUpdateRetries with the number of times an attempt is counted again when the enrollment changes concurrently.
+const mfaUpdateRetries = 3
+
+// BeginMFAEnrollment generates a TOTP secret for a user. MFA is not enabled until the enrollment is confirmed with a
+// valid code. A pending enrollment is replaced.
+func (m *Manager) BeginMFAEnrollment(userID *grpc_user_go.UserId) (*grpc_user_manager_go.MFAEnrollment, error) {
+	if m.mfaCipher == nil {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is not configured"))
+	}
+	_, err := m.usersClient.GetUser(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+	current, gErr := m.mfa.Get(userID.OrganizationId, userID.Email)
+	if gErr == nil && current.Confirmed {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("mfa is already enabled").WithParams(userID.OrganizationId, userID.Email))
+	}
+	secret, sErr := entities.NewTOTPSecret()
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	encrypted, eErr := m.mfaCipher.Encrypt(secret)
+	if eErr != nil {
+		return nil, conversions.ToGRPCError(eErr)
+	}
+	aErr := m.mfa.Set(*entities.NewMFA(userID.OrganizationId, userID.Email, encrypted))
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("mfa enrollment started")
+	return &grpc_user_manager_go.MFAEnrollment{
+		OrganizationId: userID.OrganizationId,
+		Email:          userID.Email,
+		Secret:         secret,
+		Uri:            entities.TOTPURI(entities.MFAIssuer, userID.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment enables MFA for a user once a valid code is provided, returning the initial recovery codes.
+// Confirmations count as attempts in the same way as verifications.
+func (m *Manager) ConfirmMFAEnrollment(request *grpc_user_manager_go.MFACodeRequest) (*grpc_user_manager_go.RecoveryCodes, error) {
+	enrollment, secret, err := m.reserveMFAAttempt(request.OrganizationId, request.Email, false)
+	if err != nil {
+		return nil, err
+	}
+	step, valid := enrollment.VerifyCode(secret, request.Code, time.Now().Unix())
+	if !valid {
+		log.Warn().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+			Int32("failedAttempts", enrollment.FailedAttempts).Msg("invalid mfa code")
+		return nil, conversions.ToGRPCError(derrors.NewUnauthenticatedError("invalid mfa code"))
+	}
+	codes, hashes, cErr := entities.NewRecoveryCodes()
+	if cErr != nil {
+		return nil, conversions.ToGRPCError(cErr)
+	}
+	updated := *enrollment
+	updated.Confirmed = true
+	updated.LastTimeStep = step
+	updated.RecoveryCodes = hashes
+	updated.ResetAttempts()
+	sErr := m.mfa.CompareAndSet(*enrollment, updated)
+	if sErr != nil {
+		if sErr.Type() == derrors.FailedPrecondition {
+			// a concurrent confirmation has changed the enrollment, the code may have been accepted already
+			return nil, conversions.ToGRPCError(derrors.NewUnauthenticatedError("invalid mfa code"))
+		}
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	log.Debug().Str("organizationID", request.OrganizationId).Str("email", request.Email).Msg("mfa enabled")
+	return &grpc_user_manager_go.RecoveryCodes{
+		OrganizationId: request.OrganizationId,
+		Email:          request.Email,
+		Codes:          codes,
+	}, nil
+}
+
+// VerifyMFACode checks a TOTP code or consumes a recovery code of a user with MFA enabled. Each verification counts
+// as an attempt before the code is checked, and the MFA of the user is locked for a while after too many failed
+// attempts.
+func (m *Manager) VerifyMFACode(request *grpc_user_manager_go.MFACodeRequest) error {
+	enrollment, secret, err := m.reserveMFAAttempt(request.OrganizationId, request.Email, true)
+	if err != nil {
+		return err
+	}
+	updated := *enrollment
+	if step, valid := updated.VerifyCode(secret, request.Code, time.Now().Unix()); valid {
+		updated.LastTimeStep = step
+	} else if updated.UseRecoveryCode(request.Code) {
+		log.Info().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+			Int("remaining", len(updated.RecoveryCodes)).Msg("recovery code used")
+	} else {
+		log.Warn().Str("organizationID", request.OrganizationId).Str("email", request.Email).
+			Int32("failedAttempts", updated.FailedAttempts).Msg("invalid mfa code")
+		return conversions.ToGRPCError(derrors.NewUnauthenticatedError("invalid mfa code"))
+	}
+	updated.ResetAttempts()
+	sErr := m.mfa.CompareAndSet(*enrollment, updated)
+	if sErr != nil {
+		if sErr.Type() == derrors.FailedPrecondition {
+			// a concurrent verification has changed the enrollment, the code may have been accepted already
+			return conversions.ToGRPCError(derrors.NewUnauthenticatedError("invalid mfa code"))
+		}
+		return conversions.ToGRPCError(sErr)
+	}
+	return nil
+}
+
+// reserveMFAAttempt counts a verification attempt of a user whose enrollment is confirmed or pending as expected,
+// returning the stored enrollment with the attempt and the decrypted secret. The attempt is stored before the code is
+// checked so concurrent requests cannot exceed the maximum number of attempts.
+func (m *Manager) reserveMFAAttempt(organizationID string, email string, confirmed bool) (*entities.MFA, string, error) {
+	for retry := 0; retry < mfaUpdateRetries; retry++ {
+		enrollment, secret, err := m.getMFA(organizationID, email)
+		if err != nil {
+			return nil, "", err
+		}
+		if enrollment.Confirmed && !confirmed {
+			return nil, "", conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is already enabled").WithParams(organizationID, email))
+		}
+		if !enrollment.Confirmed && confirmed {
+			return nil, "", conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is not enabled").WithParams(organizationID, email))
+		}
+		now := time.Now().Unix()
+		if enrollment.IsLocked(now) {
+			return nil, "", conversions.ToGRPCError(derrors.NewPermissionDeniedError("too many failed mfa attempts").
+				WithParams(organizationID, email, time.Unix(enrollment.LockedUntil, 0).Format(time.RFC3339)))
+		}
+		reserved := *enrollment
+		reserved.RegisterAttempt(now)
+		sErr := m.mfa.CompareAndSet(*enrollment, reserved)
+		if sErr == nil {
+			return &reserved, secret, nil
+		}
+		if sErr.Type() != derrors.FailedPrecondition {
+			return nil, "", conversions.ToGRPCError(sErr)
+		}
+	}
+	return nil, "", conversions.ToGRPCError(derrors.NewUnavailableError("mfa enrollment is being verified concurrently").WithParams(organizationID, email))
+}
+
+// DisableMFA removes the enrollment of a user. It is rejected if the MFA policy of the organization requires MFA for
+// the role of the user.
+func (m *Manager) DisableMFA(userID *grpc_user_go.UserId) error {
+	exists, err := m.mfa.Exists(userID.OrganizationId, userID.Email)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	if !exists {
+		return conversions.ToGRPCError(derrors.NewNotFoundError("mfa enrollment").WithParams(userID.OrganizationId, userID.Email))
+	}
+	required, rErr := m.mfaRequired(userID)
+	if rErr != nil {
+		return rErr
+	}
+	if required {
+		return conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is required by the policy of the organization").WithParams(userID.OrganizationId, userID.Email))
+	}
+	err = m.mfa.Remove(userID.OrganizationId, userID.Email)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	log.Debug().Str("organizationID", userID.OrganizationId).Str("email", userID.Email).Msg("mfa disabled")
+	return nil
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of a user with MFA enabled. The codes are only replaced if the
+// enrollment has not changed since it was read, so a concurrent verification is not lost.
+func (m *Manager) GenerateRecoveryCodes(userID *grpc_user_go.UserId) (*grpc_user_manager_go.RecoveryCodes, error) {
+	for retry := 0; retry < mfaUpdateRetries; retry++ {
+		enrollment, err := m.mfa.Get(userID.OrganizationId, userID.Email)
+		if err != nil {
+			return nil, conversions.ToGRPCError(err)
+		}
+		if !enrollment.Confirmed {
+			return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is not enabled").WithParams(userID.OrganizationId, userID.Email))
+		}
+		codes, hashes, err := entities.NewRecoveryCodes()
+		if err != nil {
+			return nil, conversions.ToGRPCError(err)
+		}
+		updated := *enrollment
+		updated.RecoveryCodes = hashes
+		err = m.mfa.CompareAndSet(*enrollment, updated)
+		if err == nil {
+			return &grpc_user_manager_go.RecoveryCodes{
+				OrganizationId: userID.OrganizationId,
+				Email:          userID.Email,
+				Codes:          codes,
+			}, nil
+		}
+		if err.Type() != derrors.FailedPrecondition {
+			return nil, conversions.ToGRPCError(err)
+		}
+	}
+	return nil, conversions.ToGRPCError(derrors.NewUnavailableError("mfa enrollment is being verified concurrently").WithParams(userID.OrganizationId, userID.Email))
+}
+
+// SetMFAPolicy sets the MFA policy of an organization.
+func (m *Manager) SetMFAPolicy(policy *grpc_user_manager_go.MFAPolicy) error {
+	err := m.mfa.SetPolicy(*entities.NewMFAPolicy(policy))
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	log.Debug().Str("organizationID", policy.OrganizationId).Bool("requireForOwners", policy.RequireForOwners).
+		Msg("mfa policy has been set")
+	return nil
+}
+
+// GetMFAPolicy retrieves the MFA policy of an organization. Organizations without a policy do not require MFA.
+func (m *Manager) GetMFAPolicy(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.MFAPolicy, error) {
+	policy, err := m.mfaPolicy(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return policy.ToGRPC(), nil
+}
+
+// mfaStatus obtains whether a user has MFA enabled and whether it is required for the user.
+func (m *Manager) mfaStatus(userID *grpc_user_go.UserId) (bool, bool, error) {
+	enrollment, err := m.mfa.Get(userID.OrganizationId, userID.Email)
+	if err != nil && err.Type() != derrors.NotFound {
+		return false, false, conversions.ToGRPCError(err)
+	}
+	enabled := err == nil && enrollment.Confirmed
+	required, rErr := m.mfaRequired(userID)
+	if rErr != nil {
+		return false, false, rErr
+	}
+	return enabled, required, nil
+}
+
+// mfaRequired checks if the MFA policy of the organization requires MFA for the role of a user.
+func (m *Manager) mfaRequired(userID *grpc_user_go.UserId) (bool, error) {
+	policy, err := m.mfaPolicy(userID.OrganizationId)
+	if err != nil {
+		return false, conversions.ToGRPCError(err)
+	}
+	if !policy.RequireForOwners {
+		return false, nil
+	}
+	role, rErr := m.accessClient.GetUserRole(context.Background(), userID)
+	if rErr != nil {
+		return false, rErr
+	}
+	effective, rErr := m.effectiveUserRole(userID.OrganizationId, userID.Email, role.RoleId)
+	if rErr != nil {
+		return false, rErr
+	}
+	return hasPrimitive(effective.Role, grpc_authx_go.AccessPrimitive_ORG), nil
+}
+
+// mfaPolicy retrieves the MFA policy of an organization returning the default one if it is not set.
+func (m *Manager) mfaPolicy(organizationID string) (*entities.MFAPolicy, derrors.Error) {
+	policy, err := m.mfa.GetPolicy(organizationID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return &entities.MFAPolicy{OrganizationId: organizationID}, nil
+		}
+		return nil, err
+	}
+	return policy, nil
+}
+
+// getMFA retrieves the enrollment of a user and its decrypted secret.
+func (m *Manager) getMFA(organizationID string, email string) (*entities.MFA, string, error) {
+	if m.mfaCipher == nil {
+		return nil, "", conversions.ToGRPCError(derrors.NewFailedPreconditionError("mfa is not configured"))
+	}
+	enrollment, err := m.mfa.Get(organizationID, email)
+	if err != nil {
+		return nil, "", conversions.ToGRPCError(err)
+	}
+	secret, err := m.mfaCipher.Decrypt(enrollment.EncryptedSecret)
+	if err != nil {
+		return nil, "", conversions.ToGRPCError(err)
+	}
+	return enrollment, secret, nil
+}
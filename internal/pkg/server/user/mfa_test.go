@@ -0,0 +1,233 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/mfa"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("MFA", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var enrollments *mfa.MockupMFAProvider
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addUser := func(email string, primitive grpc_authx_go.AccessPrimitive) {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           email,
+			Description:    email,
+			Primitives:     []grpc_authx_go.AccessPrimitive{primitive},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         role.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	userID := func(email string) *grpc_user_go.UserId {
+		return &grpc_user_go.UserId{OrganizationId: organizationID, Email: email}
+	}
+
+	codeRequest := func(email string, code string) *grpc_user_manager_go.MFACodeRequest {
+		return &grpc_user_manager_go.MFACodeRequest{OrganizationId: organizationID, Email: email, Code: code}
+	}
+
+	currentCode := func(secret string) string {
+		code, err := entities.TOTPCode(secret, time.Now().Unix()/entities.TOTPPeriod)
+		gomega.Expect(err).To(gomega.Succeed())
+		return code
+	}
+
+	enroll := func(email string) (string, []string) {
+		enrollment, err := manager.BeginMFAEnrollment(userID(email))
+		gomega.Expect(err).To(gomega.Succeed())
+		codes, err := manager.ConfirmMFAEnrollment(codeRequest(email, currentCode(enrollment.Secret)))
+		gomega.Expect(err).To(gomega.Succeed())
+		return enrollment.Secret, codes.Codes
+	}
+
+	ginkgo.BeforeEach(func() {
+		cipher, err := entities.NewSecretCipher("test-key")
+		gomega.Expect(err).To(gomega.Succeed())
+		enrollments = mfa.NewMockupMFAProvider()
+		providers := NewMockupProviders()
+		providers.MFA = enrollments
+		settings := testSettings()
+		settings.MfaCipher = cipher
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			providers, settings)
+		addUser(ownerEmail, grpc_authx_go.AccessPrimitive_ORG)
+		addUser(memberEmail, grpc_authx_go.AccessPrimitive_PROFILE)
+	})
+
+	ginkgo.It("should generate RFC 6238 codes", func() {
+		// SHA-1 test vector of RFC 6238 at T = 59 with the secret "12345678901234567890"
+		code, err := entities.TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", 59/entities.TOTPPeriod)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(code).To(gomega.Equal("287082"))
+	})
+
+	ginkgo.It("should enroll a user and store the secret encrypted", func() {
+		enrollment, err := manager.BeginMFAEnrollment(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(enrollment.Uri).To(gomega.HavePrefix("otpauth://totp/"))
+		gomega.Expect(enrollment.Uri).To(gomega.ContainSubstring(enrollment.Secret))
+
+		stored, err := enrollments.Get(organizationID, memberEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(stored.EncryptedSecret).ShouldNot(gomega.ContainSubstring(enrollment.Secret))
+
+		user, err := manager.GetUser(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.MfaEnabled).To(gomega.BeFalse())
+
+		_, err = manager.ConfirmMFAEnrollment(codeRequest(memberEmail, "000000"))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+		codes, err := manager.ConfirmMFAEnrollment(codeRequest(memberEmail, currentCode(enrollment.Secret)))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(codes.Codes).To(gomega.HaveLen(entities.RecoveryCodeCount))
+
+		user, err = manager.GetUser(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.MfaEnabled).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should not accept a code twice", func() {
+		enrollment, err := manager.BeginMFAEnrollment(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		code := currentCode(enrollment.Secret)
+		_, err = manager.ConfirmMFAEnrollment(codeRequest(memberEmail, code))
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.VerifyMFACode(codeRequest(memberEmail, code))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+	})
+
+	ginkgo.It("should consume recovery codes", func() {
+		_, codes := enroll(memberEmail)
+		gomega.Expect(manager.VerifyMFACode(codeRequest(memberEmail, codes[0]))).To(gomega.Succeed())
+		err := manager.VerifyMFACode(codeRequest(memberEmail, codes[0]))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+
+		regenerated, err := manager.GenerateRecoveryCodes(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.VerifyMFACode(codeRequest(memberEmail, codes[1]))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+		gomega.Expect(manager.VerifyMFACode(codeRequest(memberEmail, regenerated.Codes[0]))).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should lock the verifications after too many failed attempts", func() {
+		_, codes := enroll(memberEmail)
+		for attempt := 0; attempt < entities.MaxMFAAttempts; attempt++ {
+			err := manager.VerifyMFACode(codeRequest(memberEmail, "000000"))
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+		}
+		err := manager.VerifyMFACode(codeRequest(memberEmail, codes[0]))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		stored, gErr := enrollments.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(stored.RecoveryCodes).To(gomega.HaveLen(entities.RecoveryCodeCount))
+		expired := *stored
+		expired.LockedUntil = time.Now().Add(-time.Second).Unix()
+		gomega.Expect(enrollments.Set(expired)).To(gomega.Succeed())
+		gomega.Expect(manager.VerifyMFACode(codeRequest(memberEmail, codes[0]))).To(gomega.Succeed())
+		stored, gErr = enrollments.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(stored.FailedAttempts).To(gomega.BeZero())
+	})
+
+	ginkgo.It("should lock the confirmation after too many failed attempts", func() {
+		enrollment, err := manager.BeginMFAEnrollment(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		for attempt := 0; attempt < entities.MaxMFAAttempts; attempt++ {
+			_, err = manager.ConfirmMFAEnrollment(codeRequest(memberEmail, "000000"))
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+		}
+		_, err = manager.ConfirmMFAEnrollment(codeRequest(memberEmail, currentCode(enrollment.Secret)))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		stored, gErr := enrollments.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(stored.Confirmed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should not replace an enrollment modified concurrently", func() {
+		enroll(memberEmail)
+		read, err := enrollments.Get(organizationID, memberEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+		first := *read
+		first.LastTimeStep++
+		gomega.Expect(enrollments.CompareAndSet(*read, first)).To(gomega.Succeed())
+		second := *read
+		second.LastTimeStep++
+		err = enrollments.CompareAndSet(*read, second)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should require MFA for owners when the policy is set", func() {
+		enroll(ownerEmail)
+		enroll(memberEmail)
+		gomega.Expect(manager.SetMFAPolicy(&grpc_user_manager_go.MFAPolicy{
+			OrganizationId:   organizationID,
+			RequireForOwners: true,
+		})).To(gomega.Succeed())
+		policy, err := manager.GetMFAPolicy(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.RequireForOwners).To(gomega.BeTrue())
+
+		owner, err := manager.GetUser(userID(ownerEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(owner.MfaRequired).To(gomega.BeTrue())
+		member, err := manager.GetUser(userID(memberEmail))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(member.MfaRequired).To(gomega.BeFalse())
+
+		err = manager.DisableMFA(userID(ownerEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(manager.DisableMFA(userID(memberEmail))).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should fail if MFA is not configured", func() {
+		manager.mfaCipher = nil
+		_, err := manager.BeginMFAEnrollment(userID(memberEmail))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+})
@@ -0,0 +1,169 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// addUserRole adds an additional role to a user. The primitives of the role are merged with the ones of the primary
+// role of the user.
+func (m *Manager) addUserRole(request *grpc_user_manager_go.AssignRoleRequest) (*grpc_user_manager_go.User, error) {
+	userID := &grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: request.Email}
+	user, err := m.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	role, err := m.organizationRole(request.OrganizationId, request.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	if user.RoleId == request.RoleId {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("the role is the primary role of the user").WithParams(request.Email, request.RoleId))
+	}
+	bindings, bErr := m.userRoleBindings(request.OrganizationId, request.Email)
+	if bErr != nil {
+		return nil, conversions.ToGRPCError(bErr)
+	}
+	if !bindings.Add(request.RoleId) {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("the user already holds the role").WithParams(request.Email, request.RoleId))
+	}
+	sErr := m.roleBindings.Set(*bindings)
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	_ = m.usersCache.Clear(request.OrganizationId)
+	m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditRoleAdded, request.Email,
+		"role %s (%s) added", role.Name, role.RoleId))
+	return m.GetUser(userID)
+}
+
+// removeUserRole removes an additional role from a user. The primary role can only be replaced, and the role cannot
+// be removed if the user is one of the owners the organization needs to reach its minimum.
+func (m *Manager) removeUserRole(request *grpc_user_manager_go.AssignRoleRequest) (*grpc_user_manager_go.User, error) {
+	userID := &grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: request.Email}
+	user, err := m.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.RoleId == request.RoleId {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("the primary role cannot be removed, replace it instead").WithParams(request.Email, request.RoleId))
+	}
+	bindings, bErr := m.userRoleBindings(request.OrganizationId, request.Email)
+	if bErr != nil {
+		return nil, conversions.ToGRPCError(bErr)
+	}
+	if !bindings.Remove(request.RoleId) {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("user role").WithParams(request.Email, request.RoleId))
+	}
+	canAssign, cErr := m.usersCache.CanAssignRole(request)
+	if cErr != nil {
+		return nil, conversions.ToGRPCError(cErr)
+	}
+	if !canAssign {
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError(m.ownerQuorumMessage(request.OrganizationId, "can not remove role")))
+	}
+	sErr := m.setUserRoleBindings(bindings)
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	_ = m.usersCache.Clear(request.OrganizationId)
+	m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditRoleRemoved, request.Email,
+		"role %s removed", request.RoleId))
+	return m.GetUser(userID)
+}
+
+// dropUserRole removes a role from the additional roles of a user, if the user holds it. It is used when the role
+// becomes the primary one. Failures are logged as the role only duplicates the primary one.
+func (m *Manager) dropUserRole(organizationID string, email string, roleID string) {
+	bindings, err := m.userRoleBindings(organizationID, email)
+	if err == nil && bindings.Remove(roleID) {
+		err = m.setUserRoleBindings(bindings)
+	}
+	if err != nil {
+		log.Warn().Str("organizationID", organizationID).Str("email", email).Str("roleID", roleID).
+			Str("err", err.DebugReport()).Msg("cannot remove the primary role from the additional roles")
+	}
+}
+
+// userRoleBindings retrieves the additional roles of a user, with no roles if the user has none.
+func (m *Manager) userRoleBindings(organizationID string, email string) (*entities.RoleBindings, derrors.Error) {
+	bindings, err := m.roleBindings.Get(organizationID, email)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return entities.NewRoleBindings(organizationID, email), nil
+		}
+		return nil, err
+	}
+	return bindings, nil
+}
+
+// setUserRoleBindings stores the additional roles of a user, removing them once the user has none.
+func (m *Manager) setUserRoleBindings(bindings *entities.RoleBindings) derrors.Error {
+	if len(bindings.RoleIds) == 0 {
+		err := m.roleBindings.Remove(bindings.OrganizationId, bindings.Email)
+		if err != nil && err.Type() != derrors.NotFound {
+			return err
+		}
+		return nil
+	}
+	return m.roleBindings.Set(*bindings)
+}
+
+// assignedRoles retrieves the roles assigned to a user with their effective primitives, the primary role first.
+// Additional roles that no longer exist are skipped.
+func (m *Manager) assignedRoles(organizationID string, email string, primaryRoleID string) ([]*grpc_authx_go.Role, error) {
+	primary, err := m.effectiveRole(organizationID, primaryRoleID)
+	if err != nil {
+		return nil, err
+	}
+	result := []*grpc_authx_go.Role{primary}
+	bindings, bErr := m.userRoleBindings(organizationID, email)
+	if bErr != nil {
+		return nil, conversions.ToGRPCError(bErr)
+	}
+	for _, roleID := range bindings.RoleIds {
+		role, rErr := m.rolesCache.GetRole(organizationID, roleID)
+		if rErr != nil {
+			if rErr.Type() == derrors.NotFound {
+				continue
+			}
+			return nil, conversions.ToGRPCError(rErr)
+		}
+		result = append(result, role)
+	}
+	return result, nil
+}
+
+// userRoles converts the roles assigned to a user into the list returned to the clients.
+func userRoles(roles []*grpc_authx_go.Role) []*grpc_user_manager_go.UserRole {
+	result := make([]*grpc_user_manager_go.UserRole, 0, len(roles))
+	for index, role := range roles {
+		result = append(result, &grpc_user_manager_go.UserRole{
+			RoleId:   role.RoleId,
+			RoleName: role.Name,
+			Primary:  index == 0,
+		})
+	}
+	return result
+}
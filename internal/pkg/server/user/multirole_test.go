@@ -0,0 +1,158 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Multiple roles", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var ownerRole *grpc_authx_go.Role
+	var operatorRole *grpc_authx_go.Role
+	var developerRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	assignRole := func(email string, roleID string, operation grpc_user_manager_go.AssignRoleOperation) (*grpc_user_manager_go.User, error) {
+		return manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			RoleId:         roleID,
+			Operation:      operation,
+		})
+	}
+
+	checkAccess := func(email string, primitive grpc_authx_go.AccessPrimitive) *grpc_user_manager_go.AccessDecision {
+		response, err := manager.CheckAccess(&grpc_user_manager_go.CheckAccessRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Primitives:     []grpc_authx_go.AccessPrimitive{primitive},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return response.Decisions[0]
+	}
+
+	ginkgo.BeforeEach(func() {
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		ownerRole = response.Roles[0]
+		operatorRole = response.Roles[1]
+		developerRole = response.Roles[2]
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         developerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should grant the union of the primitives of the roles of a user", func() {
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_RESOURCES).Allowed).To(gomega.BeFalse())
+
+		user, err := assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.RoleId).To(gomega.Equal(developerRole.RoleId))
+		gomega.Expect(user.Roles).To(gomega.HaveLen(2))
+		gomega.Expect(user.Roles[0].RoleId).To(gomega.Equal(developerRole.RoleId))
+		gomega.Expect(user.Roles[0].Primary).To(gomega.BeTrue())
+		gomega.Expect(user.Roles[1].RoleId).To(gomega.Equal(operatorRole.RoleId))
+		gomega.Expect(user.Roles[1].RoleName).To(gomega.Equal(operatorRole.Name))
+		gomega.Expect(user.Roles[1].Primary).To(gomega.BeFalse())
+
+		decision := checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_RESOURCES)
+		gomega.Expect(decision.Allowed).To(gomega.BeTrue())
+		gomega.Expect(decision.Reason).To(gomega.ContainSubstring(operatorRole.Name))
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_APPS).Allowed).To(gomega.BeTrue())
+
+		user, err = assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REMOVE)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.Roles).To(gomega.HaveLen(1))
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_RESOURCES).Allowed).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should reject duplicated roles and the removal of the primary role", func() {
+		_, err := assignRole(memberEmail, developerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		_, err = assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+
+		_, err = assignRole(memberEmail, developerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REMOVE)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		_, err = assignRole(memberEmail, ownerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REMOVE)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should drop an additional role that becomes the primary one", func() {
+		_, err := assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(err).To(gomega.Succeed())
+		user, err := assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REPLACE)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.RoleId).To(gomega.Equal(operatorRole.RoleId))
+		gomega.Expect(user.Roles).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("should count additional owner roles in the owner quorum", func() {
+		_, err := assignRole(memberEmail, ownerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		// the member is the only owner left once the owner is demoted
+		_, err = assignRole(ownerEmail, developerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REPLACE)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = assignRole(memberEmail, ownerRole.RoleId, grpc_user_manager_go.AssignRoleOperation_REMOVE)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.EffectiveRoleId).To(gomega.Equal(developerRole.RoleId))
+		gomega.Expect(checkAccess(memberEmail, grpc_authx_go.AccessPrimitive_ORG).Allowed).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should not remove roles held as additional roles", func() {
+		_, err := assignRole(memberEmail, operatorRole.RoleId, grpc_user_manager_go.AssignRoleOperation_ADD)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: operatorRole.RoleId})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+})
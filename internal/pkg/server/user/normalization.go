@@ -0,0 +1,239 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"sort"
+)
+
+// NormalizeEmail returns the canonical form of an email used to store and look up the users.
+func (m *Manager) NormalizeEmail(email string) (string, derrors.Error) {
+	return m.emailNormalizer.Normalize(email)
+}
+
+// normalizeEmails replaces each email with its canonical form.
+func (m *Manager) normalizeEmails(emails ...*string) derrors.Error {
+	for _, email := range emails {
+		normalized, err := m.emailNormalizer.Normalize(*email)
+		if err != nil {
+			return err
+		}
+		*email = normalized
+	}
+	return nil
+}
+
+// normalizeEmailList replaces each email of a list with its canonical form.
+func (m *Manager) normalizeEmailList(emails []string) derrors.Error {
+	for index := range emails {
+		err := m.normalizeEmails(&emails[index])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveEmail returns the email a user of an organization is stored with. Users added before the emails were
+// normalized keep their original email until they are migrated, so the email is resolved through the canonical form
+// to the stored one. The canonical form is returned if no user has it or the stored one is ambiguous.
+func (m *Manager) ResolveEmail(organizationID string, email string) (string, derrors.Error) {
+	normalized, err := m.emailNormalizer.Normalize(email)
+	if err != nil || organizationID == "" {
+		return normalized, err
+	}
+	stored, err := m.emailsCache.Find(organizationID, normalized)
+	if err != nil {
+		return "", err
+	}
+	if len(stored) == 1 {
+		return stored[0], nil
+	}
+	for _, candidate := range stored {
+		if candidate == email {
+			return candidate, nil
+		}
+	}
+	return normalized, nil
+}
+
+// resolveEmails replaces each email of a user of an organization with the email the user is stored with.
+func (m *Manager) resolveEmails(organizationID string, emails ...*string) derrors.Error {
+	for _, email := range emails {
+		resolved, err := m.ResolveEmail(organizationID, *email)
+		if err != nil {
+			return err
+		}
+		*email = resolved
+	}
+	return nil
+}
+
+// resolveEmailList replaces each email of a list of users of an organization with the email the user is stored with.
+func (m *Manager) resolveEmailList(organizationID string, emails []string) derrors.Error {
+	for index := range emails {
+		err := m.resolveEmails(organizationID, &emails[index])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEmailUnique checks that no user of the organization other than the owner has an email with the same canonical
+// form. The users stored before the emails were normalized may only differ from the new email in the case.
+func (m *Manager) checkEmailUnique(organizationID string, email string, owner string) error {
+	existing, err := m.emailsCache.Find(organizationID, email)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	for _, userEmail := range existing {
+		if userEmail != owner {
+			return conversions.ToGRPCError(derrors.NewAlreadyExistsError("user").WithParams(organizationID, userEmail))
+		}
+	}
+	return nil
+}
+
+// FindEmailCollisions reports the users of an organization whose emails have the same canonical form, and those whose
+// email is not in canonical form as they were added before the emails were normalized. Colliding users cannot be
+// told apart by their canonical email until they are changed, the rest can be moved with MigrateEmails.
+func (m *Manager) FindEmailCollisions(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.EmailCollisionList, error) {
+	users, err := m.usersClient.GetUsers(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	result := &grpc_user_manager_go.EmailCollisionList{
+		OrganizationId:     organizationID.OrganizationId,
+		Collisions:         make([]*grpc_user_manager_go.EmailCollision, 0),
+		UnnormalizedEmails: make([]string, 0),
+		InvalidEmails:      make([]string, 0),
+	}
+	byNormalized := make(map[string][]string, 0)
+	for _, user := range users.Users {
+		normalized, nErr := m.emailNormalizer.Normalize(user.Email)
+		if nErr != nil {
+			result.InvalidEmails = append(result.InvalidEmails, user.Email)
+			continue
+		}
+		if normalized != user.Email {
+			result.UnnormalizedEmails = append(result.UnnormalizedEmails, user.Email)
+		}
+		byNormalized[normalized] = append(byNormalized[normalized], user.Email)
+	}
+	for normalized, emails := range byNormalized {
+		if len(emails) > 1 {
+			sort.Strings(emails)
+			result.Collisions = append(result.Collisions, &grpc_user_manager_go.EmailCollision{
+				NormalizedEmail: normalized,
+				Emails:          emails,
+			})
+		}
+	}
+	sort.Slice(result.Collisions, func(i, j int) bool {
+		return result.Collisions[i].NormalizedEmail < result.Collisions[j].NormalizedEmail
+	})
+	sort.Strings(result.UnnormalizedEmails)
+	sort.Strings(result.InvalidEmails)
+	return result, nil
+}
+
+// MigrateEmails moves the users of an organization added before the emails were normalized to the canonical form of
+// their email, keeping their role, password and state. Users whose canonical email collides with another user or
+// cannot be normalized are reported and must be fixed manually. With dry run the users are only reported.
+func (m *Manager) MigrateEmails(request *grpc_user_manager_go.MigrateEmailsRequest) (*grpc_user_manager_go.EmailMigrationReport, error) {
+	users, err := m.usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: request.OrganizationId})
+	if err != nil {
+		return nil, err
+	}
+	report := &grpc_user_manager_go.EmailMigrationReport{
+		OrganizationId: request.OrganizationId,
+		DryRun:         request.DryRun,
+		Migrated:       make([]*grpc_user_manager_go.EmailMigration, 0),
+		Failures:       make([]*grpc_user_manager_go.EmailMigrationFailure, 0),
+	}
+	byNormalized := make(map[string][]string, 0)
+	toMigrate := make(map[string]string, 0)
+	for _, user := range users.Users {
+		normalized, nErr := m.emailNormalizer.Normalize(user.Email)
+		if nErr != nil {
+			report.Failures = append(report.Failures, &grpc_user_manager_go.EmailMigrationFailure{Email: user.Email, Reason: nErr.Error()})
+			continue
+		}
+		byNormalized[normalized] = append(byNormalized[normalized], user.Email)
+		if normalized != user.Email {
+			toMigrate[user.Email] = normalized
+		}
+	}
+	emails := make([]string, 0, len(toMigrate))
+	for email := range toMigrate {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+
+	for _, email := range emails {
+		normalized := toMigrate[email]
+		if len(byNormalized[normalized]) > 1 {
+			report.Failures = append(report.Failures, &grpc_user_manager_go.EmailMigrationFailure{Email: email,
+				Reason: fmt.Sprintf("collides with other users as %s", normalized)})
+			continue
+		}
+		aErr := m.checkEmailAlias(request.OrganizationId, normalized, email)
+		if aErr != nil {
+			report.Failures = append(report.Failures, &grpc_user_manager_go.EmailMigrationFailure{Email: email, Reason: aErr.Error()})
+			continue
+		}
+		if !request.DryRun {
+			mErr := m.migrateEmail(request.OrganizationId, email, normalized)
+			if mErr != nil {
+				report.Failures = append(report.Failures, &grpc_user_manager_go.EmailMigrationFailure{Email: email, Reason: mErr.Error()})
+				continue
+			}
+		}
+		report.Migrated = append(report.Migrated, &grpc_user_manager_go.EmailMigration{Email: email, NewEmail: normalized})
+	}
+	return report, nil
+}
+
+// migrateEmail moves a user to the canonical form of its email. A pending email change of the user follows it.
+func (m *Manager) migrateEmail(organizationID string, email string, normalized string) error {
+	err := m.renameUser(&entities.EmailChange{OrganizationId: organizationID, Email: email, NewEmail: normalized})
+	if err != nil {
+		return err
+	}
+	if pending, gErr := m.emailChanges.GetChange(organizationID, email); gErr == nil {
+		pending.Email = normalized
+		sErr := m.emailChanges.SetChange(*pending)
+		if sErr == nil {
+			sErr = m.emailChanges.RemoveChange(organizationID, email)
+		}
+		if sErr != nil {
+			log.Warn().Str("organizationID", organizationID).Str("email", email).Str("err", sErr.DebugReport()).
+				Msg("cannot move the pending email change of the user")
+		}
+	}
+	m.audit(entities.NewAuditEntry(organizationID, entities.AuditEmailMigrated, normalized,
+		"email migrated from %s", email))
+	return nil
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"sort"
+	"time"
+)
+
+// OffboardingTokenTTL with the time a confirmation token can be used to start the offboarding of an organization.
+const OffboardingTokenTTL = 15 * time.Minute
+
+// RequestOffboardingToken issues the token that confirms the offboarding of an organization. Requesting a token for
+// an offboarding in progress replaces the token, keeping the progress.
+func (m *Manager) RequestOffboardingToken(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.OffboardingToken, error) {
+	users, err := m.usersClient.GetUsers(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	roleIDs, err := m.offboardingRoles(organizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	offboarding, gErr := m.offboardings.Get(organizationID.OrganizationId)
+	if gErr != nil && gErr.Type() != derrors.NotFound {
+		return nil, conversions.ToGRPCError(gErr)
+	}
+	var token string
+	var tErr derrors.Error
+	if gErr == nil && offboarding.Status == grpc_user_manager_go.OffboardingStatus_IN_PROGRESS {
+		token, tErr = offboarding.RenewToken(OffboardingTokenTTL)
+	} else {
+		offboarding, token, tErr = entities.NewOffboarding(organizationID.OrganizationId, OffboardingTokenTTL)
+	}
+	if tErr != nil {
+		return nil, conversions.ToGRPCError(tErr)
+	}
+	sErr := m.offboardings.Set(*offboarding)
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	return &grpc_user_manager_go.OffboardingToken{
+		OrganizationId: organizationID.OrganizationId,
+		Token:          token,
+		ExpiresAt:      offboarding.ExpiresAt,
+		NumUsers:       int32(len(users.Users)),
+		NumRoles:       int32(len(roleIDs)),
+	}, nil
+}
+
+// OffboardOrganization removes all the users, service accounts and roles of an organization. The last owner
+// protection does not apply. Failures do not stop the offboarding, they are included in the report and the
+// offboarding is completed calling again with the same token, or with a new one once it expires. Roles are only
+// removed once all the users are gone.
+func (m *Manager) OffboardOrganization(request *grpc_user_manager_go.OffboardOrganizationRequest) (*grpc_user_manager_go.OffboardingReport, error) {
+	offboarding, err := m.offboardings.Get(request.OrganizationId)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("invalid confirmation token").WithParams(request.OrganizationId))
+		}
+		return nil, conversions.ToGRPCError(err)
+	}
+	if !offboarding.Confirms(request.ConfirmationToken, time.Now().Unix()) {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("invalid confirmation token").WithParams(request.OrganizationId))
+	}
+	if offboarding.IsCompleted() {
+		return offboarding.ToGRPC(), nil
+	}
+	offboarding.Start(time.Now().Unix())
+	m.saveOffboarding(offboarding)
+
+	_ = m.usersCache.Clear(request.OrganizationId)
+	defer m.usersCache.Clear(request.OrganizationId)
+
+	m.offboardUsers(offboarding)
+	m.offboardServiceAccounts(offboarding)
+	if len(offboarding.Failures) == 0 {
+		m.offboardRoles(offboarding)
+	}
+	if len(offboarding.Failures) == 0 {
+		m.offboardLeftovers(offboarding.OrganizationId)
+		offboarding.Complete(time.Now().Unix())
+		m.audit(entities.NewAuditEntry(offboarding.OrganizationId, entities.AuditOrganizationOffboarded, "",
+			"removed %d users, %d service accounts and %d roles", len(offboarding.RemovedUsers),
+			len(offboarding.RemovedServiceAccounts), len(offboarding.RemovedRoles)))
+	}
+	m.saveOffboarding(offboarding)
+	return offboarding.ToGRPC(), nil
+}
+
+// saveOffboarding stores the progress of an offboarding.
+func (m *Manager) saveOffboarding(offboarding *entities.Offboarding) {
+	err := m.offboardings.Set(*offboarding)
+	if err != nil {
+		log.Error().Str("organizationID", offboarding.OrganizationId).Str("err", err.DebugReport()).
+			Msg("cannot store the offboarding progress")
+	}
+}
+
+// offboardingFailure records an element that could not be removed.
+func offboardingFailure(offboarding *entities.Offboarding, element string, err derrors.Error) {
+	offboarding.Failures = append(offboarding.Failures, fmt.Sprintf("%s: %s", element, err.Error()))
+}
+
+// offboardUsers removes the credentials and the system model record of every user, ignoring the ones already gone.
+func (m *Manager) offboardUsers(offboarding *entities.Offboarding) {
+	users, err := m.usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: offboarding.OrganizationId})
+	if err != nil {
+		offboardingFailure(offboarding, "users", conversions.ToDerror(err))
+		return
+	}
+	for _, user := range users.Users {
+		_, err := m.accessClient.DeleteCredentials(context.Background(), &grpc_authx_go.DeleteCredentialsRequest{
+			OrganizationId: offboarding.OrganizationId,
+			Username:       user.Email,
+		})
+		if ignoreNotFound(err) != nil {
+			offboardingFailure(offboarding, fmt.Sprintf("user %s", user.Email), conversions.ToDerror(err))
+			continue
+		}
+		_, err = m.usersClient.RemoveUser(context.Background(), &grpc_user_go.RemoveUserRequest{
+			OrganizationId: offboarding.OrganizationId,
+			Email:          user.Email,
+		})
+		if ignoreNotFound(err) != nil {
+			offboardingFailure(offboarding, fmt.Sprintf("user %s", user.Email), conversions.ToDerror(err))
+			continue
+		}
+		m.emailsCache.Remove(offboarding.OrganizationId, user.Email)
+		_ = m.passwordResets.Remove(offboarding.OrganizationId, user.Email)
+		_ = m.mfa.Remove(offboarding.OrganizationId, user.Email)
+		_ = m.roleGrants.Remove(offboarding.OrganizationId, user.Email)
+		_ = m.roleBindings.Remove(offboarding.OrganizationId, user.Email)
+		_ = m.emailChanges.RemoveChange(offboarding.OrganizationId, user.Email)
+		m.unlinkIdentity(offboarding.OrganizationId, user.Email)
+		offboarding.RemovedUsers = append(offboarding.RemovedUsers, user.Email)
+		m.saveOffboarding(offboarding)
+	}
+}
+
+// offboardServiceAccounts removes the service accounts and their API keys.
+func (m *Manager) offboardServiceAccounts(offboarding *entities.Offboarding) {
+	accounts, err := m.serviceAccounts.ListServiceAccounts(offboarding.OrganizationId)
+	if err != nil {
+		offboardingFailure(offboarding, "service accounts", err)
+		return
+	}
+	for _, account := range accounts {
+		rErr := m.serviceAccounts.RemoveServiceAccount(account.OrganizationId, account.ServiceAccountId)
+		if rErr != nil && rErr.Type() != derrors.NotFound {
+			offboardingFailure(offboarding, fmt.Sprintf("service account %s", account.ServiceAccountId), rErr)
+			continue
+		}
+		offboarding.RemovedServiceAccounts = append(offboarding.RemovedServiceAccounts, account.ServiceAccountId)
+		m.saveOffboarding(offboarding)
+	}
+}
+
+// offboardRoles removes every role both from authx and system model. A role may only exist in one of them if a
+// previous attempt was interrupted.
+func (m *Manager) offboardRoles(offboarding *entities.Offboarding) {
+	defer m.rolesCache.Clear(offboarding.OrganizationId)
+	roleIDs, err := m.offboardingRoles(&grpc_organization_go.OrganizationId{OrganizationId: offboarding.OrganizationId})
+	if err != nil {
+		offboardingFailure(offboarding, "roles", conversions.ToDerror(err))
+		return
+	}
+	for _, roleID := range roleIDs {
+		_, err := m.accessClient.RemoveRole(context.Background(), &grpc_authx_go.RoleId{
+			OrganizationId: offboarding.OrganizationId,
+			RoleId:         roleID,
+		})
+		if ignoreNotFound(err) != nil {
+			offboardingFailure(offboarding, fmt.Sprintf("role %s", roleID), conversions.ToDerror(err))
+			continue
+		}
+		_, err = m.roleClient.RemoveRole(context.Background(), &grpc_role_go.RemoveRoleRequest{
+			OrganizationId: offboarding.OrganizationId,
+			RoleId:         roleID,
+		})
+		if ignoreNotFound(err) != nil {
+			offboardingFailure(offboarding, fmt.Sprintf("role %s", roleID), conversions.ToDerror(err))
+			continue
+		}
+		hErr := m.setRoleParents(offboarding.OrganizationId, roleID, nil, nil)
+		if hErr != nil {
+			offboardingFailure(offboarding, fmt.Sprintf("role %s", roleID), hErr)
+			continue
+		}
+		offboarding.RemovedRoles = append(offboarding.RemovedRoles, roleID)
+		m.saveOffboarding(offboarding)
+	}
+}
+
+// offboardingRoles obtains the identifiers of the roles of an organization in authx or system model.
+func (m *Manager) offboardingRoles(organizationID *grpc_organization_go.OrganizationId) ([]string, error) {
+	authxRoles, err := m.accessClient.ListRoles(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	smRoles, err := m.roleClient.ListRoles(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	found := make(map[string]bool, len(authxRoles.Roles))
+	for _, role := range authxRoles.Roles {
+		found[role.RoleId] = true
+	}
+	for _, role := range smRoles.Roles {
+		found[role.RoleId] = true
+	}
+	result := make([]string, 0, len(found))
+	for roleID := range found {
+		result = append(result, roleID)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// offboardLeftovers removes the removed users that could be restored, the owner, email domain and MFA policies, the
+// claim rules, the groups, and the role grants, role bindings, role parents and email changes and aliases not removed
+// with their user or role. It also cancels the pending access requests, as their roles no longer exist.
+func (m *Manager) offboardLeftovers(organizationID string) {
+	_ = m.ownerPolicies.Remove(organizationID)
+	_ = m.domainPolicies.Remove(organizationID)
+	_ = m.mfa.RemovePolicy(organizationID)
+	_ = m.claimRules.Remove(organizationID)
+	groups, gErr := m.groups.ListGroups(organizationID)
+	if gErr == nil {
+		for _, group := range groups {
+			_ = m.groups.RemoveGroup(organizationID, group.GroupId)
+		}
+	}
+	grants, gErr := m.roleGrants.List(organizationID)
+	if gErr == nil {
+		for _, grant := range grants {
+			_ = m.roleGrants.Remove(organizationID, grant.Email)
+		}
+	}
+	bindings, bErr := m.roleBindings.List(organizationID)
+	if bErr == nil {
+		for _, binding := range bindings {
+			_ = m.roleBindings.Remove(organizationID, binding.Email)
+		}
+	}
+	parents, pErr := m.roleHierarchy.List(organizationID)
+	if pErr == nil {
+		for _, parent := range parents {
+			_ = m.roleHierarchy.Remove(organizationID, parent.RoleId)
+		}
+	}
+	changes, cErr := m.emailChanges.ListChanges(organizationID)
+	if cErr == nil {
+		for _, change := range changes {
+			_ = m.emailChanges.RemoveChange(organizationID, change.Email)
+		}
+	}
+	aliases, aErr := m.emailChanges.ListAliases(organizationID)
+	if aErr == nil {
+		for _, alias := range aliases {
+			_ = m.emailChanges.RemoveAlias(organizationID, alias.Email)
+		}
+	}
+	removed, err := m.recycleBin.List(organizationID)
+	if err == nil {
+		for _, user := range removed {
+			_ = m.recycleBin.Remove(organizationID, user.Email)
+		}
+	}
+	requests, err := m.accessRequests.List(organizationID)
+	if err == nil {
+		now := time.Now().Unix()
+		for index := range requests {
+			request := &requests[index]
+			if !request.IsPending() {
+				continue
+			}
+			tErr := request.Transition(grpc_user_manager_go.AccessRequestState_CANCELLED, "", "organization offboarded", now)
+			if tErr == nil {
+				_ = m.accessRequests.Update(*request)
+			}
+		}
+	}
+}
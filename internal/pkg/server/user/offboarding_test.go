@@ -0,0 +1,253 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/offboarding"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Organization offboarding", func() {
+
+	const organizationID = "org-1"
+	const otherOrganizationID = "org-2"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var usersClient *utils.FakeUsersClient
+	var rolesClient *utils.FakeRolesClient
+	var offboardings *offboarding.MockupOffboardingProvider
+	var providers Providers
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	bootstrap := func(organizationID string, email string) *grpc_user_manager_go.BootstrapOrganizationResponse {
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return response
+	}
+
+	requestToken := func() string {
+		token, err := manager.RequestOffboardingToken(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		return token.Token
+	}
+
+	offboard := func(token string) (*grpc_user_manager_go.OffboardingReport, error) {
+		return manager.OffboardOrganization(&grpc_user_manager_go.OffboardOrganizationRequest{
+			OrganizationId:    organizationID,
+			ConfirmationToken: token,
+		})
+	}
+
+	expectEmpty := func(organizationID string) {
+		orgID := &grpc_organization_go.OrganizationId{OrganizationId: organizationID}
+		roles, err := manager.ListRoles(orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles.Roles).To(gomega.BeEmpty())
+		smRoles, err := rolesClient.ListRoles(context.Background(), orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(smRoles.Roles).To(gomega.BeEmpty())
+		users, err := usersClient.GetUsers(context.Background(), orgID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(users.Users).To(gomega.BeEmpty())
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		usersClient = utils.NewFakeUsersClient()
+		rolesClient = utils.NewFakeRolesClient()
+		offboardings = offboarding.NewMockupOffboardingProvider()
+		providers = NewMockupProviders()
+		providers.Offboardings = offboardings
+		manager = NewManager(authxClient, usersClient, rolesClient,
+			providers, testSettings())
+		response := bootstrap(organizationID, ownerEmail)
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         response.Roles[1].RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AddServiceAccount(&grpc_user_manager_go.AddServiceAccountRequest{
+			OrganizationId: organizationID,
+			Name:           "ci",
+			RoleId:         response.Roles[1].RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		bootstrap(otherOrganizationID, "owner@other.com")
+	})
+
+	ginkgo.It("should describe what will be removed", func() {
+		token, err := manager.RequestOffboardingToken(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(token.Token).NotTo(gomega.BeEmpty())
+		gomega.Expect(token.NumUsers).To(gomega.Equal(int32(2)))
+		gomega.Expect(token.NumRoles).To(gomega.Equal(int32(len(entities.DefaultRoleTemplate().Roles))))
+	})
+
+	ginkgo.It("should remove all the users and roles, owners included", func() {
+		report, err := offboard(requestToken())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+		gomega.Expect(report.RemovedUsers).To(gomega.ConsistOf(ownerEmail, memberEmail))
+		gomega.Expect(report.RemovedRoles).To(gomega.HaveLen(len(entities.DefaultRoleTemplate().Roles)))
+		gomega.Expect(report.RemovedServiceAccounts).To(gomega.HaveLen(1))
+		gomega.Expect(report.Failures).To(gomega.BeEmpty())
+		expectEmpty(organizationID)
+		_, exists := authxClient.Password(organizationID, ownerEmail)
+		gomega.Expect(exists).To(gomega.BeFalse())
+
+		accounts, err := manager.ListServiceAccounts(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(accounts.ServiceAccounts).To(gomega.BeEmpty())
+
+		other, err := usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: otherOrganizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(other.Users).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("should remove the data of the organization kept outside the users and roles", func() {
+		gomega.Expect(providers.MFA.SetPolicy(entities.MFAPolicy{OrganizationId: organizationID, RequireForOwners: true})).To(gomega.Succeed())
+		gomega.Expect(providers.ClaimRules.Add(entities.ClaimRules{OrganizationId: organizationID, EmailClaim: "email"})).To(gomega.Succeed())
+		gomega.Expect(providers.EmailChanges.SetChange(entities.EmailChange{
+			OrganizationId: organizationID, Email: memberEmail, NewEmail: "new@example.com"})).To(gomega.Succeed())
+		gomega.Expect(providers.EmailChanges.SetAlias(entities.EmailAlias{
+			OrganizationId: organizationID, Email: "former@example.com", NewEmail: ownerEmail})).To(gomega.Succeed())
+		gomega.Expect(providers.RoleGrants.Set(entities.RoleGrant{
+			OrganizationId: organizationID, Email: "gone@example.com", RoleId: "role", ExpiresAt: time.Now().Add(time.Hour).Unix()})).To(gomega.Succeed())
+
+		report, err := offboard(requestToken())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+
+		_, gErr := providers.MFA.GetPolicy(organizationID)
+		gomega.Expect(gErr).NotTo(gomega.Succeed())
+		_, gErr = providers.ClaimRules.Get(organizationID)
+		gomega.Expect(gErr).NotTo(gomega.Succeed())
+		changes, lErr := providers.EmailChanges.ListChanges(organizationID)
+		gomega.Expect(lErr).To(gomega.Succeed())
+		gomega.Expect(changes).To(gomega.BeEmpty())
+		aliases, lErr := providers.EmailChanges.ListAliases(organizationID)
+		gomega.Expect(lErr).To(gomega.Succeed())
+		gomega.Expect(aliases).To(gomega.BeEmpty())
+		grants, lErr := providers.RoleGrants.List(organizationID)
+		gomega.Expect(lErr).To(gomega.Succeed())
+		gomega.Expect(grants).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should reject an invalid token", func() {
+		_, err := offboard("invalid")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+		requestToken()
+		_, err = offboard("invalid")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+		users, err := usersClient.GetUsers(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(users.Users).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("should reject an expired token", func() {
+		token := requestToken()
+		pending, err := offboardings.Get(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		pending.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		gomega.Expect(offboardings.Set(*pending)).To(gomega.Succeed())
+		_, oErr := offboard(token)
+		gomega.Expect(errorType(oErr)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should reject an expired token of an offboarding in progress", func() {
+		authxClient.FailOn("RemoveRole", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		token := requestToken()
+		report, err := offboard(token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_IN_PROGRESS))
+		authxClient.FailOn("RemoveRole", nil)
+
+		inProgress, gErr := offboardings.Get(organizationID)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		inProgress.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		gomega.Expect(offboardings.Set(*inProgress)).To(gomega.Succeed())
+		_, err = offboard(token)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		report, err = offboard(requestToken())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+		gomega.Expect(report.RemovedUsers).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("should resume an interrupted offboarding", func() {
+		token := requestToken()
+		authxClient.FailOn("DeleteCredentials", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		report, err := offboard(token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_IN_PROGRESS))
+		gomega.Expect(report.Failures).To(gomega.HaveLen(2))
+		gomega.Expect(report.RemovedRoles).To(gomega.BeEmpty())
+
+		authxClient.FailOn("DeleteCredentials", nil)
+		report, err = offboard(token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+		gomega.Expect(report.Failures).To(gomega.BeEmpty())
+		gomega.Expect(report.RemovedUsers).To(gomega.ConsistOf(ownerEmail, memberEmail))
+		expectEmpty(organizationID)
+
+		again, err := offboard(token)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(again).To(gomega.Equal(report))
+	})
+
+	ginkgo.It("should keep the progress when a new token is requested", func() {
+		authxClient.FailOn("RemoveRole", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		report, err := offboard(requestToken())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_IN_PROGRESS))
+		gomega.Expect(report.RemovedUsers).To(gomega.HaveLen(2))
+
+		authxClient.FailOn("RemoveRole", nil)
+		report, err = offboard(requestToken())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(report.Status).To(gomega.Equal(grpc_user_manager_go.OffboardingStatus_COMPLETED))
+		gomega.Expect(report.RemovedUsers).To(gomega.HaveLen(2))
+		expectEmpty(organizationID)
+	})
+})
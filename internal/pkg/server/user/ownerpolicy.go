@@ -0,0 +1,61 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"fmt"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// SetOwnerPolicy sets the minimum number of owners of an organization. Organizations with fewer owners are not
+// changed, but no owner can be removed or demoted until the minimum is reached.
+func (m *Manager) SetOwnerPolicy(policy *grpc_user_manager_go.OwnerPolicy) error {
+	err := m.ownerPolicies.Set(*entities.NewOwnerPolicy(policy))
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	log.Debug().Str("organizationID", policy.OrganizationId).Int32("minOwners", policy.MinOwners).
+		Msg("owner policy has been set")
+	return nil
+}
+
+// GetOwnerPolicy retrieves the owner policy of an organization, the default one if it is not set.
+func (m *Manager) GetOwnerPolicy(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.OwnerPolicy, error) {
+	minOwners, err := m.usersCache.MinOwners(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	policy := entities.OwnerPolicy{OrganizationId: organizationID.OrganizationId, MinOwners: minOwners}
+	return policy.ToGRPC(), nil
+}
+
+// ownerQuorumMessage describes why an operation would leave an organization below its minimum number of owners.
+func (m *Manager) ownerQuorumMessage(organizationID string, operation string) string {
+	minOwners, err := m.usersCache.MinOwners(organizationID)
+	if err != nil {
+		log.Warn().Str("organizationID", organizationID).Str("err", err.DebugReport()).Msg("cannot retrieve the owner policy")
+		return fmt.Sprintf("%s, the organization requires a minimum number of users with a %s role", operation,
+			grpc_authx_go.AccessPrimitive_ORG)
+	}
+	return fmt.Sprintf("%s, the organization requires at least %d users with a %s role", operation, minOwners,
+		grpc_authx_go.AccessPrimitive_ORG)
+}
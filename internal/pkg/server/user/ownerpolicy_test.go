@@ -0,0 +1,167 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Owner policy", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const secondOwnerEmail = "second@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var rolesClient *utils.FakeRolesClient
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+	var developerRole *grpc_authx_go.Role
+	var organization = &grpc_organization_go.OrganizationId{OrganizationId: organizationID}
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	setMinOwners := func(minOwners int32) {
+		gomega.Expect(manager.SetOwnerPolicy(&grpc_user_manager_go.OwnerPolicy{
+			OrganizationId: organizationID,
+			MinOwners:      minOwners,
+		})).To(gomega.Succeed())
+	}
+
+	removeUser := func(email string) error {
+		return manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+	}
+
+	ginkgo.BeforeEach(func() {
+		rolesClient = utils.NewFakeRolesClient()
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), rolesClient,
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		ownerRole = response.Roles[0]
+		memberRole = response.Roles[1]
+		developerRole = response.Roles[2]
+		addUser(secondOwnerEmail, ownerRole.RoleId)
+		addUser(memberEmail, memberRole.RoleId)
+	})
+
+	ginkgo.It("should require one owner by default", func() {
+		policy, err := manager.GetOwnerPolicy(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(policy.MinOwners).To(gomega.Equal(int32(entities.DefaultMinOwners)))
+		gomega.Expect(removeUser(secondOwnerEmail)).To(gomega.Succeed())
+		err = removeUser(ownerEmail)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		gomega.Expect(conversions.ToDerror(err).Error()).To(gomega.ContainSubstring("at least 1 users"))
+	})
+
+	ginkgo.It("should keep the minimum when removing owners", func() {
+		setMinOwners(2)
+		err := removeUser(secondOwnerEmail)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		gomega.Expect(conversions.ToDerror(err).Error()).To(gomega.ContainSubstring("at least 2 users"))
+
+		addUser("third@example.com", ownerRole.RoleId)
+		gomega.Expect(removeUser(secondOwnerEmail)).To(gomega.Succeed())
+		gomega.Expect(removeUser(memberEmail)).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the minimum when demoting owners", func() {
+		setMinOwners(2)
+		_, err := manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			RoleId:         memberRole.RoleId,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		gomega.Expect(conversions.ToDerror(err).Error()).To(gomega.ContainSubstring("at least 2 users"))
+	})
+
+	ginkgo.It("should keep the minimum when removing the ORG primitive", func() {
+		_, err := manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           memberRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		setMinOwners(3)
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           memberRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(conversions.ToDerror(err).Error()).To(gomega.ContainSubstring("at least 3 users"))
+	})
+
+	ginkgo.Context("removing roles", func() {
+		ginkgo.It("should remove an unused role from both stores", func() {
+			unused := developerRole.RoleId
+			gomega.Expect(manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: unused})).To(gomega.Succeed())
+			roles, err := manager.ListRoles(organization)
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(roles.Roles).To(gomega.HaveLen(2))
+			_, err = rolesClient.GetRole(context.Background(), &grpc_role_go.RoleId{OrganizationId: organizationID, RoleId: unused})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+		})
+
+		ginkgo.It("should not remove a role assigned to users", func() {
+			err := manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: memberRole.RoleId})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		})
+
+		ginkgo.It("should not remove the owner role below the minimum", func() {
+			err := manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: ownerRole.RoleId})
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+			gomega.Expect(conversions.ToDerror(err).Error()).To(gomega.ContainSubstring("at least 1 users"))
+		})
+	})
+})
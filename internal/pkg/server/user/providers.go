@@ -0,0 +1,114 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package user
+
+import (
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/accessrequest"
+	"github.com/nalej/user-manager/internal/pkg/provider/audit"
+	"github.com/nalej/user-manager/internal/pkg/provider/claimrules"
+	"github.com/nalej/user-manager/internal/pkg/provider/domainpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/emailchange"
+	"github.com/nalej/user-manager/internal/pkg/provider/group"
+	"github.com/nalej/user-manager/internal/pkg/provider/identity"
+	"github.com/nalej/user-manager/internal/pkg/provider/mfa"
+	"github.com/nalej/user-manager/internal/pkg/provider/offboarding"
+	"github.com/nalej/user-manager/internal/pkg/provider/ownerpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/passwordreset"
+	"github.com/nalej/user-manager/internal/pkg/provider/recyclebin"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolebinding"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolegrant"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"github.com/nalej/user-manager/internal/pkg/provider/roletemplate"
+	"github.com/nalej/user-manager/internal/pkg/provider/serviceaccount"
+	"time"
+)
+
+// Providers with the stores of the state kept by the user manager.
+type Providers struct {
+	// RecycleBin with the snapshots of the removed users.
+	RecycleBin recyclebin.Provider
+	// PasswordResets with the users that must reset their password.
+	PasswordResets passwordreset.Provider
+	// ClaimRules with the rules to provision users from the assertions of external identity providers.
+	ClaimRules claimrules.Provider
+	// ServiceAccounts with the service accounts and their API keys.
+	ServiceAccounts serviceaccount.Provider
+	// MFA with the MFA enrollments and policies.
+	MFA mfa.Provider
+	// RoleGrants with the temporary role assignments.
+	RoleGrants rolegrant.Provider
+	// AuditLog with the record of the operations on the users.
+	AuditLog audit.Provider
+	// AccessRequests with the requests of the users to be assigned a role.
+	AccessRequests accessrequest.Provider
+	// RoleTemplates with the sets of roles that can be created in the organizations.
+	RoleTemplates roletemplate.Provider
+	// Offboardings with the progress of the organizations being removed.
+	Offboardings offboarding.Provider
+	// OwnerPolicies with the minimum number of owners of the organizations.
+	OwnerPolicies ownerpolicy.Provider
+	// RoleHierarchy with the parent roles the roles inherit their primitives from.
+	RoleHierarchy rolehierarchy.Provider
+	// Groups with the groups of users and their role bindings.
+	Groups group.Provider
+	// RoleBindings with the roles the users hold besides their primary role.
+	RoleBindings rolebinding.Provider
+	// Identities with the accounts of the same person in several organizations.
+	Identities identity.Provider
+	// EmailChanges with the pending email changes and the aliases of the former emails.
+	EmailChanges emailchange.Provider
+	// DomainPolicies with the email domains whose users can be members of the organizations.
+	DomainPolicies domainpolicy.Provider
+}
+
+// NewMockupProviders creates a set of empty in-memory providers to be used in tests.
+func NewMockupProviders() Providers {
+	return Providers{
+		RecycleBin:      recyclebin.NewMockupRecycleBinProvider(),
+		PasswordResets:  passwordreset.NewMockupPasswordResetProvider(),
+		ClaimRules:      claimrules.NewMockupClaimRulesProvider(),
+		ServiceAccounts: serviceaccount.NewMockupServiceAccountProvider(),
+		MFA:             mfa.NewMockupMFAProvider(),
+		RoleGrants:      rolegrant.NewMockupRoleGrantProvider(),
+		AuditLog:        audit.NewMockupAuditProvider(),
+		AccessRequests:  accessrequest.NewMockupAccessRequestProvider(),
+		RoleTemplates:   roletemplate.NewMockupRoleTemplateProvider(),
+		Offboardings:    offboarding.NewMockupOffboardingProvider(),
+		OwnerPolicies:   ownerpolicy.NewMockupOwnerPolicyProvider(),
+		RoleHierarchy:   rolehierarchy.NewMockupRoleHierarchyProvider(),
+		Groups:          group.NewMockupGroupProvider(),
+		RoleBindings:    rolebinding.NewMockupRoleBindingProvider(),
+		Identities:      identity.NewMockupIdentityProvider(),
+		EmailChanges:    emailchange.NewMockupEmailChangeProvider(),
+		DomainPolicies:  domainpolicy.NewMockupEmailDomainPolicyProvider(),
+	}
+}
+
+// Settings with the configurable behaviour of the user manager.
+type Settings struct {
+	// RemovedUserRetention with the time a removed user can be restored.
+	RemovedUserRetention time.Duration
+	// MfaCipher encrypts the TOTP secrets. MFA enrollment is disabled if not set.
+	MfaCipher *entities.SecretCipher
+	// ApproverPrimitive with the primitive required to approve or deny access requests.
+	ApproverPrimitive grpc_authx_go.AccessPrimitive
+	// AccessRequestTTL with the time an access request can be resolved before it expires.
+	AccessRequestTTL time.Duration
+	// EmailNormalizer with the canonical form of the emails. The emails are normalized without provider rules if not set.
+	EmailNormalizer *entities.EmailNormalizer
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"strings"
+)
+
+// ProvisionFromAssertion creates or updates a user from the verified claims of an external identity provider using
+// the claim rules of the organization. Subsequent logins update the mapped attributes that changed.
+func (m *Manager) ProvisionFromAssertion(request *grpc_user_manager_go.ProvisionFromAssertionRequest) (*grpc_user_manager_go.ProvisionFromAssertionResponse, error) {
+	rules, err := m.claimRules.Get(request.OrganizationId)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError("organization has no claim rules").WithParams(request.OrganizationId))
+		}
+		return nil, conversions.ToGRPCError(err)
+	}
+	if rules.Issuer != "" && rules.Issuer != request.Issuer {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("issuer is not trusted by the organization").WithParams(request.Issuer))
+	}
+	claims := entities.NewClaims(request.Claims)
+	if rules.RequireEmailVerified && !strings.EqualFold(claims.Get(entities.EmailVerifiedClaim), "true") {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("email has not been verified by the identity provider"))
+	}
+	target := rules.ToAddUserRequest(claims)
+	if target.Email == "" {
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError("assertion does not contain the email claim"))
+	}
+	nErr := m.normalizeEmails(&target.Email)
+	if nErr != nil {
+		return nil, conversions.ToGRPCError(nErr)
+	}
+	if target.RoleId == "" {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("no role rule matches the assertion").WithParams(target.Email))
+	}
+
+	userID := &grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: target.Email}
+	existing, gErr := m.GetUser(userID)
+	if gErr != nil {
+		if conversions.ToDerror(gErr).Type() != derrors.NotFound {
+			return nil, gErr
+		}
+		created, aErr := m.provisionNewUser(target)
+		if aErr == nil {
+			log.Debug().Str("organizationID", target.OrganizationId).Str("email", target.Email).Msg("user provisioned from assertion")
+			return &grpc_user_manager_go.ProvisionFromAssertionResponse{User: created, Created: true}, nil
+		}
+		if conversions.ToDerror(aErr).Type() != derrors.AlreadyExists {
+			return nil, aErr
+		}
+		// a concurrent login created the user, continue as a subsequent login
+		existing, gErr = m.GetUser(userID)
+		if gErr != nil {
+			return nil, gErr
+		}
+	}
+	if existing.InternalRole {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("users with internal roles cannot be provisioned").WithParams(target.Email))
+	}
+
+	response := &grpc_user_manager_go.ProvisionFromAssertionResponse{}
+	update := assertionUpdate(existing, target)
+	if update != nil {
+		vErr := entities.ValidUpdateUserRequest(update)
+		if vErr != nil {
+			return nil, conversions.ToGRPCError(vErr)
+		}
+		_, uErr := m.UpdateUser(update)
+		if uErr != nil {
+			return nil, uErr
+		}
+		response.Updated = true
+	}
+	if rules.SyncRole && existing.RoleId != target.RoleId {
+		_, rErr := m.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: target.OrganizationId,
+			Email:          target.Email,
+			RoleId:         target.RoleId,
+		})
+		if rErr != nil {
+			return nil, rErr
+		}
+		response.RoleChanged = true
+	}
+	if !response.Updated && !response.RoleChanged {
+		response.User = existing
+		return response, nil
+	}
+	user, uErr := m.GetUser(userID)
+	if uErr != nil {
+		return nil, uErr
+	}
+	response.User = user
+	return response, nil
+}
+
+// provisionNewUser adds a user with a random password as the credentials are managed by the identity provider.
+func (m *Manager) provisionNewUser(target *grpc_user_manager_go.AddUserRequest) (*grpc_user_manager_go.User, error) {
+	password, pErr := generateRandomPassword()
+	if pErr != nil {
+		return nil, conversions.ToGRPCError(pErr)
+	}
+	toAdd := *target
+	toAdd.Password = password
+	vErr := entities.ValidAddUserRequest(&toAdd)
+	if vErr != nil {
+		return nil, conversions.ToGRPCError(vErr)
+	}
+	return m.AddUser(&toAdd)
+}
+
+// assertionUpdate obtains the update of the attributes present in the assertion that changed, or nil if the user is
+// up to date. Attributes missing from the assertion are left untouched.
+func assertionUpdate(existing *grpc_user_manager_go.User, target *grpc_user_manager_go.AddUserRequest) *grpc_user_go.UpdateUserRequest {
+	update := &grpc_user_go.UpdateUserRequest{OrganizationId: existing.OrganizationId, Email: existing.Email}
+	changed := false
+	if target.Name != "" && target.Name != existing.Name {
+		update.UpdateName, update.Name, changed = true, target.Name, true
+	}
+	if target.LastName != "" && target.LastName != existing.LastName {
+		update.UpdateLastName, update.LastName, changed = true, target.LastName, true
+	}
+	if target.Title != "" && target.Title != existing.Title {
+		update.UpdateTitle, update.Title, changed = true, target.Title, true
+	}
+	if target.Phone != "" && target.Phone != existing.Phone {
+		update.UpdatePhone, update.Phone, changed = true, target.Phone, true
+	}
+	if target.Location != "" && target.Location != existing.Location {
+		update.UpdateLocation, update.Location, changed = true, target.Location, true
+	}
+	if !changed {
+		return nil
+	}
+	return update
+}
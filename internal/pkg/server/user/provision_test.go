@@ -0,0 +1,154 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/claimrules"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("ProvisionFromAssertion", func() {
+
+	const organizationID = "org-1"
+	const issuer = "https://idp.example.com"
+
+	var manager Manager
+	var rules *claimrules.MockupClaimRulesProvider
+	var adminRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+
+	addRole := func(name string, primitives ...grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			Description:    name,
+			Primitives:     primitives,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	assertion := func(email string, name string, groups ...string) *grpc_user_manager_go.ProvisionFromAssertionRequest {
+		return &grpc_user_manager_go.ProvisionFromAssertionRequest{
+			OrganizationId: organizationID,
+			Issuer:         issuer,
+			Claims: []*grpc_user_manager_go.Claim{
+				{Name: "email", Values: []string{email}},
+				{Name: "email_verified", Values: []string{"true"}},
+				{Name: "given_name", Values: []string{name}},
+				{Name: "family_name", Values: []string{"Doe"}},
+				{Name: "job_title", Values: []string{"Engineer"}},
+				{Name: "groups", Values: groups},
+			},
+		}
+	}
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	ginkgo.BeforeEach(func() {
+		rules = claimrules.NewMockupClaimRulesProvider()
+		providers := NewMockupProviders()
+		providers.ClaimRules = rules
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			providers, testSettings())
+		adminRole = addRole("admin", grpc_authx_go.AccessPrimitive_ORG)
+		memberRole = addRole("member", grpc_authx_go.AccessPrimitive_PROFILE)
+		gomega.Expect(rules.Add(entities.ClaimRules{
+			OrganizationId:       organizationID,
+			Issuer:               issuer,
+			RequireEmailVerified: true,
+			NameClaim:            "given_name",
+			LastNameClaim:        "family_name",
+			TitleClaim:           "job_title",
+			RoleRules:            []entities.RoleRule{{Claim: "groups", Value: "Admins", RoleId: adminRole.RoleId}},
+			DefaultRoleId:        memberRole.RoleId,
+			SyncRole:             true,
+		})).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should create the user on the first login", func() {
+		response, err := manager.ProvisionFromAssertion(assertion("jane@example.com", "Jane", "admins"))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Created).To(gomega.BeTrue())
+		gomega.Expect(response.User.Name).To(gomega.Equal("Jane"))
+		gomega.Expect(response.User.Title).To(gomega.Equal("Engineer"))
+		gomega.Expect(response.User.RoleId).To(gomega.Equal(adminRole.RoleId))
+		gomega.Expect(response.User.PasswordResetRequired).To(gomega.BeFalse())
+	})
+
+	ginkgo.It("should be idempotent on subsequent logins", func() {
+		_, err := manager.ProvisionFromAssertion(assertion("jane@example.com", "Jane"))
+		gomega.Expect(err).To(gomega.Succeed())
+		response, err := manager.ProvisionFromAssertion(assertion("jane@example.com", "Jane"))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Created).To(gomega.BeFalse())
+		gomega.Expect(response.Updated).To(gomega.BeFalse())
+		gomega.Expect(response.RoleChanged).To(gomega.BeFalse())
+		gomega.Expect(response.User.RoleId).To(gomega.Equal(memberRole.RoleId))
+	})
+
+	ginkgo.It("should keep the attributes and the role in sync", func() {
+		_, err := manager.ProvisionFromAssertion(assertion("jane@example.com", "Jane"))
+		gomega.Expect(err).To(gomega.Succeed())
+		response, err := manager.ProvisionFromAssertion(assertion("jane@example.com", "Janet", "Admins"))
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Updated).To(gomega.BeTrue())
+		gomega.Expect(response.RoleChanged).To(gomega.BeTrue())
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: "jane@example.com"})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.Name).To(gomega.Equal("Janet"))
+		gomega.Expect(user.RoleId).To(gomega.Equal(adminRole.RoleId))
+	})
+
+	ginkgo.It("should reject untrusted issuers and unverified emails", func() {
+		request := assertion("jane@example.com", "Jane")
+		request.Issuer = "https://other.example.com"
+		_, err := manager.ProvisionFromAssertion(request)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		request = assertion("jane@example.com", "Jane")
+		request.Claims[1].Values = []string{"false"}
+		_, err = manager.ProvisionFromAssertion(request)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+
+	ginkgo.It("should reject organizations without claim rules", func() {
+		request := assertion("jane@example.com", "Jane")
+		request.OrganizationId = "org-2"
+		_, err := manager.ProvisionFromAssertion(request)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should reject assertions without a matching role", func() {
+		current, err := rules.Get(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		current.DefaultRoleId = ""
+		gomega.Expect(rules.Add(*current)).To(gomega.Succeed())
+		_, pErr := manager.ProvisionFromAssertion(assertion("jane@example.com", "Jane"))
+		gomega.Expect(errorType(pErr)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+})
@@ -0,0 +1,170 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/provider/recyclebin"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Recycle bin", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var usersClient *utils.FakeUsersClient
+	var recycleBin *recyclebin.MockupRecycleBinProvider
+	var roles []*grpc_authx_go.Role
+
+	memberID := &grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail}
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		usersClient = utils.NewFakeUsersClient()
+		recycleBin = recyclebin.NewMockupRecycleBinProvider()
+		providers := NewMockupProviders()
+		providers.RecycleBin = recycleBin
+		manager = NewManager(authxClient, usersClient, utils.NewFakeRolesClient(), providers, testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		roles = response.Roles
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+			RoleId:         roles[1].RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the snapshot of a removed user and restore it", func() {
+		gomega.Expect(manager.RemoveUser(memberID)).To(gomega.Succeed())
+		_, err := recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		restored, rErr := manager.RestoreUser(memberID)
+		gomega.Expect(rErr).To(gomega.Succeed())
+		gomega.Expect(restored.PasswordResetRequired).To(gomega.BeTrue())
+		_, err = recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(err.Type()).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should not restore a user whose email is no longer available", func() {
+		gomega.Expect(manager.RemoveUser(memberID)).To(gomega.Succeed())
+		_, err := manager.SetEmailDomainPolicy(&grpc_user_manager_go.EmailDomainPolicy{
+			OrganizationId: organizationID,
+			DeniedDomains:  []string{"example.com"},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.RestoreUser(memberID)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+
+		_, err = manager.SetEmailDomainPolicy(&grpc_user_manager_go.EmailDomainPolicy{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Other",
+			LastName:       "LastName",
+			Title:          "Title",
+			RoleId:         roles[1].RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.RestoreUser(memberID)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		_, gErr := recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should discard the snapshot if the credentials cannot be deleted", func() {
+		authxClient.FailOn("DeleteCredentials", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		err := manager.RemoveUser(memberID)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		_, gErr := recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(gErr.Type()).To(gomega.Equal(derrors.NotFound))
+		_, err = manager.GetUser(memberID)
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should keep the credentials if the user cannot be removed from system model", func() {
+		usersClient.FailOn("RemoveUser/"+memberEmail, conversions.ToGRPCError(derrors.NewUnavailableError("system model")))
+		err := manager.RemoveUser(memberID)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		_, gErr := recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(gErr.Type()).To(gomega.Equal(derrors.NotFound))
+		member, err := manager.GetUser(memberID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(member.PasswordResetRequired).To(gomega.BeFalse())
+		password, exists := authxClient.Password(organizationID, memberEmail)
+		gomega.Expect(exists).To(gomega.BeTrue())
+		gomega.Expect(password).To(gomega.Equal("password"))
+
+		usersClient.FailOn("RemoveUser/"+memberEmail, nil)
+		gomega.Expect(manager.RemoveUser(memberID)).To(gomega.Succeed())
+		_, err = usersClient.GetUser(context.Background(), memberID)
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		_, gErr = recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should restore the user on system model if the credentials cannot be removed", func() {
+		before, err := usersClient.GetUser(context.Background(), memberID)
+		gomega.Expect(err).To(gomega.Succeed())
+		authxClient.FailOn("DeleteCredentials", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		err = manager.RemoveUser(memberID)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		_, gErr := recycleBin.Get(organizationID, memberEmail)
+		gomega.Expect(gErr.Type()).To(gomega.Equal(derrors.NotFound))
+		after, err := usersClient.GetUser(context.Background(), memberID)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(after).To(gomega.Equal(before))
+		password, exists := authxClient.Password(organizationID, memberEmail)
+		gomega.Expect(exists).To(gomega.BeTrue())
+		gomega.Expect(password).To(gomega.Equal("password"))
+
+		authxClient.FailOn("DeleteCredentials", nil)
+		gomega.Expect(manager.RemoveUser(memberID)).To(gomega.Succeed())
+		_, exists = authxClient.Password(organizationID, memberEmail)
+		gomega.Expect(exists).To(gomega.BeFalse())
+	})
+})
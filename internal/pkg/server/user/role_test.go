@@ -0,0 +1,164 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Role update", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var rolesClient *utils.FakeRolesClient
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addRole := func(name string, internal bool, primitive grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			Description:    name,
+			Internal:       internal,
+			Primitives:     []grpc_authx_go.AccessPrimitive{primitive},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	authxRole := func(roleID string) *grpc_authx_go.Role {
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		for _, role := range roles.Roles {
+			if role.RoleId == roleID {
+				return role
+			}
+		}
+		ginkgo.Fail("role not found")
+		return nil
+	}
+
+	smRole := func(roleID string) *grpc_role_go.Role {
+		role, err := rolesClient.GetRole(context.Background(), &grpc_role_go.RoleId{OrganizationId: organizationID, RoleId: roleID})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	primitives := func(roleID string, primitives ...grpc_authx_go.AccessPrimitive) *grpc_user_manager_go.UpdateRoleRequest {
+		return &grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           roleID,
+			UpdatePrimitives: true,
+			Primitives:       primitives,
+		}
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		rolesClient = utils.NewFakeRolesClient()
+		manager = NewManager(authxClient, utils.NewFakeUsersClient(), rolesClient,
+			NewMockupProviders(), testSettings())
+		ownerRole = addRole("owner", false, grpc_authx_go.AccessPrimitive_ORG)
+		memberRole = addRole("member", false, grpc_authx_go.AccessPrimitive_PROFILE)
+		addUser(ownerEmail, ownerRole.RoleId)
+		addUser(memberEmail, memberRole.RoleId)
+	})
+
+	ginkgo.It("should update the role in both stores", func() {
+		updated, err := manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:    organizationID,
+			RoleId:            memberRole.RoleId,
+			UpdateName:        true,
+			Name:              "developer",
+			UpdateDescription: true,
+			Description:       "Application developers",
+			UpdatePrimitives:  true,
+			Primitives:        []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE, grpc_authx_go.AccessPrimitive_APPS},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(updated.Name).To(gomega.Equal("developer"))
+		gomega.Expect(authxRole(memberRole.RoleId).Primitives).To(gomega.ConsistOf(
+			grpc_authx_go.AccessPrimitive_PROFILE, grpc_authx_go.AccessPrimitive_APPS))
+		gomega.Expect(smRole(memberRole.RoleId).Name).To(gomega.Equal("developer"))
+		gomega.Expect(smRole(memberRole.RoleId).Description).To(gomega.Equal("Application developers"))
+	})
+
+	ginkgo.It("should not remove the ORG primitive from the last owner role", func() {
+		_, err := manager.UpdateRole(primitives(ownerRole.RoleId, grpc_authx_go.AccessPrimitive_PROFILE))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(authxRole(ownerRole.RoleId).Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_ORG))
+	})
+
+	ginkgo.It("should invalidate the owners when the ORG primitive changes", func() {
+		_, err := manager.UpdateRole(primitives(memberRole.RoleId, grpc_authx_go.AccessPrimitive_ORG))
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.UpdateRole(primitives(ownerRole.RoleId, grpc_authx_go.AccessPrimitive_PROFILE))
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: memberEmail})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		err = manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: ownerEmail})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should restore system model if authx fails", func() {
+		authxClient.FailOn("UpdateRole", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err := manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId: organizationID,
+			RoleId:         memberRole.RoleId,
+			UpdateName:     true,
+			Name:           "developer",
+		})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+		gomega.Expect(smRole(memberRole.RoleId).Name).To(gomega.Equal("member"))
+		gomega.Expect(authxRole(memberRole.RoleId).Name).To(gomega.Equal("member"))
+	})
+
+	ginkgo.It("should not update internal roles", func() {
+		internal := addRole("internal", true, grpc_authx_go.AccessPrimitive_APPCLUSTEROPS)
+		_, err := manager.UpdateRole(primitives(internal.RoleId, grpc_authx_go.AccessPrimitive_APPS))
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.PermissionDenied))
+	})
+})
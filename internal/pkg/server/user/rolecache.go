@@ -0,0 +1,108 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"sync"
+	"time"
+)
+
+// rolesCacheTTL with the time the roles of an organization are kept before reloading them from authx, so changes made
+// by other components are eventually picked up.
+const rolesCacheTTL = time.Minute
+
+// organizationRoles with the roles of an organization indexed by role_id, with their effective primitives.
+type organizationRoles struct {
+	roles    map[string]*grpc_authx_go.Role
+	loadedAt time.Time
+}
+
+// RolesCache keeps the roles and effective primitives of the organizations to evaluate access checks without querying
+// authx.
+type RolesCache struct {
+	// lock shared by all the copies of the cache
+	lock *sync.Mutex
+	// roles indexed by organization_id
+	organizations map[string]*organizationRoles
+
+	accessClient  grpc_authx_go.AuthxClient
+	roleHierarchy rolehierarchy.Provider
+}
+
+func NewRolesCache(accessClient grpc_authx_go.AuthxClient, roleHierarchy rolehierarchy.Provider) RolesCache {
+	return RolesCache{lock: &sync.Mutex{},
+		organizations: make(map[string]*organizationRoles, 0),
+		accessClient:  accessClient,
+		roleHierarchy: roleHierarchy}
+}
+
+// Clear removes the roles of an organization from the cache.
+func (rc *RolesCache) Clear(organizationID string) derrors.Error {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+	delete(rc.organizations, organizationID)
+	return nil
+}
+
+// GetRole retrieves a role of an organization. The roles are reloaded if the role is not found in the cache, so roles
+// added after the last load are found.
+func (rc *RolesCache) GetRole(organizationID string, roleID string) (*grpc_authx_go.Role, derrors.Error) {
+	rc.lock.Lock()
+	defer rc.lock.Unlock()
+
+	cached, exists := rc.organizations[organizationID]
+	if exists && time.Since(cached.loadedAt) < rolesCacheTTL {
+		if role, found := cached.roles[roleID]; found {
+			return role, nil
+		}
+	}
+	err := rc.load(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	role, found := rc.organizations[organizationID].roles[roleID]
+	if !found {
+		return nil, derrors.NewNotFoundError("role").WithParams(organizationID, roleID)
+	}
+	return role, nil
+}
+
+// load retrieves the roles of an organization from authx and resolves their effective primitives.
+func (rc *RolesCache) load(organizationID string) derrors.Error {
+	roles, err := rc.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{
+		OrganizationId: organizationID,
+	})
+	if err != nil {
+		return conversions.ToDerror(err)
+	}
+	effective, hErr := effectiveRoles(rc.roleHierarchy, organizationID, roles.Roles)
+	if hErr != nil {
+		return hErr
+	}
+	loaded := &organizationRoles{roles: make(map[string]*grpc_authx_go.Role, len(effective)), loadedAt: time.Now()}
+	for _, role := range effective {
+		loaded.roles[role.RoleId] = role
+	}
+	rc.organizations[organizationID] = loaded
+	return nil
+}
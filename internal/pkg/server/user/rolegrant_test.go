@@ -0,0 +1,181 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/audit"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolegrant"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Temporary role assignments", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var grants *rolegrant.MockupRoleGrantProvider
+	var auditLog *audit.MockupAuditProvider
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+	var organization *grpc_organization_go.OrganizationId
+
+	addRole := func(name string, primitive grpc_authx_go.AccessPrimitive) *grpc_authx_go.Role {
+		role, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			Description:    name,
+			Primitives:     []grpc_authx_go.AccessPrimitive{primitive},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role
+	}
+
+	addUser := func(email string, roleID string) {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         roleID,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	}
+
+	grant := func(email string, roleID string, expiresAt int64) (*grpc_user_manager_go.User, error) {
+		return manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          email,
+			RoleId:         roleID,
+			ExpiresAt:      expiresAt,
+		})
+	}
+
+	expire := func(email string) {
+		active, err := grants.Get(organizationID, email)
+		gomega.Expect(err).To(gomega.Succeed())
+		active.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+		gomega.Expect(grants.Set(*active)).To(gomega.Succeed())
+	}
+
+	roleOf := func(email string) string {
+		user, err := manager.GetUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+		gomega.Expect(err).To(gomega.Succeed())
+		return user.RoleId
+	}
+
+	actions := func() []string {
+		entries, err := auditLog.List(organizationID)
+		gomega.Expect(err).To(gomega.Succeed())
+		result := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			result = append(result, entry.Action)
+		}
+		return result
+	}
+
+	ginkgo.BeforeEach(func() {
+		grants = rolegrant.NewMockupRoleGrantProvider()
+		auditLog = audit.NewMockupAuditProvider()
+		providers := NewMockupProviders()
+		providers.RoleGrants = grants
+		providers.AuditLog = auditLog
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			providers, testSettings())
+		ownerRole = addRole("owner", grpc_authx_go.AccessPrimitive_ORG)
+		memberRole = addRole("member", grpc_authx_go.AccessPrimitive_PROFILE)
+		addUser(ownerEmail, ownerRole.RoleId)
+		addUser(memberEmail, memberRole.RoleId)
+		organization = &grpc_organization_go.OrganizationId{OrganizationId: organizationID}
+	})
+
+	ginkgo.It("should grant a role temporarily and revert it once expired", func() {
+		expiresAt := time.Now().Add(time.Hour).Unix()
+		user, err := grant(memberEmail, ownerRole.RoleId, expiresAt)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(user.RoleId).To(gomega.Equal(ownerRole.RoleId))
+
+		list, err := manager.ListRoleGrants(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.RoleGrants).To(gomega.HaveLen(1))
+		gomega.Expect(list.RoleGrants[0].PreviousRoleId).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(list.RoleGrants[0].ExpiresAt).To(gomega.Equal(expiresAt))
+
+		gomega.Expect(manager.RevertExpiredRoleGrants()).To(gomega.Succeed())
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(ownerRole.RoleId))
+
+		expire(memberEmail)
+		gomega.Expect(manager.RevertExpiredRoleGrants()).To(gomega.Succeed())
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(memberRole.RoleId))
+		list, err = manager.ListRoleGrants(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.RoleGrants).To(gomega.BeEmpty())
+		gomega.Expect(actions()).To(gomega.Equal([]string{entities.AuditRoleGranted, entities.AuditRoleReverted}))
+	})
+
+	ginkgo.It("should keep the original role when a grant is extended", func() {
+		_, err := grant(memberEmail, ownerRole.RoleId, time.Now().Add(time.Hour).Unix())
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = grant(memberEmail, ownerRole.RoleId, time.Now().Add(2*time.Hour).Unix())
+		gomega.Expect(err).To(gomega.Succeed())
+		active, gErr := grants.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(active.PreviousRoleId).To(gomega.Equal(memberRole.RoleId))
+	})
+
+	ginkgo.It("should cancel the grant on a permanent assignment", func() {
+		_, err := grant(memberEmail, ownerRole.RoleId, time.Now().Add(time.Hour).Unix())
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = grant(memberEmail, ownerRole.RoleId, 0)
+		gomega.Expect(err).To(gomega.Succeed())
+		list, err := manager.ListRoleGrants(organization)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.RoleGrants).To(gomega.BeEmpty())
+		gomega.Expect(actions()).To(gomega.ContainElement(entities.AuditRoleGrantReplaced))
+	})
+
+	ginkgo.It("should reject expirations in the past", func() {
+		_, err := grant(memberEmail, ownerRole.RoleId, time.Now().Add(-time.Hour).Unix())
+		gomega.Expect(conversions.ToDerror(err).Type()).To(gomega.Equal(derrors.InvalidArgument))
+	})
+
+	ginkgo.It("should not revert the last owner", func() {
+		_, err := grant(memberEmail, ownerRole.RoleId, time.Now().Add(time.Hour).Unix())
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: ownerEmail})).To(gomega.Succeed())
+
+		expire(memberEmail)
+		gomega.Expect(manager.RevertExpiredRoleGrants()).To(gomega.Succeed())
+		gomega.Expect(manager.RevertExpiredRoleGrants()).To(gomega.Succeed())
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(ownerRole.RoleId))
+		active, gErr := grants.Get(organizationID, memberEmail)
+		gomega.Expect(gErr).To(gomega.Succeed())
+		gomega.Expect(active.RevertError).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(actions()).To(gomega.Equal([]string{entities.AuditRoleGranted, entities.AuditRoleRevertFailed}))
+	})
+})
@@ -0,0 +1,227 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"github.com/rs/zerolog/log"
+)
+
+// ListRoleHierarchy lists the roles of an organization with their parents, the primitives they declare and the
+// effective ones including the inherited primitives.
+func (m *Manager) ListRoleHierarchy(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.RoleHierarchyList, error) {
+	roles, err := m.accessClient.ListRoles(context.Background(), organizationID)
+	if err != nil {
+		return nil, err
+	}
+	declared, hierarchy, hErr := declaredRoles(m.roleHierarchy, organizationID.OrganizationId, roles.Roles)
+	if hErr != nil {
+		return nil, conversions.ToGRPCError(hErr)
+	}
+	effective := hierarchy.EffectiveRoles(declared)
+	result := make([]*grpc_user_manager_go.RoleHierarchy, 0, len(declared))
+	for index, role := range declared {
+		result = append(result, &grpc_user_manager_go.RoleHierarchy{
+			OrganizationId:      role.OrganizationId,
+			RoleId:              role.RoleId,
+			Name:                role.Name,
+			ParentRoleIds:       hierarchy[role.RoleId],
+			Primitives:          role.Primitives,
+			EffectivePrimitives: effective[index].Primitives,
+		})
+	}
+	return &grpc_user_manager_go.RoleHierarchyList{Roles: result}, nil
+}
+
+// effectiveRole retrieves a role of an organization with its effective primitives.
+func (m *Manager) effectiveRole(organizationID string, roleID string) (*grpc_authx_go.Role, error) {
+	role, err := m.rolesCache.GetRole(organizationID, roleID)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return role, nil
+}
+
+// inheritedPrimitives retrieves the primitives declared by a role followed by the ones it inherits from its parent
+// roles, without duplicates.
+func (m *Manager) inheritedPrimitives(organizationID string, primitives []grpc_authx_go.AccessPrimitive, parentRoleIDs []string) ([]grpc_authx_go.AccessPrimitive, error) {
+	result := make([]grpc_authx_go.AccessPrimitive, 0, len(primitives))
+	included := make(map[grpc_authx_go.AccessPrimitive]bool, 0)
+	include := func(primitives []grpc_authx_go.AccessPrimitive) {
+		for _, primitive := range primitives {
+			if !included[primitive] {
+				included[primitive] = true
+				result = append(result, primitive)
+			}
+		}
+	}
+	include(primitives)
+	for _, parentRoleID := range parentRoleIDs {
+		parent, err := m.effectiveRole(organizationID, parentRoleID)
+		if err != nil {
+			return nil, err
+		}
+		include(parent.Primitives)
+	}
+	return result, nil
+}
+
+// checkParentRoles checks that the parents of a role are roles of the organization that can be used.
+func (m *Manager) checkParentRoles(organizationID string, parentRoleIDs []string) error {
+	for _, parentRoleID := range parentRoleIDs {
+		_, err := m.organizationRole(organizationID, parentRoleID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRoleParents checks the new parents of a role and returns the resulting hierarchy, which cannot have cycles.
+func (m *Manager) checkRoleParents(hierarchy entities.RoleHierarchy, organizationID string, roleID string, parentRoleIDs []string) (entities.RoleHierarchy, error) {
+	err := m.checkParentRoles(organizationID, parentRoleIDs)
+	if err != nil {
+		return nil, err
+	}
+	updated := hierarchy.WithParents(roleID, parentRoleIDs)
+	cErr := updated.CheckCycles()
+	if cErr != nil {
+		return nil, conversions.ToGRPCError(cErr)
+	}
+	return updated, nil
+}
+
+// setRoleParents persists the parents of a role with the primitives it declares. Roles without parents are removed
+// from the hierarchy, as their declared primitives are the effective ones kept by authx.
+func (m *Manager) setRoleParents(organizationID string, roleID string, parentRoleIDs []string, primitives []grpc_authx_go.AccessPrimitive) derrors.Error {
+	if len(parentRoleIDs) == 0 {
+		err := m.roleHierarchy.Remove(organizationID, roleID)
+		if err != nil && err.Type() != derrors.NotFound {
+			return err
+		}
+		return nil
+	}
+	return m.roleHierarchy.Set(*entities.NewRoleParents(organizationID, roleID, parentRoleIDs, primitives))
+}
+
+// loadRoleHierarchy retrieves the hierarchy of the roles of an organization.
+func loadRoleHierarchy(roleHierarchy rolehierarchy.Provider, organizationID string) (entities.RoleHierarchy, derrors.Error) {
+	parents, err := roleHierarchy.List(organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return entities.NewRoleHierarchy(parents), nil
+}
+
+// declaredRoles returns copies of the roles of an organization with the primitives they declare, together with the
+// hierarchy of the organization.
+func declaredRoles(roleHierarchy rolehierarchy.Provider, organizationID string, roles []*grpc_authx_go.Role) ([]*grpc_authx_go.Role, entities.RoleHierarchy, derrors.Error) {
+	parents, err := roleHierarchy.List(organizationID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entities.DeclaredRoles(roles, parents), entities.NewRoleHierarchy(parents), nil
+}
+
+// effectiveRoles returns copies of the roles of an organization with their effective primitives.
+func effectiveRoles(roleHierarchy rolehierarchy.Provider, organizationID string, roles []*grpc_authx_go.Role) ([]*grpc_authx_go.Role, derrors.Error) {
+	declared, hierarchy, err := declaredRoles(roleHierarchy, organizationID, roles)
+	if err != nil {
+		return nil, err
+	}
+	return hierarchy.EffectiveRoles(declared), nil
+}
+
+// revokedOwnerRoles retrieves the roles that grant the ORG primitive before a change of the roles or the hierarchy
+// and do not grant it afterwards.
+func revokedOwnerRoles(before []*grpc_authx_go.Role, beforeHierarchy entities.RoleHierarchy,
+	after []*grpc_authx_go.Role, afterHierarchy entities.RoleHierarchy) []string {
+	owners := make(map[string]bool, 0)
+	for _, role := range beforeHierarchy.EffectiveRoles(before) {
+		if hasPrimitive(role, grpc_authx_go.AccessPrimitive_ORG) {
+			owners[role.RoleId] = true
+		}
+	}
+	revoked := make([]string, 0)
+	for _, role := range afterHierarchy.EffectiveRoles(after) {
+		if owners[role.RoleId] && !hasPrimitive(role, grpc_authx_go.AccessPrimitive_ORG) {
+			revoked = append(revoked, role.RoleId)
+		}
+	}
+	return revoked
+}
+
+// changedRoles retrieves the updated role followed by the roles whose effective primitives change with it, as they
+// inherit from the role.
+func changedRoles(before []*grpc_authx_go.Role, after []*grpc_authx_go.Role, roleID string) []*grpc_authx_go.Role {
+	previous := make(map[string]*grpc_authx_go.Role, len(before))
+	for _, role := range before {
+		previous[role.RoleId] = role
+	}
+	result := make([]*grpc_authx_go.Role, 1)
+	for _, role := range after {
+		if role.RoleId == roleID {
+			result[0] = role
+		} else if !samePrimitives(previous[role.RoleId], role) {
+			result = append(result, role)
+		}
+	}
+	return result
+}
+
+// samePrimitives checks if two roles have the same primitives regardless of their order.
+func samePrimitives(role *grpc_authx_go.Role, other *grpc_authx_go.Role) bool {
+	if role == nil || len(role.Primitives) != len(other.Primitives) {
+		return false
+	}
+	for _, primitive := range role.Primitives {
+		if !hasPrimitive(other, primitive) {
+			return false
+		}
+	}
+	return true
+}
+
+// updateAuthxRoles updates a set of roles in authx. If a role cannot be updated, the roles updated before it are
+// restored to their previous version.
+func (m *Manager) updateAuthxRoles(updated []*grpc_authx_go.Role, previous []*grpc_authx_go.Role) error {
+	previousRoles := make(map[string]*grpc_authx_go.Role, len(previous))
+	for _, role := range previous {
+		previousRoles[role.RoleId] = role
+	}
+	for index, role := range updated {
+		_, err := m.accessClient.UpdateRole(context.Background(), role)
+		if err != nil {
+			for _, done := range updated[:index] {
+				_, rErr := m.accessClient.UpdateRole(context.Background(), previousRoles[done.RoleId])
+				if rErr != nil {
+					log.Error().Str("organizationID", done.OrganizationId).Str("roleID", done.RoleId).
+						Msg("cannot restore the role in authx after the update failed")
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Role hierarchy", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var ownerRole *grpc_authx_go.Role
+	var operatorRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	addRole := func(name string, primitives []grpc_authx_go.AccessPrimitive, parents ...string) (*grpc_authx_go.Role, error) {
+		return manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           name,
+			Primitives:     primitives,
+			ParentRoleIds:  parents,
+		})
+	}
+
+	listedRole := func(roleID string) *grpc_authx_go.Role {
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		for _, role := range roles.Roles {
+			if role.RoleId == roleID {
+				return role
+			}
+		}
+		ginkgo.Fail("role not found")
+		return nil
+	}
+
+	authxPrimitives := func(roleID string) []grpc_authx_go.AccessPrimitive {
+		roles, err := authxClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		for _, role := range roles.Roles {
+			if role.RoleId == roleID {
+				return role.Primitives
+			}
+		}
+		ginkgo.Fail("role not found in authx")
+		return nil
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		manager = NewManager(authxClient, utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		ownerRole = response.Roles[0]
+		operatorRole = response.Roles[1]
+	})
+
+	ginkgo.It("should inherit the primitives of the parent roles", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(listedRole(lead.RoleId).Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_PROFILE))
+
+		hierarchy, err := manager.ListRoleHierarchy(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		found := false
+		for _, role := range hierarchy.Roles {
+			if role.RoleId == lead.RoleId {
+				found = true
+				gomega.Expect(role.ParentRoleIds).To(gomega.Equal([]string{operatorRole.RoleId}))
+				gomega.Expect(role.Primitives).To(gomega.Equal([]grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}))
+				gomega.Expect(role.EffectivePrimitives).To(gomega.HaveLen(3))
+			}
+		}
+		gomega.Expect(found).To(gomega.BeTrue())
+
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         lead.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		access, err := manager.CheckAccess(&grpc_user_manager_go.CheckAccessRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_RESOURCES},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(access.Decisions[0].Allowed).To(gomega.BeTrue())
+	})
+
+	ginkgo.It("should follow the changes of the parent roles", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           operatorRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_DEVMNGR},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(listedRole(lead.RoleId).Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_DEVMNGR))
+	})
+
+	ginkgo.It("should store the effective primitives in authx", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(lead.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_PROFILE))
+		gomega.Expect(authxPrimitives(lead.RoleId)).To(gomega.ConsistOf(lead.Primitives))
+
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           operatorRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_DEVMNGR},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(authxPrimitives(lead.RoleId)).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_DEVMNGR))
+
+		updated, err := manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           lead.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(updated.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_PROFILE,
+			grpc_authx_go.AccessPrimitive_DEVMNGR))
+		gomega.Expect(authxPrimitives(lead.RoleId)).To(gomega.ConsistOf(updated.Primitives))
+
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId: organizationID,
+			RoleId:         lead.RoleId,
+			UpdateParents:  true,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(authxPrimitives(lead.RoleId)).To(gomega.Equal([]grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE}))
+	})
+
+	ginkgo.It("should restore the roles inheriting from the updated role if authx fails", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		senior, err := addRole("Senior", nil, lead.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		authxClient.FailOn("UpdateRole/Senior", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           operatorRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_DEVMNGR},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		gomega.Expect(authxPrimitives(operatorRole.RoleId)).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_RESOURCES,
+			grpc_authx_go.AccessPrimitive_PROFILE))
+		gomega.Expect(authxPrimitives(lead.RoleId)).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_PROFILE))
+		gomega.Expect(authxPrimitives(senior.RoleId)).To(gomega.ConsistOf(authxPrimitives(lead.RoleId)))
+	})
+
+	ginkgo.It("should reject cycles", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		senior, err := addRole("Senior", nil, lead.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId: organizationID,
+			RoleId:         operatorRole.RoleId,
+			UpdateParents:  true,
+			ParentRoleIds:  []string{senior.RoleId},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(listedRole(operatorRole.RoleId).Primitives).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("should reject unknown parents", func() {
+		_, err := addRole("Lead", nil, "unknown")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(roles.Roles).To(gomega.HaveLen(3))
+	})
+
+	ginkgo.It("should not remove the parent of other roles", func() {
+		_, err := addRole("Lead", nil, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.RemoveRole(&grpc_authx_go.RoleId{OrganizationId: organizationID, RoleId: operatorRole.RoleId})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should count users with an inherited ORG primitive as owners", func() {
+		coOwner, err := addRole("CoOwner", nil, ownerRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         coOwner.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: ownerEmail})).To(gomega.Succeed())
+
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId:   organizationID,
+			RoleId:           ownerRole.RoleId,
+			UpdatePrimitives: true,
+			Primitives:       []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		_, err = manager.UpdateRole(&grpc_user_manager_go.UpdateRoleRequest{
+			OrganizationId: organizationID,
+			RoleId:         coOwner.RoleId,
+			UpdateParents:  true,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should copy the effective primitives when cloning into another organization", func() {
+		lead, err := addRole("Lead", []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS}, operatorRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		clone, err := manager.CloneRole(&grpc_user_manager_go.CloneRoleRequest{
+			OrganizationId:       organizationID,
+			RoleId:               lead.RoleId,
+			TargetOrganizationId: "org-2",
+			Name:                 "Lead",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(clone.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS,
+			grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_PROFILE))
+	})
+})
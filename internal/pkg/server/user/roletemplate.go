@@ -0,0 +1,169 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/rs/zerolog/log"
+)
+
+// ListRoleTemplates retrieves the role templates that can be applied to the organizations.
+func (m *Manager) ListRoleTemplates(_ *grpc_common_go.Empty) (*grpc_user_manager_go.RoleTemplateList, error) {
+	templates, err := m.roleTemplates.List()
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	result := make([]*grpc_user_manager_go.RoleTemplate, 0, len(templates))
+	for index := range templates {
+		result = append(result, templates[index].ToGRPC())
+	}
+	return &grpc_user_manager_go.RoleTemplateList{RoleTemplates: result}, nil
+}
+
+// ApplyRoleTemplate creates the roles of a template in an organization. Roles are matched by name, so the roles that
+// already exist are left untouched and applying the same template twice does not create new roles. The template is
+// applied as a whole: if a role cannot be created, the roles created before it are removed.
+func (m *Manager) ApplyRoleTemplate(request *grpc_user_manager_go.ApplyRoleTemplateRequest) (*grpc_user_manager_go.ApplyRoleTemplateResponse, error) {
+	template, tErr := m.roleTemplates.Get(request.TemplateName)
+	if tErr != nil {
+		return nil, conversions.ToGRPCError(tErr)
+	}
+	roles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: request.OrganizationId})
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*grpc_authx_go.Role, len(roles.Roles))
+	for _, role := range roles.Roles {
+		byName[role.Name] = role
+	}
+	response := &grpc_user_manager_go.ApplyRoleTemplateResponse{
+		OrganizationId: request.OrganizationId,
+		TemplateName:   template.Name,
+		Created:        make([]*grpc_authx_go.Role, 0),
+		Existing:       make([]*grpc_authx_go.Role, 0),
+	}
+	toCreate := make([]*grpc_user_manager_go.AddRoleRequest, 0, len(template.Roles))
+	for index := range template.Roles {
+		templateRole := &template.Roles[index]
+		if existing, exists := byName[templateRole.Name]; exists {
+			response.Existing = append(response.Existing, existing)
+			continue
+		}
+		primitives, pErr := templateRole.AccessPrimitives()
+		if pErr != nil {
+			return nil, conversions.ToGRPCError(pErr)
+		}
+		toCreate = append(toCreate, &grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: request.OrganizationId,
+			Name:           templateRole.Name,
+			Description:    templateRole.Description,
+			Primitives:     primitives,
+		})
+	}
+	for _, addRequest := range toCreate {
+		added, err := m.AddRole(addRequest)
+		if err != nil {
+			log.Warn().Str("organizationID", request.OrganizationId).Str("template", template.Name).
+				Str("role", addRequest.Name).Msg("cannot create template role")
+			m.rollbackTemplateRoles(request.OrganizationId, response.Created)
+			return nil, err
+		}
+		response.Created = append(response.Created, added)
+	}
+	return response, nil
+}
+
+// rollbackTemplateRoles removes the roles created by a template that could not be applied.
+func (m *Manager) rollbackTemplateRoles(organizationID string, created []*grpc_authx_go.Role) {
+	_ = m.usersCache.Clear(organizationID)
+	for _, role := range created {
+		err := m.removeRole(organizationID, role.RoleId)
+		if err != nil {
+			log.Error().Str("organizationID", organizationID).Str("roleID", role.RoleId).
+				Str("err", conversions.ToDerror(err).DebugReport()).Msg("cannot remove role created by the template")
+		}
+	}
+}
+
+// CloneRole creates a copy of a role with a new name, either in the same organization or in the target one. A copy in
+// the same organization keeps the parents of the role, while a copy in another organization receives the effective
+// primitives as its parents do not exist there.
+func (m *Manager) CloneRole(request *grpc_user_manager_go.CloneRoleRequest) (*grpc_authx_go.Role, error) {
+	source, err := m.organizationRole(request.OrganizationId, request.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	targetID := request.TargetOrganizationId
+	if targetID == "" {
+		targetID = request.OrganizationId
+	}
+	targetRoles, err := m.accessClient.ListRoles(context.Background(), &grpc_organization_go.OrganizationId{OrganizationId: targetID})
+	if err != nil {
+		return nil, err
+	}
+	for _, role := range targetRoles.Roles {
+		if role.Name == request.Name {
+			return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("role").WithParams(targetID, request.Name))
+		}
+	}
+	description := request.Description
+	if description == "" {
+		smRole, err := m.roleClient.GetRole(context.Background(), &grpc_role_go.RoleId{
+			OrganizationId: request.OrganizationId,
+			RoleId:         request.RoleId,
+		})
+		if err != nil {
+			return nil, err
+		}
+		description = smRole.Description
+	}
+	// authx keeps the effective primitives, so a copy in the same organization takes the ones declared by the source
+	primitives := source.Primitives
+	var parentRoleIDs []string
+	if targetID == request.OrganizationId {
+		parents, hErr := m.roleHierarchy.Get(request.OrganizationId, request.RoleId)
+		if hErr != nil && hErr.Type() != derrors.NotFound {
+			return nil, conversions.ToGRPCError(hErr)
+		}
+		if parents != nil {
+			parentRoleIDs = parents.ParentRoleIds
+			primitives = parents.Primitives
+		}
+	} else {
+		effective, err := m.effectiveRole(request.OrganizationId, request.RoleId)
+		if err != nil {
+			return nil, err
+		}
+		primitives = effective.Primitives
+	}
+	copied := make([]grpc_authx_go.AccessPrimitive, len(primitives))
+	copy(copied, primitives)
+	return m.AddRole(&grpc_user_manager_go.AddRoleRequest{
+		OrganizationId: targetID,
+		Name:           request.Name,
+		Description:    description,
+		Primitives:     copied,
+		ParentRoleIds:  parentRoleIDs,
+	})
+}
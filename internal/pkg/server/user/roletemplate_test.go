@@ -0,0 +1,198 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/roletemplate"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+)
+
+var _ = ginkgo.Describe("Role templates", func() {
+
+	const organizationID = "org-1"
+	const otherOrganizationID = "org-2"
+	const templateName = "default"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var templates *roletemplate.MockupRoleTemplateProvider
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	roleNames := func(organizationID string) []string {
+		roles, err := manager.ListRoles(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		names := make([]string, 0, len(roles.Roles))
+		for _, role := range roles.Roles {
+			names = append(names, role.Name)
+		}
+		return names
+	}
+
+	ginkgo.BeforeEach(func() {
+		templates = roletemplate.NewMockupRoleTemplateProvider()
+		providers := NewMockupProviders()
+		providers.RoleTemplates = templates
+		authxClient = utils.NewFakeAuthxClient()
+		manager = NewManager(authxClient, utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			providers, testSettings())
+		err := templates.Add(entities.RoleTemplate{
+			Name:        templateName,
+			Description: "Default roles",
+			Roles: []entities.TemplateRole{
+				{Name: "Owner", Description: "Organization owner", Primitives: []string{"ORG"}},
+				{Name: "Developer", Description: "Application developer", Primitives: []string{"APPS", "PROFILE"}},
+			},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should list the templates", func() {
+		list, err := manager.ListRoleTemplates(&grpc_common_go.Empty{})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.RoleTemplates).To(gomega.HaveLen(1))
+		gomega.Expect(list.RoleTemplates[0].Roles).To(gomega.HaveLen(2))
+		gomega.Expect(list.RoleTemplates[0].Roles[1].Primitives).To(gomega.ConsistOf(
+			grpc_authx_go.AccessPrimitive_APPS, grpc_authx_go.AccessPrimitive_PROFILE))
+	})
+
+	ginkgo.It("should apply a template only once", func() {
+		request := &grpc_user_manager_go.ApplyRoleTemplateRequest{OrganizationId: organizationID, TemplateName: templateName}
+		first, err := manager.ApplyRoleTemplate(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(first.Created).To(gomega.HaveLen(2))
+		gomega.Expect(first.Existing).To(gomega.BeEmpty())
+
+		second, err := manager.ApplyRoleTemplate(request)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(second.Created).To(gomega.BeEmpty())
+		gomega.Expect(second.Existing).To(gomega.HaveLen(2))
+		gomega.Expect(roleNames(organizationID)).To(gomega.ConsistOf("Owner", "Developer"))
+	})
+
+	ginkgo.It("should only create the missing roles", func() {
+		_, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           "Owner",
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		response, err := manager.ApplyRoleTemplate(&grpc_user_manager_go.ApplyRoleTemplateRequest{
+			OrganizationId: organizationID, TemplateName: templateName})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Created).To(gomega.HaveLen(1))
+		gomega.Expect(response.Created[0].Name).To(gomega.Equal("Developer"))
+		gomega.Expect(response.Existing).To(gomega.HaveLen(1))
+	})
+
+	ginkgo.It("should remove the created roles if the template cannot be applied", func() {
+		authxClient.FailOn("AddRole/Developer", conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err := manager.ApplyRoleTemplate(&grpc_user_manager_go.ApplyRoleTemplateRequest{
+			OrganizationId: organizationID, TemplateName: templateName})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		gomega.Expect(roleNames(organizationID)).To(gomega.BeEmpty())
+
+		authxClient.FailOn("AddRole/Developer", nil)
+		response, err := manager.ApplyRoleTemplate(&grpc_user_manager_go.ApplyRoleTemplateRequest{
+			OrganizationId: organizationID, TemplateName: templateName})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.Created).To(gomega.HaveLen(2))
+	})
+
+	ginkgo.It("should not create any role if a template role is invalid", func() {
+		err := templates.Add(entities.RoleTemplate{
+			Name: "broken",
+			Roles: []entities.TemplateRole{
+				{Name: "Owner", Primitives: []string{"ORG"}},
+				{Name: "Broken", Primitives: []string{"UNKNOWN"}},
+			},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, aErr := manager.ApplyRoleTemplate(&grpc_user_manager_go.ApplyRoleTemplateRequest{
+			OrganizationId: organizationID, TemplateName: "broken"})
+		gomega.Expect(aErr).NotTo(gomega.Succeed())
+		gomega.Expect(roleNames(organizationID)).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should fail applying an unknown template", func() {
+		_, err := manager.ApplyRoleTemplate(&grpc_user_manager_go.ApplyRoleTemplateRequest{
+			OrganizationId: organizationID, TemplateName: "unknown"})
+		gomega.Expect(err).NotTo(gomega.Succeed())
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.Context("cloning roles", func() {
+		var source *grpc_authx_go.Role
+
+		ginkgo.BeforeEach(func() {
+			var err error
+			source, err = manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+				OrganizationId: organizationID,
+				Name:           "Operator",
+				Description:    "Cluster operator",
+				Primitives: []grpc_authx_go.AccessPrimitive{
+					grpc_authx_go.AccessPrimitive_RESOURCES, grpc_authx_go.AccessPrimitive_PROFILE},
+			})
+			gomega.Expect(err).To(gomega.Succeed())
+		})
+
+		ginkgo.It("should clone a role in the same organization", func() {
+			clone, err := manager.CloneRole(&grpc_user_manager_go.CloneRoleRequest{
+				OrganizationId: organizationID, RoleId: source.RoleId, Name: "Operator copy"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(clone.OrganizationId).To(gomega.Equal(organizationID))
+			gomega.Expect(clone.RoleId).NotTo(gomega.Equal(source.RoleId))
+			gomega.Expect(clone.Primitives).To(gomega.ConsistOf(source.Primitives))
+			gomega.Expect(roleNames(organizationID)).To(gomega.ConsistOf("Operator", "Operator copy"))
+		})
+
+		ginkgo.It("should clone a role into another organization", func() {
+			clone, err := manager.CloneRole(&grpc_user_manager_go.CloneRoleRequest{
+				OrganizationId: organizationID, RoleId: source.RoleId,
+				TargetOrganizationId: otherOrganizationID, Name: "Operator"})
+			gomega.Expect(err).To(gomega.Succeed())
+			gomega.Expect(clone.OrganizationId).To(gomega.Equal(otherOrganizationID))
+			gomega.Expect(clone.Primitives).To(gomega.ConsistOf(source.Primitives))
+			gomega.Expect(roleNames(otherOrganizationID)).To(gomega.ConsistOf("Operator"))
+		})
+
+		ginkgo.It("should reject a name already used in the target organization", func() {
+			_, err := manager.CloneRole(&grpc_user_manager_go.CloneRoleRequest{
+				OrganizationId: organizationID, RoleId: source.RoleId, Name: "Operator"})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+		})
+
+		ginkgo.It("should fail cloning an unknown role", func() {
+			_, err := manager.CloneRole(&grpc_user_manager_go.CloneRoleRequest{
+				OrganizationId: organizationID, RoleId: "unknown", Name: "Copy"})
+			gomega.Expect(err).NotTo(gomega.Succeed())
+			gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+		})
+	})
+})
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// AddServiceAccount adds a service account with a role of the organization.
+func (m *Manager) AddServiceAccount(request *grpc_user_manager_go.AddServiceAccountRequest) (*grpc_user_manager_go.ServiceAccount, error) {
+	role, err := m.organizationRole(request.OrganizationId, request.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	account, aErr := entities.NewServiceAccount(request)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	aErr = m.serviceAccounts.AddServiceAccount(*account)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	log.Debug().Str("organizationID", account.OrganizationId).Str("serviceAccountID", account.ServiceAccountId).
+		Msg("service account has been added")
+	return account.ToGRPC(role.Name), nil
+}
+
+// ListServiceAccounts lists the service accounts of an organization.
+func (m *Manager) ListServiceAccounts(organizationID *grpc_organization_go.OrganizationId) (*grpc_user_manager_go.ServiceAccountList, error) {
+	accounts, err := m.serviceAccounts.ListServiceAccounts(organizationID.OrganizationId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	roles, rErr := m.roleClient.ListRoles(context.Background(), organizationID)
+	if rErr != nil {
+		return nil, rErr
+	}
+	roleNames := make(map[string]string, len(roles.Roles))
+	for _, role := range roles.Roles {
+		roleNames[role.RoleId] = role.Name
+	}
+	result := make([]*grpc_user_manager_go.ServiceAccount, 0, len(accounts))
+	for _, account := range accounts {
+		result = append(result, account.ToGRPC(roleNames[account.RoleId]))
+	}
+	return &grpc_user_manager_go.ServiceAccountList{ServiceAccounts: result}, nil
+}
+
+// RemoveServiceAccount removes a service account revoking all its keys.
+func (m *Manager) RemoveServiceAccount(serviceAccountID *grpc_user_manager_go.ServiceAccountId) error {
+	err := m.serviceAccounts.RemoveServiceAccount(serviceAccountID.OrganizationId, serviceAccountID.ServiceAccountId)
+	if err != nil {
+		return conversions.ToGRPCError(err)
+	}
+	return nil
+}
+
+// CreateApiKey generates a new key for a service account. The key is only returned in this response.
+func (m *Manager) CreateApiKey(request *grpc_user_manager_go.CreateApiKeyRequest) (*grpc_user_manager_go.NewApiKey, error) {
+	account, err := m.serviceAccounts.GetServiceAccount(request.OrganizationId, request.ServiceAccountId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	if request.ExpiresAt != 0 && request.ExpiresAt <= time.Now().Unix() {
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError("expires_at must be in the future"))
+	}
+	role, rErr := m.serviceAccountRole(account)
+	if rErr != nil {
+		return nil, rErr
+	}
+	sErr := validScopes(role, request.Scopes)
+	if sErr != nil {
+		return nil, conversions.ToGRPCError(sErr)
+	}
+	return m.addApiKey(account, request.Scopes, request.ExpiresAt)
+}
+
+// RotateApiKey replaces a key with a new one with the same scopes and lifetime. The previous key stops working
+// immediately.
+func (m *Manager) RotateApiKey(keyID *grpc_user_manager_go.ApiKeyId) (*grpc_user_manager_go.NewApiKey, error) {
+	key, err := m.getApiKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	account, aErr := m.serviceAccounts.GetServiceAccount(key.OrganizationId, key.ServiceAccountId)
+	if aErr != nil {
+		return nil, conversions.ToGRPCError(aErr)
+	}
+	expiresAt := int64(0)
+	if key.ExpiresAt != 0 {
+		expiresAt = time.Now().Unix() + (key.ExpiresAt - key.Created)
+	}
+	newKey, nErr := m.addApiKey(account, key.Scopes, expiresAt)
+	if nErr != nil {
+		return nil, nErr
+	}
+	rErr := m.serviceAccounts.RemoveApiKey(key.KeyId)
+	if rErr != nil {
+		_ = m.serviceAccounts.RemoveApiKey(newKey.ApiKey.KeyId)
+		return nil, conversions.ToGRPCError(rErr)
+	}
+	log.Debug().Str("organizationID", key.OrganizationId).Str("serviceAccountID", key.ServiceAccountId).
+		Str("keyID", key.KeyId).Str("newKeyID", newKey.ApiKey.KeyId).Msg("api key has been rotated")
+	return newKey, nil
+}
+
+// ListApiKeys lists the keys of a service account without their secrets.
+func (m *Manager) ListApiKeys(serviceAccountID *grpc_user_manager_go.ServiceAccountId) (*grpc_user_manager_go.ApiKeyList, error) {
+	_, err := m.serviceAccounts.GetServiceAccount(serviceAccountID.OrganizationId, serviceAccountID.ServiceAccountId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	keys, err := m.serviceAccounts.ListApiKeys(serviceAccountID.OrganizationId, serviceAccountID.ServiceAccountId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	result := make([]*grpc_user_manager_go.ApiKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, key.ToGRPC())
+	}
+	return &grpc_user_manager_go.ApiKeyList{ApiKeys: result}, nil
+}
+
+// RevokeApiKey removes a key.
+func (m *Manager) RevokeApiKey(keyID *grpc_user_manager_go.ApiKeyId) error {
+	key, err := m.getApiKey(keyID)
+	if err != nil {
+		return err
+	}
+	rErr := m.serviceAccounts.RemoveApiKey(key.KeyId)
+	if rErr != nil {
+		return conversions.ToGRPCError(rErr)
+	}
+	return nil
+}
+
+// AuthenticateApiKey checks a key returning its service account and the primitives granted by the key, which are the
+// primitives of the role of the account restricted to the scopes of the key.
+func (m *Manager) AuthenticateApiKey(request *grpc_user_manager_go.AuthenticateApiKeyRequest) (*grpc_user_manager_go.ApiKeyAuthentication, error) {
+	keyID, err := entities.ApiKeyID(request.Key)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	invalidKey := conversions.ToGRPCError(derrors.NewUnauthenticatedError("invalid api key"))
+	key, err := m.serviceAccounts.GetApiKey(keyID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return nil, invalidKey
+		}
+		return nil, conversions.ToGRPCError(err)
+	}
+	if !key.Matches(request.Key) {
+		return nil, invalidKey
+	}
+	now := time.Now().Unix()
+	if key.IsExpired(now) {
+		return nil, conversions.ToGRPCError(derrors.NewUnauthenticatedError("api key has expired"))
+	}
+	account, err := m.serviceAccounts.GetServiceAccount(key.OrganizationId, key.ServiceAccountId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	role, rErr := m.serviceAccountRole(account)
+	if rErr != nil {
+		return nil, rErr
+	}
+	uErr := m.serviceAccounts.UpdateLastUsed(key.KeyId, now)
+	if uErr != nil {
+		return nil, conversions.ToGRPCError(uErr)
+	}
+	return &grpc_user_manager_go.ApiKeyAuthentication{
+		ServiceAccount: account.ToGRPC(role.Name),
+		KeyId:          key.KeyId,
+		Primitives:     scopedPrimitives(role, key.Scopes),
+	}, nil
+}
+
+// addApiKey generates and stores a key for a service account.
+func (m *Manager) addApiKey(account *entities.ServiceAccount, scopes []string, expiresAt int64) (*grpc_user_manager_go.NewApiKey, error) {
+	key, secret, err := entities.NewApiKey(account.OrganizationId, account.ServiceAccountId, scopes, expiresAt)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	err = m.serviceAccounts.AddApiKey(*key)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	return &grpc_user_manager_go.NewApiKey{ApiKey: key.ToGRPC(), Key: secret}, nil
+}
+
+// getApiKey retrieves a key checking that it belongs to the service account of the request.
+func (m *Manager) getApiKey(keyID *grpc_user_manager_go.ApiKeyId) (*entities.ApiKey, error) {
+	key, err := m.serviceAccounts.GetApiKey(keyID.KeyId)
+	if err != nil {
+		return nil, conversions.ToGRPCError(err)
+	}
+	if key.OrganizationId != keyID.OrganizationId || key.ServiceAccountId != keyID.ServiceAccountId {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("api key").WithParams(keyID.KeyId))
+	}
+	return key, nil
+}
+
+// serviceAccountRole retrieves the role of a service account with the primitives inherited from its parents.
+func (m *Manager) serviceAccountRole(account *entities.ServiceAccount) (*grpc_authx_go.Role, error) {
+	_, err := m.organizationRole(account.OrganizationId, account.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	return m.effectiveRole(account.OrganizationId, account.RoleId)
+}
+
+// validScopes checks that the scopes are effective primitives of the role.
+func validScopes(role *grpc_authx_go.Role, scopes []string) derrors.Error {
+	for _, scope := range scopes {
+		found := false
+		for _, primitive := range role.Primitives {
+			if primitive.String() == scope {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return derrors.NewInvalidArgumentError("scope is not granted by the role of the service account").WithParams(scope, role.RoleId)
+		}
+	}
+	return nil
+}
+
+// scopedPrimitives obtains the primitives of a role restricted to a set of scopes. All the primitives are granted if
+// there are no scopes.
+func scopedPrimitives(role *grpc_authx_go.Role, scopes []string) []grpc_authx_go.AccessPrimitive {
+	result := make([]grpc_authx_go.AccessPrimitive, 0, len(role.Primitives))
+	for _, primitive := range role.Primitives {
+		if len(scopes) == 0 {
+			result = append(result, primitive)
+			continue
+		}
+		for _, scope := range scopes {
+			if primitive.String() == scope {
+				result = append(result, primitive)
+				break
+			}
+		}
+	}
+	return result
+}
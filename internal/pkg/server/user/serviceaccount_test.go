@@ -0,0 +1,229 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/serviceaccount"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Service accounts", func() {
+
+	const organizationID = "org-1"
+
+	var manager Manager
+	var accounts *serviceaccount.MockupServiceAccountProvider
+	var ownerRole *grpc_authx_go.Role
+	var account *grpc_user_manager_go.ServiceAccount
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	createKey := func(scopes []string, expiresAt int64) *grpc_user_manager_go.NewApiKey {
+		key, err := manager.CreateApiKey(&grpc_user_manager_go.CreateApiKeyRequest{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+			Scopes:           scopes,
+			ExpiresAt:        expiresAt,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		return key
+	}
+
+	keyID := func(key *grpc_user_manager_go.NewApiKey) *grpc_user_manager_go.ApiKeyId {
+		return &grpc_user_manager_go.ApiKeyId{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+			KeyId:            key.ApiKey.KeyId,
+		}
+	}
+
+	authenticate := func(key string) (*grpc_user_manager_go.ApiKeyAuthentication, error) {
+		return manager.AuthenticateApiKey(&grpc_user_manager_go.AuthenticateApiKeyRequest{Key: key})
+	}
+
+	ginkgo.BeforeEach(func() {
+		accounts = serviceaccount.NewMockupServiceAccountProvider()
+		providers := NewMockupProviders()
+		providers.ServiceAccounts = accounts
+		manager = NewManager(utils.NewFakeAuthxClient(), utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			providers, testSettings())
+		var err error
+		ownerRole, err = manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           "owner",
+			Description:    "owner",
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_ORG, grpc_authx_go.AccessPrimitive_PROFILE},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		account, err = manager.AddServiceAccount(&grpc_user_manager_go.AddServiceAccountRequest{
+			OrganizationId: organizationID,
+			Name:           "ci",
+			Description:    "continuous integration",
+			RoleId:         ownerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should add, list and remove service accounts", func() {
+		gomega.Expect(account.ServiceAccountId).ShouldNot(gomega.BeEmpty())
+		gomega.Expect(account.RoleName).To(gomega.Equal("owner"))
+
+		_, err := manager.AddServiceAccount(&grpc_user_manager_go.AddServiceAccountRequest{
+			OrganizationId: organizationID,
+			Name:           "ci",
+			RoleId:         ownerRole.RoleId,
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.AlreadyExists))
+
+		list, err := manager.ListServiceAccounts(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.ServiceAccounts).To(gomega.HaveLen(1))
+
+		gomega.Expect(manager.RemoveServiceAccount(&grpc_user_manager_go.ServiceAccountId{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+		})).To(gomega.Succeed())
+		list, err = manager.ListServiceAccounts(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(list.ServiceAccounts).To(gomega.BeEmpty())
+	})
+
+	ginkgo.It("should reject unknown roles", func() {
+		_, err := manager.AddServiceAccount(&grpc_user_manager_go.AddServiceAccountRequest{
+			OrganizationId: organizationID,
+			Name:           "other",
+			RoleId:         "unknown",
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.NotFound))
+	})
+
+	ginkgo.It("should authenticate keys restricted to their scopes", func() {
+		key := createKey([]string{grpc_authx_go.AccessPrimitive_PROFILE.String()}, 0)
+		gomega.Expect(key.Key).ShouldNot(gomega.BeEmpty())
+
+		auth, err := authenticate(key.Key)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(auth.ServiceAccount.ServiceAccountId).To(gomega.Equal(account.ServiceAccountId))
+		gomega.Expect(auth.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_PROFILE))
+
+		keys, err := manager.ListApiKeys(&grpc_user_manager_go.ServiceAccountId{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(keys.ApiKeys).To(gomega.HaveLen(1))
+		gomega.Expect(keys.ApiKeys[0].LastUsed).ShouldNot(gomega.BeZero())
+
+		_, err = authenticate(key.Key + "x")
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+	})
+
+	ginkgo.It("should reject scopes not granted by the role", func() {
+		_, err := manager.CreateApiKey(&grpc_user_manager_go.CreateApiKeyRequest{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+			Scopes:           []string{grpc_authx_go.AccessPrimitive_APPS.String()},
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+	})
+
+	ginkgo.It("should grant the primitives inherited from the parent roles", func() {
+		parent, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           "developer",
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_APPS},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		child, err := manager.AddRole(&grpc_user_manager_go.AddRoleRequest{
+			OrganizationId: organizationID,
+			Name:           "lead",
+			Primitives:     []grpc_authx_go.AccessPrimitive{grpc_authx_go.AccessPrimitive_PROFILE},
+			ParentRoleIds:  []string{parent.RoleId},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		account, err = manager.AddServiceAccount(&grpc_user_manager_go.AddServiceAccountRequest{
+			OrganizationId: organizationID,
+			Name:           "deployer",
+			RoleId:         child.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+
+		scoped := createKey([]string{grpc_authx_go.AccessPrimitive_APPS.String()}, 0)
+		auth, aErr := authenticate(scoped.Key)
+		gomega.Expect(aErr).To(gomega.Succeed())
+		gomega.Expect(auth.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS))
+
+		unscoped := createKey(nil, 0)
+		auth, aErr = authenticate(unscoped.Key)
+		gomega.Expect(aErr).To(gomega.Succeed())
+		gomega.Expect(auth.Primitives).To(gomega.ConsistOf(grpc_authx_go.AccessPrimitive_APPS, grpc_authx_go.AccessPrimitive_PROFILE))
+	})
+
+	ginkgo.It("should reject expired keys", func() {
+		_, err := manager.CreateApiKey(&grpc_user_manager_go.CreateApiKeyRequest{
+			OrganizationId:   organizationID,
+			ServiceAccountId: account.ServiceAccountId,
+			ExpiresAt:        time.Now().Add(-time.Minute).Unix(),
+		})
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+
+		expired, key, kErr := entities.NewApiKey(organizationID, account.ServiceAccountId, nil, time.Now().Add(-time.Minute).Unix())
+		gomega.Expect(kErr).To(gomega.Succeed())
+		gomega.Expect(accounts.AddApiKey(*expired)).To(gomega.Succeed())
+		_, err = authenticate(key)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+	})
+
+	ginkgo.It("should rotate and revoke keys", func() {
+		key := createKey(nil, 0)
+		rotated, err := manager.RotateApiKey(keyID(key))
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = authenticate(key.Key)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+		_, err = authenticate(rotated.Key)
+		gomega.Expect(err).To(gomega.Succeed())
+
+		gomega.Expect(manager.RevokeApiKey(keyID(rotated))).To(gomega.Succeed())
+		_, err = authenticate(rotated.Key)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unauthenticated))
+	})
+
+	ginkgo.It("should not count service accounts as owners", func() {
+		_, err := manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          "owner@example.com",
+			Password:       "password",
+			Name:           "Owner",
+			RoleId:         ownerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		err = manager.RemoveUser(&grpc_user_go.UserId{OrganizationId: organizationID, Email: "owner@example.com"})
+		gomega.Expect(err).To(gomega.HaveOccurred())
+	})
+})
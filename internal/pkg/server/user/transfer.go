@@ -0,0 +1,145 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/rs/zerolog/log"
+)
+
+// TransferOwnership gives the owner role of a user to another one and demotes the former owner to a given role. The
+// new owner must be a user of the organization that is not an owner through any of its roles or groups. The transfer
+// is checked against the minimum number of owners as a whole instead of as two role assignments. The new owner is
+// restored to the previous role if the demotion fails.
+func (m *Manager) TransferOwnership(request *grpc_user_manager_go.TransferOwnershipRequest) (*grpc_user_manager_go.TransferOwnershipResponse, error) {
+	fromID := &grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: request.FromEmail}
+	toID := &grpc_user_go.UserId{OrganizationId: request.OrganizationId, Email: request.ToEmail}
+
+	ownerRole, err := m.accessClient.GetUserRole(context.Background(), fromID)
+	if err != nil {
+		return nil, err
+	}
+	effectiveOwnerRole, err := m.effectiveRole(request.OrganizationId, ownerRole.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	if !hasPrimitive(effectiveOwnerRole, grpc_authx_go.AccessPrimitive_ORG) {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError(fmt.Sprintf("the current owner does not hold a %s role", grpc_authx_go.AccessPrimitive_ORG)).WithParams(request.FromEmail))
+	}
+	if ownerRole.Internal {
+		return nil, conversions.ToGRPCError(derrors.NewPermissionDeniedError("internal roles cannot be transferred").WithParams(ownerRole.RoleId))
+	}
+	// service accounts never count as owners, so they cannot take over the ownership
+	_, err = m.usersClient.GetUser(context.Background(), toID)
+	if err != nil {
+		return nil, err
+	}
+	previousRole, err := m.accessClient.GetUserRole(context.Background(), toID)
+	if err != nil {
+		return nil, err
+	}
+	effectivePreviousRole, err := m.effectiveUserRole(request.OrganizationId, request.ToEmail, previousRole.RoleId)
+	if err != nil {
+		return nil, err
+	}
+	if hasPrimitive(effectivePreviousRole.Role, grpc_authx_go.AccessPrimitive_ORG) {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError(fmt.Sprintf("the new owner already holds a %s role", grpc_authx_go.AccessPrimitive_ORG)).WithParams(request.ToEmail))
+	}
+	_, err = m.organizationRole(request.OrganizationId, request.DemoteToRoleId)
+	if err != nil {
+		return nil, err
+	}
+	demoteRole, err := m.effectiveRole(request.OrganizationId, request.DemoteToRoleId)
+	if err != nil {
+		return nil, err
+	}
+	if hasPrimitive(demoteRole, grpc_authx_go.AccessPrimitive_ORG) {
+		return nil, conversions.ToGRPCError(derrors.NewInvalidArgumentError(fmt.Sprintf("the role of the former owner cannot have the %s primitive", grpc_authx_go.AccessPrimitive_ORG)).WithParams(request.DemoteToRoleId))
+	}
+	canTransfer, cErr := m.usersCache.CanTransferOwnership(request.OrganizationId, request.FromEmail, request.ToEmail)
+	if cErr != nil {
+		return nil, conversions.ToGRPCError(cErr)
+	}
+	if !canTransfer {
+		return nil, conversions.ToGRPCError(derrors.NewFailedPreconditionError(m.ownerQuorumMessage(request.OrganizationId, "can not transfer ownership")).WithParams(request.FromEmail, request.ToEmail))
+	}
+
+	// clear userCache
+	_ = m.usersCache.Clear(request.OrganizationId)
+	defer m.usersCache.Clear(request.OrganizationId)
+
+	// 1. Promote the new owner
+	_, err = m.accessClient.EditUserRole(context.Background(), &grpc_authx_go.EditUserRoleRequest{
+		OrganizationId: request.OrganizationId,
+		Username:       request.ToEmail,
+		NewRoleId:      ownerRole.RoleId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 2. Demote the former owner
+	_, err = m.accessClient.EditUserRole(context.Background(), &grpc_authx_go.EditUserRoleRequest{
+		OrganizationId: request.OrganizationId,
+		Username:       request.FromEmail,
+		NewRoleId:      demoteRole.RoleId,
+	})
+	if err != nil {
+		_, cErr := m.accessClient.EditUserRole(context.Background(), &grpc_authx_go.EditUserRoleRequest{
+			OrganizationId: request.OrganizationId,
+			Username:       request.ToEmail,
+			NewRoleId:      previousRole.RoleId,
+		})
+		if cErr != nil {
+			log.Error().Str("organizationID", request.OrganizationId).Str("email", request.ToEmail).
+				Msg("cannot restore the role of the new owner after the demotion failed")
+			m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditOwnershipTransferFailed, request.ToEmail,
+				"role %s kept after the demotion of %s failed, previous role %s", ownerRole.RoleId, request.FromEmail, previousRole.RoleId))
+		}
+		return nil, err
+	}
+
+	newRoles := map[string]string{request.FromEmail: demoteRole.RoleId, request.ToEmail: ownerRole.RoleId}
+	for email, roleID := range newRoles {
+		active, gErr := m.roleGrants.Get(request.OrganizationId, email)
+		if gErr == nil {
+			m.replaceRoleGrant(active, roleID)
+		}
+	}
+	m.audit(entities.NewAuditEntry(request.OrganizationId, entities.AuditOwnershipTransferred, request.ToEmail,
+		"role %s transferred from %s, demoted to role %s", ownerRole.RoleId, request.FromEmail, demoteRole.RoleId))
+
+	previousOwner, err := m.GetUser(fromID)
+	if err != nil {
+		return nil, err
+	}
+	newOwner, err := m.GetUser(toID)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc_user_manager_go.TransferOwnershipResponse{
+		OrganizationId: request.OrganizationId,
+		PreviousOwner:  previousOwner,
+		NewOwner:       newOwner,
+	}, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package user
+
+import (
+	"context"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-user-manager-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/utils"
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+	"time"
+)
+
+var _ = ginkgo.Describe("Ownership transfer", func() {
+
+	const organizationID = "org-1"
+	const ownerEmail = "owner@example.com"
+	const memberEmail = "member@example.com"
+
+	var manager Manager
+	var authxClient *utils.FakeAuthxClient
+	var ownerRole *grpc_authx_go.Role
+	var memberRole *grpc_authx_go.Role
+	var developerRole *grpc_authx_go.Role
+
+	errorType := func(err error) derrors.ErrorType {
+		return conversions.ToDerror(err).Type()
+	}
+
+	roleOf := func(email string) string {
+		role, err := authxClient.GetUserRole(context.Background(), &grpc_user_go.UserId{OrganizationId: organizationID, Email: email})
+		gomega.Expect(err).To(gomega.Succeed())
+		return role.RoleId
+	}
+
+	transfer := func(from string, to string, roleID string) (*grpc_user_manager_go.TransferOwnershipResponse, error) {
+		return manager.TransferOwnership(&grpc_user_manager_go.TransferOwnershipRequest{
+			OrganizationId: organizationID,
+			FromEmail:      from,
+			ToEmail:        to,
+			DemoteToRoleId: roleID,
+		})
+	}
+
+	ginkgo.BeforeEach(func() {
+		authxClient = utils.NewFakeAuthxClient()
+		manager = NewManager(authxClient, utils.NewFakeUsersClient(), utils.NewFakeRolesClient(),
+			NewMockupProviders(), testSettings())
+		response, err := manager.BootstrapOrganization(&grpc_user_manager_go.BootstrapOrganizationRequest{
+			OrganizationId: organizationID,
+			Email:          ownerEmail,
+			Password:       "password",
+			Name:           "Name",
+			LastName:       "LastName",
+			Title:          "Title",
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		ownerRole = response.Roles[0]
+		memberRole = response.Roles[1]
+		developerRole = response.Roles[2]
+		_, err = manager.AddUser(&grpc_user_manager_go.AddUserRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			Password:       "password",
+			Name:           "Name",
+			RoleId:         memberRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+	})
+
+	ginkgo.It("should transfer the ownership of the last owner", func() {
+		response, err := transfer(ownerEmail, memberEmail, memberRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(response.NewOwner.RoleId).To(gomega.Equal(ownerRole.RoleId))
+		gomega.Expect(response.PreviousOwner.RoleId).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(ownerRole.RoleId))
+		gomega.Expect(roleOf(ownerEmail)).To(gomega.Equal(memberRole.RoleId))
+
+		entries, err := manager.ListAuditEntries(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(entries.AuditEntries[len(entries.AuditEntries)-1].Action).To(gomega.Equal(entities.AuditOwnershipTransferred))
+	})
+
+	ginkgo.It("should reject a transfer from a user that is not an owner", func() {
+		_, err := transfer(memberEmail, ownerEmail, memberRole.RoleId)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+	})
+
+	ginkgo.It("should reject a transfer to a user that is an owner through its groups", func() {
+		admins, err := manager.AddGroup(&grpc_user_manager_go.AddGroupRequest{
+			OrganizationId: organizationID,
+			Name:           "Admins",
+			RoleId:         ownerRole.RoleId,
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = manager.AddGroupMembers(&grpc_user_manager_go.GroupMembersRequest{
+			OrganizationId: organizationID,
+			GroupId:        admins.GroupId,
+			Emails:         []string{memberEmail},
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = transfer(ownerEmail, memberEmail, memberRole.RoleId)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.FailedPrecondition))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(roleOf(ownerEmail)).To(gomega.Equal(ownerRole.RoleId))
+	})
+
+	ginkgo.It("should reject demoting the former owner to an owner role", func() {
+		_, err := transfer(ownerEmail, memberEmail, ownerRole.RoleId)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.InvalidArgument))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(memberRole.RoleId))
+	})
+
+	ginkgo.It("should restore the new owner if the demotion fails", func() {
+		authxClient.FailOn("EditUserRole/"+ownerEmail, conversions.ToGRPCError(derrors.NewUnavailableError("authx")))
+		_, err := transfer(ownerEmail, memberEmail, memberRole.RoleId)
+		gomega.Expect(errorType(err)).To(gomega.Equal(derrors.Unavailable))
+		gomega.Expect(roleOf(memberEmail)).To(gomega.Equal(memberRole.RoleId))
+		gomega.Expect(roleOf(ownerEmail)).To(gomega.Equal(ownerRole.RoleId))
+	})
+
+	ginkgo.It("should cancel the temporary grants of both users", func() {
+		_, err := manager.AssignRole(&grpc_user_manager_go.AssignRoleRequest{
+			OrganizationId: organizationID,
+			Email:          memberEmail,
+			RoleId:         developerRole.RoleId,
+			ExpiresAt:      time.Now().Add(time.Hour).Unix(),
+		})
+		gomega.Expect(err).To(gomega.Succeed())
+		_, err = transfer(ownerEmail, memberEmail, memberRole.RoleId)
+		gomega.Expect(err).To(gomega.Succeed())
+		grants, err := manager.ListRoleGrants(&grpc_organization_go.OrganizationId{OrganizationId: organizationID})
+		gomega.Expect(err).To(gomega.Succeed())
+		gomega.Expect(grants.RoleGrants).To(gomega.BeEmpty())
+	})
+})
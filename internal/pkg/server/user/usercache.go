@@ -26,55 +26,96 @@ import (
 	"github.com/nalej/grpc-user-go"
 	"github.com/nalej/grpc-user-manager-go"
 	"github.com/nalej/grpc-utils/pkg/conversions"
+	"github.com/nalej/user-manager/internal/pkg/entities"
+	"github.com/nalej/user-manager/internal/pkg/provider/group"
+	"github.com/nalej/user-manager/internal/pkg/provider/ownerpolicy"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolebinding"
+	"github.com/nalej/user-manager/internal/pkg/provider/rolehierarchy"
+	"sync"
 )
 
+// ownerGrant with the source of the ORG primitive of an owner: the primary role of the user, one of its additional
+// roles or the role bound to one of its groups.
+type ownerGrant struct {
+	roleID string
+	// groupID with the group binding the role, empty for the roles assigned to the user
+	groupID string
+	// additional is set for the additional roles of the user
+	additional bool
+}
+
 type UsersCache struct {
+	// lock shared by all the copies of the cache
+	lock *sync.Mutex
 	// all owner roles indexed by organization_id
 	ownerRoleIds map[string][]string
-	// all owner users - indexed by organization_id
+	// all owner users - indexed by organization_id. Only system model users are included, service accounts never
+	// count as owners even if they hold an owner role. Users are owners through their role or their groups.
 	ownerUsers map[string][]string
+	// owner roles of each owner user - indexed by organization_id and email
+	ownerGrants map[string]map[string][]ownerGrant
 
 	accessClient grpc_authx_go.AuthxClient
 	usersClient  grpc_user_go.UsersClient
 	roleClient   grpc_role_go.RolesClient
+	// ownerPolicies with the minimum number of owners of each organization
+	ownerPolicies ownerpolicy.Provider
+	// roleHierarchy with the parent roles, so roles inheriting the ORG primitive are owner roles too
+	roleHierarchy rolehierarchy.Provider
+	// groups with the role bindings that make their members owners
+	groups group.Provider
+	// roleBindings with the additional roles that make the users owners
+	roleBindings rolebinding.Provider
 }
 
 func NewUsersCache(accessClient grpc_authx_go.AuthxClient, usersClient grpc_user_go.UsersClient,
-	roleClient grpc_role_go.RolesClient) UsersCache {
+	roleClient grpc_role_go.RolesClient, ownerPolicies ownerpolicy.Provider, roleHierarchy rolehierarchy.Provider,
+	groups group.Provider, roleBindings rolebinding.Provider) UsersCache {
 	rolesIds := make(map[string][]string, 0)
 	users := make(map[string][]string, 0)
-	return UsersCache{accessClient: accessClient,
-		usersClient:  usersClient,
-		roleClient:   roleClient,
-		ownerRoleIds: rolesIds,
-		ownerUsers:   users}
+	return UsersCache{lock: &sync.Mutex{},
+		accessClient:  accessClient,
+		usersClient:   usersClient,
+		roleClient:    roleClient,
+		ownerPolicies: ownerPolicies,
+		roleHierarchy: roleHierarchy,
+		groups:        groups,
+		roleBindings:  roleBindings,
+		ownerRoleIds:  rolesIds,
+		ownerUsers:    users,
+		ownerGrants:   make(map[string]map[string][]ownerGrant, 0)}
 }
 
 func (uc *UsersCache) Clear(organizationID string) derrors.Error {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
 	// clear ownerRoleIds
 	delete(uc.ownerRoleIds, organizationID)
 	// Clear users-roles
 	delete(uc.ownerUsers, organizationID)
+	delete(uc.ownerGrants, organizationID)
 	return nil
 }
 
 // check if thr removeUser operaton can be done
 // 1.- If the user Role is not ORG -> the operation can be done
 // 2.- If the user Role is ORG:
-// 2.1.- If there are more ORG users -> the operation can be done
-// 2.2.- If there are not more ORG users -> the operation cannot be done
+// 2.1.- If the remaining ORG users reach the minimum of the organization -> the operation can be done
+// 2.2.- Otherwise -> the operation cannot be done
 func (uc *UsersCache) CanRemoveUser(userID *grpc_user_go.UserId) (bool, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
 
 	isOwner, err := uc.userIsOwner(userID.OrganizationId, userID.Email)
 	if err != nil {
 		return false, err
 	}
 	if isOwner {
-		hasMoreOwner, err := uc.hasMoreOwner(userID.OrganizationId, userID.Email)
+		hasQuorum, err := uc.hasOwnerQuorum(userID.OrganizationId, userID.Email)
 		if err != nil {
 			return false, err
 		}
-		if !hasMoreOwner {
+		if !hasQuorum {
 			return false, nil
 		}
 	}
@@ -83,13 +124,25 @@ func (uc *UsersCache) CanRemoveUser(userID *grpc_user_go.UserId) (bool, derrors.
 }
 
 // check if the assignRole operation can be done.
+// If the operation adds a role -> nothing to check
+// If the operation removes an additional role:
+// If the role is not ORG -> nothing to check
+// If the remaining ORG users, including the user if it is an owner through its other roles or groups, reach the minimum of the organization -> pass the validation
+// Otherwise -> not pass the validation
+// If the operation replaces the primary role:
 // If new Role is ORG -> nothing to check
 // If new Role is not ORG:
 // If old Role was no ORG -> noting to check
 // It old Role was ORG:
-// If there are more ORG users -> pass the validation
-// It there aren't more ORG users -> not pass the validation
+// If the remaining ORG users, including the user if it is an owner through its groups, reach the minimum of the organization -> pass the validation
+// Otherwise -> not pass the validation
 func (uc *UsersCache) CanAssignRole(assignRoleRequest *grpc_user_manager_go.AssignRoleRequest) (bool, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+
+	if assignRoleRequest.Operation == grpc_user_manager_go.AssignRoleOperation_ADD {
+		return true, nil
+	}
 
 	// 1.- If newRole != ORG and oldRole == ORG -> check if the change can be made
 	isOwner, err := uc.roleIsOwner(assignRoleRequest.OrganizationId, assignRoleRequest.RoleId)
@@ -97,17 +150,30 @@ func (uc *UsersCache) CanAssignRole(assignRoleRequest *grpc_user_manager_go.Assi
 		return false, err
 	}
 
+	if assignRoleRequest.Operation == grpc_user_manager_go.AssignRoleOperation_REMOVE {
+		if !isOwner {
+			return true, nil
+		}
+		// the user keeps the ORG primitive of its primary role, its other roles and its groups
+		return uc.hasOwnerQuorumWithout(assignRoleRequest.OrganizationId, func(email string, grant ownerGrant) bool {
+			return email == assignRoleRequest.Email && grant.additional && grant.roleID == assignRoleRequest.RoleId
+		})
+	}
+
 	if !isOwner {
 		wasOwnerBefore, err := uc.userIsOwner(assignRoleRequest.OrganizationId, assignRoleRequest.Email)
 		if err != nil {
 			return false, err
 		}
 		if wasOwnerBefore {
-			hasMoreOwner, err := uc.hasMoreOwner(assignRoleRequest.OrganizationId, assignRoleRequest.Email)
+			// the user keeps the ORG primitive of its additional roles and its groups
+			hasQuorum, err := uc.hasOwnerQuorumWithout(assignRoleRequest.OrganizationId, func(email string, grant ownerGrant) bool {
+				return email == assignRoleRequest.Email && grant.groupID == "" && !grant.additional
+			})
 			if err != nil {
 				return false, err
 			}
-			if !hasMoreOwner {
+			if !hasQuorum {
 				return false, nil
 			}
 		}
@@ -117,6 +183,88 @@ func (uc *UsersCache) CanAssignRole(assignRoleRequest *grpc_user_manager_go.Assi
 
 }
 
+// CanRevokeOwnerRole checks if a set of roles can stop granting the ORG primitive, either because the primitive is
+// removed from the roles or their ancestors or because the roles themselves are removed.
+// 1.- If none of the roles is ORG -> the operation can be done
+// 2.- If the ORG users with another ORG role reach the minimum of the organization -> the operation can be done
+// 3.- Otherwise -> the operation cannot be done
+func (uc *UsersCache) CanRevokeOwnerRole(organizationID string, roleIDs ...string) (bool, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+
+	revoked := make(map[string]bool, len(roleIDs))
+	for _, roleID := range roleIDs {
+		isOwner, err := uc.roleIsOwner(organizationID, roleID)
+		if err != nil {
+			return false, err
+		}
+		if isOwner {
+			revoked[roleID] = true
+		}
+	}
+	if len(revoked) == 0 {
+		return true, nil
+	}
+	return uc.hasOwnerQuorumWithout(organizationID, func(email string, grant ownerGrant) bool {
+		return revoked[grant.roleID]
+	})
+}
+
+// CanRevokeGroupOwnership checks if a group can stop granting the ORG primitive to some of its members, or to all of
+// them if no email is given, either because they leave the group, the group is removed or its role binding changes.
+// 1.- If the group does not make any of the users an owner -> the operation can be done
+// 2.- If the remaining ORG users reach the minimum of the organization -> the operation can be done
+// 3.- Otherwise -> the operation cannot be done
+func (uc *UsersCache) CanRevokeGroupOwnership(organizationID string, groupID string, emails ...string) (bool, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+
+	if _, exists := uc.ownerUsers[organizationID]; !exists {
+		err := uc.add(organizationID)
+		if err != nil {
+			return false, err
+		}
+	}
+	members := make(map[string]bool, len(emails))
+	for _, email := range emails {
+		members[email] = true
+	}
+	revoked := func(email string, grant ownerGrant) bool {
+		return grant.groupID == groupID && (len(members) == 0 || members[email])
+	}
+	affected := false
+	for email, grants := range uc.ownerGrants[organizationID] {
+		for _, grant := range grants {
+			affected = affected || revoked(email, grant)
+		}
+	}
+	if !affected {
+		return true, nil
+	}
+	return uc.hasOwnerQuorumWithout(organizationID, revoked)
+}
+
+// CanTransferOwnership checks if the primary role of an owner can be given to another user of the organization while
+// the former owner is demoted.
+// 1.- If the owners, without the primary role of the former one and with the new owner, reach the minimum of the organization -> the operation can be done
+// 2.- Otherwise -> the operation cannot be done
+func (uc *UsersCache) CanTransferOwnership(organizationID string, fromEmail string, toEmail string) (bool, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+
+	// the former owner keeps the ORG primitive of its additional roles and its groups
+	return uc.hasOwnerQuorumWithout(organizationID, func(email string, grant ownerGrant) bool {
+		return email == fromEmail && grant.groupID == "" && !grant.additional
+	}, toEmail)
+}
+
+// MinOwners retrieves the minimum number of owners of an organization.
+func (uc *UsersCache) MinOwners(organizationID string) (int, derrors.Error) {
+	uc.lock.Lock()
+	defer uc.lock.Unlock()
+	return uc.minOwners(organizationID)
+}
+
 // Add load into the cache the users and roles in the organizationID
 func (uc *UsersCache) add(organizationID string) derrors.Error {
 
@@ -129,11 +277,17 @@ func (uc *UsersCache) add(organizationID string) derrors.Error {
 	if err != nil {
 		return conversions.ToDerror(err)
 	}
+	effective, hErr := effectiveRoles(uc.roleHierarchy, organizationID, roles.Roles)
+	if hErr != nil {
+		return hErr
+	}
 	roleIds := make([]string, 0)
-	for _, rol := range roles.Roles {
+	ownerRoles := make(map[string]bool, 0)
+	for _, rol := range effective {
 		for _, primitive := range rol.Primitives {
 			if primitive == grpc_authx_go.AccessPrimitive_ORG {
 				roleIds = append(roleIds, rol.RoleId)
+				ownerRoles[rol.RoleId] = true
 			}
 		}
 	}
@@ -151,8 +305,10 @@ func (uc *UsersCache) add(organizationID string) derrors.Error {
 		return conversions.ToDerror(err)
 	}
 
-	userEmails := make([]string, 0)
+	members := make(map[string]bool, len(organizationUsers.Users))
+	userGrants := make(map[string][]ownerGrant, 0)
 	for _, user := range organizationUsers.Users {
+		members[user.Email] = true
 		credentials, err := uc.accessClient.GetUserRole(context.Background(), &grpc_user_go.UserId{
 			OrganizationId: user.OrganizationId,
 			Email:          user.Email,
@@ -160,16 +316,51 @@ func (uc *UsersCache) add(organizationID string) derrors.Error {
 		if err != nil {
 			return conversions.ToDerror(err)
 		}
-		isOwner, err := uc.roleIsOwner(credentials.OrganizationId, credentials.RoleId)
-		if err != nil {
-			return conversions.ToDerror(err)
+		if ownerRoles[credentials.RoleId] {
+			userGrants[user.Email] = append(userGrants[user.Email], ownerGrant{roleID: credentials.RoleId})
 		}
-		if isOwner {
-			userEmails = append(userEmails, user.Email)
+	}
+	// ---------------
+	// additionalRoles
+	// ---------------
+	bindings, bErr := uc.roleBindings.List(organizationID)
+	if bErr != nil {
+		return bErr
+	}
+	for _, binding := range bindings {
+		if !members[binding.Email] {
+			continue
+		}
+		for _, roleID := range binding.RoleIds {
+			if ownerRoles[roleID] {
+				userGrants[binding.Email] = append(userGrants[binding.Email], ownerGrant{roleID: roleID, additional: true})
+			}
 		}
 	}
-	if len(userEmails) > 0 {
+	// ----------
+	// groupRoles
+	// ----------
+	groups, gErr := uc.groups.ListGroups(organizationID)
+	if gErr != nil {
+		return gErr
+	}
+	for _, group := range groups {
+		if !ownerRoles[group.RoleId] {
+			continue
+		}
+		for _, email := range group.Members {
+			if members[email] {
+				userGrants[email] = append(userGrants[email], ownerGrant{roleID: group.RoleId, groupID: group.GroupId})
+			}
+		}
+	}
+	if len(userGrants) > 0 {
+		userEmails := make([]string, 0, len(userGrants))
+		for email := range userGrants {
+			userEmails = append(userEmails, email)
+		}
 		uc.ownerUsers[organizationID] = userEmails
+		uc.ownerGrants[organizationID] = userGrants
 	}
 
 	return nil
@@ -225,26 +416,59 @@ func (uc *UsersCache) roleIsOwner(organizationID string, roleID string) (bool, d
 	return false, nil
 }
 
-// HasMoreOwner checks if exists another user with an owner role
-func (uc *UsersCache) hasMoreOwner(organizationID string, email string) (bool, derrors.Error) {
+// hasOwnerQuorum checks if the owners other than the given user reach the minimum of the organization
+func (uc *UsersCache) hasOwnerQuorum(organizationID string, email string) (bool, derrors.Error) {
+	return uc.hasOwnerQuorumWithout(organizationID, func(owner string, grant ownerGrant) bool {
+		return owner == email
+	})
+}
 
-	users, exists := uc.ownerUsers[organizationID]
+// hasOwnerQuorumWithout checks if the owners that keep any of their grants once the revoked ones are discarded,
+// together with the gained users that are not owners yet, reach the minimum of the organization
+func (uc *UsersCache) hasOwnerQuorumWithout(organizationID string, revoked func(email string, grant ownerGrant) bool, gained ...string) (bool, derrors.Error) {
+
+	_, exists := uc.ownerUsers[organizationID]
 	if !exists {
 		err := uc.add(organizationID)
 		if err != nil {
 			return false, err
 		}
-		users, exists = uc.ownerUsers[organizationID]
+		_, exists = uc.ownerUsers[organizationID]
 		if !exists {
-			return false, derrors.NewInvalidArgumentError(fmt.Sprintf("cannot chech if there is more %d users in the system. No users found ", grpc_authx_go.AccessPrimitive_ORG))
+			return false, derrors.NewInvalidArgumentError(fmt.Sprintf("cannot check the number of %s users in the system. No users found", grpc_authx_go.AccessPrimitive_ORG))
 		}
 	}
 
-	for _, user := range users {
-		if user != email {
-			return true, nil
+	minOwners, err := uc.minOwners(organizationID)
+	if err != nil {
+		return false, err
+	}
+	remaining := 0
+	for email, grants := range uc.ownerGrants[organizationID] {
+		for _, grant := range grants {
+			if !revoked(email, grant) {
+				remaining++
+				break
+			}
+		}
+	}
+	for _, email := range gained {
+		if _, isOwner := uc.ownerGrants[organizationID][email]; !isOwner {
+			remaining++
 		}
 	}
 
-	return false, nil
+	return remaining >= minOwners, nil
+}
+
+// minOwners retrieves the minimum number of owners of an organization, the default one if it has no policy
+func (uc *UsersCache) minOwners(organizationID string) (int, derrors.Error) {
+	policy, err := uc.ownerPolicies.Get(organizationID)
+	if err != nil {
+		if err.Type() == derrors.NotFound {
+			return entities.DefaultMinOwners, nil
+		}
+		return 0, err
+	}
+	return policy.MinOwners, nil
 }
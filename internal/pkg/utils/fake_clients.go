@@ -0,0 +1,475 @@
+/*
+ * Copyright 2020 Nalej
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// In-memory fakes of the system model and authx clients. They embed the client interface so only the methods
+// used by the user manager are implemented; calling any other method panics.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/nalej/derrors"
+	"github.com/nalej/grpc-authx-go"
+	"github.com/nalej/grpc-common-go"
+	"github.com/nalej/grpc-organization-go"
+	"github.com/nalej/grpc-role-go"
+	"github.com/nalej/grpc-user-go"
+	"github.com/nalej/grpc-utils/pkg/conversions"
+	"google.golang.org/grpc"
+	"sync"
+	"time"
+)
+
+// FakeUsersClient is an in-memory implementation of the system model users client.
+type FakeUsersClient struct {
+	grpc_user_go.UsersClient
+	sync.Mutex
+	// users indexed by organization_id and email.
+	users map[string]map[string]*grpc_user_go.User
+	// failures with the errors returned by the methods configured to fail, indexed by method name.
+	failures map[string]error
+}
+
+// NewFakeUsersClient creates an empty users client.
+func NewFakeUsersClient() *FakeUsersClient {
+	return &FakeUsersClient{
+		users:    make(map[string]map[string]*grpc_user_go.User, 0),
+		failures: make(map[string]error, 0),
+	}
+}
+
+// FailOn makes a method return an error until it is reset with a nil error, so tests can check the recovery of
+// partial failures. RemoveUser can also be made to fail for a single user with the method name RemoveUser/email.
+func (f *FakeUsersClient) FailOn(method string, err error) {
+	f.Lock()
+	defer f.Unlock()
+	if err == nil {
+		delete(f.failures, method)
+		return
+	}
+	f.failures[method] = err
+}
+
+func (f *FakeUsersClient) AddUser(ctx context.Context, in *grpc_user_go.AddUserRequest, opts ...grpc.CallOption) (*grpc_user_go.User, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["AddUser"]; exists {
+		return nil, err
+	}
+	users, exists := f.users[in.OrganizationId]
+	if !exists {
+		users = make(map[string]*grpc_user_go.User, 0)
+		f.users[in.OrganizationId] = users
+	}
+	if _, exists := users[in.Email]; exists {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("user").WithParams(in.OrganizationId, in.Email))
+	}
+	user := &grpc_user_go.User{
+		OrganizationId: in.OrganizationId,
+		Email:          in.Email,
+		Name:           in.Name,
+		PhotoBase64:    in.PhotoBase64,
+		MemberSince:    time.Now().Unix(),
+		LastName:       in.LastName,
+		Title:          in.Title,
+		Phone:          in.Phone,
+		Location:       in.Location,
+	}
+	users[in.Email] = user
+	return user, nil
+}
+
+func (f *FakeUsersClient) GetUser(ctx context.Context, in *grpc_user_go.UserId, opts ...grpc.CallOption) (*grpc_user_go.User, error) {
+	f.Lock()
+	defer f.Unlock()
+	user, exists := f.users[in.OrganizationId][in.Email]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("user").WithParams(in.OrganizationId, in.Email))
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (f *FakeUsersClient) GetUsers(ctx context.Context, in *grpc_organization_go.OrganizationId, opts ...grpc.CallOption) (*grpc_user_go.UserList, error) {
+	f.Lock()
+	defer f.Unlock()
+	result := make([]*grpc_user_go.User, 0)
+	for _, user := range f.users[in.OrganizationId] {
+		copied := *user
+		result = append(result, &copied)
+	}
+	return &grpc_user_go.UserList{Users: result}, nil
+}
+
+func (f *FakeUsersClient) RemoveUser(ctx context.Context, in *grpc_user_go.RemoveUserRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["RemoveUser"]; exists {
+		return nil, err
+	}
+	if err, exists := f.failures["RemoveUser/"+in.Email]; exists {
+		return nil, err
+	}
+	if _, exists := f.users[in.OrganizationId][in.Email]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("user").WithParams(in.OrganizationId, in.Email))
+	}
+	delete(f.users[in.OrganizationId], in.Email)
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeUsersClient) Update(ctx context.Context, in *grpc_user_go.UpdateUserRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	user, exists := f.users[in.OrganizationId][in.Email]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("user").WithParams(in.OrganizationId, in.Email))
+	}
+	if in.UpdateName {
+		user.Name = in.Name
+	}
+	if in.UpdatePhotoBase64 {
+		user.PhotoBase64 = in.PhotoBase64
+	}
+	if in.UpdateLastName {
+		user.LastName = in.LastName
+	}
+	if in.UpdateTitle {
+		user.Title = in.Title
+	}
+	if in.UpdatePhone {
+		user.Phone = in.Phone
+	}
+	if in.UpdateLocation {
+		user.Location = in.Location
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// FakeRolesClient is an in-memory implementation of the system model roles client.
+type FakeRolesClient struct {
+	grpc_role_go.RolesClient
+	sync.Mutex
+	// roles indexed by organization_id and role_id.
+	roles map[string]map[string]*grpc_role_go.Role
+	// nextID used to generate role identifiers.
+	nextID int
+}
+
+// NewFakeRolesClient creates an empty roles client.
+func NewFakeRolesClient() *FakeRolesClient {
+	return &FakeRolesClient{roles: make(map[string]map[string]*grpc_role_go.Role, 0)}
+}
+
+func (f *FakeRolesClient) AddRole(ctx context.Context, in *grpc_role_go.AddRoleRequest, opts ...grpc.CallOption) (*grpc_role_go.Role, error) {
+	f.Lock()
+	defer f.Unlock()
+	roles, exists := f.roles[in.OrganizationId]
+	if !exists {
+		roles = make(map[string]*grpc_role_go.Role, 0)
+		f.roles[in.OrganizationId] = roles
+	}
+	f.nextID++
+	role := &grpc_role_go.Role{
+		OrganizationId: in.OrganizationId,
+		RoleId:         fmt.Sprintf("role-%d", f.nextID),
+		Name:           in.Name,
+		Description:    in.Description,
+		Internal:       in.Internal,
+		Created:        time.Now().Unix(),
+	}
+	roles[role.RoleId] = role
+	copied := *role
+	return &copied, nil
+}
+
+func (f *FakeRolesClient) GetRole(ctx context.Context, in *grpc_role_go.RoleId, opts ...grpc.CallOption) (*grpc_role_go.Role, error) {
+	f.Lock()
+	defer f.Unlock()
+	role, exists := f.roles[in.OrganizationId][in.RoleId]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	copied := *role
+	return &copied, nil
+}
+
+func (f *FakeRolesClient) ListRoles(ctx context.Context, in *grpc_organization_go.OrganizationId, opts ...grpc.CallOption) (*grpc_role_go.RoleList, error) {
+	f.Lock()
+	defer f.Unlock()
+	result := make([]*grpc_role_go.Role, 0)
+	for _, role := range f.roles[in.OrganizationId] {
+		copied := *role
+		result = append(result, &copied)
+	}
+	return &grpc_role_go.RoleList{Roles: result}, nil
+}
+
+func (f *FakeRolesClient) RemoveRole(ctx context.Context, in *grpc_role_go.RemoveRoleRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if _, exists := f.roles[in.OrganizationId][in.RoleId]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	delete(f.roles[in.OrganizationId], in.RoleId)
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeRolesClient) UpdateRole(ctx context.Context, in *grpc_role_go.UpdateRoleRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	role, exists := f.roles[in.OrganizationId][in.RoleId]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	if in.UpdateName {
+		role.Name = in.Name
+	}
+	if in.UpdateDescription {
+		role.Description = in.Description
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+// fakeCredentials with the information stored by the fake authx for each user.
+type fakeCredentials struct {
+	organizationID string
+	password       string
+	roleID         string
+}
+
+// FakeAuthxClient is an in-memory implementation of the authx client.
+type FakeAuthxClient struct {
+	grpc_authx_go.AuthxClient
+	sync.Mutex
+	// roles indexed by organization_id and role_id.
+	roles map[string]map[string]*grpc_authx_go.Role
+	// credentials indexed by organization_id and username.
+	credentials map[string]map[string]*fakeCredentials
+	// failures with the errors returned by the methods configured to fail, indexed by method name.
+	failures map[string]error
+}
+
+// NewFakeAuthxClient creates an empty authx client.
+func NewFakeAuthxClient() *FakeAuthxClient {
+	return &FakeAuthxClient{
+		roles:       make(map[string]map[string]*grpc_authx_go.Role, 0),
+		credentials: make(map[string]map[string]*fakeCredentials, 0),
+		failures:    make(map[string]error, 0),
+	}
+}
+
+// FailOn makes a method return an error until it is reset with a nil error, so tests can check the recovery of
+// partial failures. EditUserRole can also be made to fail for a single user with the method name EditUserRole/username,
+// and AddRole for a single role with AddRole/name.
+func (f *FakeAuthxClient) FailOn(method string, err error) {
+	f.Lock()
+	defer f.Unlock()
+	if err == nil {
+		delete(f.failures, method)
+		return
+	}
+	f.failures[method] = err
+}
+
+// Password retrieves the password of a user so tests can check it.
+func (f *FakeAuthxClient) Password(organizationID string, username string) (string, bool) {
+	f.Lock()
+	defer f.Unlock()
+	credentials, exists := f.credentials[organizationID][username]
+	if !exists {
+		return "", false
+	}
+	return credentials.password, true
+}
+
+func (f *FakeAuthxClient) AddRole(ctx context.Context, in *grpc_authx_go.Role, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["AddRole"]; exists {
+		return nil, err
+	}
+	if err, exists := f.failures["AddRole/"+in.Name]; exists {
+		return nil, err
+	}
+	roles, exists := f.roles[in.OrganizationId]
+	if !exists {
+		roles = make(map[string]*grpc_authx_go.Role, 0)
+		f.roles[in.OrganizationId] = roles
+	}
+	copied := *in
+	copied.Primitives = append([]grpc_authx_go.AccessPrimitive{}, in.Primitives...)
+	roles[in.RoleId] = &copied
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) UpdateRole(ctx context.Context, in *grpc_authx_go.Role, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["UpdateRole"]; exists {
+		return nil, err
+	}
+	if err, exists := f.failures["UpdateRole/"+in.Name]; exists {
+		return nil, err
+	}
+	role, exists := f.roles[in.OrganizationId][in.RoleId]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	role.Name = in.Name
+	role.Primitives = append([]grpc_authx_go.AccessPrimitive{}, in.Primitives...)
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) RemoveRole(ctx context.Context, in *grpc_authx_go.RoleId, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["RemoveRole"]; exists {
+		return nil, err
+	}
+	if _, exists := f.roles[in.OrganizationId][in.RoleId]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	delete(f.roles[in.OrganizationId], in.RoleId)
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) ListRoles(ctx context.Context, in *grpc_organization_go.OrganizationId, opts ...grpc.CallOption) (*grpc_authx_go.RoleList, error) {
+	f.Lock()
+	defer f.Unlock()
+	result := make([]*grpc_authx_go.Role, 0)
+	for _, role := range f.roles[in.OrganizationId] {
+		copied := *role
+		result = append(result, &copied)
+	}
+	return &grpc_authx_go.RoleList{Roles: result}, nil
+}
+
+func (f *FakeAuthxClient) AddBasicCredentials(ctx context.Context, in *grpc_authx_go.AddBasicCredentialRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["AddBasicCredentials"]; exists {
+		return nil, err
+	}
+	if _, exists := f.credentials[in.OrganizationId][in.Username]; exists {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("credentials").WithParams(in.OrganizationId, in.Username))
+	}
+	if _, exists := f.roles[in.OrganizationId][in.RoleId]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, in.RoleId))
+	}
+	organization, exists := f.credentials[in.OrganizationId]
+	if !exists {
+		organization = make(map[string]*fakeCredentials, 0)
+		f.credentials[in.OrganizationId] = organization
+	}
+	organization[in.Username] = &fakeCredentials{
+		organizationID: in.OrganizationId,
+		password:       in.Password,
+		roleID:         in.RoleId,
+	}
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) DeleteCredentials(ctx context.Context, in *grpc_authx_go.DeleteCredentialsRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["DeleteCredentials"]; exists {
+		return nil, err
+	}
+	if _, exists := f.credentials[in.OrganizationId][in.Username]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("credentials").WithParams(in.OrganizationId, in.Username))
+	}
+	delete(f.credentials[in.OrganizationId], in.Username)
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) EditUserRole(ctx context.Context, in *grpc_authx_go.EditUserRoleRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["EditUserRole"]; exists {
+		return nil, err
+	}
+	if err, exists := f.failures["EditUserRole/"+in.Username]; exists {
+		return nil, err
+	}
+	credentials, exists := f.credentials[in.OrganizationId][in.Username]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("credentials").WithParams(in.OrganizationId, in.Username))
+	}
+	if _, exists := f.roles[credentials.organizationID][in.NewRoleId]; !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.NewRoleId))
+	}
+	credentials.roleID = in.NewRoleId
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) ChangePassword(ctx context.Context, in *grpc_authx_go.ChangePasswordRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	credentials, exists := f.credentials[in.OrganizationId][in.Username]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("credentials").WithParams(in.OrganizationId, in.Username))
+	}
+	credentials.password = in.NewPassword
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) ChangeUsername(ctx context.Context, in *grpc_authx_go.ChangeUsernameRequest, opts ...grpc.CallOption) (*grpc_common_go.Success, error) {
+	f.Lock()
+	defer f.Unlock()
+	if err, exists := f.failures["ChangeUsername"]; exists {
+		return nil, err
+	}
+	credentials, exists := f.credentials[in.OrganizationId][in.Username]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("credentials").WithParams(in.OrganizationId, in.Username))
+	}
+	if _, exists := f.credentials[in.OrganizationId][in.NewUsername]; exists {
+		return nil, conversions.ToGRPCError(derrors.NewAlreadyExistsError("credentials").WithParams(in.OrganizationId, in.NewUsername))
+	}
+	delete(f.credentials[in.OrganizationId], in.Username)
+	f.credentials[in.OrganizationId][in.NewUsername] = credentials
+	return &grpc_common_go.Success{}, nil
+}
+
+func (f *FakeAuthxClient) GetUserRole(ctx context.Context, in *grpc_user_go.UserId, opts ...grpc.CallOption) (*grpc_authx_go.Role, error) {
+	f.Lock()
+	defer f.Unlock()
+	credentials, exists := f.credentials[in.OrganizationId][in.Email]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("credentials").WithParams(in.OrganizationId, in.Email))
+	}
+	role, exists := f.roles[in.OrganizationId][credentials.roleID]
+	if !exists {
+		return nil, conversions.ToGRPCError(derrors.NewNotFoundError("role").WithParams(in.OrganizationId, credentials.roleID))
+	}
+	copied := *role
+	return &copied, nil
+}
+
+func (f *FakeAuthxClient) GetUserAuthxInfo(ctx context.Context, in *grpc_user_go.UserId, opts ...grpc.CallOption) (*grpc_authx_go.UserAuthxInfo, error) {
+	role, err := f.GetUserRole(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc_authx_go.UserAuthxInfo{
+		OrganizationId: in.OrganizationId,
+		Email:          in.Email,
+		RoleId:         role.RoleId,
+		RoleName:       role.Name,
+		InternalRole:   role.Internal,
+	}, nil
+}